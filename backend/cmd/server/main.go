@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -10,9 +11,14 @@ import (
 
 	"cert-manager-backend/internal/api"
 	"cert-manager-backend/internal/config"
+	"cert-manager-backend/internal/ct"
 	"cert-manager-backend/internal/db"
+	"cert-manager-backend/internal/domains"
+	"cert-manager-backend/internal/egress"
 	"cert-manager-backend/internal/notify"
+	"cert-manager-backend/internal/revocation"
 	"cert-manager-backend/internal/scanner"
+	"cert-manager-backend/internal/secrets"
 	"cert-manager-backend/internal/service"
 	"cert-manager-backend/internal/worker"
 
@@ -59,35 +65,110 @@ func main() {
 
 	// A. Core Services
 	// AgentService: Handles Agent Lifecycle (List, Delete, Cleanup)
-	agentSvc := service.NewAgentService(store.Conn, cfg.AgentOfflineMinutes)
+	agentSvc := service.NewAgentService(store.Conn, cfg.AgentOfflineMinutes, cfg.AgentEnrollmentTTL)
 
 	// CertificateService: Handles Ingestion (ProcessReport), Cleanup, Listing, Stats
 	// (Previously split between IngestService and CertService)
-	certSvc := service.NewCertificateService(store.Conn, cfg.AgentOfflineMinutes)
+	ctPolicy, err := ct.ParsePolicy(cfg.CTPolicy)
+	if err != nil {
+		log.Fatalf("Invalid CT_POLICY: %v", err)
+	}
+	certSvc := service.NewCertificateService(store.Conn, cfg.AgentOfflineMinutes, ctPolicy)
 
 	// HistoryService: Needed for Alerter logs
 	historySvc := service.NewHistoryService(store.Conn)
 
 	// B. Cloud Monitoring Components
 	tlsScanner := scanner.NewTLSScanner(cfg.CloudScannerTimeout)
+
+	// Egress: Scans (and their OCSP lookups) go direct or through the configured proxy
+	namedProxies, err := scanner.ParseNamedProxies(cfg.CloudScannerProxies)
+	if err != nil {
+		log.Fatalf("Invalid CLOUD_SCANNER_PROXIES: %v", err)
+	}
+	tlsScanner.Dialers, err = scanner.NewDialerSet(cfg.CloudScannerProxy, namedProxies, cfg.CloudScannerProxyMandatory, cfg.CloudScannerTimeout)
+	if err != nil {
+		log.Fatalf("Invalid scanner proxy configuration: %v", err)
+	}
+
+	// SSRF Guard: Destinations are resolved & checked before every dial (scans, OCSP, CRL)
+	egressPolicy, err := egress.NewPolicy(cfg.EgressDefaultDeny,
+		egress.ParseList(cfg.EgressAllowCIDRs), egress.ParseList(cfg.EgressDenyCIDRs))
+	if err != nil {
+		log.Fatalf("Invalid egress policy: %v", err)
+	}
+	egressPolicy.AllowProxyResolved(egress.ParseList(cfg.EgressProxyResolvedNames))
+	tlsScanner.Dialers.Guard(egressPolicy, net.DefaultResolver)
+	if !cfg.EgressDefaultDeny {
+		log.Println("⚠️ EGRESS_DEFAULT_DENY disabled: scans may reach private and metadata addresses")
+	}
+
+	guardedDefault, _ := tlsScanner.Dialers.For("") // The default route never fails
+	tlsScanner.OCSP.Client.Transport = &http.Transport{DialContext: guardedDefault.DialContext}
+	if cfg.CloudScannerProxy != "" {
+		log.Printf("🌐 Cloud Scanner: Routing scans through proxy (%d named proxies)", len(namedProxies))
+	}
+
+	// Secrets Box: Encrypts mTLS client keys at rest
+	secretsKey := cfg.SecretsKey
+	if secretsKey == "" {
+		log.Println("⚠️ SECRETS_ENCRYPTION_KEY not set, deriving the secrets key from JWT_SECRET")
+		secretsKey = cfg.JWTSecret
+	}
+	secretsBox, err := secrets.NewBox(secretsKey)
+	if err != nil {
+		log.Fatalf("Failed to init secrets encryption: %v", err)
+	}
+
 	// CloudService: Orchestrates the "Scan -> Save" flow. Consumes certSvc for ingestion.
-	cloudSvc := service.NewAgentLessTargetService(store.Conn, tlsScanner, certSvc, cfg.CloudScannerUserDefaultScanHour)
+	cloudSvc := service.NewAgentLessTargetService(store.Conn, tlsScanner, certSvc, secretsBox, cfg.CloudScannerUserDefaultScanHour,
+		service.FailurePolicy{
+			DegradedAfter: cfg.TargetDegradedAfter,
+			DownAfter:     cfg.TargetDownAfter,
+			RetryAfter:    cfg.TargetFailureRetry,
+		})
+	// TrustStoreService: Private CAs per user (Verified alongside the System Roots)
+	trustSvc := service.NewTrustStoreService(store.Conn)
+	// DiscoveryService: CIDR / Port sweeps, capped per user
+	discoverySvc := service.NewDiscoveryService(store.Conn, certSvc, egressPolicy, cfg.DiscoveryMaxAddresses, cfg.DiscoveryMaxAddressesPerDay, cfg.CloudScannerUserDefaultScanHour)
+
+	// ResponderService: CRL / OCSP endpoint health (same egress guard as every other outbound fetch)
+	responderProber := revocation.NewProber(cfg.ResponderTimeout)
+	responderProber.Client.Transport = &http.Transport{DialContext: guardedDefault.DialContext}
+	responderSvc := service.NewResponderService(store.Conn, responderProber,
+		cfg.ResponderNextUpdateWarning, cfg.AlerterExpiryWindow, cfg.ResponderDefaultFrequency)
+
+	// DomainService: Registration expiry of the domains behind targets & certificates (RDAP, then WHOIS)
+	rdapClient := domains.NewRDAPClient(cfg.DomainLookupTimeout, cfg.DomainRDAPBootstrapURL, cfg.DomainRDAPBaseURL)
+	rdapClient.Client.Transport = &http.Transport{DialContext: guardedDefault.DialContext}
+	whoisClient := domains.NewWHOISClient(cfg.DomainLookupTimeout, cfg.DomainWHOISServer)
+	whoisClient.Dial = guardedDefault.DialContext
+	domainSvc := service.NewDomainService(store.Conn,
+		&domains.FallbackClient{RDAP: rdapClient, WHOIS: whoisClient}, cfg.DomainCheckInterval)
 
 	// C. Auth & Notifications
 	emailNotifier := notify.NewEmailNotifier(cfg.SMTP, cfg.FrontendURL, historySvc)
 	authSvc := service.NewAuthService(store.Conn, cfg.JWTSecret, emailNotifier)
 
+	// APIKeyService: Named, scoped account keys (Ingest for legacy agents, Read for the API)
+	apiKeySvc := service.NewAPIKeyService(store.Conn)
+
 	// =========================================================================
 	// 4. Handler Wiring
 	// =========================================================================
 
 	authHandler := api.NewAuthHandler(authSvc)
+	apiKeyHandler := api.NewAPIKeyHandler(apiKeySvc)
 	agentHandler := api.NewAgentHandler(agentSvc)
 
 	// CertHandler now handles BOTH ingestion (POST) and listing (GET)
 	certHandler := api.NewCertHandler(certSvc)
 
-	cloudHandler := api.NewCloudHandler(cloudSvc)
+	cloudHandler := api.NewCloudHandler(cloudSvc, cfg.CloudScannerProxy != "" && cfg.CloudScannerProxyMandatory)
+	trustHandler := api.NewTrustStoreHandler(trustSvc)
+	discoveryHandler := api.NewDiscoveryHandler(discoverySvc)
+	responderHandler := api.NewResponderHandler(responderSvc)
+	domainHandler := api.NewDomainHandler(domainSvc)
 
 	// =========================================================================
 	// 5.1 Background Workers
@@ -98,9 +179,25 @@ func main() {
 		emailNotifier,
 	}
 
+	responderNotifiers := []service.ResponderNotifier{
+		emailNotifier,
+	}
+
+	domainNotifiers := []service.DomainNotifier{
+		emailNotifier,
+	}
+
+	healthNotifiers := []service.TargetHealthNotifier{
+		emailNotifier,
+	}
+
 	// Conditionally add the Log Notifier
 	if cfg.EnableLogAlerts {
-		activeNotifiers = append([]service.Notifier{notify.NewLogNotifier()}, activeNotifiers...)
+		logNotifier := notify.NewLogNotifier()
+		activeNotifiers = append([]service.Notifier{logNotifier}, activeNotifiers...)
+		responderNotifiers = append([]service.ResponderNotifier{logNotifier}, responderNotifiers...)
+		domainNotifiers = append([]service.DomainNotifier{logNotifier}, domainNotifiers...)
+		healthNotifiers = append([]service.TargetHealthNotifier{logNotifier}, healthNotifiers...)
 		log.Println("✅ Log Alerts Enabled")
 	}
 
@@ -109,8 +206,38 @@ func main() {
 		cloudSvc,   // Source of Targets (GetStaleTargets)
 		tlsScanner, // The Network Tool
 		certSvc,    // The Data Sink (IngestScanResults)
+		authSvc,    // Owner lookup for DOWN / recovery alerts
+		healthNotifiers,
 		cfg.CloudScannerInterval,
 		cfg.CloudScannerConcurrency,
+		cfg.CloudScannerRetries,
+		cfg.CloudScannerRetryBackoff,
+		cfg.CloudScannerPostureInterval,
+	)
+
+	// C. Discovery Worker (CIDR / Port Sweeps)
+	worker.StartDiscoveryWorker(
+		discoverySvc, // Job Queue & Data Sink
+		tlsScanner,
+		cfg.DiscoveryInterval,
+		cfg.DiscoveryConcurrency,
+		cfg.DiscoveryProbeTimeout,
+	)
+
+	// D. Responder Monitor (CRL / OCSP Endpoints)
+	worker.StartResponderMonitor(
+		responderSvc,
+		authSvc, // Owner lookup for alerts
+		responderNotifiers,
+		cfg.ResponderInterval,
+		cfg.ResponderConcurrency,
+	)
+
+	// E. Domain Monitor (Registration Expiry)
+	worker.StartDomainMonitor(
+		domainSvc,
+		cfg.DomainMonitorInterval,
+		cfg.DomainMonitorConcurrency,
 	)
 
 	// ==========================================j
@@ -120,25 +247,30 @@ func main() {
 	// Create the Scheduler
 	c := cron.New()
 
-	// C. Schedule Janitor
+	// F. Schedule Janitor
 	// Uses cfg.JanitorSchedule (default: "0 0 * * *")
 	_, janitorErr := c.AddFunc(cfg.JanitorSchedule, worker.NewJanitorJob(
 		agentSvc,
 		certSvc,
+		cloudSvc,
 		cfg.AgentTTL,
 		cfg.MissingCertTTL,
+		cfg.ScanHistoryRetention,
+		cfg.ReplacementHistoryRetention,
 	))
 	if janitorErr != nil {
 		log.Fatalf("❌ Failed to schedule Janitor: %v", janitorErr)
 	}
 	log.Printf("✅ Janitor scheduled: %s", cfg.JanitorSchedule)
 
-	// D. Schedule Alerter
+	// G. Schedule Alerter
 	// Uses cfg.AlerterSchedule (default: "0 9 * * *")
 	_, alerterErr := c.AddFunc(cfg.AlerterSchedule, worker.NewAlerterJob(
 		certSvc,
+		domainSvc, // Domain registrations share the expiry window
 		authSvc,
 		activeNotifiers,
+		domainNotifiers,
 		cfg.AlerterExpiryWindow,
 	))
 	if alerterErr != nil {
@@ -146,6 +278,19 @@ func main() {
 	}
 	log.Printf("✅ Alerter scheduled: %s", cfg.AlerterSchedule)
 
+	// H. Schedule CRL Revocation Check
+	// Uses cfg.CRLCheckSchedule (default: "15 * * * *")
+	crlCache := revocation.NewCRLCache(cfg.RevocationFetchTimeout)
+	crlCache.Client.Transport = &http.Transport{DialContext: guardedDefault.DialContext} // CRL URLs come from scanned certs: same guard
+	_, crlErr := c.AddFunc(cfg.CRLCheckSchedule, worker.NewCRLCheckJob(
+		certSvc,
+		crlCache,
+	))
+	if crlErr != nil {
+		log.Fatalf("❌ Failed to schedule CRL Check: %v", crlErr)
+	}
+	log.Printf("✅ CRL Check scheduled: %s", cfg.CRLCheckSchedule)
+
 	// Start the Scheduler (runs in its own goroutine)
 	c.Start()
 
@@ -180,6 +325,7 @@ func main() {
 	// Ingestion (Physical Agents)
 	// Now mapped to CertHandler because CertService has ProcessReport
 	r.Post("/api/certs", certHandler.HandleIngest)
+	r.Post("/api/agents/enroll", agentHandler.HandleEnroll)
 
 	// Downloads
 	r.Get("/api/agent/install", agentHandler.HandleGetInstallScript)
@@ -189,10 +335,14 @@ func main() {
 
 	// --- Protected Routes ---
 	r.Group(func(r chi.Router) {
-		r.Use(api.MakeAuthMiddleware(cfg.JWTSecret))
+		r.Use(api.MakeAuthMiddleware(cfg.JWTSecret, apiKeySvc))
 
 		// Certificates
 		r.Get("/api/certs", certHandler.HandleListCerts)
+		r.Get("/api/certs/{id}", certHandler.HandleGetCert)
+		r.Get("/api/certs/{id}/pem", certHandler.HandleDownloadPEM)
+		r.Get("/api/certs/{id}/history", certHandler.HandleGetHistory)
+		r.Get("/api/certs/{id}/alerts", certHandler.HandleGetAlerts)
 		r.Delete("/api/certs/{id}", certHandler.HandleDeleteInstance)
 		r.Delete("/api/certs/missing", certHandler.HandlePruneMissing)
 		r.Get("/api/stats", certHandler.HandleGetStats)
@@ -201,7 +351,13 @@ func main() {
 		r.Post("/api/key/regenerate", authHandler.HandleRegenerateKey)
 		r.Get("/api/agents", agentHandler.HandleListAgents)
 		r.Delete("/api/agents/{agentID}", agentHandler.HandleDeleteAgent)
-		r.Post("/api/key/regenerate", authHandler.HandleRegenerateKey)
+		r.Post("/api/agents/{agentID}/revoke", agentHandler.HandleRevokeAgent)
+		r.Post("/api/agents/enrollment-tokens", agentHandler.HandleCreateEnrollmentToken)
+
+		// API Keys
+		r.Post("/api/keys", apiKeyHandler.HandleCreateKey)
+		r.Get("/api/keys", apiKeyHandler.HandleListKeys)
+		r.Delete("/api/keys/{keyID}", apiKeyHandler.HandleRevokeKey)
 
 		// Profile
 		r.Get("/api/profile", authHandler.HandleGetProfile)
@@ -212,6 +368,29 @@ func main() {
 		r.Get("/api/cloud/targets", cloudHandler.HandleListTargets)
 		r.Delete("/api/cloud/targets/{id}", cloudHandler.HandleDeleteTarget)
 		r.Put("/api/cloud/targets/{id}", cloudHandler.HandleUpdateTarget)
+		r.Get("/api/cloud/targets/{id}/posture", cloudHandler.HandleGetPosture)
+		r.Get("/api/cloud/targets/{id}/stats", cloudHandler.HandleGetStats)
+		r.Put("/api/cloud/targets/{id}/client-cert", cloudHandler.HandleSetClientCert)
+		r.Delete("/api/cloud/targets/{id}/client-cert", cloudHandler.HandleRemoveClientCert)
+
+		// 🔐 Trust Store (Private CAs for Cloud Monitoring)
+		r.Post("/api/trust-store", trustHandler.HandleAddRoots)
+		r.Get("/api/trust-store", trustHandler.HandleListRoots)
+		r.Delete("/api/trust-store/{id}", trustHandler.HandleDeleteRoot)
+
+		// 🔭 Discovery (CIDR / Port Sweeps)
+		r.Post("/api/discovery/jobs", discoveryHandler.HandleCreateJob)
+		r.Get("/api/discovery/jobs", discoveryHandler.HandleListJobs)
+		r.Get("/api/discovery/jobs/{id}", discoveryHandler.HandleGetJob)
+		r.Post("/api/discovery/jobs/{id}/cancel", discoveryHandler.HandleCancelJob)
+
+		// 📜 Revocation Endpoints (CRL / OCSP Health)
+		r.Post("/api/responders", responderHandler.HandleCreate)
+		r.Get("/api/responders", responderHandler.HandleList)
+		r.Delete("/api/responders/{id}", responderHandler.HandleDelete)
+
+		// 🌍 Domain Registrations (Expiry via RDAP / WHOIS)
+		r.Get("/api/domains", domainHandler.HandleList)
 	})
 
 	// =========================================================================
@@ -13,4 +13,5 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/robfig/cron/v3 v3.0.1
 	golang.org/x/crypto v0.45.0
+	golang.org/x/net v0.47.0
 )
@@ -2,8 +2,10 @@ package api
 
 import (
 	"cert-manager-backend/internal/assets"
+	"cert-manager-backend/internal/model"
 	"cert-manager-backend/internal/service"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -61,6 +63,67 @@ func (h *AgentHandler) HandleDeleteAgent(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte(`{"status":"deleted"}`))
 }
 
+// HandleRevokeAgent stops accepting reports from one agent (POST /api/agents/{agentID}/revoke)
+func (h *AgentHandler) HandleRevokeAgent(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	agentID := chi.URLParam(r, "agentID")
+	if err := h.Service.RevokeAgent(r.Context(), userID, agentID); err != nil {
+		http.Error(w, "Failed to revoke agent: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"revoked"}`))
+}
+
+// HandleCreateEnrollmentToken issues a one-time token for install.sh (POST /api/agents/enrollment-tokens)
+func (h *AgentHandler) HandleCreateEnrollmentToken(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := h.Service.CreateEnrollmentToken(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to create enrollment token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
+}
+
+// HandleEnroll exchanges an enrollment token for the agent's ID and secret (POST /api/agents/enroll).
+// This endpoint is PUBLIC: the enrollment token is the authentication.
+func (h *AgentHandler) HandleEnroll(w http.ResponseWriter, r *http.Request) {
+	var req model.AgentEnrollRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	cred, err := h.Service.EnrollAgent(r.Context(), req.EnrollmentToken, req.Hostname, GetClientIP(r))
+	if errors.Is(err, service.ErrAgentUnauthorized) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to enroll agent: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cred)
+}
+
 // HandleGetInstallScript serves the dynamic install.sh
 // This endpoint is PUBLIC (no auth required to download the template).
 func (h *AgentHandler) HandleGetInstallScript(w http.ResponseWriter, r *http.Request) {
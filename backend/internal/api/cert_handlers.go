@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -38,7 +39,11 @@ func (h *CertHandler) HandleIngest(w http.ResponseWriter, r *http.Request) {
 
 	clientIP := GetClientIP(r)
 	if err := h.Service.ProcessReport(r.Context(), report, clientIP); err != nil {
-		http.Error(w, "Failed to process report: "+err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrAgentUnauthorized) {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, "Failed to process report: "+err.Error(), status)
 		return
 	}
 	w.WriteHeader(http.StatusAccepted)
@@ -143,7 +148,24 @@ func (h *CertHandler) HandleListCerts(w http.ResponseWriter, r *http.Request) {
 		opts = append(opts, service.WithStatus(status))
 	}
 
-	// 6. Execute
+	// 6. Hostname Mismatch Filter (hostname_mismatch=true/false)
+	if mismatchStr := query.Get("hostname_mismatch"); mismatchStr != "" {
+		if mismatch, err := strconv.ParseBool(mismatchStr); err == nil {
+			opts = append(opts, service.WithHostnameMismatch(&mismatch))
+		}
+	}
+
+	// 7. Weak Crypto Filter (crypto_finding=ANY/WEAK_RSA_KEY/WEAK_SIGNATURE/DEPRECATED_CURVE)
+	if finding := query.Get("crypto_finding"); finding != "" {
+		opts = append(opts, service.WithCryptoFinding(strings.ToUpper(finding)))
+	}
+
+	// 8. SAN Filter (san=api.example.com matches *.example.com too; IPs, emails & URIs match exactly)
+	if san := query.Get("san"); san != "" {
+		opts = append(opts, service.WithSAN(san))
+	}
+
+	// 9. Execute
 	resp, err := h.Service.ListCertificates(r.Context(), userID, opts...)
 	if err != nil {
 		http.Error(w, "Failed to fetch certificates", http.StatusInternalServerError)
@@ -156,6 +178,91 @@ func (h *CertHandler) HandleListCerts(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleGetCert returns a single certificate instance with its served chain
+func (h *CertHandler) HandleGetCert(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	instanceID := chi.URLParam(r, "id")
+	if instanceID == "" {
+		http.Error(w, "ID required", http.StatusBadRequest)
+		return
+	}
+
+	cert, err := h.Service.GetCertificate(r.Context(), userID, instanceID)
+	if err != nil {
+		http.Error(w, "Failed to fetch certificate: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cert)
+}
+
+// GET /api/certs/{id}/pem?chain=true
+// Downloads the certificate (and the intermediates served with it, if chain=true)
+func (h *CertHandler) HandleDownloadPEM(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	instanceID := chi.URLParam(r, "id")
+	withChain, _ := strconv.ParseBool(r.URL.Query().Get("chain"))
+
+	pemBytes, err := h.Service.GetCertificatePEM(r.Context(), userID, instanceID, withChain)
+	if err != nil {
+		http.Error(w, "Failed to fetch certificate: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pem"`, instanceID))
+	w.Write(pemBytes)
+}
+
+// GET /api/certs/{id}/history
+// The certificates previously served at this instance's source (renewals & swaps), newest first
+func (h *CertHandler) HandleGetHistory(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	history, err := h.Service.GetReplacementHistory(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to fetch history: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// GET /api/certs/{id}/alerts
+// The alerts sent for this instance's certificate, newest first, with their resolution (e.g. RENEWED)
+func (h *CertHandler) HandleGetAlerts(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	alerts, err := h.Service.GetAlertHistory(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to fetch alerts: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
 // NEW: HandleDeleteInstance deletes a specific certificate instance
 func (h *CertHandler) HandleDeleteInstance(w http.ResponseWriter, r *http.Request) {
 	userID := GetUserFromContext(r.Context())
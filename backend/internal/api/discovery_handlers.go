@@ -0,0 +1,113 @@
+package api
+
+import (
+	"cert-manager-backend/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DiscoveryHandler manages CIDR / port sweeps
+type DiscoveryHandler struct {
+	Service service.DiscoveryService
+}
+
+// NewDiscoveryHandler is the constructor
+func NewDiscoveryHandler(svc service.DiscoveryService) *DiscoveryHandler {
+	return &DiscoveryHandler{
+		Service: svc,
+	}
+}
+
+// Request DTO
+type CreateDiscoveryJobRequest struct {
+	CIDRs      []string `json:"cidrs"`       // e.g. ["10.0.0.0/24", "192.168.1.10"]
+	Ports      []int    `json:"ports"`       // Optional: Defaults to [443]
+	AddTargets bool     `json:"add_targets"` // Optional: Monitor every endpoint found
+}
+
+// POST /api/discovery/jobs
+func (h *DiscoveryHandler) HandleCreateJob(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// 1. Parse
+	var req CreateDiscoveryJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// 2. Service Call (Validation & Address Cap)
+	job, err := h.Service.CreateJob(r.Context(), userID, req.CIDRs, req.Ports, req.AddTargets)
+	if err != nil {
+		writeServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// 3. Respond (The sweep runs in the background)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GET /api/discovery/jobs
+func (h *DiscoveryHandler) HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobs, err := h.Service.ListJobs(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch discovery jobs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GET /api/discovery/jobs/{id}
+func (h *DiscoveryHandler) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobID := chi.URLParam(r, "id")
+
+	job, err := h.Service.GetJob(r.Context(), userID, jobID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// POST /api/discovery/jobs/{id}/cancel
+func (h *DiscoveryHandler) HandleCancelJob(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobID := chi.URLParam(r, "id")
+
+	if err := h.Service.CancelJob(r.Context(), userID, jobID); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"cancelled"}`))
+}
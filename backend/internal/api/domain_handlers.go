@@ -0,0 +1,38 @@
+package api
+
+import (
+	"cert-manager-backend/internal/service"
+	"encoding/json"
+	"net/http"
+)
+
+// DomainHandler exposes the registration expiry of the domains behind a user's hostnames
+type DomainHandler struct {
+	Service service.DomainService
+}
+
+// NewDomainHandler is the constructor
+func NewDomainHandler(svc service.DomainService) *DomainHandler {
+	return &DomainHandler{
+		Service: svc,
+	}
+}
+
+// GET /api/domains
+// Domains are derived by the Domain Monitor, there is nothing to create or delete here.
+func (h *DomainHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	domains, err := h.Service.ListDomains(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch domains", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domains)
+}
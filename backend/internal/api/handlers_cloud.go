@@ -1,9 +1,14 @@
 package api
 
 import (
+	"cert-manager-backend/internal/egress"
+	"cert-manager-backend/internal/model"
 	"cert-manager-backend/internal/service"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -11,19 +16,36 @@ import (
 // CloudHandler manages the Agentless/Cloud Monitoring endpoints
 type CloudHandler struct {
 	Service service.AgentLessTargetService
+	// ProxyMandatory: scans must go through the egress proxy, "direct" is refused
+	ProxyMandatory bool
 }
 
 // NewCloudHandler is the constructor
-func NewCloudHandler(svc service.AgentLessTargetService) *CloudHandler {
+func NewCloudHandler(svc service.AgentLessTargetService, proxyMandatory bool) *CloudHandler {
 	return &CloudHandler{
-		Service: svc,
+		Service:        svc,
+		ProxyMandatory: proxyMandatory,
 	}
 }
 
 // Request DTO
 type AddTargetRequest struct {
-	URL            string `json:"url"`
-	FrequencyHours int    `json:"frequency_hours"`
+	URL              string `json:"url"`
+	Kind             string `json:"kind"`     // Optional: "TLS" (default), "SAML_METADATA" or "JWKS" (url = document URL)
+	Protocol         string `json:"protocol"` // Optional: "TLS", "SMTP", "POSTGRES"... Defaults by port.
+	FrequencyHours   int    `json:"frequency_hours"`
+	ServerName       string `json:"server_name"`        // Optional: SNI to present (e.g. production hostname)
+	ConnectAddress   string `json:"connect_address"`    // Optional: Host/IP to dial instead of the URL's host
+	Ports            []int  `json:"ports"`              // Optional: Extra ports to scan on the same host
+	ScanAllAddresses bool   `json:"scan_all_addresses"` // Optional: Handshake with every resolved IP
+	ClientCertPEM    string `json:"client_cert_pem"`    // Optional: mTLS client certificate (chain)
+	ClientKeyPEM     string `json:"client_key_pem"`     // Optional: mTLS private key (stored encrypted)
+	Proxy            string `json:"proxy"`              // Optional: "direct" or a configured proxy name
+}
+
+type ClientCertRequest struct {
+	CertPEM string `json:"cert_pem"`
+	KeyPEM  string `json:"key_pem"`
 }
 
 type UpdateTargetRequest struct {
@@ -42,6 +64,10 @@ func (h *CloudHandler) HandleAddTarget(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "URL is required", http.StatusBadRequest)
 		return
 	}
+	if h.ProxyMandatory && strings.TrimSpace(req.Proxy) == model.ProxyDirect {
+		http.Error(w, "Direct scans are disabled: targets must use the egress proxy", http.StatusBadRequest)
+		return
+	}
 
 	userID := GetUserFromContext(r.Context())
 	if userID == "" {
@@ -50,9 +76,21 @@ func (h *CloudHandler) HandleAddTarget(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 3. Service Call
-	target, err := h.Service.AddTarget(r.Context(), userID, req.URL, req.FrequencyHours)
+	target, err := h.Service.AddTarget(r.Context(), userID, model.Target{
+		TargetURL:        req.URL,
+		Kind:             model.TargetKind(req.Kind),
+		Protocol:         model.ScanProtocol(req.Protocol),
+		FrequencyHours:   req.FrequencyHours,
+		ServerName:       req.ServerName,
+		ConnectAddress:   req.ConnectAddress,
+		Ports:            req.Ports,
+		ScanAllAddresses: req.ScanAllAddresses,
+		ClientCertPEM:    req.ClientCertPEM,
+		ClientKeyPEM:     req.ClientKeyPEM,
+		Proxy:            req.Proxy,
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 
@@ -129,3 +167,124 @@ func (h *CloudHandler) HandleDeleteTarget(w http.ResponseWriter, r *http.Request
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GET /api/cloud/targets/{id}/posture
+func (h *CloudHandler) HandleGetPosture(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetID := chi.URLParam(r, "id")
+
+	posture, err := h.Service.GetPosture(r.Context(), userID, targetID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posture)
+}
+
+// maxStatsWindow bounds the stats window (history is pruned by the Janitor anyway)
+const maxStatsWindow = 90 * 24 * time.Hour
+
+// GET /api/cloud/targets/{id}/stats?window=7d
+func (h *CloudHandler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetID := chi.URLParam(r, "id")
+
+	// Window: "24h", "90m" (Go duration) or "7d" (days). Default: 24h
+	window := 24 * time.Hour
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		parsed, err := parseWindow(raw)
+		if err != nil || parsed <= 0 || parsed > maxStatsWindow {
+			http.Error(w, "Invalid window (e.g. 24h, 7d; max 90d)", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	stats, err := h.Service.GetTargetStats(r.Context(), userID, targetID, window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// parseWindow accepts Go durations plus a "d" (days) suffix.
+func parseWindow(raw string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(raw)
+}
+
+// PUT /api/cloud/targets/{id}/client-cert
+func (h *CloudHandler) HandleSetClientCert(w http.ResponseWriter, r *http.Request) {
+	var req ClientCertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetID := chi.URLParam(r, "id")
+
+	if err := h.Service.SetClientCert(r.Context(), userID, targetID, req.CertPEM, req.KeyPEM); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"updated"}`))
+}
+
+// DELETE /api/cloud/targets/{id}/client-cert
+func (h *CloudHandler) HandleRemoveClientCert(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetID := chi.URLParam(r, "id")
+
+	if err := h.Service.RemoveClientCert(r.Context(), userID, targetID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeServiceError answers egress policy rejections with 403 and a machine readable code:
+// {"error": "...", "code": "EGRESS_BLOCKED"}. Other errors are sent as plain text with status.
+func writeServiceError(w http.ResponseWriter, err error, status int) {
+	if egress.IsBlocked(err) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "code": egress.ErrorCode})
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIKeyHandler manages the user's named API keys
+type APIKeyHandler struct {
+	Service service.APIKeyService
+}
+
+// NewAPIKeyHandler is the constructor
+func NewAPIKeyHandler(svc service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{Service: svc}
+}
+
+// POST /api/keys
+// The response is the only time the plaintext key is shown
+func (h *APIKeyHandler) HandleCreateKey(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req model.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.Service.CreateKey(r.Context(), userID, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GET /api/keys
+func (h *APIKeyHandler) HandleListKeys(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.Service.ListKeys(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch api keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// DELETE /api/keys/{keyID}
+func (h *APIKeyHandler) HandleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keyID := chi.URLParam(r, "keyID")
+	if err := h.Service.RevokeKey(r.Context(), userID, keyID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"revoked"}`))
+}
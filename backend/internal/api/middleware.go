@@ -1,6 +1,9 @@
 package api
 
 import (
+	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/service"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,7 +12,8 @@ import (
 )
 
 // MakeAuthMiddleware creates the middleware using the provided secret.
-func MakeAuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// Besides session tokens it accepts API keys of the "read" scope, for GET requests only.
+func MakeAuthMiddleware(jwtSecret string, apiKeys service.APIKeyService) func(http.Handler) http.Handler {
 	secretBytes := []byte(jwtSecret)
 
 	return func(next http.Handler) http.Handler {
@@ -26,6 +30,26 @@ func MakeAuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
+			if service.IsAPIKey(tokenString) {
+				if r.Method != http.MethodGet && r.Method != http.MethodHead {
+					http.Error(w, "API keys are read-only", http.StatusForbidden)
+					return
+				}
+				userID, err := apiKeys.Authenticate(r.Context(), tokenString, model.APIKeyScopeRead, GetClientIP(r))
+				if errors.Is(err, service.ErrInvalidAPIKey) {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				} else if errors.Is(err, service.ErrAPIKeyScope) {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				} else if err != nil {
+					http.Error(w, "Failed to verify API key", http.StatusInternalServerError)
+					return
+				}
+				next.ServeHTTP(w, SetUserInContext(r, userID))
+				return
+			}
+
 			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
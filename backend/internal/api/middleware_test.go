@@ -0,0 +1,119 @@
+package api
+
+import (
+	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testJWTSecret = "test-secret"
+
+// fakeAPIKeys answers Authenticate from a fixed table and records the calls.
+type fakeAPIKeys struct {
+	service.APIKeyService
+	results map[string]fakeKeyResult // Key -> outcome
+	calls   []string                 // "key scope ip"
+}
+
+type fakeKeyResult struct {
+	userID string
+	err    error
+}
+
+func (f *fakeAPIKeys) Authenticate(ctx context.Context, key string, scope model.APIKeyScope, ipAddress string) (string, error) {
+	f.calls = append(f.calls, fmt.Sprintf("%s %s %s", key, scope, ipAddress))
+	r, ok := f.results[key]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown, revoked or expired", service.ErrInvalidAPIKey)
+	}
+	return r.userID, r.err
+}
+
+func signJWT(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	valid := signJWT(t, testJWTSecret, jwt.MapClaims{"user_id": "user-jwt", "exp": time.Now().Add(time.Hour).Unix()})
+	expired := signJWT(t, testJWTSecret, jwt.MapClaims{"user_id": "user-jwt", "exp": time.Now().Add(-time.Hour).Unix()})
+	forged := signJWT(t, "another-secret", jwt.MapClaims{"user_id": "user-jwt", "exp": time.Now().Add(time.Hour).Unix()})
+	noUser := signJWT(t, testJWTSecret, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()})
+
+	keys := map[string]fakeKeyResult{
+		"crt_live_read":   {userID: "user-key"},
+		"crt_live_ingest": {err: fmt.Errorf("%w: key has the \"ingest\" scope, \"read\" required", service.ErrAPIKeyScope)},
+		"crt_live_broken": {err: errors.New("connection reset")},
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		wantStatus int
+		wantUser   string
+		wantCall   string // The Authenticate call expected ("" = none)
+	}{
+		{name: "no header", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", method: http.MethodGet, header: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized},
+		{name: "session token", method: http.MethodPost, header: "Bearer " + valid, wantStatus: http.StatusOK, wantUser: "user-jwt"},
+		{name: "expired session token", method: http.MethodGet, header: "Bearer " + expired, wantStatus: http.StatusUnauthorized},
+		{name: "token signed with another secret", method: http.MethodGet, header: "Bearer " + forged, wantStatus: http.StatusUnauthorized},
+		{name: "token without user", method: http.MethodGet, header: "Bearer " + noUser, wantStatus: http.StatusUnauthorized},
+		{name: "read key on GET", method: http.MethodGet, header: "Bearer crt_live_read", wantStatus: http.StatusOK, wantUser: "user-key",
+			wantCall: "crt_live_read read 203.0.113.7"},
+		{name: "read key on HEAD", method: http.MethodHead, header: "Bearer crt_live_read", wantStatus: http.StatusOK, wantUser: "user-key",
+			wantCall: "crt_live_read read 203.0.113.7"},
+		{name: "read key on POST", method: http.MethodPost, header: "Bearer crt_live_read", wantStatus: http.StatusForbidden},
+		{name: "read key on DELETE", method: http.MethodDelete, header: "Bearer crt_live_read", wantStatus: http.StatusForbidden},
+		{name: "ingest key", method: http.MethodGet, header: "Bearer crt_live_ingest", wantStatus: http.StatusForbidden,
+			wantCall: "crt_live_ingest read 203.0.113.7"},
+		{name: "revoked or expired key", method: http.MethodGet, header: "Bearer crt_live_revoked", wantStatus: http.StatusUnauthorized,
+			wantCall: "crt_live_revoked read 203.0.113.7"},
+		{name: "key check failing", method: http.MethodGet, header: "Bearer crt_live_broken", wantStatus: http.StatusInternalServerError,
+			wantCall: "crt_live_broken read 203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKeys := &fakeAPIKeys{results: keys}
+			var gotUser string
+			handler := MakeAuthMiddleware(testJWTSecret, apiKeys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUser = GetUserFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/certificates", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if gotUser != tt.wantUser {
+				t.Fatalf("handler saw user %q, want %q", gotUser, tt.wantUser)
+			}
+			switch {
+			case tt.wantCall == "" && len(apiKeys.calls) != 0:
+				t.Fatalf("unexpected key check %v", apiKeys.calls)
+			case tt.wantCall != "" && (len(apiKeys.calls) != 1 || apiKeys.calls[0] != tt.wantCall):
+				t.Fatalf("key checks = %v, want [%s]", apiKeys.calls, tt.wantCall)
+			}
+		})
+	}
+}
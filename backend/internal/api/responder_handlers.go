@@ -0,0 +1,101 @@
+package api
+
+import (
+	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ResponderHandler manages CRL / OCSP endpoint monitoring
+type ResponderHandler struct {
+	Service service.ResponderService
+}
+
+// NewResponderHandler is the constructor
+func NewResponderHandler(svc service.ResponderService) *ResponderHandler {
+	return &ResponderHandler{
+		Service: svc,
+	}
+}
+
+// Request DTO
+type CreateResponderRequest struct {
+	Kind             string `json:"kind"`              // "CRL" or "OCSP"
+	URL              string `json:"url"`               // CRL distribution point or OCSP responder
+	IssuerPEM        string `json:"issuer_pem"`        // CA certificate that signs the CRL / responses
+	SerialNumber     string `json:"serial_number"`     // OCSP only: a certificate issued by that CA (decimal or hex)
+	FrequencyMinutes int    `json:"frequency_minutes"` // Optional
+}
+
+// POST /api/responders
+func (h *ResponderHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// 1. Parse
+	var req CreateResponderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// 2. Service Call (Validation & Check-on-Create)
+	target, err := h.Service.CreateTarget(r.Context(), userID, model.ResponderTarget{
+		Kind:             model.ResponderKind(req.Kind),
+		URL:              req.URL,
+		IssuerPEM:        req.IssuerPEM,
+		SerialNumber:     req.SerialNumber,
+		FrequencyMinutes: req.FrequencyMinutes,
+	})
+	if err != nil {
+		writeServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// 3. Respond
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(target)
+}
+
+// GET /api/responders
+func (h *ResponderHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targets, err := h.Service.ListTargets(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch responders", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(targets)
+}
+
+// DELETE /api/responders/{id}
+func (h *ResponderHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetID := chi.URLParam(r, "id")
+
+	if err := h.Service.DeleteTarget(r.Context(), userID, targetID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"cert-manager-backend/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxTrustBundleBytes caps the upload size of a PEM bundle (1MB)
+const maxTrustBundleBytes = 1 << 20
+
+// TrustStoreHandler manages the per-user private CAs for Cloud Monitoring
+type TrustStoreHandler struct {
+	Service service.TrustStoreService
+}
+
+// NewTrustStoreHandler is the constructor
+func NewTrustStoreHandler(svc service.TrustStoreService) *TrustStoreHandler {
+	return &TrustStoreHandler{
+		Service: svc,
+	}
+}
+
+// Request DTO
+type AddTrustedRootsRequest struct {
+	Name string `json:"name"` // Optional: Defaults to each certificate's CN
+	PEM  string `json:"pem"`  // One or more "-----BEGIN CERTIFICATE-----" blocks
+}
+
+// POST /api/trust-store
+func (h *TrustStoreHandler) HandleAddRoots(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// 1. Parse
+	r.Body = http.MaxBytesReader(w, r.Body, maxTrustBundleBytes)
+	var req AddTrustedRootsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.PEM == "" {
+		http.Error(w, "PEM is required", http.StatusBadRequest)
+		return
+	}
+
+	// 2. Service Call
+	added, err := h.Service.AddRoots(r.Context(), userID, req.Name, req.PEM)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 3. Respond
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(added)
+}
+
+// GET /api/trust-store
+func (h *TrustStoreHandler) HandleListRoots(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roots, err := h.Service.ListRoots(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch trust store", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roots)
+}
+
+// DELETE /api/trust-store/{id}
+func (h *TrustStoreHandler) HandleDeleteRoot(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rootID := chi.URLParam(r, "id")
+
+	if err := h.Service.DeleteRoot(r.Context(), userID, rootID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
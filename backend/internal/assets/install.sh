@@ -10,20 +10,21 @@ RED='\033[0;31m'
 YELLOW='\033[1;33m'
 NC='\033[0m'
 
-API_KEY=""
+ENROLL_TOKEN=""
 INSTALL_DIR="/usr/local/bin"
 CONFIG_DIR="/etc/cert-agent"
 STATE_DIR="/var/lib/cert-agent"
 SERVICE_FILE=""
 
 show_help() {
-    echo "Usage: curl ... | sudo bash -s -- -k <KEY>"
+    echo "Usage: curl ... | sudo bash -s -- -t <ENROLLMENT_TOKEN>"
+    echo "  The one-time enrollment token comes from POST /api/agents/enrollment-tokens."
     # ...
 }
 
 while [[ "$#" -gt 0 ]]; do
     case $1 in
-        -k|--key) API_KEY="$2"; shift ;;
+        -t|--token) ENROLL_TOKEN="$2"; shift ;;
         -s|--state-dir) STATE_DIR="$2"; shift ;;
         -c|--config-dir) CONFIG_DIR="$2"; shift ;;
         -h|--help) show_help; exit 0 ;;
@@ -32,8 +33,8 @@ while [[ "$#" -gt 0 ]]; do
     shift
 done
 
-if [ -z "$API_KEY" ]; then
-    echo -e "${RED}Error: API Key is required (-k)${NC}"; exit 1
+if [ -z "$ENROLL_TOKEN" ]; then
+    echo -e "${RED}Error: Enrollment token is required (-t)${NC}"; exit 1
 fi
 
 # 1. Detect OS & Arch
@@ -83,7 +84,26 @@ if [ "$OS" = "Darwin" ] && command -v xattr >/dev/null 2>&1; then
     xattr -d com.apple.quarantine "$TARGET_BIN" 2>/dev/null || true
 fi
 
-# 4. Generate Config (From Template)
+# 4. Enroll (Exchange the one-time token for this agent's own ID & secret)
+ENROLL_URL="${BASE_URL}/agents/enroll"
+ENROLL_BODY="{\"enrollment_token\":\"${ENROLL_TOKEN}\",\"hostname\":\"$(hostname)\"}"
+
+echo "Enrolling agent at $ENROLL_URL..."
+if command -v curl >/dev/null 2>&1; then
+    ENROLL_RESPONSE="$(curl -f -sS -X POST -H "Content-Type: application/json" -d "$ENROLL_BODY" "$ENROLL_URL")" || ENROLL_RESPONSE=""
+else
+    ENROLL_RESPONSE="$(wget -q -O - --header="Content-Type: application/json" --post-data="$ENROLL_BODY" "$ENROLL_URL")" || ENROLL_RESPONSE=""
+fi
+
+AGENT_ID="$(printf '%s' "$ENROLL_RESPONSE" | sed -n 's/.*"agent_id":"\([^"]*\)".*/\1/p')"
+AGENT_SECRET="$(printf '%s' "$ENROLL_RESPONSE" | sed -n 's/.*"agent_secret":"\([^"]*\)".*/\1/p')"
+
+if [ -z "$AGENT_ID" ] || [ -z "$AGENT_SECRET" ]; then
+    echo -e "${RED}Error: Enrollment failed. The token may be invalid, expired or already used.${NC}"; exit 1
+fi
+echo "Enrolled as agent: $AGENT_ID"
+
+# 5. Generate Config (From Template)
 CONFIG_FILE="$CONFIG_DIR/config.yaml"
 TEMPLATE_URL="${BASE_URL}/downloads/config.yaml"
 # Log path relative to the state dir for the agent log
@@ -101,22 +121,30 @@ fi
 
 echo "Configuring agent settings..."
 
-# Helper to replace values in YAML safely (Works on Linux & Mac)
+# Helper to replace values in YAML safely (Works on Linux & Mac); appends keys the template lacks
 replace_yaml_value() {
     local key=$1
     local val=$2
     local file=$3
-    # Use | as delimiter to avoid escaping slashes in URLs/Paths
-    sed "s|^$key:.*|$key: \"$val\"|" "$file" > "${file}.tmp" && mv "${file}.tmp" "$file"
+    if grep -q "^$key:" "$file"; then
+        # Use | as delimiter to avoid escaping slashes in URLs/Paths
+        sed "s|^$key:.*|$key: \"$val\"|" "$file" > "${file}.tmp" && mv "${file}.tmp" "$file"
+    else
+        echo "$key: \"$val\"" >> "$file"
+    fi
 }
 
 # Inject the dynamic values into the downloaded template
 replace_yaml_value "backend_url" "${BASE_URL}/certs" "$CONFIG_FILE"
-replace_yaml_value "api_key" "${API_KEY}" "$CONFIG_FILE"
+replace_yaml_value "agent_id" "${AGENT_ID}" "$CONFIG_FILE"
+replace_yaml_value "api_key" "${AGENT_SECRET}" "$CONFIG_FILE"
 replace_yaml_value "state_path" "${STATE_DIR}" "$CONFIG_FILE"
 replace_yaml_value "log_path" "${LOG_FILE}" "$CONFIG_FILE"
 
-# 5. Service Installation (Linux Only)
+# The config now holds the agent's secret
+chmod 600 "$CONFIG_FILE"
+
+# 6. Service Installation (Linux Only)
 if [ "$OS" = "Linux" ] && [ -d "/etc/systemd/system" ] && command -v systemctl >/dev/null 2>&1; then
     echo "Installing system service..."
     SERVICE_FILE="/etc/systemd/system/cert-agent.service"
@@ -147,7 +175,7 @@ else
     echo -e "${GREEN}sudo $TARGET_BIN -config $CONFIG_FILE${NC}"
 fi
 
-# 6. Log Rotation (Linux Only)
+# 7. Log Rotation (Linux Only)
 # Check if /etc/logrotate.d exists (Standard on almost all Linux distros)
 if [ "$OS" = "Linux" ]; then
     if [ -d "/etc/logrotate.d" ]; then
@@ -174,6 +202,7 @@ echo ""
 echo "================================================"
 echo "           INSTALLATION SUMMARY"
 echo "================================================"
+echo "Agent ID:  $AGENT_ID"
 echo "Binary:    $TARGET_BIN"
 echo "Config:    $CONFIG_FILE"
 echo "Logs:      $LOG_FILE"
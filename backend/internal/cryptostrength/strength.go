@@ -0,0 +1,67 @@
+package cryptostrength
+
+import (
+	"cert-manager-backend/internal/model"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+)
+
+// MinRSABits is the smallest RSA modulus considered safe (NIST SP 800-131A).
+const MinRSABits = 2048
+
+// deprecatedCurves are curves below the 128-bit security level.
+var deprecatedCurves = map[string]bool{
+	"P-192": true,
+	"P-224": true,
+}
+
+// PublicKey describes a certificate's public key: algorithm, size in bits and (EC only) curve.
+func PublicKey(c *x509.Certificate) (algo string, bits int, curve string) {
+	switch k := c.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", k.N.BitLen(), ""
+	case *ecdsa.PublicKey:
+		params := k.Curve.Params()
+		return "ECDSA", params.BitSize, params.Name
+	case ed25519.PublicKey:
+		return "Ed25519", 256, ""
+	default:
+		return c.PublicKeyAlgorithm.String(), 0, ""
+	}
+}
+
+// SPKISHA256 is the hex SHA-256 of the SubjectPublicKeyInfo (the HPKP / pinning fingerprint).
+func SPKISHA256(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// Evaluate returns the weak-crypto finding codes for a certificate.
+// Works on the stored fields, so reporters that omit the key (old agents) are only judged on the signature.
+func Evaluate(cert model.Certificate) []string {
+	findings := []string{}
+
+	if cert.KeyAlgo == "RSA" && cert.KeySize > 0 && cert.KeySize < MinRSABits {
+		findings = append(findings, model.CryptoFindingWeakRSAKey)
+	}
+	if cert.KeyAlgo == "ECDSA" && deprecatedCurves[cert.KeyCurve] {
+		findings = append(findings, model.CryptoFindingDeprecatedCurve)
+	}
+
+	// A self-signed cert's own signature is never checked by clients (Trust Anchors), so it cannot be weak.
+	if cert.Subject != cert.Issuer && isWeakSignature(cert.SignatureAlgo) {
+		findings = append(findings, model.CryptoFindingWeakSignature)
+	}
+	return findings
+}
+
+// isWeakSignature matches x509.SignatureAlgorithm names such as "SHA1-RSA", "ECDSA-SHA1" or "MD5-RSA".
+func isWeakSignature(algo string) bool {
+	algo = strings.ToUpper(algo)
+	return strings.Contains(algo, "SHA1") || strings.Contains(algo, "MD5") || strings.Contains(algo, "MD2")
+}
@@ -0,0 +1,128 @@
+package cryptostrength
+
+import (
+	"cert-manager-backend/internal/model"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// selfSigned issues a certificate for key, signed by key itself.
+func selfSigned(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "strength.example.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPublicKey(t *testing.T) {
+	rsaKey := func(bits int) crypto.Signer {
+		k, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	ecKey := func(curve elliptic.Curve) crypto.Signer {
+		k, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		key          crypto.Signer
+		wantAlgo     string
+		wantBits     int
+		wantCurve    string
+		wantFindings []string
+	}{
+		{name: "rsa 1024", key: rsaKey(1024), wantAlgo: "RSA", wantBits: 1024, wantFindings: []string{model.CryptoFindingWeakRSAKey}},
+		{name: "rsa 2048", key: rsaKey(2048), wantAlgo: "RSA", wantBits: 2048, wantFindings: []string{}},
+		{name: "ec p-224", key: ecKey(elliptic.P224()), wantAlgo: "ECDSA", wantBits: 224, wantCurve: "P-224", wantFindings: []string{model.CryptoFindingDeprecatedCurve}},
+		{name: "ec p-256", key: ecKey(elliptic.P256()), wantAlgo: "ECDSA", wantBits: 256, wantCurve: "P-256", wantFindings: []string{}},
+		{name: "ec p-384", key: ecKey(elliptic.P384()), wantAlgo: "ECDSA", wantBits: 384, wantCurve: "P-384", wantFindings: []string{}},
+		{name: "ed25519", key: edKey, wantAlgo: "Ed25519", wantBits: 256, wantFindings: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := selfSigned(t, tt.key)
+			algo, bits, curve := PublicKey(c)
+			if algo != tt.wantAlgo || bits != tt.wantBits || curve != tt.wantCurve {
+				t.Fatalf("PublicKey = %s/%d/%q, want %s/%d/%q", algo, bits, curve, tt.wantAlgo, tt.wantBits, tt.wantCurve)
+			}
+			if len(SPKISHA256(c)) != 64 {
+				t.Fatalf("SPKISHA256 = %q, want a hex sha-256", SPKISHA256(c))
+			}
+
+			cert := model.Certificate{KeyAlgo: algo, KeySize: bits, KeyCurve: curve, SignatureAlgo: c.SignatureAlgorithm.String()}
+			if got := Evaluate(cert); !reflect.DeepEqual(got, tt.wantFindings) {
+				t.Fatalf("findings = %v, want %v", got, tt.wantFindings)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	leaf := model.DN{CN: "leaf.example.test"}
+	ca := model.DN{CN: "Test CA"}
+	issued := func(algo string, size int, curve, sig string) model.Certificate {
+		return model.Certificate{Subject: leaf, Issuer: ca, KeyAlgo: algo, KeySize: size, KeyCurve: curve, SignatureAlgo: sig}
+	}
+
+	tests := []struct {
+		name string
+		cert model.Certificate
+		want []string
+	}{
+		{name: "rsa 2048 sha256", cert: issued("RSA", 2048, "", "SHA256-RSA"), want: []string{}},
+		{name: "rsa 2047", cert: issued("RSA", 2047, "", "SHA256-RSA"), want: []string{model.CryptoFindingWeakRSAKey}},
+		{name: "rsa size unknown", cert: issued("RSA", 0, "", "SHA256-RSA"), want: []string{}},
+		{name: "p-192", cert: issued("ECDSA", 192, "P-192", "ECDSA-SHA256"), want: []string{model.CryptoFindingDeprecatedCurve}},
+		{name: "p-521", cert: issued("ECDSA", 521, "P-521", "ECDSA-SHA512"), want: []string{}},
+		{name: "ed25519", cert: issued("Ed25519", 256, "", "Ed25519"), want: []string{}},
+		{name: "sha1 rsa", cert: issued("RSA", 2048, "", "SHA1-RSA"), want: []string{model.CryptoFindingWeakSignature}},
+		{name: "ecdsa sha1", cert: issued("ECDSA", 256, "P-256", "ECDSA-SHA1"), want: []string{model.CryptoFindingWeakSignature}},
+		{name: "md5 rsa", cert: issued("RSA", 2048, "", "MD5-RSA"), want: []string{model.CryptoFindingWeakSignature}},
+		{name: "md2 rsa, lowercase", cert: issued("RSA", 2048, "", "md2-rsa"), want: []string{model.CryptoFindingWeakSignature}},
+		{name: "sha1 self-signed root", cert: model.Certificate{Subject: ca, Issuer: ca, KeyAlgo: "RSA", KeySize: 2048, SignatureAlgo: "SHA1-RSA"}, want: []string{}},
+		{name: "weak key and signature", cert: issued("RSA", 1024, "", "SHA1-RSA"), want: []string{model.CryptoFindingWeakRSAKey, model.CryptoFindingWeakSignature}},
+		{name: "key omitted by the reporter", cert: issued("", 0, "", "SHA1-RSA"), want: []string{model.CryptoFindingWeakSignature}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Evaluate(tt.cert); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("findings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
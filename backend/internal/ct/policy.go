@@ -0,0 +1,72 @@
+package ct
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tier requires MinSCTs distinct logs for certs whose lifetime is at most MaxLifetimeDays.
+// MaxLifetimeDays = 0 is the catch-all tier for longer lifetimes.
+type Tier struct {
+	MaxLifetimeDays int
+	MinSCTs         int
+}
+
+// Policy is an ordered list of lifetime tiers.
+type Policy []Tier
+
+// ParsePolicy reads "days:count,...,count" (e.g. "180:2,3").
+// A bare count is the catch-all tier; without one, the last tier's count applies to longer lifetimes.
+func ParsePolicy(spec string) (Policy, error) {
+	var p Policy
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var t Tier
+		var err error
+		if days, count, ok := strings.Cut(part, ":"); ok {
+			if t.MaxLifetimeDays, err = strconv.Atoi(strings.TrimSpace(days)); err != nil || t.MaxLifetimeDays < 1 {
+				return nil, fmt.Errorf("invalid ct policy tier %q: bad lifetime", part)
+			}
+			part = count
+		}
+		if t.MinSCTs, err = strconv.Atoi(strings.TrimSpace(part)); err != nil || t.MinSCTs < 0 {
+			return nil, fmt.Errorf("invalid ct policy tier %q: bad sct count", part)
+		}
+		p = append(p, t)
+	}
+
+	if len(p) == 0 {
+		return nil, fmt.Errorf("ct policy is empty")
+	}
+
+	// Bounded tiers ascending, catch-all last
+	sort.SliceStable(p, func(i, j int) bool {
+		if p[i].MaxLifetimeDays == 0 || p[j].MaxLifetimeDays == 0 {
+			return p[j].MaxLifetimeDays == 0 && p[i].MaxLifetimeDays != 0
+		}
+		return p[i].MaxLifetimeDays < p[j].MaxLifetimeDays
+	})
+	return p, nil
+}
+
+// Required returns how many distinct-log SCTs a cert with the given validity needs.
+func (p Policy) Required(validFrom, validUntil time.Time) int {
+	if len(p) == 0 {
+		return 0
+	}
+
+	lifetimeDays := validUntil.Sub(validFrom).Hours() / 24
+	for _, t := range p {
+		if t.MaxLifetimeDays == 0 || lifetimeDays <= float64(t.MaxLifetimeDays) {
+			return t.MinSCTs
+		}
+	}
+	return p[len(p)-1].MinSCTs
+}
@@ -0,0 +1,82 @@
+package ct
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Policy
+		wantErr bool
+	}{
+		{spec: "180:2,3", want: Policy{{MaxLifetimeDays: 180, MinSCTs: 2}, {MinSCTs: 3}}},
+		{spec: " 3 , 180 : 2 ", want: Policy{{MaxLifetimeDays: 180, MinSCTs: 2}, {MinSCTs: 3}}},
+		{spec: "398:3,90:1,180:2", want: Policy{{90, 1}, {180, 2}, {398, 3}}},
+		{spec: "2", want: Policy{{MinSCTs: 2}}},
+		{spec: "180:2,,3,", want: Policy{{MaxLifetimeDays: 180, MinSCTs: 2}, {MinSCTs: 3}}},
+		{spec: "", wantErr: true},
+		{spec: " , ", wantErr: true},
+		{spec: "0:2", wantErr: true},
+		{spec: "-5:2", wantErr: true},
+		{spec: "days:2", wantErr: true},
+		{spec: "180:-1", wantErr: true},
+		{spec: "180:two", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParsePolicy(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyRequired(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n float64) time.Time { return from.Add(time.Duration(n * 24 * float64(time.Hour))) }
+
+	tests := []struct {
+		name     string
+		spec     string
+		lifetime time.Time
+		want     int
+	}{
+		{name: "90 days", spec: "180:2,3", lifetime: days(90), want: 2},
+		{name: "exactly 180 days", spec: "180:2,3", lifetime: days(180), want: 2},
+		{name: "just over 180 days", spec: "180:2,3", lifetime: days(180.5), want: 3},
+		{name: "398 days", spec: "180:2,3", lifetime: days(398), want: 3},
+		{name: "shortest matching tier wins", spec: "90:1,180:2,3", lifetime: days(45), want: 1},
+		{name: "no catch-all: last tier applies", spec: "90:1,180:2", lifetime: days(365), want: 2},
+		{name: "catch-all only", spec: "3", lifetime: days(7), want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePolicy(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Required(from, tt.lifetime); got != tt.want {
+				t.Fatalf("Required = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := Policy(nil).Required(from, days(90)); got != 0 {
+		t.Fatalf("empty policy requires %d SCTs, want 0", got)
+	}
+}
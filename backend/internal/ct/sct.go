@@ -0,0 +1,97 @@
+package ct
+
+import (
+	"cert-manager-backend/internal/model"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// oidEmbeddedSCTList is the X.509v3 extension carrying precertificate SCTs (RFC 6962, Section 3.3).
+var oidEmbeddedSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// EmbeddedSCTs extracts the SCTs a CA embedded in the certificate.
+// Returns nil (no error) when the extension is absent.
+func EmbeddedSCTs(c *x509.Certificate) ([]model.SCT, error) {
+	for _, ext := range c.Extensions {
+		if !ext.Id.Equal(oidEmbeddedSCTList) {
+			continue
+		}
+
+		// extnValue wraps the TLS encoded list in an OCTET STRING
+		var list []byte
+		if _, err := asn1.Unmarshal(ext.Value, &list); err != nil {
+			return nil, fmt.Errorf("invalid sct extension: %w", err)
+		}
+		return ParseSCTList(list, model.SCTSourceEmbedded)
+	}
+	return nil, nil
+}
+
+// HandshakeSCTs parses the SCTs delivered in the TLS extension (ConnectionState().SignedCertificateTimestamps).
+func HandshakeSCTs(raw [][]byte) ([]model.SCT, error) {
+	scts := make([]model.SCT, 0, len(raw))
+	for _, r := range raw {
+		sct, err := ParseSCT(r, model.SCTSourceTLS)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+// ParseSCTList decodes a SignedCertificateTimestampList: opaque SerializedSCT<1..2^16-1>.
+func ParseSCTList(raw []byte, source string) ([]model.SCT, error) {
+	if len(raw) < 2 {
+		return nil, fmt.Errorf("sct list too short")
+	}
+	total := int(binary.BigEndian.Uint16(raw))
+	raw = raw[2:]
+	if total != len(raw) {
+		return nil, fmt.Errorf("sct list length mismatch")
+	}
+
+	var scts []model.SCT
+	for len(raw) > 0 {
+		if len(raw) < 2 {
+			return nil, fmt.Errorf("truncated sct list")
+		}
+		n := int(binary.BigEndian.Uint16(raw))
+		if len(raw) < 2+n {
+			return nil, fmt.Errorf("truncated sct list")
+		}
+
+		sct, err := ParseSCT(raw[2:2+n], source)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+		raw = raw[2+n:]
+	}
+	return scts, nil
+}
+
+// ParseSCT decodes the fields we keep from a single serialized SCT (RFC 6962, Section 3.2):
+// version(1) log_id(32) timestamp(8) extensions<0..2^16-1> signature...
+// The signature is not verified: we report what the server / CA claims, not log inclusion.
+func ParseSCT(raw []byte, source string) (model.SCT, error) {
+	const headerLen = 1 + 32 + 8
+
+	if len(raw) < headerLen {
+		return model.SCT{}, fmt.Errorf("sct too short")
+	}
+	if raw[0] != 0 {
+		return model.SCT{}, fmt.Errorf("unsupported sct version %d", raw[0])
+	}
+
+	ms := binary.BigEndian.Uint64(raw[33:41])
+	return model.SCT{
+		LogID:     base64.StdEncoding.EncodeToString(raw[1:33]),
+		Timestamp: time.UnixMilli(int64(ms)).UTC(),
+		Source:    source,
+	}, nil
+}
@@ -0,0 +1,182 @@
+package ct
+
+import (
+	"bytes"
+	"cert-manager-backend/internal/model"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// serializeSCT builds a v1 SCT for the log whose ID is 32 times logByte, with an empty signature.
+func serializeSCT(logByte byte, ts time.Time) []byte {
+	raw := []byte{0}
+	raw = append(raw, bytes.Repeat([]byte{logByte}, 32)...)
+	raw = binary.BigEndian.AppendUint64(raw, uint64(ts.UnixMilli()))
+	raw = append(raw, 0, 0)       // extensions<0..2^16-1>
+	raw = append(raw, 4, 3, 0, 0) // digitally-signed: sha256/ecdsa, empty signature
+	return raw
+}
+
+// sctList wraps serialized SCTs into a SignedCertificateTimestampList.
+func sctList(scts ...[]byte) []byte {
+	var body []byte
+	for _, s := range scts {
+		body = binary.BigEndian.AppendUint16(body, uint16(len(s)))
+		body = append(body, s...)
+	}
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(body))), body...)
+}
+
+func logID(logByte byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{logByte}, 32))
+}
+
+func TestParseSCTList(t *testing.T) {
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	a, b := serializeSCT(0xaa, ts), serializeSCT(0xbb, ts.Add(time.Second))
+	badVersion := append([]byte{1}, a[1:]...)
+
+	tests := []struct {
+		name    string
+		raw     []byte
+		want    []string // Log IDs
+		wantErr bool
+	}{
+		{name: "two logs", raw: sctList(a, b), want: []string{logID(0xaa), logID(0xbb)}},
+		{name: "same log twice", raw: sctList(a, a), want: []string{logID(0xaa), logID(0xaa)}},
+		{name: "empty list", raw: sctList()},
+		{name: "too short", raw: []byte{0}, wantErr: true},
+		{name: "length mismatch", raw: append(sctList(a), 0), wantErr: true},
+		{name: "truncated entry", raw: func() []byte { l := sctList(a); l[3]++; return l }(), wantErr: true},
+		{name: "short sct", raw: sctList(a[:40]), wantErr: true},
+		{name: "unsupported version", raw: sctList(badVersion), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scts, err := ParseSCTList(tt.raw, model.SCTSourceEmbedded)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", scts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(scts) != len(tt.want) {
+				t.Fatalf("got %d scts, want %d", len(scts), len(tt.want))
+			}
+			for i, sct := range scts {
+				if sct.LogID != tt.want[i] || sct.Source != model.SCTSourceEmbedded {
+					t.Fatalf("sct %d = %+v, want log %s from %s", i, sct, tt.want[i], model.SCTSourceEmbedded)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSCTTimestamp(t *testing.T) {
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 123e6, time.UTC)
+	sct, err := ParseSCT(serializeSCT(0xaa, ts), model.SCTSourceTLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sct.Timestamp.Equal(ts) || sct.Source != model.SCTSourceTLS {
+		t.Fatalf("got %+v, want timestamp %s from %s", sct, ts, model.SCTSourceTLS)
+	}
+}
+
+func TestHandshakeSCTs(t *testing.T) {
+	ts := time.Now()
+	scts, err := HandshakeSCTs([][]byte{serializeSCT(0xaa, ts), serializeSCT(0xbb, ts)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scts) != 2 || scts[0].LogID != logID(0xaa) || scts[1].LogID != logID(0xbb) || scts[1].Source != model.SCTSourceTLS {
+		t.Fatalf("got %+v", scts)
+	}
+
+	if _, err := HandshakeSCTs([][]byte{serializeSCT(0xaa, ts), {0}}); err == nil {
+		t.Fatal("expected an error for a malformed sct")
+	}
+}
+
+func TestEmbeddedSCTs(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(extValue []byte) *x509.Certificate {
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "ct.example.test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		if extValue != nil {
+			tmpl.ExtraExtensions = []pkix.Extension{{Id: oidEmbeddedSCTList, Value: extValue}}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	octets := func(b []byte) []byte {
+		v, err := asn1.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	ts := time.Now()
+	tests := []struct {
+		name     string
+		extValue []byte
+		wantLogs int
+		wantErr  bool
+	}{
+		{name: "no extension"},
+		{name: "two logs", extValue: octets(sctList(serializeSCT(0xaa, ts), serializeSCT(0xbb, ts))), wantLogs: 2},
+		{name: "not an octet string", extValue: []byte{0x05, 0x00}, wantErr: true},
+		{name: "malformed list", extValue: octets([]byte{0, 9, 0}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scts, err := EmbeddedSCTs(issue(tt.extValue))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", scts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(scts) != tt.wantLogs {
+				t.Fatalf("got %d scts, want %d", len(scts), tt.wantLogs)
+			}
+			for _, sct := range scts {
+				if sct.Source != model.SCTSourceEmbedded {
+					t.Fatalf("source = %s, want %s", sct.Source, model.SCTSourceEmbedded)
+				}
+			}
+		})
+	}
+}
@@ -7,7 +7,7 @@ CREATE TABLE IF NOT EXISTS users (
     email TEXT UNIQUE NOT NULL,
     password_hash TEXT NOT NULL,
     organization_name TEXT NOT NULL,
-    api_key_hash TEXT UNIQUE,
+    api_key_hash TEXT UNIQUE,           -- Legacy: moved to api_keys (see Migrations)
     email_enabled BOOLEAN DEFAULT TRUE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP, 
 
@@ -43,8 +43,20 @@ CREATE TABLE IF NOT EXISTS certificates (
     valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
     valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
     signature_algo TEXT,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE (serial_number, issuer_cn, issuer_org, issuer_ou)
+    issuer_certificate_id UUID REFERENCES certificates(id) ON DELETE SET NULL, -- Parent (Issuer) as observed in a served chain
+
+    -- Revocation (Latest OCSP / CRL result)
+    revocation_status TEXT,             -- 'GOOD', 'REVOKED', 'UNKNOWN' (NULL = never checked)
+    revocation_reason TEXT,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revocation_source TEXT,             -- 'OCSP', 'OCSP_STAPLE', 'CRL'
+    revocation_checked_at TIMESTAMP WITH TIME ZONE,
+    crl_urls TEXT[],                    -- CRL Distribution Points
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    
+    -- Identity is fingerprint_sha256 (idx_certificates_fingerprint, see Migrations): serials are not unique
+    -- across a sloppy private CA's certificates
 );
 
 CREATE INDEX IF NOT EXISTS idx_certs_valid_until ON certificates(valid_until);
@@ -64,10 +76,17 @@ CREATE TABLE IF NOT EXISTS certificate_instances (
 
     is_trusted BOOLEAN DEFAULT FALSE,
     trust_error TEXT,
+    ocsp_stapled BOOLEAN DEFAULT FALSE,
+    hostname_mismatch BOOLEAN DEFAULT FALSE, -- Leaf does not cover the scanned host (Network scans only)
+    hostname_error TEXT,
+    resolved_ip TEXT,                   -- Node address ("Scan all addresses" targets only)
     scanned_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    -- Chain Context: 0 = Leaf, 1..n = Intermediates served alongside it
+    chain_position INTEGER NOT NULL DEFAULT 0,
+    leaf_instance_id UUID REFERENCES certificate_instances(id) ON DELETE CASCADE
     
-    -- The Unique Constraint now uses source_uid
-    UNIQUE (agent_id, source_uid)
+    -- Uniqueness (agent_id, source_uid, chain_position) is enforced by an index below
 );
 
 -- 6. Alert History
@@ -91,19 +110,441 @@ CREATE TABLE IF NOT EXISTS monitored_targets (
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     
     target_url TEXT NOT NULL, -- e.g. "google.com:443"
+    protocol TEXT NOT NULL DEFAULT 'TLS', -- 'TLS' (implicit) or a STARTTLS protocol: 'SMTP', 'LDAP', 'POSTGRES'...
+    server_name TEXT NOT NULL DEFAULT '',     -- SNI override ('' = host of target_url)
+    connect_address TEXT NOT NULL DEFAULT '', -- Dial override, e.g. an origin IP ('' = host of target_url)
+    ports INTEGER[],                          -- Extra ports scanned with the same host / SNI
     
     frequency_hours INTEGER DEFAULT 12,
+    scan_all_addresses BOOLEAN NOT NULL DEFAULT FALSE, -- Handshake with every resolved A/AAAA address
+    nodes_disagree BOOLEAN NOT NULL DEFAULT FALSE,     -- Nodes serve different leaf certificates
+    node_mismatches TEXT,
     last_scanned_at TIMESTAMP WITH TIME ZONE,
     last_status TEXT,         -- 'SUCCESS' or 'FAILED'
     last_error TEXT,
     
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
     
-    -- Prevent duplicate targets for the same user
-    UNIQUE(user_id, target_url)
+    -- Duplicate targets are prevented by idx_monitored_targets_endpoint (see Migrations)
 );
 
 -- Indexes for "Bulk Check" performance
 CREATE INDEX IF NOT EXISTS idx_alert_hist_cert ON alert_history(alert_type, certificate_id, sent_at);
 CREATE INDEX IF NOT EXISTS idx_alert_hist_agent ON alert_history(alert_type, agent_id, sent_at);
-CREATE INDEX IF NOT EXISTS idx_monitored_targets_last_scanned ON monitored_targets (last_scanned_at);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_monitored_targets_last_scanned ON monitored_targets (last_scanned_at);
+
+-- 8. Migrations: Full Chain Capture
+-- Existing deployments were created with UNIQUE (agent_id, source_uid), which only allows the leaf.
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS issuer_certificate_id UUID REFERENCES certificates(id) ON DELETE SET NULL;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS chain_position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS leaf_instance_id UUID REFERENCES certificate_instances(id) ON DELETE CASCADE;
+ALTER TABLE certificate_instances DROP CONSTRAINT IF EXISTS certificate_instances_agent_id_source_uid_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cert_instances_source_chain ON certificate_instances (agent_id, source_uid, chain_position);
+CREATE INDEX IF NOT EXISTS idx_cert_instances_leaf ON certificate_instances (leaf_instance_id);
+
+-- 9. Migrations: STARTTLS Protocols for Monitored Targets
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS protocol TEXT NOT NULL DEFAULT 'TLS';
+
+-- 10. TLS Posture (Latest audit per Monitored Target)
+CREATE TABLE IF NOT EXISTS target_tls_posture (
+    target_id UUID PRIMARY KEY REFERENCES monitored_targets(id) ON DELETE CASCADE,
+    grade TEXT NOT NULL,        -- 'A' .. 'F'
+    versions JSONB NOT NULL,    -- [{version, supported, cipher_suites}]
+    findings JSONB NOT NULL,    -- [{code, severity, message}]
+    scanned_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 11. Migrations: OCSP Revocation & Stapling
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS revocation_status TEXT;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS revocation_reason TEXT;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS revocation_checked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS ocsp_stapled BOOLEAN DEFAULT FALSE;
+
+-- 12. Migrations: CRL Revocation Checking
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS revocation_source TEXT;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS crl_urls TEXT[];
+
+-- 13. Migrations: Hostname Mismatch (Separate from Chain Trust)
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS hostname_mismatch BOOLEAN DEFAULT FALSE;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS hostname_error TEXT;
+
+-- 14. Migrations: Scan Every Resolved Address
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS scan_all_addresses BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS nodes_disagree BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS node_mismatches TEXT;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS resolved_ip TEXT;
+
+-- 15. Trust Store (Private CAs per User for Cloud Scans)
+-- The agent uses extra_certs_path for this; Cloud Monitors read the roots from here.
+CREATE TABLE IF NOT EXISTS trusted_roots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    name TEXT NOT NULL,
+    subject_cn TEXT,
+    subject_org TEXT,
+    subject_ou TEXT,
+    issuer_cn TEXT,
+    issuer_org TEXT,
+    issuer_ou TEXT,
+    is_ca BOOLEAN NOT NULL DEFAULT TRUE,
+    fingerprint_sha256 TEXT NOT NULL,
+    valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    pem TEXT NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    -- Uploading the same CA twice is a no-op
+    UNIQUE(user_id, fingerprint_sha256)
+);
+
+-- 16. Migrations: Custom SNI, Connect Address & Port Lists
+-- The same hostname may now be monitored once per connect address / SNI (e.g. origin IP vs CDN).
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS server_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS connect_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS ports INTEGER[];
+ALTER TABLE monitored_targets DROP CONSTRAINT IF EXISTS monitored_targets_user_id_target_url_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_monitored_targets_endpoint ON monitored_targets (user_id, target_url, server_name, connect_address);
+
+-- 17. Certificate Transparency (SCTs & CT Policy)
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS sct_count INTEGER NOT NULL DEFAULT 0; -- Distinct logs
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS ct_required_scts INTEGER;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS ct_compliant BOOLEAN; -- NULL = not publicly trusted / not evaluated
+
+CREATE TABLE IF NOT EXISTS certificate_scts (
+    certificate_id UUID NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    log_id TEXT NOT NULL,   -- Base64 SHA-256 of the log key
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    source TEXT NOT NULL,   -- 'EMBEDDED' or 'TLS'
+    PRIMARY KEY (certificate_id, log_id, source)
+);
+
+-- 18. Migrations: Public Key & Crypto Strength
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS key_algo TEXT;      -- 'RSA', 'ECDSA', 'Ed25519'
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS key_size INTEGER;   -- Bits
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS key_curve TEXT;     -- 'P-256'... (ECDSA only)
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS spki_sha256 TEXT;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS crypto_findings TEXT[] NOT NULL DEFAULT '{}'; -- 'WEAK_RSA_KEY', 'WEAK_SIGNATURE', 'DEPRECATED_CURVE'
+
+CREATE INDEX IF NOT EXISTS idx_certificates_crypto_findings ON certificates USING GIN (crypto_findings);
+
+-- 19. Migrations: mTLS Client Certificates for Monitored Targets
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS client_cert_enc TEXT;        -- AES-GCM sealed PEM (cert chain + key)
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS client_cert_subject TEXT;
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS client_cert_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS requires_client_cert BOOLEAN NOT NULL DEFAULT FALSE; -- Server sent a CertificateRequest
+
+-- 20. Migrations: Egress Proxy per Monitored Target
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS proxy TEXT NOT NULL DEFAULT ''; -- '' = server default, 'direct' or a CLOUD_SCANNER_PROXIES name
+
+-- 21. Discovery Jobs (CIDR / Port Sweeps)
+CREATE TABLE IF NOT EXISTS discovery_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    cidrs TEXT[] NOT NULL,                          -- e.g. {'10.0.0.0/24'}
+    ports INTEGER[] NOT NULL,
+    add_targets BOOLEAN NOT NULL DEFAULT FALSE,     -- Promote found endpoints to monitored_targets
+
+    status TEXT NOT NULL DEFAULT 'PENDING',         -- 'PENDING', 'RUNNING', 'COMPLETED', 'CANCELLED', 'FAILED'
+    address_count INTEGER NOT NULL,                 -- Counts against the per-user cap while PENDING / RUNNING
+    total_probes INTEGER NOT NULL,                  -- address_count x ports
+    probes_done INTEGER NOT NULL DEFAULT 0,
+    found INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_discovery_jobs_user ON discovery_jobs (user_id, status);
+CREATE INDEX IF NOT EXISTS idx_discovery_jobs_queue ON discovery_jobs (created_at) WHERE status = 'PENDING';
+
+CREATE TABLE IF NOT EXISTS discovery_results (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id UUID NOT NULL REFERENCES discovery_jobs(id) ON DELETE CASCADE,
+
+    address TEXT NOT NULL,                          -- "ip:port"
+    protocol TEXT NOT NULL,
+    subject_cn TEXT,
+    issuer_cn TEXT,
+    valid_until TIMESTAMP WITH TIME ZONE,
+    target_id UUID REFERENCES monitored_targets(id) ON DELETE SET NULL, -- Set when promoted
+
+    found_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(job_id, address)
+);
+
+-- 22. Scan History (Availability & Latency per Monitored Target)
+CREATE TABLE IF NOT EXISTS target_scan_results (
+    id BIGSERIAL PRIMARY KEY,
+    target_id UUID NOT NULL REFERENCES monitored_targets(id) ON DELETE CASCADE,
+    scanned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    outcome TEXT NOT NULL,          -- 'SUCCESS' or 'FAILED'
+    error_class TEXT,               -- 'DNS', 'TIMEOUT', 'REFUSED', 'TLS', 'EGRESS_BLOCKED'...
+    error TEXT,
+
+    dns_ms DOUBLE PRECISION,        -- NULL when dialing an IP
+    connect_ms DOUBLE PRECISION,
+    handshake_ms DOUBLE PRECISION,
+    resolved_ip TEXT,
+    fingerprint_sha256 TEXT         -- Leaf served by the primary endpoint
+);
+
+CREATE INDEX IF NOT EXISTS idx_target_scan_results_target ON target_scan_results (target_id, scanned_at DESC);
+CREATE INDEX IF NOT EXISTS idx_target_scan_results_scanned ON target_scan_results (scanned_at);
+
+-- 23. Migrations: Failure Thresholds & Early Retry (Monitored Targets)
+-- health moves HEALTHY -> DEGRADED -> DOWN as consecutive scans fail; retry_at re-schedules a failing
+-- target sooner than its frequency (NULL once a scan succeeds).
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS health TEXT NOT NULL DEFAULT 'HEALTHY';
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS retry_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_monitored_targets_retry ON monitored_targets (retry_at) WHERE retry_at IS NOT NULL;
+
+-- 24. Migrations: Target Kinds (SAML Metadata / JWKS documents)
+-- Document kinds store the full URL in target_url; their certificates use source_type 'METADATA'
+-- and source UIDs of the form "<url>#<entityID>/<use>" or "<url>#<kid>".
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'TLS';
+
+-- 25. Responder Targets (CRL / OCSP Health of a Private CA)
+CREATE TABLE IF NOT EXISTS responder_targets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    kind TEXT NOT NULL,                         -- 'CRL' or 'OCSP'
+    url TEXT NOT NULL,
+    issuer_pem TEXT NOT NULL,                   -- CA that signs the CRL / OCSP responses
+    issuer_subject TEXT,
+    serial_number TEXT NOT NULL DEFAULT '',     -- OCSP only: decimal serial of a known certificate
+    frequency_minutes INTEGER NOT NULL DEFAULT 15,
+
+    health TEXT NOT NULL DEFAULT 'HEALTHY',     -- 'HEALTHY', 'DEGRADED' (nearing nextUpdate / signer expiry), 'DOWN'
+    problems TEXT[],
+    last_checked_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+
+    -- Last successful probe
+    latency_ms DOUBLE PRECISION,
+    this_update TIMESTAMP WITH TIME ZONE,
+    next_update TIMESTAMP WITH TIME ZONE,
+    signer_subject TEXT,
+    signer_expires_at TIMESTAMP WITH TIME ZONE,
+    crl_number TEXT,
+    crl_size_bytes INTEGER,
+    revoked_count INTEGER,
+    ocsp_status TEXT,                           -- 'GOOD', 'REVOKED', 'UNKNOWN'
+    produced_at TIMESTAMP WITH TIME ZONE,
+
+    -- Alert cooldown: the health last notified, and when
+    last_alert_health TEXT,
+    last_alerted_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(user_id, kind, url, serial_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_responder_targets_due ON responder_targets (last_checked_at);
+
+-- 26. Domain Registrations (Registry Expiry of the Domains behind Targets & Certificates)
+-- Rows are derived per user by the Domain Monitor; the RDAP / WHOIS lookup is shared by every user of a domain.
+CREATE TABLE IF NOT EXISTS domain_registrations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    domain TEXT NOT NULL,                       -- Registrable domain, e.g. 'example.co.uk'
+    expires_at TIMESTAMP WITH TIME ZONE,        -- NULL until a lookup succeeds (some ccTLDs never publish it)
+    registrar TEXT,
+    statuses TEXT[],                            -- EPP statuses, e.g. 'clientTransferProhibited'
+    source TEXT,                                -- 'RDAP' or 'WHOIS'
+    last_checked_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,                            -- Last lookup failure (the previous result is kept)
+    last_alerted_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(user_id, domain)
+);
+
+CREATE INDEX IF NOT EXISTS idx_domain_registrations_domain ON domain_registrations (domain);
+CREATE INDEX IF NOT EXISTS idx_domain_registrations_expiry ON domain_registrations (expires_at);
+
+-- 27. Migrations: Subject Alternative Names (Normalized on ingestion, NULL = not reported)
+-- DNS names are lowercase without a trailing dot ('*.example.com' kept as is), IPs canonical.
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS san_dns TEXT[];
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS san_ips TEXT[];
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS san_emails TEXT[];
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS san_uris TEXT[];
+
+CREATE INDEX IF NOT EXISTS idx_certificates_san_dns ON certificates USING GIN (san_dns);
+CREATE INDEX IF NOT EXISTS idx_certificates_san_ips ON certificates USING GIN (san_ips);
+
+-- 28. Migrations: Raw DER & Fingerprints (Certificate Identity)
+-- Rows stored before this have no DER: they are adopted (matched on serial + issuer + subject + expiry)
+-- by the first report that carries it. Serial + issuer is no longer unique.
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS raw_der BYTEA;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS fingerprint_sha256 TEXT;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS fingerprint_sha1 TEXT;
+ALTER TABLE certificates DROP CONSTRAINT IF EXISTS certificates_serial_number_issuer_cn_issuer_org_issuer_ou_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_certificates_fingerprint ON certificates (fingerprint_sha256);
+CREATE INDEX IF NOT EXISTS idx_certificates_serial_issuer ON certificates (serial_number, issuer_cn);
+
+-- 29. Agent Credentials & Enrollment
+-- Each agent authenticates with its own secret, bound to its ID at enrollment (credential_hash = SHA-256).
+-- Agents without one still report with an account API key (pre-enrollment installs, see api_keys).
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS credential_hash TEXT UNIQUE;
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS enrolled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS agent_enrollment_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,                                 -- SHA-256 (the token is shown once)
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,                                -- Single use
+    agent_id UUID REFERENCES agents(id) ON DELETE SET NULL,          -- The agent it enrolled
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrollment_tokens_user ON agent_enrollment_tokens (user_id);
+
+-- 30. API Keys (Named, scoped & individually revocable: replaces users.api_key_hash)
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,                   -- First characters of the key, for display
+    key_hash TEXT NOT NULL UNIQUE,          -- SHA-256 (the key is shown once)
+    scope TEXT NOT NULL,                    -- 'ingest' (agent reports), 'read' (GET API)
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE,    -- NULL = never
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip TEXT,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id);
+
+-- Migrate each user's single key (agents keep working), then retire the column's value
+INSERT INTO api_keys (user_id, name, prefix, key_hash, scope)
+SELECT id, 'Default', 'crt_live_', api_key_hash, 'ingest' FROM users WHERE api_key_hash IS NOT NULL
+ON CONFLICT (key_hash) DO NOTHING;
+
+UPDATE users SET api_key_hash = NULL WHERE api_key_hash IS NOT NULL;
+
+-- 31. Certificate Replacements (Renewal / Swap History per Source)
+-- Written when the leaf at an instance's source changes. Old definitions are kept by the Janitor while referenced.
+CREATE TABLE IF NOT EXISTS certificate_replacements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    instance_id UUID NOT NULL REFERENCES certificate_instances(id) ON DELETE CASCADE,
+    old_certificate_id UUID REFERENCES certificates(id) ON DELETE SET NULL,
+    new_certificate_id UUID REFERENCES certificates(id) ON DELETE SET NULL,
+    kind TEXT NOT NULL,                         -- 'RENEWAL' (same subject & SANs, later expiry) or 'SWAP'
+    replaced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    alerts_resolved INTEGER NOT NULL DEFAULT 0  -- Alerts of the old certificate closed by a renewal
+);
+
+CREATE INDEX IF NOT EXISTS idx_cert_replacements_instance ON certificate_replacements (instance_id, replaced_at DESC);
+CREATE INDEX IF NOT EXISTS idx_cert_replacements_old ON certificate_replacements (old_certificate_id);
+
+-- Alerts are resolved automatically when their certificate is renewed
+ALTER TABLE alert_history ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE alert_history ADD COLUMN IF NOT EXISTS resolution TEXT;   -- 'RENEWED'
+
+-- 32. Migrations: CRL Distribution Points from DER only
+-- Points claimed by a reporter without DER are dropped (the CRL job now only uses the DER's own).
+UPDATE certificates SET crl_urls = NULL WHERE raw_der IS NULL AND crl_urls IS NOT NULL;
+
+-- 33. Revocation per Instance
+-- A reporter's OCSP/staple result only speaks for its own instance; the definition's columns belong to the CRL job.
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS revocation_status TEXT;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS revocation_reason TEXT;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS revocation_source TEXT;
+ALTER TABLE certificate_instances ADD COLUMN IF NOT EXISTS revocation_checked_at TIMESTAMP WITH TIME ZONE;
+
+-- Results reporters wrote to the shared definition are dropped (they are reported again per instance)
+UPDATE certificates
+SET revocation_status = NULL, revocation_reason = NULL, revoked_at = NULL,
+    revocation_source = NULL, revocation_checked_at = NULL
+WHERE revocation_source IS DISTINCT FROM 'CRL' AND revocation_status IS NOT NULL;
+
+-- 34. Migrations: CT Compliance from our own Observations
+-- One-time data migrations record their name here, so a restart doesn't re-run them on newer data.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    name TEXT PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Handshake SCTs and CT verdicts of certificates never seen by the cloud scanner came from agent reports.
+-- Not a no-op when re-run: it would drop the handshake SCTs of certificates whose target was deleted since.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM schema_migrations WHERE name = '34_ct_own_observations') THEN
+        DELETE FROM certificate_scts s
+        WHERE s.source = 'TLS'
+          AND NOT EXISTS (
+              SELECT 1 FROM certificate_instances ci JOIN agents a ON ci.agent_id = a.id
+              WHERE ci.certificate_id = s.certificate_id AND a.is_virtual = TRUE
+          );
+
+        UPDATE certificates c
+        SET ct_required_scts = NULL, ct_compliant = NULL
+        WHERE c.ct_required_scts IS NOT NULL
+          AND NOT EXISTS (
+              SELECT 1 FROM certificate_instances ci JOIN agents a ON ci.agent_id = a.id
+              WHERE ci.certificate_id = c.id AND a.is_virtual = TRUE
+          );
+
+        UPDATE certificates c
+        SET sct_count = (SELECT COUNT(DISTINCT log_id) FROM certificate_scts WHERE certificate_id = c.id)
+        WHERE c.sct_count > 0;
+
+        INSERT INTO schema_migrations (name) VALUES ('34_ct_own_observations');
+    END IF;
+END $$;
+
+-- 35. Discovery: Daily Address Quota (Counts every job a user created in the last 24h)
+CREATE INDEX IF NOT EXISTS idx_discovery_jobs_user_created ON discovery_jobs (user_id, created_at);
+
+-- 36. Migrations: Target-qualified Source UIDs
+-- Node / connect-address instances used to be tagged "host:port@ip", which two targets could share.
+-- Every SourceUID but a target's plain "host:port" now ends in "#<target id>". The old, ambiguous
+-- entries are dropped and their targets rescanned (once), which recreates them under the new tags.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM schema_migrations WHERE name = '36_target_qualified_source_uids') THEN
+        DELETE FROM certificate_instances ci
+        USING agents a
+        WHERE ci.agent_id = a.id AND a.is_virtual = TRUE
+          AND ci.source_uid LIKE '%@%' AND ci.source_uid NOT LIKE '%#%';
+
+        UPDATE monitored_targets
+        SET last_scanned_at = NULL
+        WHERE kind = 'TLS'
+          AND (server_name <> '' OR connect_address <> '' OR cardinality(ports) > 0 OR scan_all_addresses)
+          AND NOT EXISTS (
+              SELECT 1 FROM certificate_instances ci JOIN agents a ON ci.agent_id = a.id
+              WHERE a.user_id = monitored_targets.user_id AND a.is_virtual = TRUE
+                AND ci.source_uid LIKE '%#' || monitored_targets.id::text
+          );
+
+        INSERT INTO schema_migrations (name) VALUES ('36_target_qualified_source_uids');
+    END IF;
+END $$;
+
+-- 37. Target Health Alerts (Cooldown: the health last notified, and when)
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS last_alert_health TEXT;
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS last_alerted_at TIMESTAMP WITH TIME ZONE;
+
+-- 38. Unreachable Nodes (Endpoints / nodes the last successful scan couldn't reach)
+ALTER TABLE monitored_targets ADD COLUMN IF NOT EXISTS unreachable_nodes TEXT;
@@ -0,0 +1,41 @@
+package domains
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Lookuper is one way of asking a registry about a domain (RDAP, WHOIS, or a test stub).
+type Lookuper interface {
+	Lookup(ctx context.Context, domain string) (*model.DomainLookup, error)
+}
+
+// FallbackClient asks RDAP first and WHOIS when RDAP fails or publishes no expiry date.
+type FallbackClient struct {
+	RDAP  Lookuper
+	WHOIS Lookuper // Optional
+}
+
+// Lookup implements service.DomainLookupClient.
+func (c *FallbackClient) Lookup(ctx context.Context, domain string) (*model.DomainLookup, error) {
+	// 1. RDAP (structured, authoritative)
+	result, rdapErr := c.RDAP.Lookup(ctx, domain)
+	if rdapErr == nil && result.ExpiresAt != nil {
+		return result, nil
+	}
+	if errors.Is(rdapErr, ErrNotFound) || c.WHOIS == nil {
+		return result, rdapErr
+	}
+
+	// 2. WHOIS (TLDs without RDAP, or registries that only publish the date there)
+	fallback, whoisErr := c.WHOIS.Lookup(ctx, domain)
+	if whoisErr == nil {
+		return fallback, nil
+	}
+	if rdapErr == nil {
+		return result, nil // RDAP answered, the registry just doesn't publish an expiry (e.g. .de)
+	}
+	return nil, fmt.Errorf("rdap: %v; whois: %w", rdapErr, whoisErr)
+}
@@ -0,0 +1,219 @@
+package domains
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRDAPBootstrapURL is IANA's registry of RDAP servers per TLD (RFC 9224).
+const DefaultRDAPBootstrapURL = "https://data.iana.org/rdap/dns.json"
+
+// bootstrapTTL: the IANA file changes rarely, refresh it daily.
+const bootstrapTTL = 24 * time.Hour
+
+// maxRDAPResponseSize caps RDAP downloads (domain objects are a few KB, the bootstrap file ~100KB).
+const maxRDAPResponseSize = 2 << 20
+
+// ErrNotFound means the registry has no such domain (unregistered or already purged).
+var ErrNotFound = errors.New("domain not found at registry")
+
+// RDAPClient looks domains up over RDAP, finding each TLD's server through the IANA bootstrap file.
+// BaseURL pins every query to one server instead (e.g. a local stub).
+type RDAPClient struct {
+	Client       *http.Client
+	BootstrapURL string
+	BaseURL      string
+
+	mu        sync.Mutex
+	servers   map[string]string // TLD -> base URL
+	fetchedAt time.Time
+}
+
+// NewRDAPClient creates a client with a bounded HTTP timeout. baseURL may be empty (bootstrap).
+func NewRDAPClient(timeout time.Duration, bootstrapURL, baseURL string) *RDAPClient {
+	if bootstrapURL == "" {
+		bootstrapURL = DefaultRDAPBootstrapURL
+	}
+	return &RDAPClient{
+		Client:       &http.Client{Timeout: timeout},
+		BootstrapURL: bootstrapURL,
+		BaseURL:      baseURL,
+	}
+}
+
+// rdapDomain is the subset of an RFC 9083 domain object we need.
+type rdapDomain struct {
+	Status []string `json:"status"`
+	Events []struct {
+		Action string `json:"eventAction"`
+		Date   string `json:"eventDate"`
+	} `json:"events"`
+	Entities []struct {
+		Roles      []string        `json:"roles"`
+		VCardArray json.RawMessage `json:"vcardArray"`
+	} `json:"entities"`
+}
+
+// Lookup queries the domain's registry (RFC 9082 "domain/<name>").
+func (c *RDAPClient) Lookup(ctx context.Context, domain string) (*model.DomainLookup, error) {
+	// 1. Find the Server
+	base := c.BaseURL
+	if base == "" {
+		var err error
+		if base, err = c.serverFor(ctx, domain); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Query
+	body, status, err := c.get(ctx, strings.TrimSuffix(base, "/")+"/domain/"+domain)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("rdap server returned HTTP %d", status)
+	}
+
+	var obj rdapDomain
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, fmt.Errorf("invalid rdap response: %w", err)
+	}
+
+	// 3. Extract
+	result := &model.DomainLookup{Source: model.DomainSourceRDAP, Statuses: obj.Status}
+	for _, e := range obj.Events {
+		if e.Action != "expiration" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, e.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rdap expiration date %q", e.Date)
+		}
+		result.ExpiresAt = &t
+	}
+	for _, ent := range obj.Entities {
+		for _, role := range ent.Roles {
+			if role == "registrar" {
+				result.Registrar = vcardName(ent.VCardArray)
+			}
+		}
+	}
+	return result, nil
+}
+
+// serverFor returns the RDAP base URL serving the domain's TLD.
+func (c *RDAPClient) serverFor(ctx context.Context, domain string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.servers == nil || time.Since(c.fetchedAt) > bootstrapTTL {
+		servers, err := c.fetchBootstrap(ctx)
+		if err != nil {
+			if c.servers == nil {
+				return "", err
+			}
+			// Keep serving the stale copy rather than failing every lookup
+		} else {
+			c.servers, c.fetchedAt = servers, time.Now()
+		}
+	}
+
+	// Longest match first: bootstrap entries are TLDs, but may in theory be deeper
+	labels := strings.Split(domain, ".")
+	for i := 1; i < len(labels); i++ {
+		if base, ok := c.servers[strings.Join(labels[i:], ".")]; ok {
+			return base, nil
+		}
+	}
+	return "", fmt.Errorf("no rdap server for %s", domain)
+}
+
+// fetchBootstrap downloads the IANA file: {"services": [[["com", "net"], ["https://rdap.verisign.com/com/v1/"]], ...]}
+func (c *RDAPClient) fetchBootstrap(ctx context.Context) (map[string]string, error) {
+	body, status, err := c.get(ctx, c.BootstrapURL)
+	if err != nil {
+		return nil, fmt.Errorf("rdap bootstrap: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("rdap bootstrap returned HTTP %d", status)
+	}
+
+	var file struct {
+		Services [][][]string `json:"services"`
+	}
+	if err := json.Unmarshal(body, &file); err != nil {
+		return nil, fmt.Errorf("invalid rdap bootstrap: %w", err)
+	}
+
+	servers := map[string]string{}
+	for _, svc := range file.Services {
+		if len(svc) != 2 || len(svc[1]) == 0 {
+			continue
+		}
+		// Prefer HTTPS when a registry lists several URLs
+		base := svc[1][0]
+		for _, u := range svc[1] {
+			if strings.HasPrefix(u, "https://") {
+				base = u
+				break
+			}
+		}
+		for _, tld := range svc[0] {
+			servers[strings.ToLower(tld)] = base
+		}
+	}
+	return servers, nil
+}
+
+func (c *RDAPClient) get(ctx context.Context, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("rdap request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRDAPResponseSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read rdap response: %w", err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// vcardName extracts "fn" from a jCard: ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Name"]]]
+func vcardName(raw json.RawMessage) string {
+	var card []json.RawMessage
+	if err := json.Unmarshal(raw, &card); err != nil || len(card) != 2 {
+		return ""
+	}
+	var props [][]json.RawMessage
+	if err := json.Unmarshal(card[1], &props); err != nil {
+		return ""
+	}
+	for _, p := range props {
+		var name, value string
+		if len(p) < 4 || json.Unmarshal(p[0], &name) != nil || name != "fn" {
+			continue
+		}
+		if json.Unmarshal(p[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package domains
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// internalSuffixes are never registered at a public registry.
+var internalSuffixes = []string{
+	"local", "localhost", "internal", "intranet", "lan", "corp", "home", "home.arpa",
+	"test", "example", "invalid", "onion", "arpa",
+}
+
+// Registrable returns the domain a hostname is registered under ("api.eu.example.co.uk" -> "example.co.uk").
+// Accepts "host", "host:port", "*.host" and URLs. IPs, single labels and internal names yield false.
+func Registrable(host string) (string, bool) {
+	host = Hostname(host)
+	if host == "" || net.ParseIP(host) != nil {
+		return "", false
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	for _, l := range labels {
+		if !validLabel(l) {
+			return "", false
+		}
+	}
+	for _, s := range internalSuffixes {
+		if host == s || strings.HasSuffix(host, "."+s) {
+			return "", false
+		}
+	}
+
+	// The TLD must be alphabetic (or an IDN "xn--" label): rules out version strings & such in CNs
+	tld := labels[len(labels)-1]
+	if !strings.HasPrefix(tld, "xn--") && strings.Trim(tld, "abcdefghijklmnopqrstuvwxyz") != "" {
+		return "", false
+	}
+
+	// Public Suffix List. Private-section suffixes ("github.io") are registered under an ICANN one,
+	// and that registration is what RDAP / WHOIS know about: "foo.github.io" -> "github.io".
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	for !icann && strings.Contains(suffix, ".") {
+		suffix, icann = publicsuffix.PublicSuffix(suffix[strings.Index(suffix, ".")+1:])
+	}
+	if host == suffix {
+		return "", false // The suffix itself ("co.uk")
+	}
+	rest := strings.TrimSuffix(host, "."+suffix)
+	return rest[strings.LastIndex(rest, ".")+1:] + "." + suffix, true
+}
+
+// Hostname normalizes a target address or certificate name to a bare lowercase hostname.
+func Hostname(raw string) string {
+	raw = strings.TrimSpace(strings.ToLower(raw))
+	if strings.Contains(raw, "://") {
+		if u, err := url.Parse(raw); err == nil {
+			raw = u.Hostname()
+		}
+	} else if h, _, err := net.SplitHostPort(raw); err == nil {
+		raw = h
+	}
+	raw = strings.TrimPrefix(raw, "*.")
+	return strings.TrimSuffix(raw, ".")
+}
+
+func validLabel(l string) bool {
+	if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+		return false
+	}
+	for _, r := range l {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package domains
+
+import "testing"
+
+func TestRegistrable(t *testing.T) {
+	tests := []struct {
+		host string
+		want string // "" = not registrable
+	}{
+		{host: "example.com", want: "example.com"},
+		{host: "api.eu.example.com", want: "example.com"},
+		{host: "api.eu.example.co.uk", want: "example.co.uk"},
+		{host: "https://Login.Example.COM./path", want: "example.com"},
+		{host: "*.shop.example.de:8443", want: "example.de"},
+		{host: "a.b.example.com.vn", want: "example.com.vn"},
+		{host: "shop.example.co.at", want: "example.co.at"},
+		{host: "x.gov.in", want: "x.gov.in"},
+		{host: "portal.example.gov.in", want: "example.gov.in"},
+		{host: "foo.github.io", want: "github.io"}, // Private-section suffix: registered under .io
+		{host: "bucket.s3.amazonaws.com", want: "amazonaws.com"},
+		{host: "co.uk"},
+		{host: "com"},
+		{host: "localhost"},
+		{host: "db.internal"},
+		{host: "printer.home.arpa"},
+		{host: "192.0.2.1"},
+		{host: "[2001:db8::1]:443"},
+		{host: "v1.2"},
+		{host: "bad_label-.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, ok := Registrable(tt.host)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Fatalf("Registrable(%q) = %q, %v; want %q", tt.host, got, ok, tt.want)
+			}
+		})
+	}
+}
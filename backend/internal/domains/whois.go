@@ -0,0 +1,192 @@
+package domains
+
+import (
+	"bufio"
+	"cert-manager-backend/internal/model"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWHOISServer answers "which server handles this TLD" (the "refer:" line).
+const DefaultWHOISServer = "whois.iana.org"
+
+// maxWHOISResponseSize caps a WHOIS answer (plain text, usually a few KB).
+const maxWHOISResponseSize = 1 << 20
+
+// WHOISClient is the port 43 fallback for TLDs without RDAP. Dial is where the egress guard plugs in.
+type WHOISClient struct {
+	Dial    func(ctx context.Context, network, addr string) (net.Conn, error)
+	Timeout time.Duration
+	Server  string // IANA referral server ("host" or "host:port")
+
+	mu      sync.Mutex
+	servers map[string]string // TLD -> WHOIS server
+}
+
+// NewWHOISClient creates a client dialing directly; server may be empty (IANA).
+func NewWHOISClient(timeout time.Duration, server string) *WHOISClient {
+	if server == "" {
+		server = DefaultWHOISServer
+	}
+	return &WHOISClient{
+		Dial:    (&net.Dialer{Timeout: timeout}).DialContext,
+		Timeout: timeout,
+		Server:  server,
+		servers: map[string]string{},
+	}
+}
+
+// whoisExpiryKeys are the labels registries use for the expiry date (lowercase, most specific first).
+var whoisExpiryKeys = []string{
+	"registry expiry date", "registrar registration expiration date", "domain expiration date",
+	"expiration date", "expiry date", "expiration time", "expires on", "expires", "expire date",
+	"paid-till", "renewal date", "expire",
+}
+
+// whoisDateLayouts covers the formats seen across gTLD and ccTLD registries.
+var whoisDateLayouts = []string{
+	time.RFC3339, "2006-01-02T15:04:05Z", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04:05 MST",
+	"2006-01-02", "2006.01.02", "2006/01/02", "02-Jan-2006", "02.01.2006", "02/01/2006", "January 02 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+}
+
+// Lookup asks the TLD's WHOIS server (found via IANA) about the domain.
+func (c *WHOISClient) Lookup(ctx context.Context, domain string) (*model.DomainLookup, error) {
+	server, err := c.serverFor(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.query(ctx, server, domain)
+	if err != nil {
+		return nil, err
+	}
+	fields := parseWHOIS(resp)
+
+	lower := strings.ToLower(resp)
+	if len(fields) == 0 || strings.Contains(lower, "no match for") || (strings.Contains(lower, "not found") && fields["domain name"] == nil) {
+		return nil, ErrNotFound
+	}
+
+	result := &model.DomainLookup{Source: model.DomainSourceWHOIS}
+	for _, key := range whoisExpiryKeys {
+		if v := fields[key]; v != nil {
+			t, err := parseWHOISDate(v[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid whois %s %q", key, v[0])
+			}
+			result.ExpiresAt = &t
+			break
+		}
+	}
+	for _, key := range []string{"registrar", "sponsoring registrar"} {
+		if v := fields[key]; v != nil && result.Registrar == "" {
+			result.Registrar = v[0]
+		}
+	}
+	for _, key := range []string{"domain status", "status"} {
+		for _, v := range fields[key] {
+			// "clientTransferProhibited https://icann.org/epp#clientTransferProhibited"
+			result.Statuses = append(result.Statuses, strings.Fields(v)[0])
+		}
+	}
+	return result, nil
+}
+
+// serverFor returns (and caches) the WHOIS server of the domain's TLD.
+func (c *WHOISClient) serverFor(ctx context.Context, domain string) (string, error) {
+	tld := domain[strings.LastIndex(domain, ".")+1:]
+
+	c.mu.Lock()
+	server, ok := c.servers[tld]
+	c.mu.Unlock()
+	if ok {
+		return server, nil
+	}
+
+	resp, err := c.query(ctx, c.Server, tld)
+	if err != nil {
+		return "", fmt.Errorf("whois referral: %w", err)
+	}
+	refer := parseWHOIS(resp)["refer"]
+	if refer == nil {
+		refer = parseWHOIS(resp)["whois"]
+	}
+	if refer == nil {
+		return "", fmt.Errorf("no whois server for .%s", tld)
+	}
+
+	c.mu.Lock()
+	c.servers[tld] = refer[0]
+	c.mu.Unlock()
+	return refer[0], nil
+}
+
+// query sends one RFC 3912 request: "<query>\r\n", the server answers and closes.
+func (c *WHOISClient) query(ctx context.Context, server, q string) (string, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "43")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	conn, err := c.Dial(ctx, "tcp", server)
+	if err != nil {
+		return "", fmt.Errorf("whois %s unreachable: %w", server, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write([]byte(q + "\r\n")); err != nil {
+		return "", fmt.Errorf("whois %s: %w", server, err)
+	}
+	raw, err := io.ReadAll(io.LimitReader(conn, maxWHOISResponseSize))
+	if err != nil {
+		return "", fmt.Errorf("whois %s: %w", server, err)
+	}
+	return string(raw), nil
+}
+
+// parseWHOIS collects "Key: Value" lines (lowercase keys, repeated keys keep every value).
+func parseWHOIS(resp string) map[string][]string {
+	fields := map[string][]string{}
+	sc := bufio.NewScanner(strings.NewReader(resp))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ">>>") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		fields[key] = append(fields[key], value)
+	}
+	return fields
+}
+
+func parseWHOISDate(v string) (time.Time, error) {
+	v = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "."))
+	for _, layout := range whoisDateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	// "2026-08-13T04:00:00.0Z", "2026-08-13 (YYYY-MM-DD)"...: retry on the date part
+	if len(v) > 10 {
+		if t, err := time.Parse("2006-01-02", v[:10]); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date")
+}
@@ -0,0 +1,280 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ErrorCode is returned to API clients when a destination is rejected by the policy.
+const ErrorCode = "EGRESS_BLOCKED"
+
+// DefaultDeny lists the ranges scans must never reach unless an admin allows them:
+// loopback, private, link-local (incl. cloud metadata), CGNAT, multicast and reserved space.
+var DefaultDeny = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10", // CGNAT (also Alibaba Cloud metadata 100.100.100.200)
+	"127.0.0.0/8",
+	"169.254.0.0/16", // Link-local: AWS / GCP / Azure / OCI metadata 169.254.169.254
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"168.63.129.16/32", // Azure WireServer
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96", // NAT64: would reach any IPv4 address, including the ones above
+	"fc00::/7",     // Unique local (incl. AWS metadata fd00:ec2::254)
+	"fe80::/10",
+	"ff00::/8",
+}
+
+type rule struct {
+	prefix netip.Prefix
+	allow  bool
+}
+
+// Policy decides which IP addresses scans may connect to.
+// The most specific matching rule wins (a tie goes to deny); addresses no rule matches are allowed.
+// This lets admins open e.g. 10.20.0.0/16 for internal appliances while 10.20.0.1/32 stays denied.
+type Policy struct {
+	rules []rule
+
+	// proxyNames may reach a proxy by name, unresolved here (exact names, or ".zone" for its subdomains)
+	proxyNames []string
+}
+
+// NewPolicy builds a policy from the default deny-list (optional) plus admin CIDRs.
+// Bare IPs are accepted as single-address ranges.
+func NewPolicy(defaultDeny bool, allow, deny []string) (*Policy, error) {
+	p := &Policy{}
+	if defaultDeny {
+		if err := p.add(DefaultDeny, false); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.add(deny, false); err != nil {
+		return nil, fmt.Errorf("deny list: %w", err)
+	}
+	if err := p.add(allow, true); err != nil {
+		return nil, fmt.Errorf("allow list: %w", err)
+	}
+	return p, nil
+}
+
+func (p *Policy) add(cidrs []string, allow bool) error {
+	for _, raw := range cidrs {
+		prefix, err := ParsePrefix(raw)
+		if err != nil {
+			return err
+		}
+		p.rules = append(p.rules, rule{prefix: prefix, allow: allow})
+	}
+	return nil
+}
+
+// ParsePrefix accepts a CIDR block or a bare IP.
+func ParsePrefix(raw string) (netip.Prefix, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, "/") {
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", raw)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", raw)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Allowed reports whether ip may be contacted. A nil Policy allows everything.
+func (p *Policy) Allowed(ip netip.Addr) bool {
+	if p == nil {
+		return true
+	}
+	// "::ffff:127.0.0.1" is 127.0.0.1; zoned addresses ("fe80::1%eth0") never match a prefix otherwise
+	ip = ip.Unmap().WithZone("")
+
+	best, allowed := -1, true
+	for _, r := range p.rules {
+		if !r.prefix.Contains(ip) {
+			continue
+		}
+		if r.prefix.Bits() > best || (r.prefix.Bits() == best && !r.allow) {
+			best, allowed = r.prefix.Bits(), r.allow
+		}
+	}
+	return allowed
+}
+
+// AllowsPrefix reports whether every address of prefix may be contacted (used to vet sweep ranges upfront).
+func (p *Policy) AllowsPrefix(prefix netip.Prefix) bool {
+	if p == nil {
+		return true
+	}
+	prefix = prefix.Masked()
+
+	// 1. The range as a whole: decided by the most specific rule covering all of it.
+	// (An allow for 10.20.0.0/16 says nothing about the rest of 10.20.0.0/15.)
+	if !p.covers(prefix) {
+		return false
+	}
+	// 2. Any narrower rule that denies its own part of the range makes the range partially blocked
+	for _, r := range p.rules {
+		if r.prefix.Bits() > prefix.Bits() && prefix.Contains(r.prefix.Addr()) && !p.covers(r.prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// covers reports the verdict of the most specific rule containing the whole prefix (a tie goes to deny).
+func (p *Policy) covers(prefix netip.Prefix) bool {
+	best, allowed := -1, true
+	for _, r := range p.rules {
+		if r.prefix.Bits() > prefix.Bits() || !r.prefix.Contains(prefix.Addr()) {
+			continue
+		}
+		if r.prefix.Bits() > best || (r.prefix.Bits() == best && !r.allow) {
+			best, allowed = r.prefix.Bits(), r.allow
+		}
+	}
+	return allowed
+}
+
+// BlockedError is returned when a destination resolves only to disallowed addresses.
+type BlockedError struct {
+	Host string
+	IP   netip.Addr
+}
+
+func (e *BlockedError) Error() string {
+	if e.Host == e.IP.String() {
+		return fmt.Sprintf("%s: %s is not allowed by the egress policy", ErrorCode, e.IP)
+	}
+	return fmt.Sprintf("%s: %s resolves to %s, which is not allowed by the egress policy", ErrorCode, e.Host, e.IP)
+}
+
+// IsBlocked reports whether err (or, for aggregated multi-endpoint errors, its message) is a policy rejection.
+func IsBlocked(err error) bool {
+	var blocked *BlockedError
+	return errors.As(err, &blocked) || (err != nil && strings.Contains(err.Error(), ErrorCode))
+}
+
+// Resolver looks up every address behind a hostname. *net.Resolver satisfies it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Resolve returns the allowed addresses of host (an IP or hostname), in resolver order.
+// Callers must connect to one of these IPs rather than the hostname: resolving again
+// would let a DNS rebinding answer swap in a blocked address after the check.
+func (p *Policy) Resolve(ctx context.Context, resolver Resolver, host string) ([]netip.Addr, error) {
+	var candidates []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		candidates = []netip.Addr{ip}
+	} else {
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if ip, ok := netip.AddrFromSlice(a.IP); ok && a.Zone == "" {
+				candidates = append(candidates, ip.Unmap())
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("no addresses found for %s", host)
+		}
+	}
+
+	var allowed []netip.Addr
+	for _, ip := range candidates {
+		if p.Allowed(ip) {
+			allowed = append(allowed, ip)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, &BlockedError{Host: host, IP: candidates[0]}
+	}
+	return allowed, nil
+}
+
+// AllowProxyResolved lists the hostnames a proxy may resolve itself (internal zones only its DNS view knows).
+// Entries are exact names, or ".corp.example" for every name below corp.example.
+// Any other hostname is resolved and vetted here, and the proxy is handed the vetted IP.
+func (p *Policy) AllowProxyResolved(names []string) {
+	p.proxyNames = nil
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), ".")); name != "" {
+			p.proxyNames = append(p.proxyNames, name)
+		}
+	}
+}
+
+// ProxyResolves reports whether host goes to a proxy by name. A nil Policy hands every hostname over.
+func (p *Policy) ProxyResolves(host string) bool {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return false
+	}
+	if p == nil {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, name := range p.proxyNames {
+		if host == name || (strings.HasPrefix(name, ".") && strings.HasSuffix(host, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Vet checks every address host resolves to, for the names a proxy resolves itself (see ProxyResolves).
+// A hostname that doesn't resolve here is left to the proxy's resolver and its own access rules.
+func (p *Policy) Vet(ctx context.Context, resolver Resolver, host string) error {
+	if p == nil {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !p.Allowed(ip) {
+			return &BlockedError{Host: host, IP: ip}
+		}
+		return nil
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil
+		}
+		return err
+	}
+	// The proxy may pick any of them: one blocked address blocks the name
+	for _, a := range addrs {
+		if ip, ok := netip.AddrFromSlice(a.IP); ok && !p.Allowed(ip) {
+			return &BlockedError{Host: host, IP: ip.Unmap()}
+		}
+	}
+	return nil
+}
+
+// ParseList splits a comma separated env value ("10.0.0.0/8, 192.168.1.5").
+func ParseList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestPolicyAllowed(t *testing.T) {
+	p, err := NewPolicy(true, []string{"10.20.0.0/16", "192.168.1.5"}, []string{"10.20.0.1", "203.0.113.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.215.14", want: true},     // Public
+		{ip: "127.0.0.1", want: false},        // Loopback
+		{ip: "::ffff:127.0.0.1", want: false}, // IPv4-mapped loopback
+		{ip: "169.254.169.254", want: false},  // Metadata
+		{ip: "fd00:ec2::254", want: false},    // AWS IPv6 metadata
+		{ip: "10.1.2.3", want: false},         // Default deny
+		{ip: "10.20.3.4", want: true},         // Allowed range inside the default deny
+		{ip: "10.20.0.1", want: false},        // Denied address inside the allowed range
+		{ip: "192.168.1.5", want: true},       // Bare IP allow
+		{ip: "192.168.1.6", want: false},
+		{ip: "203.0.113.7", want: false}, // Admin deny of public space
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := p.Allowed(netip.MustParseAddr(tt.ip)); got != tt.want {
+				t.Fatalf("Allowed(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	var nilPolicy *Policy
+	if !nilPolicy.Allowed(netip.MustParseAddr("127.0.0.1")) {
+		t.Fatal("a nil policy allows everything")
+	}
+}
+
+func TestPolicyAllowsPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		allow  []string
+		deny   []string
+		prefix string
+		want   bool
+	}{
+		{name: "public range", prefix: "93.184.215.0/24", want: true},
+		{name: "private range", prefix: "10.1.0.0/16", want: false},
+		{name: "everything", prefix: "0.0.0.0/0", want: false},
+		{name: "allowed range", allow: []string{"10.20.0.0/16"}, prefix: "10.20.0.0/16", want: true},
+		{name: "inside an allowed range", allow: []string{"10.20.0.0/16"}, prefix: "10.20.5.0/24", want: true},
+		{name: "wider than the allowed range", allow: []string{"10.20.0.0/16"}, prefix: "10.20.0.0/15", want: false},
+		{name: "allow starting at the range address", allow: []string{"10.0.0.0/24"}, prefix: "10.0.0.0/8", want: false},
+		{name: "deny carved inside", allow: []string{"10.20.0.0/16"}, deny: []string{"10.20.9.9"}, prefix: "10.20.0.0/16", want: false},
+		{name: "deny carved elsewhere", allow: []string{"10.20.0.0/16"}, deny: []string{"10.20.9.9"}, prefix: "10.20.5.0/24", want: true},
+		{name: "public range with an admin deny inside", deny: []string{"198.51.100.7"}, prefix: "198.51.100.0/24", want: false},
+		{name: "re-allowed inside a deny inside", allow: []string{"10.20.0.0/16", "10.20.9.0/24"}, deny: []string{"10.20.9.0/24"}, prefix: "10.20.0.0/16", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(true, tt.allow, tt.deny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.AllowsPrefix(netip.MustParsePrefix(tt.prefix)); got != tt.want {
+				t.Fatalf("AllowsPrefix(%s) = %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var out []net.IPAddr
+	for _, a := range addrs {
+		out = append(out, net.IPAddr{IP: net.ParseIP(a)})
+	}
+	return out, nil
+}
+
+func TestPolicyResolveAndVet(t *testing.T) {
+	p, err := NewPolicy(true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := fakeResolver{
+		"public.example.com": {"93.184.215.14"},
+		"mixed.example.com":  {"10.0.0.5", "93.184.215.14"},
+		"rebind.example.com": {"127.0.0.1"},
+	}
+
+	tests := []struct {
+		host        string
+		wantResolve []string // nil: blocked
+		wantVet     bool
+	}{
+		{host: "public.example.com", wantResolve: []string{"93.184.215.14"}, wantVet: true},
+		{host: "mixed.example.com", wantResolve: []string{"93.184.215.14"}, wantVet: false},
+		{host: "rebind.example.com", wantVet: false},
+		{host: "169.254.169.254", wantVet: false},
+		{host: "8.8.8.8", wantResolve: []string{"8.8.8.8"}, wantVet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			ips, err := p.Resolve(context.Background(), resolver, tt.host)
+			if tt.wantResolve == nil {
+				var blocked *BlockedError
+				if !errors.As(err, &blocked) || !IsBlocked(err) {
+					t.Fatalf("Resolve: expected a BlockedError, got %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(ips) != len(tt.wantResolve) || ips[0].String() != tt.wantResolve[0] {
+					t.Fatalf("Resolve = %v, want %v", ips, tt.wantResolve)
+				}
+			}
+
+			if err := p.Vet(context.Background(), resolver, tt.host); (err == nil) != tt.wantVet {
+				t.Fatalf("Vet error = %v, want allowed: %v", err, tt.wantVet)
+			}
+		})
+	}
+
+	// A name only the proxy can resolve is left to the proxy
+	if err := p.Vet(context.Background(), resolver, "internal.corp.example"); err != nil {
+		t.Fatalf("Vet of an unresolvable name: %v", err)
+	}
+}
+
+func TestNewPolicyRejectsInvalidCIDRs(t *testing.T) {
+	if _, err := NewPolicy(false, []string{"10.0.0.0/33"}, nil); err == nil {
+		t.Fatal("expected an error for an invalid allow CIDR")
+	}
+	if _, err := NewPolicy(false, nil, []string{"not-an-ip"}); err == nil {
+		t.Fatal("expected an error for an invalid deny entry")
+	}
+}
+
+func TestPolicyProxyResolves(t *testing.T) {
+	p, err := NewPolicy(true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.AllowProxyResolved([]string{" .Corp.Example ", "intranet.example.", ""})
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "app.corp.example", want: true},
+		{host: "a.b.corp.example", want: true},
+		{host: "APP.CORP.EXAMPLE.", want: true},
+		{host: "intranet.example", want: true},
+		{host: "corp.example", want: false},
+		{host: "evilcorp.example", want: false},
+		{host: "www.intranet.example", want: false},
+		{host: "example.com", want: false},
+		{host: "10.0.0.5", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := p.ProxyResolves(tt.host); got != tt.want {
+				t.Fatalf("ProxyResolves(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+
+	// Without a policy the proxy resolves every name (nothing is enforced)
+	var none *Policy
+	if !none.ProxyResolves("example.com") || none.ProxyResolves("10.0.0.5") {
+		t.Fatal("nil policy: names go by name, IPs by IP")
+	}
+}
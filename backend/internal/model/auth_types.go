@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // User represents a tenant/user in the system
 type User struct {
 	ID           string `json:"id"`
@@ -52,3 +54,37 @@ type ResetPasswordRequest struct {
 type VerifyEmailRequest struct {
 	Token string `json:"token"`
 }
+
+// --- API Keys ---
+
+// APIKeyScope limits what a key may be used for.
+type APIKeyScope string
+
+const (
+	APIKeyScopeIngest APIKeyScope = "ingest" // Agent reports (POST /api/certs) from installs without their own credential
+	APIKeyScopeRead   APIKeyScope = "read"   // The read-only API (GET requests, "Authorization: Bearer <key>")
+)
+
+type APIKey struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Prefix     string      `json:"prefix"` // First characters of the key, to tell keys apart
+	Scope      APIKeyScope `json:"scope"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty"`
+	LastUsedIP string      `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time  `json:"revoked_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string      `json:"name"`
+	Scope         APIKeyScope `json:"scope"`
+	ExpiresInDays int         `json:"expires_in_days"` // 0 = never expires
+}
+
+// CreatedAPIKey is the only response that carries the plaintext key.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"api_key"`
+}
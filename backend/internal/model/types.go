@@ -1,6 +1,11 @@
 package model
 
-import "time"
+import (
+	"crypto/tls"
+	"net"
+	"strconv"
+	"time"
+)
 
 // --- CONSTANTS ---
 
@@ -15,6 +20,17 @@ const (
 	StatusExpiringTomorrow CertStatus = "Expiring Tomorrow"
 	StatusExpiringToday    CertStatus = "Expiring Today"
 	StatusUntrusted        CertStatus = "Untrusted"
+	StatusRevoked          CertStatus = "Revoked"
+	StatusHostnameMismatch CertStatus = "Hostname Mismatch"
+)
+
+// RevocationStatus is the result of the latest OCSP/CRL check. Empty means "not checked".
+type RevocationStatus string
+
+const (
+	RevocationGood    RevocationStatus = "GOOD"
+	RevocationRevoked RevocationStatus = "REVOKED"
+	RevocationUnknown RevocationStatus = "UNKNOWN"
 )
 
 type InstanceStatus string
@@ -24,11 +40,79 @@ const (
 	InstanceMissing InstanceStatus = "MISSING"
 )
 
+// ScanProtocol defines how the agentless scanner reaches the TLS layer of a target.
+// Anything other than ProtocolTLS performs a STARTTLS-style upgrade before the handshake.
+type ScanProtocol string
+
+const (
+	ProtocolTLS      ScanProtocol = "TLS" // Implicit TLS (ClientHello right after TCP connect)
+	ProtocolSMTP     ScanProtocol = "SMTP"
+	ProtocolIMAP     ScanProtocol = "IMAP"
+	ProtocolPOP3     ScanProtocol = "POP3"
+	ProtocolFTP      ScanProtocol = "FTP"
+	ProtocolLDAP     ScanProtocol = "LDAP"
+	ProtocolPostgres ScanProtocol = "POSTGRES"
+	ProtocolMySQL    ScanProtocol = "MYSQL"
+)
+
+// TargetHealth summarizes the recent availability of a Monitored Target (consecutive failed scans).
+type TargetHealth string
+
+const (
+	TargetHealthy  TargetHealth = "HEALTHY"
+	TargetDegraded TargetHealth = "DEGRADED" // Failing, but below the DOWN threshold (may be a blip)
+	TargetDown     TargetHealth = "DOWN"
+)
+
+// TargetHealthChange is the outcome of recording a scan against a target's failure counter.
+type TargetHealthChange struct {
+	Previous            TargetHealth
+	Current             TargetHealth
+	ConsecutiveFailures int
+	RetryAt             *time.Time // Early re-scan of a failing target (nil once it succeeds)
+	// AlertDue: the target is DOWN and its owner hasn't been told within the cooldown,
+	// or it recovered after its owner was told it was DOWN.
+	AlertDue bool
+}
+
+// TargetHealthAlert tells a target's owner that it went DOWN, or recovered.
+type TargetHealthAlert struct {
+	TargetID            string
+	UserID              string
+	TargetURL           string
+	Health              TargetHealth // DOWN, or HEALTHY for a recovery
+	ConsecutiveFailures int
+	LastError           string
+}
+
+// ProxyDirect is the per-target proxy name that bypasses the default proxy (unless the proxy is mandatory).
+const ProxyDirect = "direct"
+
+// TargetKind selects how a Monitored Target is checked.
+type TargetKind string
+
+const (
+	TargetKindTLS TargetKind = "TLS" // TLS handshake with the endpoint (the default)
+	// Documents fetched over HTTPS whose embedded X.509 certificates are monitored (SSO signing keys)
+	TargetKindSAMLMetadata TargetKind = "SAML_METADATA"
+	TargetKindJWKS         TargetKind = "JWKS"
+)
+
+// IsDocument reports whether the target is a fetched document rather than a TLS endpoint.
+func (k TargetKind) IsDocument() bool {
+	return k == TargetKindSAMLMetadata || k == TargetKindJWKS
+}
+
+// SourceTypeMetadata tags certificates embedded in SAML metadata / JWKS documents
+// (Agents report "FILE" / "NETWORK", TLS handshakes "CLOUD").
+const SourceTypeMetadata = "METADATA"
+
 type AgentStatus string
 
 const (
 	StatusAgentOnline  AgentStatus = "Online"
 	StatusAgentOffline AgentStatus = "Offline"
+	StatusAgentRevoked AgentStatus = "Revoked"
 )
 
 // Notification Policies
@@ -50,7 +134,8 @@ const (
 // --- MODELS ---
 
 type AgentReport struct {
-	AgentID      string        `json:"agent_id"`
+	AgentID string `json:"agent_id"`
+	// The agent's own secret (from enrollment). Installs that predate enrollment send an account API key.
 	APIKey       string        `json:"api_key"`
 	Hostname     string        `json:"hostname"`
 	ScannedAt    time.Time     `json:"scanned_at"`
@@ -69,6 +154,94 @@ type Certificate struct {
 	DNSNames      []string  `json:"dns_names"`
 	IsTrusted     bool      `json:"is_trusted"`
 	TrustError    string    `json:"trust_error,omitempty"`
+
+	// Other Subject Alternative Names (optional: older agents only report dns_names)
+	IPAddresses    []string `json:"ip_addresses,omitempty"`
+	EmailAddresses []string `json:"email_addresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+
+	// Public Key: Algorithm ("RSA", "ECDSA", "Ed25519"), size in bits, curve (EC only) and SPKI fingerprint
+	KeyAlgo    string `json:"key_algo,omitempty"`
+	KeySize    int    `json:"key_size,omitempty"`
+	KeyCurve   string `json:"key_curve,omitempty"`
+	SPKISHA256 string `json:"spki_sha256,omitempty"`
+
+	// Hostname check (Leaf of network scans only): the scanned host / SNI is not covered by the SANs.
+	HostnameMismatch bool   `json:"hostname_mismatch"`
+	HostnameError    string `json:"hostname_error,omitempty"`
+
+	// Position in the served chain: 0 = Leaf, 1..n = Intermediates (and Root, if sent).
+	// Certs sharing a SourceUID form one chain.
+	ChainPosition int `json:"chain_position"`
+
+	// Set when the target is scanned per resolved address ("Scan all addresses" mode)
+	ResolvedIP string `json:"resolved_ip,omitempty"`
+
+	// Revocation (OCSP / CRL). OCSPStapled reports whether the server stapled a response in the handshake.
+	RevocationStatus      RevocationStatus `json:"revocation_status,omitempty"`
+	RevocationReason      string           `json:"revocation_reason,omitempty"`
+	RevokedAt             *time.Time       `json:"revoked_at,omitempty"`
+	RevocationSource      string           `json:"revocation_source,omitempty"` // "OCSP", "OCSP_STAPLE", "CRL"
+	OCSPStapled           bool             `json:"ocsp_stapled"`
+	CRLDistributionPoints []string         `json:"crl_distribution_points,omitempty"`
+
+	// Certificate Transparency (Leaf only): SCTs embedded by the CA and/or delivered in the TLS handshake.
+	// PubliclyTrusted (chains to a system root) makes the cert subject to the CT policy.
+	SCTs            []SCT `json:"scts,omitempty"`
+	PubliclyTrusted bool  `json:"publicly_trusted,omitempty"`
+
+	// Leaf of network scans only: the server sent a CertificateRequest (mTLS endpoint)
+	ClientCertRequested bool `json:"client_cert_requested,omitempty"`
+
+	// DER encoding (base64 in JSON). The backend derives the fingerprints from it; without it,
+	// FingerprintSHA256 alone still identifies the certificate.
+	RawDER            []byte `json:"raw_der,omitempty"`
+	FingerprintSHA256 string `json:"fingerprint_sha256,omitempty"`
+
+	// Leaf of network scans only: how long the scan's connection took, phase by phase
+	Timing *ScanTiming `json:"-"`
+}
+
+// ScanTiming breaks down a network scan's connection. DNS is zero when dialing an IP.
+// Connect includes any proxy negotiation.
+type ScanTiming struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	Handshake time.Duration
+	RemoteIP  string
+}
+
+// SCT is a Signed Certificate Timestamp: a CT log's promise to publish the certificate.
+type SCT struct {
+	LogID     string    `json:"log_id"` // Base64 SHA-256 of the log's public key
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"` // "EMBEDDED" or "TLS"
+}
+
+const (
+	SCTSourceEmbedded = "EMBEDDED"
+	SCTSourceTLS      = "TLS"
+)
+
+// Weak-crypto finding codes (see cryptostrength.Evaluate)
+const (
+	CryptoFindingWeakRSAKey      = "WEAK_RSA_KEY"     // RSA < 2048 bits
+	CryptoFindingWeakSignature   = "WEAK_SIGNATURE"   // SHA-1 / MD5 signature
+	CryptoFindingDeprecatedCurve = "DEPRECATED_CURVE" // EC curve below 128-bit security
+)
+
+// RevocationCheck is a stored certificate queued for a CRL lookup, and the result of that lookup.
+type RevocationCheck struct {
+	CertificateID string
+	Serial        string
+	Issuer        DN
+	IssuerDER     []byte // The issuer certificate, to verify the CRL's signature with
+	CRLURLs       []string
+
+	Status    RevocationStatus
+	Reason    string
+	RevokedAt *time.Time
+	Source    string
 }
 
 type DN struct {
@@ -92,19 +265,406 @@ type CertResponse struct {
 	TrustError    string     `json:"trust_error,omitempty"`
 	Status        CertStatus `json:"status"`
 
+	// Hostname check (Distinct from chain trust)
+	HostnameMismatch bool   `json:"hostname_mismatch"`
+	HostnameError    string `json:"hostname_error,omitempty"`
+
+	// Revocation
+	RevocationStatus RevocationStatus `json:"revocation_status,omitempty"`
+	RevocationReason string           `json:"revocation_reason,omitempty"`
+	RevokedAt        *time.Time       `json:"revoked_at,omitempty"`
+	RevocationSource string           `json:"revocation_source,omitempty"`
+	OCSPStapled      bool             `json:"ocsp_stapled"`
+
+	ResolvedIP string `json:"resolved_ip,omitempty"`
+
+	SANs SubjectAltNames `json:"sans"`
+
+	// Fingerprints of the DER encoding (empty for certs reported before DER collection)
+	FingerprintSHA256 string `json:"fingerprint_sha256,omitempty"`
+	FingerprintSHA1   string `json:"fingerprint_sha1,omitempty"`
+
+	// Public Key & Crypto Strength
+	SignatureAlgo  string   `json:"signature_algo,omitempty"`
+	KeyAlgo        string   `json:"key_algo,omitempty"`
+	KeySize        int      `json:"key_size,omitempty"`
+	KeyCurve       string   `json:"key_curve,omitempty"`
+	SPKISHA256     string   `json:"spki_sha256,omitempty"`
+	CryptoFindings []string `json:"crypto_findings,omitempty"`
+
+	// Certificate Transparency: CTCompliant is only set for publicly trusted leaves.
+	SCTCount    int   `json:"sct_count"`
+	CTRequired  int   `json:"ct_required_scts,omitempty"`
+	CTCompliant *bool `json:"ct_compliant,omitempty"`
+	SCTs        []SCT `json:"scts,omitempty"` // Detail view only
+
+	// Chain Context: intermediates point back to their leaf instance.
+	ChainPosition  int            `json:"chain_position"`
+	LeafInstanceID string         `json:"leaf_instance_id,omitempty"`
+	Chain          []CertResponse `json:"chain,omitempty"` // Populated on leaves only
+
 	// The Link to the User.
 	OwnerID string `json:"owner_id"`
 }
 
+// SubjectAltNames are a certificate's SANs, normalized on ingestion: lowercase DNS names without a
+// trailing dot, canonical IPs, emails with a lowercase domain, URIs with a lowercase scheme and host.
+type SubjectAltNames struct {
+	DNSNames       []string `json:"dns_names,omitempty"`
+	IPAddresses    []string `json:"ip_addresses,omitempty"`
+	EmailAddresses []string `json:"email_addresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+}
+
 type Target struct {
-	ID             string    `json:"id"`
-	UserID         string    `json:"user_id,omitempty"`
-	TargetURL      string    `json:"target_url"`
-	FrequencyHours int       `json:"frequency_hours"`
-	LastScannedAt  time.Time `json:"last_scanned_at,omitempty"`
-	Status         string    `json:"last_status,omitempty"`
-	LastError      string    `json:"last_error,omitempty"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID             string       `json:"id"`
+	UserID         string       `json:"user_id,omitempty"`
+	TargetURL      string       `json:"target_url"` // "host:port", or the full https:// URL of a document kind
+	Kind           TargetKind   `json:"kind"`
+	Protocol       ScanProtocol `json:"protocol"`
+	FrequencyHours int          `json:"frequency_hours"`
+
+	// Optional overrides: SNI / hostname to verify, host or IP to dial, and extra ports of the same host.
+	// Empty ServerName / ConnectAddress default to the host of TargetURL.
+	ServerName     string `json:"server_name,omitempty"`
+	ConnectAddress string `json:"connect_address,omitempty"`
+	Ports          []int  `json:"ports,omitempty"`
+
+	// Egress route for the scan: "" = the server's default proxy, "direct", or a configured proxy name
+	Proxy string `json:"proxy,omitempty"`
+
+	// Scan every resolved A/AAAA address (Load Balancers / CDNs) instead of the first one
+	ScanAllAddresses bool `json:"scan_all_addresses"`
+	// Set when nodes behind the hostname serve different leaf certificates
+	NodesDisagree  bool   `json:"nodes_disagree"`
+	NodeMismatches string `json:"node_mismatches,omitempty"`
+	// Endpoints / nodes the last (otherwise successful) scan couldn't reach: "addr: error; ..."
+	UnreachableNodes string `json:"unreachable_nodes,omitempty"`
+
+	// PEM bundle of the owner's private CAs (Trust Store). Loaded for scanning only.
+	CustomRootsPEM []byte `json:"-"`
+
+	// mTLS: Client certificate presented during the handshake (decrypted for scanning only).
+	// RequiresClientCert is detected by the scanner (the server sent a CertificateRequest).
+	ClientCert          *tls.Certificate `json:"-"`
+	ClientCertPEM       string           `json:"-"` // Input only (AddTarget): never loaded back
+	ClientKeyPEM        string           `json:"-"` // Input only (AddTarget): never loaded back
+	HasClientCert       bool             `json:"has_client_cert"`
+	ClientCertSubject   string           `json:"client_cert_subject,omitempty"`
+	ClientCertExpiresAt *time.Time       `json:"client_cert_expires_at,omitempty"`
+	RequiresClientCert  bool             `json:"requires_client_cert"`
+
+	LastScannedAt time.Time `json:"last_scanned_at,omitempty"`
+	Status        string    `json:"last_status,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+
+	// Availability: HEALTHY -> DEGRADED -> DOWN as consecutive scans fail. Failing targets are re-scanned at RetryAt.
+	Health              TargetHealth `json:"health"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"`
+	CreatedAt           time.Time    `json:"created_at"`
+
+	// Latest TLS posture audit. Loaded for scanning only: the worker re-audits on its own, slower schedule.
+	PostureAuditedAt *time.Time `json:"-"`
+}
+
+// TargetScanResult is one entry of a Monitored Target's scan history.
+// Durations are in milliseconds and only set for successful scans (DNS: only when dialing a hostname).
+type TargetScanResult struct {
+	ScannedAt         time.Time `json:"scanned_at"`
+	Outcome           string    `json:"outcome"`               // "SUCCESS" or "FAILED"
+	ErrorClass        string    `json:"error_class,omitempty"` // One of the ScanError* classes
+	Error             string    `json:"error,omitempty"`
+	DNSMs             *float64  `json:"dns_ms,omitempty"`
+	ConnectMs         *float64  `json:"connect_ms,omitempty"`
+	HandshakeMs       *float64  `json:"handshake_ms,omitempty"`
+	ResolvedIP        string    `json:"resolved_ip,omitempty"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256,omitempty"` // Leaf served by the primary endpoint
+}
+
+// Error classes of failed scans (Scan History)
+const (
+	ScanErrorDNS         = "DNS"
+	ScanErrorTimeout     = "TIMEOUT"
+	ScanErrorRefused     = "REFUSED"
+	ScanErrorUnreachable = "UNREACHABLE"
+	ScanErrorProxy       = "PROXY"
+	ScanErrorStartTLS    = "STARTTLS"
+	ScanErrorTLS         = "TLS"
+	ScanErrorBlocked     = "EGRESS_BLOCKED"
+	ScanErrorOther       = "OTHER"
+)
+
+// LatencyPercentiles summarizes a duration series (milliseconds).
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// TargetStats is the availability & latency of a Monitored Target over a time window.
+type TargetStats struct {
+	TargetID      string    `json:"target_id"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Scans         int       `json:"scans"`
+	Successes     int       `json:"successes"`
+	UptimePercent *float64  `json:"uptime_percent"` // null when the window holds no scans
+
+	// Successful scans only; null when there are none
+	DNS       *LatencyPercentiles `json:"dns_ms"`
+	Connect   *LatencyPercentiles `json:"connect_ms"`
+	Handshake *LatencyPercentiles `json:"handshake_ms"`
+
+	ErrorClasses map[string]int     `json:"error_classes"`
+	Results      []TargetScanResult `json:"results"` // Oldest first (capped, newest kept)
+}
+
+// TrustedRoot is a private CA (root or intermediate) uploaded to a user's Trust Store.
+// Cloud scans verify chains against the system roots plus these certificates.
+type TrustedRoot struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	Subject           DN        `json:"subject"`
+	Issuer            DN        `json:"issuer"`
+	IsCA              bool      `json:"is_ca"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+	ValidUntil        time.Time `json:"valid_until"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// DiscoveryStatus is the lifecycle of a Discovery Job.
+type DiscoveryStatus string
+
+const (
+	DiscoveryPending   DiscoveryStatus = "PENDING"
+	DiscoveryRunning   DiscoveryStatus = "RUNNING"
+	DiscoveryCompleted DiscoveryStatus = "COMPLETED"
+	DiscoveryCancelled DiscoveryStatus = "CANCELLED"
+	DiscoveryFailed    DiscoveryStatus = "FAILED"
+)
+
+// DiscoveryJob sweeps CIDR blocks x ports for TLS endpoints.
+// Every endpoint that completes a handshake is ingested; with AddTargets it also becomes a Monitored Target.
+type DiscoveryJob struct {
+	ID           string          `json:"id"`
+	UserID       string          `json:"user_id,omitempty"`
+	CIDRs        []string        `json:"cidrs"`
+	Ports        []int           `json:"ports"`
+	AddTargets   bool            `json:"add_targets"`
+	Status       DiscoveryStatus `json:"status"`
+	AddressCount int             `json:"address_count"`
+	TotalProbes  int             `json:"total_probes"` // AddressCount x len(Ports)
+	ProbesDone   int             `json:"probes_done"`
+	Found        int             `json:"found"`
+	Error        string          `json:"error,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	FinishedAt   *time.Time      `json:"finished_at,omitempty"`
+
+	// Detail view only
+	Results []DiscoveryResult `json:"results,omitempty"`
+}
+
+// DiscoveryResult is an endpoint found by a Discovery Job.
+type DiscoveryResult struct {
+	Address    string       `json:"address"` // "ip:port"
+	Protocol   ScanProtocol `json:"protocol"`
+	SubjectCN  string       `json:"subject_cn"`
+	IssuerCN   string       `json:"issuer_cn"`
+	ValidUntil time.Time    `json:"valid_until"`
+	TargetID   string       `json:"target_id,omitempty"` // Set once promoted to a Monitored Target
+	FoundAt    time.Time    `json:"found_at"`
+}
+
+// ResponderKind selects what a Responder Target checks: a CRL distribution point or an OCSP responder.
+type ResponderKind string
+
+const (
+	ResponderCRL  ResponderKind = "CRL"
+	ResponderOCSP ResponderKind = "OCSP"
+)
+
+// ResponderTarget monitors the revocation infrastructure of a (private) CA.
+// CRL: the list at URL is fetched and its freshness tracked. OCSP: URL is asked for the status of SerialNumber.
+// Health is DEGRADED when a CRL nears nextUpdate or a signer nears expiry, DOWN when it is stale or unreachable.
+type ResponderTarget struct {
+	ID               string        `json:"id"`
+	UserID           string        `json:"user_id,omitempty"`
+	Kind             ResponderKind `json:"kind"`
+	URL              string        `json:"url"`
+	IssuerPEM        string        `json:"-"` // CA that signs the CRL / OCSP responses (input & probing only)
+	IssuerSubject    string        `json:"issuer_subject"`
+	SerialNumber     string        `json:"serial_number,omitempty"` // OCSP only: decimal serial of a known certificate
+	FrequencyMinutes int           `json:"frequency_minutes"`
+
+	Health        TargetHealth `json:"health"`
+	Problems      []string     `json:"problems,omitempty"`
+	LastCheckedAt *time.Time   `json:"last_checked_at,omitempty"`
+	LastError     string       `json:"last_error,omitempty"`
+
+	// Last successful probe
+	ResponderCheck
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResponderCheck is the outcome of one CRL fetch / OCSP query.
+type ResponderCheck struct {
+	LatencyMs       *float64   `json:"latency_ms,omitempty"`
+	ThisUpdate      *time.Time `json:"this_update,omitempty"`
+	NextUpdate      *time.Time `json:"next_update,omitempty"`
+	SignerSubject   string     `json:"signer_subject,omitempty"` // The CA, or the OCSP delegated responder
+	SignerExpiresAt *time.Time `json:"signer_expires_at,omitempty"`
+
+	// CRL only
+	CRLNumber    string `json:"crl_number,omitempty"`
+	SizeBytes    int    `json:"size_bytes,omitempty"`
+	RevokedCount int    `json:"revoked_count,omitempty"`
+
+	// OCSP only
+	OCSPStatus RevocationStatus `json:"ocsp_status,omitempty"`
+	ProducedAt *time.Time       `json:"produced_at,omitempty"`
+}
+
+// DomainRegistration tracks when a registrable domain (e.g. "example.co.uk") lapses at its registry.
+// Domains are derived from the owner's Monitored Targets and certificate hostnames; one row per owner,
+// while the lookup itself is shared (see DomainLookup).
+type DomainRegistration struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id,omitempty"`
+	Domain    string     `json:"domain"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil until a lookup succeeds (some ccTLDs never publish it)
+	Registrar string     `json:"registrar,omitempty"`
+	Statuses  []string   `json:"statuses,omitempty"` // EPP statuses, e.g. "clientTransferProhibited"
+	Source    string     `json:"source,omitempty"`   // "RDAP" or "WHOIS"
+	Status    CertStatus `json:"status,omitempty"`   // Same expiry buckets as certificates
+
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// DomainLookup is the registry's answer for one domain.
+type DomainLookup struct {
+	ExpiresAt *time.Time
+	Registrar string
+	Statuses  []string
+	Source    string
+}
+
+const (
+	DomainSourceRDAP  = "RDAP"
+	DomainSourceWHOIS = "WHOIS"
+)
+
+// TLSPosture is the result of probing how a target speaks TLS (versions, cipher suites).
+type TLSPosture struct {
+	TargetID  string              `json:"target_id"`
+	ScannedAt time.Time           `json:"scanned_at"`
+	Grade     string              `json:"grade"` // "A" (best) .. "F"
+	Versions  []TLSVersionSupport `json:"versions"`
+	Findings  []PostureFinding    `json:"findings"`
+	Coverage  string              `json:"coverage"` // What the probes can't detect (suites the scanner can't offer)
+}
+
+// TLSVersionSupport records whether a protocol version is accepted and with which suites.
+type TLSVersionSupport struct {
+	Version      string   `json:"version"` // e.g. "TLS 1.2"
+	Supported    bool     `json:"supported"`
+	CipherSuites []string `json:"cipher_suites,omitempty"`
+}
+
+type FindingSeverity string
+
+const (
+	SeverityLow      FindingSeverity = "LOW"
+	SeverityMedium   FindingSeverity = "MEDIUM"
+	SeverityHigh     FindingSeverity = "HIGH"
+	SeverityCritical FindingSeverity = "CRITICAL"
+)
+
+// PostureFinding is a single weak-configuration issue found during the audit.
+type PostureFinding struct {
+	Code     string          `json:"code"` // e.g. "LEGACY_TLS", "RC4"
+	Severity FindingSeverity `json:"severity"`
+	Message  string          `json:"message"`
+}
+
+// NodeSourceUID is the SourceUID of a single node when a target is scanned per resolved address.
+// Format: "host:port@ip#<target id>". See Target.Endpoints for how a target maps to SourceUIDs.
+func NodeSourceUID(ep Endpoint, ip string) string {
+	return ep.BaseUID + "@" + ip + TargetUIDSuffix(ep.TargetID)
+}
+
+// TargetUIDSuffix ends every SourceUID of a target except its plain one, so that targets reaching
+// the same endpoint (another SNI, connect address or port list of the same host) never share instances.
+func TargetUIDSuffix(targetID string) string {
+	return "#" + targetID
+}
+
+// UnreachableNode is an endpoint, or a node behind it, that a partially successful scan couldn't reach.
+type UnreachableNode struct {
+	SourceUID string
+	Addr      string
+	Error     string
+}
+
+// Endpoint is a single "connect here, present this SNI" combination of a Target.
+type Endpoint struct {
+	Addr       string // "host:port" to dial
+	ServerName string // SNI & hostname verification
+	BaseUID    string // "servername:port"
+	TargetID   string
+	SourceUID  string // TargetURL for the plain endpoint, else "servername:port[@connect]#<target id>"
+}
+
+// Endpoints expands a Target into one Endpoint per port (TargetURL's port first).
+// TargetURL must be in normalized "host:port" format.
+// Only the plain endpoint (TargetURL's port, no SNI or connect override) keeps the bare "host:port" SourceUID:
+// targets are unique per (TargetURL, ServerName, ConnectAddress), so no other target produces it.
+func (t Target) Endpoints() []Endpoint {
+	host, port, err := net.SplitHostPort(t.TargetURL)
+	if err != nil {
+		// Fallback: If split fails (e.g. no port), assume target is just the host
+		host, port = t.TargetURL, "443"
+	}
+
+	serverName := host
+	if t.ServerName != "" {
+		serverName = t.ServerName
+	}
+	dialHost := host
+	if t.ConnectAddress != "" {
+		dialHost = t.ConnectAddress
+	}
+
+	ports := []string{port}
+	for _, p := range t.Ports {
+		if ps := strconv.Itoa(p); ps != port {
+			ports = append(ports, ps)
+		}
+	}
+
+	endpoints := make([]Endpoint, 0, len(ports))
+	for i, p := range ports {
+		ep := Endpoint{
+			Addr:       net.JoinHostPort(dialHost, p),
+			ServerName: serverName,
+			BaseUID:    net.JoinHostPort(serverName, p),
+			TargetID:   t.ID,
+		}
+		ep.SourceUID = ep.BaseUID
+		if dialHost != serverName {
+			// Same SNI, different server (e.g. origin IP): keep its instances apart
+			ep.SourceUID += "@" + dialHost
+		}
+		if i > 0 || t.ServerName != "" || t.ConnectAddress != "" {
+			ep.SourceUID += TargetUIDSuffix(t.ID)
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints
 }
 
 type PaginatedCerts struct {
@@ -122,6 +682,72 @@ type AgentResponse struct {
 	IsVirtual  bool        `json:"is_virtual"`
 	Status     AgentStatus `json:"status"`
 	CertCount  int         `json:"cert_count"`
+	Enrolled   bool        `json:"enrolled"` // Has its own credential (FALSE = legacy user-wide key)
+	RevokedAt  *time.Time  `json:"revoked_at,omitempty"`
+}
+
+// AgentEnrollmentToken is shown once, when created. install.sh exchanges it for the agent's own credential.
+type AgentEnrollmentToken struct {
+	Token     string    `json:"enrollment_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type AgentEnrollRequest struct {
+	EnrollmentToken string `json:"enrollment_token"`
+	Hostname        string `json:"hostname"`
+}
+
+// AgentCredential is the result of an enrollment: the agent reports as AgentID, authenticated by AgentSecret.
+// Only the secret's hash is stored.
+type AgentCredential struct {
+	AgentID     string `json:"agent_id"`
+	AgentSecret string `json:"agent_secret"`
+}
+
+// ReplacementKind classifies a new certificate showing up at the same source.
+type ReplacementKind string
+
+const (
+	ReplacementRenewal ReplacementKind = "RENEWAL" // Same subject & SANs, later expiry
+	ReplacementSwap    ReplacementKind = "SWAP"    // Anything else
+)
+
+// CertReplacement is one change of the leaf served at an instance's source.
+type CertReplacement struct {
+	ID             string               `json:"id"`
+	InstanceID     string               `json:"instance_id"`
+	Kind           ReplacementKind      `json:"kind"`
+	ReplacedAt     time.Time            `json:"replaced_at"`
+	AlertsResolved int                  `json:"alerts_resolved"` // Alerts of the old certificate closed by a renewal
+	Old            *ReplacedCertificate `json:"old"`             // nil once the definition is deleted
+	New            *ReplacedCertificate `json:"new"`
+}
+
+// AlertResolution tells why an alert no longer applies.
+type AlertResolution string
+
+const (
+	AlertResolutionRenewed AlertResolution = "RENEWED" // The source now serves a renewal of the certificate
+)
+
+// AlertRecord is one alert sent for the certificate at an instance.
+type AlertRecord struct {
+	ID         string          `json:"id"`
+	AlertType  AlertType       `json:"alert_type"`
+	SentAt     time.Time       `json:"sent_at"`
+	ResolvedAt *time.Time      `json:"resolved_at,omitempty"`
+	Resolution AlertResolution `json:"resolution,omitempty"` // Empty while the alert is open
+}
+
+type ReplacedCertificate struct {
+	ID                string    `json:"id"`
+	Serial            string    `json:"serial"`
+	Subject           DN        `json:"subject"`
+	Issuer            DN        `json:"issuer"`
+	ValidFrom         time.Time `json:"valid_from"`
+	ValidUntil        time.Time `json:"valid_until"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256,omitempty"`
+	DNSNames          []string  `json:"dns_names,omitempty"`
 }
 
 // DashboardStats holds the counts for the summary cards
@@ -132,4 +758,13 @@ type DashboardStats struct {
 	TotalAgents   int `json:"total_agents"`
 	OnlineAgents  int `json:"online_agents"`
 	OfflineAgents int `json:"offline_agents"`
+
+	// Weak Crypto: certs with at least one finding, and the count per finding code
+	WeakCrypto     int            `json:"weak_crypto"`
+	CryptoFindings map[string]int `json:"crypto_findings"`
+
+	// Domain Registrations (same 30 day window as certificates)
+	TotalDomains        int `json:"total_domains"`
+	DomainsExpiringSoon int `json:"domains_expiring_soon"`
+	DomainsExpired      int `json:"domains_expired"`
 }
@@ -0,0 +1,65 @@
+package model
+
+import "testing"
+
+func TestTargetEndpointsSourceUIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		want   []string
+	}{
+		{
+			name:   "plain target keeps host:port",
+			target: Target{ID: "t1", TargetURL: "example.com:443"},
+			want:   []string{"example.com:443"},
+		},
+		{
+			name:   "extra ports are qualified",
+			target: Target{ID: "t1", TargetURL: "example.com:443", Ports: []int{443, 8443}},
+			want:   []string{"example.com:443", "example.com:8443#t1"},
+		},
+		{
+			name:   "connect address",
+			target: Target{ID: "t2", TargetURL: "example.com:443", ConnectAddress: "1.2.3.4"},
+			want:   []string{"example.com:443@1.2.3.4#t2"},
+		},
+		{
+			name:   "server name override",
+			target: Target{ID: "t3", TargetURL: "lb.example.com:443", ServerName: "example.com"},
+			want:   []string{"example.com:443@lb.example.com#t3"},
+		},
+		{
+			name:   "explicit server name equal to the host",
+			target: Target{ID: "t4", TargetURL: "example.com:443", ServerName: "example.com"},
+			want:   []string{"example.com:443#t4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eps := tt.target.Endpoints()
+			if len(eps) != len(tt.want) {
+				t.Fatalf("got %d endpoints, want %d", len(eps), len(tt.want))
+			}
+			for i, ep := range eps {
+				if ep.SourceUID != tt.want[i] {
+					t.Errorf("endpoint %d: SourceUID = %q, want %q", i, ep.SourceUID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// Targets reaching the same node must never share a SourceUID
+func TestNodeSourceUIDDistinctPerTarget(t *testing.T) {
+	scanAll := Target{ID: "a", TargetURL: "example.com:443", ScanAllAddresses: true}
+	viaIP := Target{ID: "b", TargetURL: "example.com:443", ConnectAddress: "1.2.3.4"}
+
+	node := NodeSourceUID(scanAll.Endpoints()[0], "1.2.3.4")
+	if node == viaIP.Endpoints()[0].SourceUID {
+		t.Fatalf("scan-all node and connect-address target share %q", node)
+	}
+	if node != "example.com:443@1.2.3.4#a" {
+		t.Fatalf("NodeSourceUID = %q", node)
+	}
+}
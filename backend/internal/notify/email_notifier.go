@@ -6,6 +6,7 @@ import (
 	"cert-manager-backend/internal/service"
 	"context"
 	"fmt"
+	"html"
 	"log"
 	"net/smtp"
 	"strings"
@@ -80,6 +81,99 @@ func (e *EmailNotifier) Notify(ctx context.Context, certs []model.CertResponse,
 	return nil
 }
 
+// NotifyResponders implements the service.ResponderNotifier interface.
+// Deduplication is done by the caller (per target cooldown), so every target passed in is sent.
+func (e *EmailNotifier) NotifyResponders(ctx context.Context, targets []model.ResponderTarget, users map[string]model.User) error {
+	// 1. Group by Owner
+	buckets := make(map[string][]model.ResponderTarget)
+	for _, t := range targets {
+		buckets[t.UserID] = append(buckets[t.UserID], t)
+	}
+
+	// 2. Send
+	for ownerID, userTargets := range buckets {
+		user, exists := users[ownerID]
+		if !exists || user.Email == "" || !user.EmailEnabled {
+			continue
+		}
+
+		subject := fmt.Sprintf("Action Required: %d Revocation Endpoints Failing", len(userTargets))
+		body := e.buildResponderAlertHTML(user, userTargets)
+
+		if err := e.sendSMTP(user.Email, subject, body); err != nil {
+			log.Printf("❌ [EmailNotifier] Failed to send responder alert to %s: %v", user.Email, err)
+		} else {
+			log.Printf("✅ [EmailNotifier] Sent responder alert to %s", user.Email)
+		}
+	}
+	return nil
+}
+
+// NotifyTargetHealth implements the service.TargetHealthNotifier interface.
+// Deduplication is done by the caller (per target cooldown), so every alert passed in is sent.
+func (e *EmailNotifier) NotifyTargetHealth(ctx context.Context, alerts []model.TargetHealthAlert, users map[string]model.User) error {
+	// 1. Group by Owner
+	buckets := make(map[string][]model.TargetHealthAlert)
+	for _, a := range alerts {
+		buckets[a.UserID] = append(buckets[a.UserID], a)
+	}
+
+	// 2. Send
+	for ownerID, userAlerts := range buckets {
+		user, exists := users[ownerID]
+		if !exists || user.Email == "" || !user.EmailEnabled {
+			continue
+		}
+
+		down := 0
+		for _, a := range userAlerts {
+			if a.Health == model.TargetDown {
+				down++
+			}
+		}
+		subject := fmt.Sprintf("Action Required: %d Monitored Targets Down", down)
+		if down == 0 {
+			subject = fmt.Sprintf("Resolved: %d Monitored Targets Recovered", len(userAlerts))
+		}
+		body := e.buildTargetHealthAlertHTML(user, userAlerts)
+
+		if err := e.sendSMTP(user.Email, subject, body); err != nil {
+			log.Printf("❌ [EmailNotifier] Failed to send target health alert to %s: %v", user.Email, err)
+		} else {
+			log.Printf("✅ [EmailNotifier] Sent target health alert to %s", user.Email)
+		}
+	}
+	return nil
+}
+
+// NotifyDomains implements the service.DomainNotifier interface.
+// Deduplication is done by the caller (per domain cooldown), so every domain passed in is sent.
+func (e *EmailNotifier) NotifyDomains(ctx context.Context, domains []model.DomainRegistration, users map[string]model.User) error {
+	// 1. Group by Owner
+	buckets := make(map[string][]model.DomainRegistration)
+	for _, d := range domains {
+		buckets[d.UserID] = append(buckets[d.UserID], d)
+	}
+
+	// 2. Send
+	for ownerID, userDomains := range buckets {
+		user, exists := users[ownerID]
+		if !exists || user.Email == "" || !user.EmailEnabled {
+			continue
+		}
+
+		subject := fmt.Sprintf("Action Required: %d Domains Expiring Soon", len(userDomains))
+		body := e.buildDomainAlertHTML(user, userDomains)
+
+		if err := e.sendSMTP(user.Email, subject, body); err != nil {
+			log.Printf("❌ [EmailNotifier] Failed to send domain alert to %s: %v", user.Email, err)
+		} else {
+			log.Printf("✅ [EmailNotifier] Sent domain alert to %s", user.Email)
+		}
+	}
+	return nil
+}
+
 // --- PART 2: EmailService Interface (Auth) ---
 
 func (e *EmailNotifier) SendVerificationEmail(toEmail, token string) error {
@@ -160,7 +254,7 @@ func (e *EmailNotifier) buildAlertHTML(user model.User, certs []model.CertRespon
 	var sb strings.Builder
 	sb.WriteString("<html><body style='font-family: Arial, sans-serif; color: #333;'>")
 	sb.WriteString(fmt.Sprintf("<h3>Hello %s,</h3>", user.OrgName))
-	sb.WriteString(fmt.Sprintf("<p>The following <strong>%d certificates</strong> are expiring soon:</p>", len(certs)))
+	sb.WriteString(fmt.Sprintf("<p>The following <strong>%d certificates</strong> are expiring soon or failing validation:</p>", len(certs)))
 
 	sb.WriteString("<table border='1' cellpadding='10' cellspacing='0' style='border-collapse: collapse; width: 100%; border-color: #ddd;'>")
 	sb.WriteString("<tr style='background-color: #f8f9fa; text-align: left;'><th>Host</th><th>Certificate</th><th>Expires</th></tr>")
@@ -180,7 +274,114 @@ func (e *EmailNotifier) buildAlertHTML(user model.User, certs []model.CertRespon
 			e.frontendURL, cert.AgentHostname, cert.SourceUID,
 		))
 		sb.WriteString(fmt.Sprintf("<td>CN=%s<br/><small>Issuer: %s</small></td>", cert.Subject.CN, cert.Issuer.CN))
-		sb.WriteString(fmt.Sprintf("<td><b style='color:%s'>%s</b><br/><small>%d days left</small></td>", color, cert.ValidUntil.Format("2006-01-02"), daysLeft))
+		if cert.Status == model.StatusRevoked {
+			// Critical: Revoked certs are broken now, regardless of their expiry date
+			sb.WriteString(fmt.Sprintf("<td><b style='color:#dc3545'>REVOKED</b><br/><small>%s</small></td>", cert.RevocationReason))
+		} else if cert.Status == model.StatusHostnameMismatch {
+			// The cert itself may be fine, but it is served for the wrong host
+			sb.WriteString(fmt.Sprintf("<td><b style='color:#dc3545'>HOSTNAME MISMATCH</b><br/><small>%s</small></td>", cert.HostnameError))
+		} else {
+			sb.WriteString(fmt.Sprintf("<td><b style='color:%s'>%s</b><br/><small>%d days left</small></td>", color, cert.ValidUntil.Format("2006-01-02"), daysLeft))
+		}
+		sb.WriteString("</tr>")
+	}
+	sb.WriteString("</table></body></html>")
+	return sb.String()
+}
+
+// buildResponderAlertHTML generates the table for CRL / OCSP endpoint alerts
+func (e *EmailNotifier) buildResponderAlertHTML(user model.User, targets []model.ResponderTarget) string {
+	var sb strings.Builder
+	sb.WriteString("<html><body style='font-family: Arial, sans-serif; color: #333;'>")
+	sb.WriteString(fmt.Sprintf("<h3>Hello %s,</h3>", user.OrgName))
+	sb.WriteString(fmt.Sprintf("<p>The following <strong>%d revocation endpoints</strong> are stale or failing. Clients validating certificates of these CAs may start to fail:</p>", len(targets)))
+
+	sb.WriteString("<table border='1' cellpadding='10' cellspacing='0' style='border-collapse: collapse; width: 100%; border-color: #ddd;'>")
+	sb.WriteString("<tr style='background-color: #f8f9fa; text-align: left;'><th>Endpoint</th><th>Status</th><th>Problems</th></tr>")
+
+	for _, t := range targets {
+		color := "#ffc107"
+		if t.Health == model.TargetDown {
+			color = "#dc3545"
+		}
+
+		sb.WriteString("<tr>")
+		sb.WriteString(fmt.Sprintf(
+			"<td><a href='%s' style='color:#2563EB; text-decoration:none;'>%s</a><br/><small>%s | Issuer: %s</small></td>",
+			e.frontendURL, t.URL, t.Kind, t.IssuerSubject,
+		))
+		sb.WriteString(fmt.Sprintf("<td><b style='color:%s'>%s</b></td>", color, t.Health))
+		// Problems embed responder error text: escape it
+		problems := make([]string, len(t.Problems))
+		for i, p := range t.Problems {
+			problems[i] = html.EscapeString(p)
+		}
+		sb.WriteString(fmt.Sprintf("<td><small>%s</small></td>", strings.Join(problems, "<br/>")))
+		sb.WriteString("</tr>")
+	}
+	sb.WriteString("</table></body></html>")
+	return sb.String()
+}
+
+// buildTargetHealthAlertHTML generates the table for DOWN / recovered Monitored Targets
+func (e *EmailNotifier) buildTargetHealthAlertHTML(user model.User, alerts []model.TargetHealthAlert) string {
+	var sb strings.Builder
+	sb.WriteString("<html><body style='font-family: Arial, sans-serif; color: #333;'>")
+	sb.WriteString(fmt.Sprintf("<h3>Hello %s,</h3>", user.OrgName))
+	sb.WriteString(fmt.Sprintf("<p>The availability of <strong>%d monitored targets</strong> changed. Certificates of a DOWN target are not being checked:</p>", len(alerts)))
+
+	sb.WriteString("<table border='1' cellpadding='10' cellspacing='0' style='border-collapse: collapse; width: 100%; border-color: #ddd;'>")
+	sb.WriteString("<tr style='background-color: #f8f9fa; text-align: left;'><th>Target</th><th>Status</th><th>Last Error</th></tr>")
+
+	for _, a := range alerts {
+		status := fmt.Sprintf("<b style='color:#dc3545'>DOWN</b><br/><small>%d failed scans</small>", a.ConsecutiveFailures)
+		if a.Health == model.TargetHealthy {
+			status = "<b style='color:#28a745'>RECOVERED</b>"
+		}
+
+		sb.WriteString("<tr>")
+		sb.WriteString(fmt.Sprintf(
+			"<td><a href='%s' style='color:#2563EB; text-decoration:none;'>%s</a></td>",
+			e.frontendURL, html.EscapeString(a.TargetURL),
+		))
+		sb.WriteString(fmt.Sprintf("<td>%s</td>", status))
+		// The error embeds scanner / server text: escape it
+		sb.WriteString(fmt.Sprintf("<td><small>%s</small></td>", html.EscapeString(a.LastError)))
+		sb.WriteString("</tr>")
+	}
+	sb.WriteString("</table></body></html>")
+	return sb.String()
+}
+
+// buildDomainAlertHTML generates the table for domain registration alerts
+func (e *EmailNotifier) buildDomainAlertHTML(user model.User, domains []model.DomainRegistration) string {
+	var sb strings.Builder
+	sb.WriteString("<html><body style='font-family: Arial, sans-serif; color: #333;'>")
+	sb.WriteString(fmt.Sprintf("<h3>Hello %s,</h3>", user.OrgName))
+	sb.WriteString(fmt.Sprintf("<p>The following <strong>%d domains</strong> are expiring soon or have lapsed. Every certificate under them stops working when they do:</p>", len(domains)))
+
+	sb.WriteString("<table border='1' cellpadding='10' cellspacing='0' style='border-collapse: collapse; width: 100%; border-color: #ddd;'>")
+	sb.WriteString("<tr style='background-color: #f8f9fa; text-align: left;'><th>Domain</th><th>Expires</th></tr>")
+
+	for _, d := range domains {
+		daysLeft := int(time.Until(*d.ExpiresAt).Hours() / 24)
+		color := "#28a745"
+		if daysLeft < 7 {
+			color = "#dc3545"
+		} else if daysLeft < 30 {
+			color = "#ffc107"
+		}
+
+		sb.WriteString("<tr>")
+		sb.WriteString(fmt.Sprintf(
+			"<td><a href='%s' style='color:#2563EB; text-decoration:none;'>%s</a><br/><small>Registrar: %s</small></td>",
+			e.frontendURL, d.Domain, html.EscapeString(d.Registrar),
+		))
+		if d.Status == model.StatusExpired {
+			sb.WriteString(fmt.Sprintf("<td><b style='color:#dc3545'>EXPIRED</b><br/><small>%s</small></td>", d.ExpiresAt.Format("2006-01-02")))
+		} else {
+			sb.WriteString(fmt.Sprintf("<td><b style='color:%s'>%s</b><br/><small>%d days left</small></td>", color, d.ExpiresAt.Format("2006-01-02"), daysLeft))
+		}
 		sb.WriteString("</tr>")
 	}
 	sb.WriteString("</table></body></html>")
@@ -43,7 +43,7 @@ func (n *LogNotifier) Notify(ctx context.Context, certs []model.CertResponse, us
 // Accepts 'users' map to resolve OwnerID -> Email/OrgName.
 func (n *LogNotifier) renderBody(certs []model.CertResponse, users map[string]model.User) string {
 	var sb strings.Builder
-	sb.WriteString("The following certificates are expiring soon:\n\n")
+	sb.WriteString("The following certificates are expiring soon or failing validation:\n\n")
 
 	for _, cert := range certs {
 		// Helper to format DN (Distinguished Name) - Preserved from your original code
@@ -81,9 +81,104 @@ func (n *LogNotifier) renderBody(certs []model.CertResponse, users map[string]mo
 		sb.WriteString(fmt.Sprintf("   Subject: %s\n", subjectStr))
 		sb.WriteString(fmt.Sprintf("   Issuer:  %s\n", issuerStr))
 		sb.WriteString(fmt.Sprintf("   Expires: %s (%s - %d days left)\n", cert.ValidUntil.Format("2006-01-02"), cert.Status, daysLeft))
+		if cert.Status == model.StatusRevoked {
+			sb.WriteString(fmt.Sprintf("   ⛔ REVOKED: %s\n", cert.RevocationReason))
+		} else if cert.Status == model.StatusHostnameMismatch {
+			sb.WriteString(fmt.Sprintf("   ⚠️ HOSTNAME MISMATCH: %s\n", cert.HostnameError))
+		}
 		sb.WriteString("\n")
 	}
 
 	sb.WriteString("Please update these certificates to avoid service interruption.\n")
 	return sb.String()
 }
+
+// NotifyResponders implements the service.ResponderNotifier interface.
+func (n *LogNotifier) NotifyResponders(ctx context.Context, targets []model.ResponderTarget, users map[string]model.User) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("The following revocation endpoints are stale or failing:\n\n")
+	for _, t := range targets {
+		ownerInfo := "[Unknown Owner]"
+		if user, exists := users[t.UserID]; exists {
+			ownerInfo = fmt.Sprintf("[%s | %s]", user.Email, user.OrgName)
+		}
+
+		sb.WriteString(fmt.Sprintf("🔴 [%s %s] %s %s\n", t.Kind, t.Health, t.URL, ownerInfo))
+		sb.WriteString(fmt.Sprintf("   Issuer: %s\n", t.IssuerSubject))
+		for _, p := range t.Problems {
+			sb.WriteString(fmt.Sprintf("   - %s\n", p))
+		}
+		sb.WriteString("\n")
+	}
+
+	log.Println("---------------------------------------------------")
+	log.Printf("🔔 [LogNotifier] ALERT SYSTEM")
+	log.Printf("Subject: Action Required: %d Revocation Endpoints Failing", len(targets))
+	log.Printf("Body:\n%s", sb.String())
+	log.Println("---------------------------------------------------")
+	return nil
+}
+
+// NotifyTargetHealth implements the service.TargetHealthNotifier interface.
+func (n *LogNotifier) NotifyTargetHealth(ctx context.Context, alerts []model.TargetHealthAlert, users map[string]model.User) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("The availability of the following monitored targets changed:\n\n")
+	for _, a := range alerts {
+		ownerInfo := "[Unknown Owner]"
+		if user, exists := users[a.UserID]; exists {
+			ownerInfo = fmt.Sprintf("[%s | %s]", user.Email, user.OrgName)
+		}
+
+		if a.Health == model.TargetHealthy {
+			sb.WriteString(fmt.Sprintf("🟢 [RECOVERED] %s %s\n\n", a.TargetURL, ownerInfo))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("🔴 [DOWN] %s %s\n", a.TargetURL, ownerInfo))
+		sb.WriteString(fmt.Sprintf("   %d consecutive failed scans, last error: %s\n\n", a.ConsecutiveFailures, a.LastError))
+	}
+
+	log.Println("---------------------------------------------------")
+	log.Printf("🔔 [LogNotifier] ALERT SYSTEM")
+	log.Printf("Subject: Monitored Target Availability: %d Changes", len(alerts))
+	log.Printf("Body:\n%s", sb.String())
+	log.Println("---------------------------------------------------")
+	return nil
+}
+
+// NotifyDomains implements the service.DomainNotifier interface.
+func (n *LogNotifier) NotifyDomains(ctx context.Context, domains []model.DomainRegistration, users map[string]model.User) error {
+	if len(domains) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("The following domain registrations are expiring soon or have lapsed:\n\n")
+	for _, d := range domains {
+		ownerInfo := "[Unknown Owner]"
+		if user, exists := users[d.UserID]; exists {
+			ownerInfo = fmt.Sprintf("[%s | %s]", user.Email, user.OrgName)
+		}
+
+		daysLeft := int(time.Until(*d.ExpiresAt).Hours() / 24)
+		sb.WriteString(fmt.Sprintf("🔴 [Domain: %s] %s\n", d.Domain, ownerInfo))
+		sb.WriteString(fmt.Sprintf("   Registrar: %s\n", d.Registrar))
+		sb.WriteString(fmt.Sprintf("   Expires: %s (%s - %d days left)\n", d.ExpiresAt.Format("2006-01-02"), d.Status, daysLeft))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Please renew these domains: every certificate under them stops working when they lapse.\n")
+
+	log.Println("---------------------------------------------------")
+	log.Printf("🔔 [LogNotifier] ALERT SYSTEM")
+	log.Printf("Subject: Action Required: %d Domains Expiring Soon", len(domains))
+	log.Printf("Body:\n%s", sb.String())
+	log.Println("---------------------------------------------------")
+	return nil
+}
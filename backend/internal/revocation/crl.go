@@ -0,0 +1,170 @@
+package revocation
+
+import (
+	"bytes"
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCRLSize caps CRL downloads. Large public CAs publish CRLs of several MB.
+const maxCRLSize = 32 << 20
+
+// defaultCRLTTL is used for CRLs that omit nextUpdate.
+const defaultCRLTTL = time.Hour
+
+// crlEntry is a parsed, signature-verified CRL reduced to what we need for serial lookups.
+type crlEntry struct {
+	signerKey  []byte // RawSubjectPublicKeyInfo of the issuer the signature was verified with
+	nextUpdate time.Time
+	expiresAt  time.Time                           // Cache eviction time
+	revoked    map[string]x509.RevocationListEntry // Keyed by decimal serial
+}
+
+// CRLCache downloads CRLs from distribution points and keeps them until their nextUpdate.
+// It is safe for concurrent use.
+type CRLCache struct {
+	Client *http.Client
+
+	mu      sync.Mutex
+	entries map[string]*crlEntry
+}
+
+// NewCRLCache creates an empty cache with a bounded HTTP timeout.
+func NewCRLCache(timeout time.Duration) *CRLCache {
+	return &CRLCache{
+		Client:  &http.Client{Timeout: timeout},
+		entries: make(map[string]*crlEntry),
+	}
+}
+
+// Check looks up serial (decimal, as stored in certificates.serial_number) in the CRLs at urls.
+// A CRL only counts if it is signed by issuer; the first one that can be fetched and verified decides the result.
+// Returns (nil, nil) when none of the urls is fetchable over HTTP(S) (e.g. only ldap:// points).
+func (c *CRLCache) Check(ctx context.Context, serial string, issuer *x509.Certificate, urls []string) (*Result, error) {
+	var lastErr error
+
+	for _, url := range urls {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			continue
+		}
+
+		entry, err := c.get(ctx, url, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		res := &Result{Source: "CRL"}
+		if rev, ok := entry.revoked[serial]; ok {
+			res.Status = model.RevocationRevoked
+			res.Reason = ReasonName(rev.ReasonCode)
+			revokedAt := rev.RevocationTime
+			res.RevokedAt = &revokedAt
+		} else if !entry.nextUpdate.IsZero() && time.Now().After(entry.nextUpdate) {
+			// The CA failed to publish a fresh CRL: absence of the serial proves nothing
+			res.Status = model.RevocationUnknown
+		} else {
+			res.Status = model.RevocationGood
+		}
+		return res, nil
+	}
+
+	return nil, lastErr
+}
+
+// get returns the cached CRL for url, downloading it if missing or past its nextUpdate.
+// Only CRLs whose signature verifies against issuer are returned (and cached).
+func (c *CRLCache) get(ctx context.Context, url string, issuer *x509.Certificate) (*crlEntry, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[url]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		if !bytes.Equal(entry.signerKey, issuer.RawSubjectPublicKeyInfo) {
+			return nil, fmt.Errorf("crl %s is not signed by %s", url, issuer.Subject.CommonName)
+		}
+		return entry, nil
+	}
+
+	// Fetch outside the lock: a slow CA must not block lookups of other CRLs
+	entry, err := c.fetch(ctx, url, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[url] = entry
+	c.mu.Unlock()
+
+	log.Printf("📜 CRL Cache: Loaded %s (%d revoked, next update %s)", url, len(entry.revoked), entry.nextUpdate.Format(time.RFC3339))
+	return entry, nil
+}
+
+func (c *CRLCache) fetch(ctx context.Context, url string, issuer *x509.Certificate) (*crlEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid crl url: %w", err)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("crl download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crl download returned HTTP %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxCRLSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crl: %w", err)
+	}
+
+	list, err := ParseCRL(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := list.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("crl %s signature is not valid for %s: %w", url, issuer.Subject.CommonName, err)
+	}
+
+	entry := &crlEntry{
+		signerKey:  issuer.RawSubjectPublicKeyInfo,
+		nextUpdate: list.NextUpdate,
+		revoked:    make(map[string]x509.RevocationListEntry, len(list.RevokedCertificateEntries)),
+	}
+	for _, rev := range list.RevokedCertificateEntries {
+		entry.revoked[rev.SerialNumber.String()] = rev
+	}
+
+	entry.expiresAt = list.NextUpdate
+	if entry.expiresAt.IsZero() || time.Now().After(entry.expiresAt) {
+		// Missing or already stale nextUpdate: re-check periodically instead of on every lookup
+		entry.expiresAt = time.Now().Add(defaultCRLTTL)
+	}
+
+	return entry, nil
+}
+
+// ParseCRL accepts DER or PEM ("X509 CRL") encoded revocation lists.
+func ParseCRL(raw []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	}
+
+	list, err := x509.ParseRevocationList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid crl: %w", err)
+	}
+	return list, nil
+}
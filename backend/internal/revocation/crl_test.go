@@ -0,0 +1,121 @@
+package revocation
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testCA returns a self-signed CA certificate and its key.
+func testCA(t *testing.T, cn string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+// testCRL returns a DER CRL signed by ca revoking serial 42.
+func testCRL(t *testing.T, ca *x509.Certificate, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(42), RevocationTime: time.Now().Add(-time.Minute), ReasonCode: 1},
+		},
+	}, ca, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestCRLCacheCheck(t *testing.T) {
+	ca, caKey := testCA(t, "Test CA")
+	other, otherKey := testCA(t, "Test CA") // Same name, different key: a forged CRL
+
+	genuine := testCRL(t, ca, caKey)
+	forged := testCRL(t, other, otherKey)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/genuine.crl", func(w http.ResponseWriter, r *http.Request) { w.Write(genuine) })
+	mux.HandleFunc("/forged.crl", func(w http.ResponseWriter, r *http.Request) { w.Write(forged) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		serial  string
+		urls    []string
+		want    model.RevocationStatus
+		wantErr bool
+		wantNil bool
+	}{
+		{name: "revoked serial", serial: "42", urls: []string{srv.URL + "/genuine.crl"}, want: model.RevocationRevoked},
+		{name: "good serial", serial: "7", urls: []string{srv.URL + "/genuine.crl"}, want: model.RevocationGood},
+		{name: "forged crl rejected", serial: "42", urls: []string{srv.URL + "/forged.crl"}, wantErr: true},
+		{name: "falls through to a verified crl", serial: "42", urls: []string{srv.URL + "/forged.crl", srv.URL + "/genuine.crl"}, want: model.RevocationRevoked},
+		{name: "ldap only", serial: "42", urls: []string{"ldap://example.com/crl"}, wantNil: true},
+	}
+
+	cache := NewCRLCache(5 * time.Second)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := cache.Check(context.Background(), tt.serial, ca, tt.urls)
+			switch {
+			case tt.wantErr:
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", res)
+				}
+			case tt.wantNil:
+				if res != nil || err != nil {
+					t.Fatalf("expected (nil, nil), got (%+v, %v)", res, err)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Status != tt.want {
+					t.Fatalf("status = %s, want %s", res.Status, tt.want)
+				}
+			}
+		})
+	}
+
+	// A forged CRL is never cached; a verified one isn't served for another issuer
+	if _, ok := cache.entries[srv.URL+"/forged.crl"]; ok {
+		t.Fatal("forged crl was cached")
+	}
+	if _, err := cache.Check(context.Background(), "42", other, []string{srv.URL + "/genuine.crl"}); err == nil {
+		t.Fatal("cached crl accepted for another issuer")
+	}
+}
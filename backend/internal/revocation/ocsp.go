@@ -0,0 +1,194 @@
+package revocation
+
+import (
+	"bytes"
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Result is the outcome of a single revocation check.
+type Result struct {
+	Status    model.RevocationStatus
+	Reason    string     // Human readable RFC 5280 reason (Revoked only)
+	RevokedAt *time.Time // Revoked only
+	Source    string     // "OCSP", "OCSP_STAPLE" or "CRL"
+}
+
+// maxResponseSize caps responder downloads (OCSP responses are typically < 4KB).
+const maxResponseSize = 1 << 20
+
+// defaultOCSPTTL is used for OCSP responses that omit nextUpdate.
+const defaultOCSPTTL = time.Hour
+
+// ocspKey identifies a certificate at a responder: the serial is only unique per issuer key.
+type ocspKey struct {
+	responder string
+	issuerKey string // RawSubjectPublicKeyInfo
+	serial    string
+}
+
+// ocspEntry is a verified responder answer, reused until its nextUpdate.
+type ocspEntry struct {
+	result    *Result
+	expiresAt time.Time
+}
+
+// OCSPChecker queries the OCSP responder advertised in a certificate's AIA extension.
+// Answers are cached until their nextUpdate, so repeated scans (and retries) of a certificate
+// don't query its responder again. It is safe for concurrent use.
+type OCSPChecker struct {
+	Client *http.Client
+
+	mu      sync.Mutex
+	entries map[ocspKey]*ocspEntry
+}
+
+// NewOCSPChecker creates a checker with a bounded HTTP timeout.
+func NewOCSPChecker(timeout time.Duration) *OCSPChecker {
+	return &OCSPChecker{
+		Client:  &http.Client{Timeout: timeout},
+		entries: make(map[ocspKey]*ocspEntry),
+	}
+}
+
+// Check asks the cert's OCSP responder for its status, or returns its cached answer.
+// Returns (nil, nil) when the cert does not advertise a responder. Failures are never cached.
+func (c *OCSPChecker) Check(ctx context.Context, cert, issuer *x509.Certificate) (*Result, error) {
+	if len(cert.OCSPServer) == 0 {
+		return nil, nil
+	}
+
+	key := ocspKey{responder: cert.OCSPServer[0], issuerKey: string(issuer.RawSubjectPublicKeyInfo), serial: cert.SerialNumber.String()}
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.result, nil
+	}
+
+	// Query outside the lock: a slow responder must not block lookups of other certificates
+	res, nextUpdate, err := c.query(ctx, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(defaultOCSPTTL)
+	if !nextUpdate.IsZero() {
+		expiresAt = nextUpdate
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[ocspKey]*ocspEntry)
+	}
+	for k, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, k) // Drop answers of certificates we no longer scan
+		}
+	}
+	if now.Before(expiresAt) {
+		c.entries[key] = &ocspEntry{result: res, expiresAt: expiresAt}
+	}
+	c.mu.Unlock()
+
+	return res, nil
+}
+
+// query sends one OCSP request and returns the verified answer with its nextUpdate (zero if omitted).
+func (c *OCSPChecker) query(ctx context.Context, cert, issuer *x509.Certificate) (*Result, time.Time, error) {
+
+	reqBytes, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to build ocsp request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.OCSPServer[0], bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid ocsp responder url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("ocsp responder unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("ocsp responder returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read ocsp response: %w", err)
+	}
+
+	return parseOCSP(body, cert, issuer, "OCSP")
+}
+
+// ParseStaple evaluates an OCSP response stapled to the TLS handshake.
+func ParseStaple(raw []byte, cert, issuer *x509.Certificate) (*Result, error) {
+	res, _, err := parseOCSP(raw, cert, issuer, "OCSP_STAPLE")
+	return res, err
+}
+
+// parseOCSP verifies and evaluates an OCSP response, returning its nextUpdate (zero if omitted).
+func parseOCSP(raw []byte, cert, issuer *x509.Certificate, source string) (*Result, time.Time, error) {
+	// ParseResponseForCert also verifies the responder signature against the issuer
+	resp, err := ocsp.ParseResponseForCert(raw, cert, issuer)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid ocsp response: %w", err)
+	}
+
+	res := &Result{Source: source}
+	switch resp.Status {
+	case ocsp.Good:
+		res.Status = model.RevocationGood
+	case ocsp.Revoked:
+		res.Status = model.RevocationRevoked
+		res.Reason = ReasonName(resp.RevocationReason)
+		revokedAt := resp.RevokedAt
+		res.RevokedAt = &revokedAt
+	default:
+		res.Status = model.RevocationUnknown
+	}
+	return res, resp.NextUpdate, nil
+}
+
+// ReasonName maps an RFC 5280 CRLReason code to its name.
+func ReasonName(code int) string {
+	switch code {
+	case ocsp.Unspecified:
+		return "unspecified"
+	case ocsp.KeyCompromise:
+		return "keyCompromise"
+	case ocsp.CACompromise:
+		return "cACompromise"
+	case ocsp.AffiliationChanged:
+		return "affiliationChanged"
+	case ocsp.Superseded:
+		return "superseded"
+	case ocsp.CessationOfOperation:
+		return "cessationOfOperation"
+	case ocsp.CertificateHold:
+		return "certificateHold"
+	case ocsp.RemoveFromCRL:
+		return "removeFromCRL"
+	case ocsp.PrivilegeWithdrawn:
+		return "privilegeWithdrawn"
+	case ocsp.AACompromise:
+		return "aACompromise"
+	default:
+		return fmt.Sprintf("reason(%d)", code)
+	}
+}
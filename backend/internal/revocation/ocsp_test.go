@@ -0,0 +1,212 @@
+package revocation
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspResponder is a local OCSP responder stand-in answering for the certificates of one CA.
+type ocspResponder struct {
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	status map[string]int // Serial -> ocsp.Good / ocsp.Revoked / ocsp.Unknown
+	// nextUpdate is added to now for every answer (0 = the field is omitted)
+	nextUpdate time.Duration
+	hits       atomic.Int32
+}
+
+func (o *ocspResponder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.hits.Add(1)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := ocsp.ParseRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tmpl := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+	}
+	if status, ok := o.status[req.SerialNumber.String()]; ok {
+		tmpl.Status = status
+	}
+	if o.nextUpdate != 0 {
+		tmpl.NextUpdate = time.Now().Add(o.nextUpdate)
+	}
+	if tmpl.Status == ocsp.Revoked {
+		tmpl.RevokedAt = time.Now().Add(-time.Hour).Truncate(time.Second)
+		tmpl.RevocationReason = ocsp.KeyCompromise
+	}
+
+	resp, err := ocsp.CreateResponse(o.ca, o.ca, tmpl, o.caKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+// testLeaf issues a certificate with the given serial under ca, pointing at the OCSP responder url.
+func testLeaf(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64, responder string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "leaf.example.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	if responder != "" {
+		tmpl.OCSPServer = []string{responder}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestOCSPCheck(t *testing.T) {
+	ca, caKey := testCA(t, "Test CA")
+	responder := &ocspResponder{ca: ca, caKey: caKey, nextUpdate: time.Hour, status: map[string]int{
+		"1001": ocsp.Good,
+		"1002": ocsp.Revoked,
+	}}
+	srv := httptest.NewServer(responder)
+	defer srv.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	tests := []struct {
+		name       string
+		serial     int64
+		responder  string
+		want       model.RevocationStatus // "" = no result
+		wantReason string
+		wantErr    bool
+	}{
+		{name: "good", serial: 1001, responder: srv.URL, want: model.RevocationGood},
+		{name: "revoked", serial: 1002, responder: srv.URL, want: model.RevocationRevoked, wantReason: "keyCompromise"},
+		{name: "unknown serial", serial: 1003, responder: srv.URL, want: model.RevocationUnknown},
+		{name: "no responder advertised", serial: 1001},
+		{name: "responder error", serial: 1001, responder: broken.URL, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf := testLeaf(t, ca, caKey, tt.serial, tt.responder)
+			res, err := NewOCSPChecker(5*time.Second).Check(context.Background(), leaf, ca)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if res != nil {
+					t.Fatalf("expected no result, got %+v", res)
+				}
+				return
+			}
+			if res == nil || res.Status != tt.want || res.Reason != tt.wantReason || res.Source != "OCSP" {
+				t.Fatalf("got %+v, want status %s, reason %q, source OCSP", res, tt.want, tt.wantReason)
+			}
+			if (res.RevokedAt != nil) != (tt.want == model.RevocationRevoked) {
+				t.Fatalf("revoked_at = %v for status %s", res.RevokedAt, res.Status)
+			}
+		})
+	}
+}
+
+func TestOCSPCheckCache(t *testing.T) {
+	ca, caKey := testCA(t, "Test CA")
+
+	tests := []struct {
+		name       string
+		nextUpdate time.Duration
+		wantHits   int32
+	}{
+		{name: "reused until nextUpdate", nextUpdate: time.Hour, wantHits: 1},
+		{name: "no nextUpdate: reused for the default ttl", wantHits: 1},
+		{name: "nextUpdate already passed", nextUpdate: -time.Minute, wantHits: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responder := &ocspResponder{ca: ca, caKey: caKey, nextUpdate: tt.nextUpdate, status: map[string]int{"7": ocsp.Good}}
+			srv := httptest.NewServer(responder)
+			defer srv.Close()
+
+			leaf := testLeaf(t, ca, caKey, 7, srv.URL)
+			checker := NewOCSPChecker(5 * time.Second)
+			for i := 0; i < 3; i++ {
+				res, err := checker.Check(context.Background(), leaf, ca)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Status != model.RevocationGood {
+					t.Fatalf("status = %s, want GOOD", res.Status)
+				}
+			}
+			if hits := responder.hits.Load(); hits != tt.wantHits {
+				t.Fatalf("responder queried %d times, want %d", hits, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestOCSPCheckCacheKeyedByIssuer(t *testing.T) {
+	ca, caKey := testCA(t, "Test CA")
+	other, otherKey := testCA(t, "Other CA") // Reuses serial 7 for a revoked certificate, behind the same URL
+
+	good := &ocspResponder{ca: ca, caKey: caKey, nextUpdate: time.Hour, status: map[string]int{"7": ocsp.Good}}
+	revoked := &ocspResponder{ca: other, caKey: otherKey, nextUpdate: time.Hour, status: map[string]int{"7": ocsp.Revoked}}
+	var active atomic.Pointer[ocspResponder]
+	active.Store(good)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		active.Load().ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	checker := NewOCSPChecker(5 * time.Second)
+	if res, err := checker.Check(context.Background(), testLeaf(t, ca, caKey, 7, srv.URL), ca); err != nil || res.Status != model.RevocationGood {
+		t.Fatalf("first CA: %+v, %v", res, err)
+	}
+	active.Store(revoked)
+	if res, err := checker.Check(context.Background(), testLeaf(t, other, otherKey, 7, srv.URL), other); err != nil || res.Status != model.RevocationRevoked {
+		t.Fatalf("second CA: %+v, %v (the first CA's answer must not be reused)", res, err)
+	}
+}
@@ -0,0 +1,186 @@
+package revocation
+
+import (
+	"bytes"
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Prober checks the health of a CA's revocation endpoints (Responder Targets).
+// Unlike CRLCache, nothing is cached: every probe hits the endpoint.
+type Prober struct {
+	Client *http.Client
+}
+
+// NewProber creates a prober with a bounded HTTP timeout.
+func NewProber(timeout time.Duration) *Prober {
+	return &Prober{
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// ProbeCRL downloads the CRL at url and verifies it was signed by issuer.
+func (p *Prober) ProbeCRL(ctx context.Context, url string, issuer *x509.Certificate) (*model.ResponderCheck, error) {
+	// 1. Fetch
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid crl url: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("crl download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crl download returned HTTP %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxCRLSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crl: %w", err)
+	}
+	if len(raw) > maxCRLSize {
+		return nil, fmt.Errorf("crl exceeds %d MB", maxCRLSize>>20)
+	}
+	latency := millis(time.Since(start))
+
+	// 2. Parse & Verify
+	list, err := ParseCRL(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := list.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("crl signature is not valid for %s: %w", issuer.Subject.CommonName, err)
+	}
+
+	check := &model.ResponderCheck{
+		LatencyMs:       &latency,
+		ThisUpdate:      timePtr(list.ThisUpdate),
+		NextUpdate:      timePtr(list.NextUpdate),
+		SignerSubject:   issuer.Subject.String(),
+		SignerExpiresAt: timePtr(issuer.NotAfter),
+		SizeBytes:       len(raw),
+		RevokedCount:    len(list.RevokedCertificateEntries),
+	}
+	if list.Number != nil {
+		check.CRLNumber = list.Number.String()
+	}
+	return check, nil
+}
+
+// ProbeOCSP asks the responder at url for the status of serial (a certificate issued by issuer).
+// The response must be signed by the issuer or a responder it delegated to, and cover the serial we asked about.
+func (p *Prober) ProbeOCSP(ctx context.Context, url string, issuer *x509.Certificate, serial *big.Int) (*model.ResponderCheck, error) {
+	// 1. Build the Request (by serial: we don't need the certificate itself)
+	reqBytes, err := ocspRequest(issuer, serial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ocsp request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ocsp responder url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	// 2. Query
+	start := time.Now()
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ocsp responder unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp responder returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ocsp response: %w", err)
+	}
+	latency := millis(time.Since(start))
+
+	// 3. Parse & Verify (tryLater, unauthorized... surface as errors here)
+	parsed, err := ocsp.ParseResponse(body, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ocsp response: %w", err)
+	}
+	if parsed.SerialNumber == nil || parsed.SerialNumber.Cmp(serial) != 0 {
+		return nil, fmt.Errorf("ocsp response is for serial %v, not %v", parsed.SerialNumber, serial)
+	}
+
+	check := &model.ResponderCheck{
+		LatencyMs:       &latency,
+		ThisUpdate:      timePtr(parsed.ThisUpdate),
+		NextUpdate:      timePtr(parsed.NextUpdate),
+		SignerSubject:   issuer.Subject.String(),
+		SignerExpiresAt: timePtr(issuer.NotAfter),
+		ProducedAt:      timePtr(parsed.ProducedAt),
+	}
+	if parsed.Certificate != nil {
+		// Delegated responder: its certificate expires independently of the CA
+		check.SignerSubject = parsed.Certificate.Subject.String()
+		check.SignerExpiresAt = timePtr(parsed.Certificate.NotAfter)
+	}
+
+	switch parsed.Status {
+	case ocsp.Good:
+		check.OCSPStatus = model.RevocationGood
+	case ocsp.Revoked:
+		check.OCSPStatus = model.RevocationRevoked
+	default:
+		check.OCSPStatus = model.RevocationUnknown
+	}
+	return check, nil
+}
+
+// ocspRequest builds a DER OCSP request for serial, identifying the issuer by SHA-1 hashes (RFC 6960 CertID).
+func ocspRequest(issuer *x509.Certificate, serial *big.Int) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+
+	req := &ocsp.Request{
+		HashAlgorithm:  crypto.SHA1,
+		IssuerNameHash: nameHash[:],
+		IssuerKeyHash:  keyHash[:],
+		SerialNumber:   serial,
+	}
+	return req.Marshal()
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// timePtr maps the zero time (field absent) to nil.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -1,27 +1,70 @@
 package scanner
 
 import (
+	"cert-manager-backend/internal/cryptostrength"
+	"cert-manager-backend/internal/ct"
 	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/revocation"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// Resolver looks up every address behind a hostname. *net.Resolver satisfies it.
+// Swappable so "scan all addresses" can be pointed at local listeners.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
 // TLSScanner is the concrete implementation using crypto/tls
 type TLSScanner struct {
-	Dialer *net.Dialer
+	Dialers  *DialerSet
+	Timeout  time.Duration // Per handshake, including any STARTTLS negotiation
+	OCSP     *revocation.OCSPChecker
+	Resolver Resolver
 }
 
-// NewTLSScanner creates a scanner with a default timeout
+// NewTLSScanner creates a scanner with a default timeout that dials targets directly.
+// Replace Dialers to route scans through an egress proxy.
 func NewTLSScanner(timeout time.Duration) *TLSScanner {
+	dialers, _ := NewDialerSet("", nil, false, timeout) // Direct only: cannot fail
 	return &TLSScanner{
-		Dialer: &net.Dialer{
-			Timeout: timeout,
-		},
+		Dialers:  dialers,
+		Timeout:  timeout,
+		OCSP:     revocation.NewOCSPChecker(timeout),
+		Resolver: net.DefaultResolver,
+	}
+}
+
+// ScanReport collects what a scan couldn't reach while still succeeding overall.
+// Attach it with WithScanReport; Scan resets it first, so it can be reused across retries.
+type ScanReport struct {
+	Unreachable []model.UnreachableNode
+}
+
+type scanReportKey struct{}
+
+// WithScanReport makes Scan record its unreachable endpoints & nodes into report.
+func WithScanReport(ctx context.Context, report *ScanReport) context.Context {
+	return context.WithValue(ctx, scanReportKey{}, report)
+}
+
+func scanReportFrom(ctx context.Context) *ScanReport {
+	report, _ := ctx.Value(scanReportKey{}).(*ScanReport)
+	return report
+}
+
+func (r *ScanReport) add(nodes ...model.UnreachableNode) {
+	if r != nil {
+		r.Unreachable = append(r.Unreachable, nodes...)
 	}
 }
 
@@ -40,90 +83,387 @@ func init() {
 }
 
 // Scan performs the TLS handshake and extracts the certificate chain.
-func (s *TLSScanner) Scan(ctx context.Context, target string) ([]model.Certificate, error) {
+// For STARTTLS protocols (SMTP, LDAP, Postgres...) the plaintext upgrade is negotiated first.
+// Every endpoint of the target (one per port) is scanned; with ScanAllAddresses, every resolved IP
+// of each endpoint is scanned (same SNI) and reported as its own source.
+// Partial failures are logged (and recorded into the ScanReport of ctx, if any); only a total failure fails the scan.
+// Document kinds (SAML metadata / JWKS) are fetched instead, and yield the certificates they embed.
+func (s *TLSScanner) Scan(ctx context.Context, t model.Target) ([]model.Certificate, error) {
+	report := scanReportFrom(ctx)
+	if report != nil {
+		report.Unreachable = nil
+	}
+	if t.Kind.IsDocument() {
+		return s.scanDocument(ctx, t)
+	}
+
+	var all []model.Certificate
+	var failures []string
+	var lastErr error
+
+	for _, ep := range t.Endpoints() {
+		certs, err := s.scanEndpoint(ctx, t, ep)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", ep.Addr, err))
+			lastErr = err
+			report.add(model.UnreachableNode{SourceUID: ep.SourceUID, Addr: ep.Addr, Error: err.Error()})
+			continue
+		}
+		all = append(all, certs...)
+	}
 
-	// plit Host and Port to get the SNI ServerName
-	host, _, err := net.SplitHostPort(target)
+	if len(all) == 0 {
+		if len(failures) == 1 {
+			return nil, lastErr // Single endpoint: keep the original error
+		}
+		return nil, fmt.Errorf("all %d endpoints failed: %s", len(failures), strings.Join(failures, "; "))
+	}
+	for _, f := range failures {
+		log.Printf("⚠️ Scanner: %s endpoint unreachable (%s)", t.TargetURL, f)
+	}
+	return all, nil
+}
+
+// scanEndpoint scans one port of the target: its dial address, or every address behind it.
+func (s *TLSScanner) scanEndpoint(ctx context.Context, t model.Target, ep model.Endpoint) ([]model.Certificate, error) {
+	dialHost, port, _ := net.SplitHostPort(ep.Addr)
+
+	if !t.ScanAllAddresses || net.ParseIP(dialHost) != nil {
+		return s.scanAddr(ctx, t, ep.Addr, ep.ServerName, ep.SourceUID, "")
+	}
+
+	// Resolve every A/AAAA record behind the hostname
+	addrs, err := s.Resolver.LookupIPAddr(ctx, dialHost)
 	if err != nil {
-		// Fallback: If split fails (e.g. no port), assume target is just the host
-		host = target
-		target = net.JoinHostPort(host, "443")
+		return nil, fmt.Errorf("dns lookup failed: %w", err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("dns lookup returned no addresses for %s", dialHost)
 	}
 
+	var all []model.Certificate
+	var failures []string
+	var unreachable []model.UnreachableNode
+	for _, a := range addrs {
+		ip := a.IP.String()
+		certs, err := s.scanAddr(ctx, t, net.JoinHostPort(ip, port), ep.ServerName, model.NodeSourceUID(ep, ip), ip)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", ip, err))
+			unreachable = append(unreachable, model.UnreachableNode{
+				SourceUID: model.NodeSourceUID(ep, ip), Addr: net.JoinHostPort(ip, port), Error: err.Error(),
+			})
+			continue
+		}
+		all = append(all, certs...)
+	}
+
+	if len(all) == 0 {
+		return nil, fmt.Errorf("all %d addresses failed: %s", len(addrs), strings.Join(failures, "; "))
+	}
+	for _, f := range failures {
+		log.Printf("⚠️ Scanner: %s node unreachable (%s)", ep.Addr, f)
+	}
+	// Only nodes of a reachable endpoint: a fully failed one is reported as a whole by Scan
+	scanReportFrom(ctx).add(unreachable...)
+	return all, nil
+}
+
+// scanAddr handshakes with one dial address and converts the served chain.
+// host is used for SNI and the hostname check; sourceUID tags the resulting instances.
+func (s *TLSScanner) scanAddr(ctx context.Context, t model.Target, target, host, sourceUID, resolvedIP string) ([]model.Certificate, error) {
 	// 1. Prepare Config (InsecureSkipVerify: true to capture expired/self-signed certs)
 	// CRITICAL: ServerName must be set for SNI to work on virtual hosts
 	cfg := &tls.Config{
 		ServerName:         host,
 		InsecureSkipVerify: true,
 	}
+	if t.ClientCert != nil {
+		cfg.Certificates = []tls.Certificate{*t.ClientCert}
+	}
+
+	// 2. Dial, Upgrade & Handshake
+	res, err := s.handshake(ctx, t, target, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if res.Rejected != nil {
+		// mTLS endpoint refused us (no / wrong client cert): the server chain was still captured
+		log.Printf("🔐 Scanner: %s demands a client certificate (%v); recording the server chain only", sourceUID, res.Rejected)
+	}
+	state := res.State
+
+	roots := trustRoots(t.CustomRootsPEM)
+
+	// 3. Extract Certificates
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+
+	// 4. Convert the full served chain to the Domain Model
+	// Position 0 is the leaf, followed by every intermediate (and root, if sent) in served order.
+	certs := make([]model.Certificate, 0, len(state.PeerCertificates))
+	for i, c := range state.PeerCertificates {
+		// Everything served after this cert is a candidate intermediate for its verification
+		intermediates := x509.NewCertPool()
+		for _, ic := range state.PeerCertificates[i+1:] {
+			intermediates.AddCert(ic)
+		}
+
+		isTrusted, trustErr := verifyTrust(c, intermediates, roots, i > 0)
+
+		// Detect a wrong/out-of-order chain: each cert must be signed by the next one served.
+		if i > 0 {
+			if err := state.PeerCertificates[i-1].CheckSignatureFrom(c); err != nil {
+				isTrusted = false
+				trustErr = fmt.Sprintf("served chain is broken: position %d did not issue position %d", i, i-1)
+			}
+		}
+
+		cert := CertificateFromX509(c, sourceUID)
+		cert.ChainPosition = i
+		cert.ResolvedIP = resolvedIP
+		cert.IsTrusted = isTrusted
+		cert.TrustError = trustErr
+
+		// Hostname check (Leaf only): kept apart from chain trust, so a valid cert
+		// served for the wrong host is reported as such rather than as "untrusted".
+		if i == 0 {
+			if err := c.VerifyHostname(host); err != nil {
+				cert.HostnameMismatch = true
+				cert.HostnameError = err.Error()
+			}
+
+			// Certificate Transparency: only certs chaining to a public root are subject to CT
+			cert.PubliclyTrusted = isTrusted
+			if roots != systemRoots && isTrusted {
+				cert.PubliclyTrusted, _ = verifyTrust(c, intermediates, systemRoots, false)
+			}
+			cert.SCTs = collectSCTs(c, state.SignedCertificateTimestamps, sourceUID)
+			cert.ClientCertRequested = res.ClientCertRequested
+			cert.Timing = res.Timing
+		}
+
+		// Revocation: needs the issuer, i.e. the next cert served in the chain
+		if i+1 < len(state.PeerCertificates) {
+			var staple []byte
+			if i == 0 {
+				// Servers only staple for the leaf
+				staple = state.OCSPResponse
+				cert.OCSPStapled = len(staple) > 0
+			}
+			s.checkRevocation(ctx, &cert, c, state.PeerCertificates[i+1], staple)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// collectSCTs gathers the leaf's SCTs from the certificate extension and the TLS handshake.
+// Malformed SCTs are logged and skipped: they must not fail the scan.
+func collectSCTs(c *x509.Certificate, handshake [][]byte, sourceUID string) []model.SCT {
+	scts, err := ct.EmbeddedSCTs(c)
+	if err != nil {
+		log.Printf("⚠️ Scanner: Invalid embedded SCTs for %s: %v", sourceUID, err)
+	}
+
+	tlsSCTs, err := ct.HandshakeSCTs(handshake)
+	if err != nil {
+		log.Printf("⚠️ Scanner: Invalid handshake SCTs for %s: %v", sourceUID, err)
+	}
+	return append(scts, tlsSCTs...)
+}
+
+// checkRevocation fills the revocation fields, preferring a stapled response over a live OCSP query.
+func (s *TLSScanner) checkRevocation(ctx context.Context, cert *model.Certificate, c, issuer *x509.Certificate, staple []byte) {
+	var res *revocation.Result
+	var err error
+
+	if len(staple) > 0 {
+		res, err = revocation.ParseStaple(staple, c, issuer)
+	}
+	if res == nil && s.OCSP != nil {
+		res, err = s.OCSP.Check(ctx, c, issuer)
+	}
 
-	// 2. Dial (with Context)
-	conn, err := s.Dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		log.Printf("⚠️ Scanner: OCSP check failed for %s (serial %s): %v", cert.SourceUID, cert.Serial, err)
+		cert.RevocationStatus = model.RevocationUnknown
+		return
+	}
+	if res == nil {
+		return // No responder advertised: leave unchecked
+	}
+
+	cert.RevocationStatus = res.Status
+	cert.RevocationReason = res.Reason
+	cert.RevokedAt = res.RevokedAt
+	cert.RevocationSource = res.Source
+}
+
+// handshakeResult is the negotiated state of a handshake, plus what we learned about client authentication.
+type handshakeResult struct {
+	State *tls.ConnectionState
+
+	// The server sent a CertificateRequest (mTLS)
+	ClientCertRequested bool
+	// The server aborted the handshake after sending its chain (typically: no / rejected client cert).
+	// State is still usable for the server chain and the negotiated version / suite.
+	Rejected error
+
+	// DNS, connect & handshake durations (Availability / Latency history)
+	Timing *model.ScanTiming
+}
+
+// handshake dials addr through the target's proxy, performs any STARTTLS upgrade and completes a TLS handshake with cfg.
+// cfg.Certificates[0], if set, is presented when the server asks for a client certificate.
+// The connection is closed before returning; only the negotiated state is kept.
+func (s *TLSScanner) handshake(ctx context.Context, t model.Target, addr string, cfg *tls.Config) (*handshakeResult, error) {
+	res := &handshakeResult{Timing: &model.ScanTiming{}}
+
+	// Capture the server's side of the handshake before we answer a CertificateRequest:
+	// if the server then aborts, we still know its chain.
+	cfg = cfg.Clone()
+	var captured *tls.ConnectionState
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		captured = &cs
+		return nil
+	}
+	clientCerts := cfg.Certificates
+	cfg.Certificates = nil
+	cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		res.ClientCertRequested = true
+		if len(clientCerts) > 0 {
+			return &clientCerts[0], nil
+		}
+		return &tls.Certificate{}, nil // Send an empty Certificate message
+	}
+
+	// A. Dial (with Context), directly or through the egress proxy
+	dialer, err := s.Dialers.For(t.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(withDialTiming(dialCtx, res.Timing), "tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("dial failed: %w", err)
 	}
 	defer conn.Close()
 
-	// 3. Upgrade to TLS
-	tlsConn := tls.Client(conn, cfg)
+	// The deadline covers both the STARTTLS negotiation and the handshake
+	conn.SetDeadline(time.Now().Add(s.Timeout))
 
-	// Force Handshake with deadline
-	tlsConn.SetDeadline(time.Now().Add(s.Dialer.Timeout))
-	if err := tlsConn.Handshake(); err != nil {
-		return nil, fmt.Errorf("tls handshake failed: %w", err)
+	// B. Protocol Upgrade (No-op for implicit TLS)
+	if err := startTLS(conn, t.Protocol); err != nil {
+		return nil, err
 	}
 
-	// 4. Extract Certificates
-	state := tlsConn.ConnectionState()
-	if len(state.PeerCertificates) == 0 {
-		return nil, fmt.Errorf("no certificates found")
+	// C. Upgrade to TLS & Force Handshake
+	tlsConn := tls.Client(conn, cfg)
+	handshakeStart := time.Now()
+	err = tlsConn.HandshakeContext(ctx)
+	res.Timing.Handshake = time.Since(handshakeStart)
+	if err != nil {
+		if res.ClientCertRequested && captured != nil {
+			res.State = captured
+			res.Rejected = fmt.Errorf("tls handshake failed: %w", err)
+			return res, nil
+		}
+		return nil, fmt.Errorf("tls handshake failed: %w", err)
 	}
 
-	// 5. Convert to Domain Model (Leaf only for now)
-	leaf := state.PeerCertificates[0]
+	state := tlsConn.ConnectionState()
+	res.State = &state
+	return res, nil
+}
 
-	// Prepare intermediates for trust verification
-	intermediates := x509.NewCertPool()
-	for _, c := range state.PeerCertificates[1:] {
-		intermediates.AddCert(c)
-	}
+// CertificateFromX509 maps the x509 fields we persist into the Domain Model.
+// Ingestion uses it too, to take a definition's fields from the reported DER rather than the reporter's JSON.
+func CertificateFromX509(c *x509.Certificate, sourceUID string) model.Certificate {
+	keyAlgo, keySize, keyCurve := cryptostrength.PublicKey(c)
 
-	isTrusted, trustErr := verifyTrust(leaf, intermediates)
+	fingerprint := sha256.Sum256(c.Raw)
 
-	cert := model.Certificate{
-		SourceUID:  target,
-		SourceType: "CLOUD",
-		Serial:     leaf.SerialNumber.String(),
+	return model.Certificate{
+		SourceUID:         sourceUID,
+		SourceType:        "CLOUD",
+		Serial:            c.SerialNumber.String(),
+		FingerprintSHA256: hex.EncodeToString(fingerprint[:]),
+		RawDER:            c.Raw,
 		Subject: model.DN{
-			CN:  leaf.Subject.CommonName,
-			Org: join(leaf.Subject.Organization),
-			OU:  join(leaf.Subject.OrganizationalUnit),
+			CN:  c.Subject.CommonName,
+			Org: join(c.Subject.Organization),
+			OU:  join(c.Subject.OrganizationalUnit),
 		},
 		Issuer: model.DN{
-			CN:  leaf.Issuer.CommonName,
-			Org: join(leaf.Issuer.Organization),
-			OU:  join(leaf.Issuer.OrganizationalUnit),
+			CN:  c.Issuer.CommonName,
+			Org: join(c.Issuer.Organization),
+			OU:  join(c.Issuer.OrganizationalUnit),
 		},
-		SignatureAlgo: leaf.SignatureAlgorithm.String(),
-		ValidFrom:     leaf.NotBefore,
-		ValidUntil:    leaf.NotAfter,
-		DNSNames:      leaf.DNSNames,
-		IsTrusted:     isTrusted,
-		TrustError:    trustErr,
+		SignatureAlgo: c.SignatureAlgorithm.String(),
+		ValidFrom:     c.NotBefore,
+		ValidUntil:    c.NotAfter,
+		DNSNames:      c.DNSNames,
+
+		IPAddresses:    ipStrings(c.IPAddresses),
+		EmailAddresses: c.EmailAddresses,
+		URIs:           uriStrings(c.URIs),
+
+		KeyAlgo:    keyAlgo,
+		KeySize:    keySize,
+		KeyCurve:   keyCurve,
+		SPKISHA256: cryptostrength.SPKISHA256(c),
+
+		CRLDistributionPoints: c.CRLDistributionPoints,
 	}
+}
 
-	return []model.Certificate{cert}, nil
+func ipStrings(ips []net.IP) []string {
+	out := make([]string, len(ips))
+	for i, ip := range ips {
+		out[i] = ip.String()
+	}
+	return out
+}
+
+func uriStrings(uris []*url.URL) []string {
+	out := make([]string, len(uris))
+	for i, u := range uris {
+		out[i] = u.String()
+	}
+	return out
 }
 
-// verifyTrust checks if the cert is trusted by the System Root CAs
-func verifyTrust(leaf *x509.Certificate, intermediates *x509.CertPool) (bool, string) {
+// trustRoots returns the pool to verify against: System Roots, plus the owner's private CAs if any.
+func trustRoots(customPEM []byte) *x509.CertPool {
+	if len(customPEM) == 0 {
+		return systemRoots
+	}
+
+	// Clone: the shared system pool must never see a tenant's CAs
+	pool := systemRoots.Clone()
+	if !pool.AppendCertsFromPEM(customPEM) {
+		log.Printf("⚠️ Scanner Warning: Trust Store bundle contains no usable certificates")
+	}
+	return pool
+}
+
+// verifyTrust checks if the cert is trusted by the given Root CAs.
+// CA certs (intermediates) are verified for any usage, since ServerAuth only applies to leaves.
+func verifyTrust(cert *x509.Certificate, intermediates, roots *x509.CertPool, isCA bool) (bool, string) {
 	opts := x509.VerifyOptions{
 		Intermediates: intermediates,
 		CurrentTime:   time.Now(),
-		Roots:         systemRoots, // Explicitly use our cached pool
+		Roots:         roots,
+	}
+	if isCA {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
 	}
 
-	if _, err := leaf.Verify(opts); err != nil {
+	if _, err := cert.Verify(opts); err != nil {
 		return false, err.Error()
 	}
 	return true, ""
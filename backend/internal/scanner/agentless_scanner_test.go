@@ -0,0 +1,355 @@
+package scanner
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// testPKI is a private CA issuing server certificates for local listeners.
+type testPKI struct {
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Scanner Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testPKI{ca: ca, caKey: key}
+}
+
+// issue returns a server certificate for dnsName, served together with the CA (chain position 1).
+func (p *testPKI) issue(t *testing.T, dnsName string, serial int64, ocspURL string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	if ocspURL != "" {
+		tmpl.OCSPServer = []string{ocspURL}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der, p.ca.Raw}, PrivateKey: key, Leaf: leaf}
+}
+
+// ocspAnswer signs an OCSP response of the CA for serial (reason: revoked answers only).
+func (p *testPKI) ocspAnswer(t *testing.T, serial *big.Int, status, reason int) []byte {
+	t.Helper()
+	tmpl := ocsp.Response{
+		Status:       status,
+		SerialNumber: serial,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}
+	if status == ocsp.Revoked {
+		tmpl.RevokedAt = time.Now().Add(-time.Hour).Truncate(time.Second)
+		tmpl.RevocationReason = reason
+	}
+	resp, err := ocsp.CreateResponse(p.ca, p.ca, tmpl, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// ocspResponder is a local OCSP responder stand-in: status maps serials to ocsp.Good / Revoked (anything else: Unknown).
+// Revocations are answered with reason keyCompromise.
+func (p *testPKI) ocspResponder(t *testing.T, status map[string]int, hits *atomic.Int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		answer, ok := status[req.SerialNumber.String()]
+		if !ok {
+			answer = ocsp.Unknown
+		}
+		w.Write(p.ocspAnswer(t, req.SerialNumber, answer, ocsp.KeyCompromise))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serveTLS accepts TLS handshakes with cert on addr ("127.0.0.1:0" for any port) and returns the bound address.
+func serveTLS(t *testing.T, addr string, cert tls.Certificate) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				c.(*tls.Conn).Handshake()
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestScanRevocation(t *testing.T) {
+	pki := newTestPKI(t)
+	var hits atomic.Int32
+	responder := pki.ocspResponder(t, map[string]int{"101": ocsp.Good, "102": ocsp.Revoked}, &hits)
+
+	tests := []struct {
+		name       string
+		serial     int64
+		ocspURL    string
+		staple     int // ocsp.Good / ocsp.Revoked stapled by the server; -1 = no staple
+		want       model.RevocationStatus
+		wantReason string
+		wantSource string
+		wantHits   int32 // Responder queries across two scans
+	}{
+		{name: "good", serial: 101, ocspURL: responder.URL, staple: -1, want: model.RevocationGood, wantSource: "OCSP", wantHits: 1},
+		{name: "revoked", serial: 102, ocspURL: responder.URL, staple: -1, want: model.RevocationRevoked, wantReason: "keyCompromise", wantSource: "OCSP", wantHits: 1},
+		{name: "unknown", serial: 103, ocspURL: responder.URL, staple: -1, want: model.RevocationUnknown, wantSource: "OCSP", wantHits: 1},
+		{name: "stapled answer wins", serial: 104, ocspURL: responder.URL, staple: ocsp.Revoked, want: model.RevocationRevoked, wantReason: "superseded", wantSource: "OCSP_STAPLE"},
+		{name: "no responder advertised", serial: 105, staple: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			cert := pki.issue(t, "revocation.test", tt.serial, tt.ocspURL)
+			if tt.staple >= 0 {
+				cert.OCSPStaple = pki.ocspAnswer(t, big.NewInt(tt.serial), tt.staple, ocsp.Superseded)
+			}
+			addr := serveTLS(t, "127.0.0.1:0", cert)
+
+			s := NewTLSScanner(5 * time.Second)
+			target := model.Target{ID: "t1", TargetURL: addr, ServerName: "revocation.test"}
+			for i := 0; i < 2; i++ {
+				certs, err := s.Scan(context.Background(), target)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(certs) != 2 {
+					t.Fatalf("got %d certificates, want leaf + CA", len(certs))
+				}
+				leaf := certs[0]
+				if leaf.RevocationStatus != tt.want || leaf.RevocationReason != tt.wantReason || leaf.RevocationSource != tt.wantSource {
+					t.Fatalf("scan %d: revocation = %s / %q / %q, want %s / %q / %q", i+1,
+						leaf.RevocationStatus, leaf.RevocationReason, leaf.RevocationSource, tt.want, tt.wantReason, tt.wantSource)
+				}
+				if leaf.OCSPStapled != (tt.staple >= 0) {
+					t.Fatalf("ocsp_stapled = %v", leaf.OCSPStapled)
+				}
+				if (leaf.RevokedAt != nil) != (tt.want == model.RevocationRevoked) {
+					t.Fatalf("revoked_at = %v for %s", leaf.RevokedAt, leaf.RevocationStatus)
+				}
+			}
+			// The second scan reuses the answer (valid until its nextUpdate)
+			if got := hits.Load(); got != tt.wantHits {
+				t.Fatalf("responder queried %d times over two scans, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+// staticResolver answers every lookup with addrs (or err).
+type staticResolver struct {
+	addrs []string
+	err   error
+}
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var out []net.IPAddr
+	for _, a := range r.addrs {
+		out = append(out, net.IPAddr{IP: net.ParseIP(a)})
+	}
+	return out, nil
+}
+
+// serveNodes starts one TLS listener per loopback IP (127.0.0.x), all on the same port, and returns the port.
+func serveNodes(t *testing.T, certs map[string]tls.Certificate) string {
+	t.Helper()
+	for attempt := 0; attempt < 10; attempt++ {
+		var port string
+		var listeners []net.Listener
+		ok := true
+		for ip, cert := range certs {
+			addr := net.JoinHostPort(ip, "0")
+			if port != "" {
+				addr = net.JoinHostPort(ip, port)
+			}
+			ln, err := tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{cert}})
+			if err != nil {
+				ok = false // Port taken on this address: start over with a new one
+				break
+			}
+			listeners = append(listeners, ln)
+			_, port, _ = net.SplitHostPort(ln.Addr().String())
+		}
+		if !ok {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			continue
+		}
+		for _, ln := range listeners {
+			t.Cleanup(func() { ln.Close() })
+			go func(ln net.Listener) {
+				for {
+					conn, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					go func(c net.Conn) {
+						defer c.Close()
+						c.(*tls.Conn).Handshake()
+					}(conn)
+				}
+			}(ln)
+		}
+		return port
+	}
+	t.Fatal("could not bind the same port on every node address")
+	return ""
+}
+
+func TestScanAllAddresses(t *testing.T) {
+	pki := newTestPKI(t)
+	certA := pki.issue(t, "lb.example.test", 201, "")
+	certB := pki.issue(t, "lb.example.test", 202, "") // A stale node serving another certificate
+	port := serveNodes(t, map[string]tls.Certificate{"127.0.0.1": certA, "127.0.0.2": certB, "127.0.0.3": certA})
+	// 127.0.0.4 resolves, but nothing listens there
+
+	target := model.Target{ID: "tgt-1", TargetURL: net.JoinHostPort("lb.example.test", port), ScanAllAddresses: true}
+	ep := target.Endpoints()[0]
+	dnsErr := &net.DNSError{Err: "no such host", Name: "lb.example.test", IsNotFound: true}
+
+	tests := []struct {
+		name            string
+		resolver        staticResolver
+		wantSerials     map[string]string // Node IP -> leaf serial
+		wantUnreachable []string          // SourceUIDs in the scan report
+		wantErr         bool
+	}{
+		{
+			name:        "every node scanned",
+			resolver:    staticResolver{addrs: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}},
+			wantSerials: map[string]string{"127.0.0.1": "201", "127.0.0.2": "202", "127.0.0.3": "201"},
+		},
+		{
+			name:            "unreachable node is reported",
+			resolver:        staticResolver{addrs: []string{"127.0.0.1", "127.0.0.4"}},
+			wantSerials:     map[string]string{"127.0.0.1": "201"},
+			wantUnreachable: []string{model.NodeSourceUID(ep, "127.0.0.4")},
+		},
+		{
+			name:            "all nodes down reports the endpoint",
+			resolver:        staticResolver{addrs: []string{"127.0.0.4"}},
+			wantUnreachable: []string{ep.SourceUID},
+			wantErr:         true,
+		},
+		{
+			name:            "dns failure",
+			resolver:        staticResolver{err: dnsErr},
+			wantUnreachable: []string{ep.SourceUID},
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewTLSScanner(2 * time.Second)
+			s.Resolver = tt.resolver
+
+			report := ScanReport{Unreachable: []model.UnreachableNode{{SourceUID: "left over from a previous attempt"}}}
+			certs, err := s.Scan(WithScanReport(context.Background(), &report), target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got := map[string]string{}
+			for _, c := range certs {
+				if c.ChainPosition != 0 {
+					continue
+				}
+				if c.SourceUID != model.NodeSourceUID(ep, c.ResolvedIP) {
+					t.Fatalf("node %s tagged %q", c.ResolvedIP, c.SourceUID)
+				}
+				got[c.ResolvedIP] = c.Serial
+			}
+			if len(got) != len(tt.wantSerials) {
+				t.Fatalf("leaf serials by node = %v, want %v", got, tt.wantSerials)
+			}
+			for ip, serial := range tt.wantSerials {
+				if got[ip] != serial {
+					t.Fatalf("leaf serials by node = %v, want %v", got, tt.wantSerials)
+				}
+			}
+
+			var unreachable []string
+			for _, n := range report.Unreachable {
+				unreachable = append(unreachable, n.SourceUID)
+			}
+			if len(unreachable) != len(tt.wantUnreachable) || (len(unreachable) > 0 && unreachable[0] != tt.wantUnreachable[0]) {
+				t.Fatalf("unreachable = %q, want %q", unreachable, tt.wantUnreachable)
+			}
+		})
+	}
+}
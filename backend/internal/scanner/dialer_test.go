@@ -0,0 +1,438 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"cert-manager-backend/internal/egress"
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// socks5Server plays the proxy side of a SOCKS5 CONNECT. wantReq is the exact CONNECT request expected.
+func socks5Server(user, pass string, authOK bool, reply byte, wantReq []byte) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		// 1. Greeting
+		head := make([]byte, 2)
+		if _, err := io.ReadFull(conn, head); err != nil {
+			return err
+		}
+		methods := make([]byte, head[1])
+		if _, err := io.ReadFull(conn, methods); err != nil {
+			return err
+		}
+
+		// 2. Authentication
+		if user == "" {
+			if _, err := conn.Write([]byte{0x05, 0x00}); err != nil {
+				return err
+			}
+		} else {
+			if !bytes.Contains(methods, []byte{0x02}) {
+				conn.Write([]byte{0x05, 0xff})
+				return fmt.Errorf("client did not offer username/password")
+			}
+			if _, err := conn.Write([]byte{0x05, 0x02}); err != nil {
+				return err
+			}
+			want := append(append(append([]byte{0x01, byte(len(user))}, user...), byte(len(pass))), pass...)
+			got := make([]byte, len(want))
+			if _, err := io.ReadFull(conn, got); err != nil {
+				return err
+			}
+			status := byte(0x00)
+			if !authOK || !bytes.Equal(got, want) {
+				status = 0x01
+			}
+			if _, err := conn.Write([]byte{0x01, status}); err != nil {
+				return err
+			}
+			if status != 0x00 {
+				return nil
+			}
+		}
+
+		// 3. CONNECT
+		got := make([]byte, len(wantReq))
+		if _, err := io.ReadFull(conn, got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, wantReq) {
+			return fmt.Errorf("connect request % x, want % x", got, wantReq)
+		}
+		// Reply bound to 0.0.0.0:0
+		_, err := conn.Write([]byte{0x05, reply, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return err
+	}
+}
+
+func TestSOCKS5Negotiate(t *testing.T) {
+	hostnameReq := append(append([]byte{0x05, 0x01, 0x00, 0x03, byte(len("example.com"))}, "example.com"...), 0x01, 0xbb)
+	ipv4Req := []byte{0x05, 0x01, 0x00, 0x01, 192, 0, 2, 10, 0x01, 0xbb}
+	ipv6Req := append(append([]byte{0x05, 0x01, 0x00, 0x04}, net.ParseIP("2001:db8::1").To16()...), 0x01, 0xbb)
+
+	tests := []struct {
+		name       string
+		dialer     socks5Dialer
+		host       string
+		server     func(conn net.Conn) error
+		wantErr    bool
+		wantServer bool // the server side must complete without error
+	}{
+		{
+			name:       "hostname is sent for the proxy to resolve",
+			host:       "example.com",
+			server:     socks5Server("", "", true, 0x00, hostnameReq),
+			wantServer: true,
+		},
+		{
+			name:       "ipv4",
+			host:       "192.0.2.10",
+			server:     socks5Server("", "", true, 0x00, ipv4Req),
+			wantServer: true,
+		},
+		{
+			name:       "ipv6",
+			host:       "2001:db8::1",
+			server:     socks5Server("", "", true, 0x00, ipv6Req),
+			wantServer: true,
+		},
+		{
+			name:       "username / password",
+			dialer:     socks5Dialer{user: "scanner", pass: "s3cret"},
+			host:       "example.com",
+			server:     socks5Server("scanner", "s3cret", true, 0x00, hostnameReq),
+			wantServer: true,
+		},
+		{
+			name:    "authentication failure",
+			dialer:  socks5Dialer{user: "scanner", pass: "s3cre7"},
+			host:    "example.com",
+			server:  socks5Server("scanner", "s3cret", false, 0x00, hostnameReq),
+			wantErr: true,
+		},
+		{
+			name:    "proxy requires credentials we don't have",
+			host:    "example.com",
+			server:  socks5Server("scanner", "s3cret", true, 0x00, hostnameReq),
+			wantErr: true,
+		},
+		{
+			name:    "connect refused",
+			host:    "example.com",
+			server:  socks5Server("", "", true, 0x05, hostnameReq),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			client.SetDeadline(time.Now().Add(5 * time.Second))
+			server.SetDeadline(time.Now().Add(5 * time.Second))
+
+			serverErr := make(chan error, 1)
+			go func() {
+				defer server.Close()
+				serverErr <- tt.server(server)
+			}()
+
+			err := tt.dialer.negotiate(client, tt.host, 443)
+			client.Close()
+			srvErr := <-serverErr
+
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("negotiate error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantServer && srvErr != nil {
+				t.Fatalf("server: %v", srvErr)
+			}
+		})
+	}
+}
+
+// connectProxy serves one HTTP CONNECT: checks the request, answers status and, on 200, a server-first greeting.
+func connectProxy(t *testing.T, status int, wantAuth string) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			done <- err
+			return
+		}
+		if req.Method != http.MethodConnect || req.Host != "example.com:25" {
+			done <- fmt.Errorf("unexpected request %s %s", req.Method, req.Host)
+			return
+		}
+		if got := req.Header.Get("Proxy-Authorization"); got != wantAuth {
+			done <- fmt.Errorf("Proxy-Authorization = %q, want %q", got, wantAuth)
+			return
+		}
+
+		// The greeting is written together with the response: it lands in the dialer's read buffer
+		fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\n\r\n", status, http.StatusText(status))
+		if status == http.StatusOK {
+			io.WriteString(conn, "220 mail.example.com ESMTP\r\n")
+		}
+		done <- nil
+	}()
+	return ln.Addr().String(), done
+}
+
+func TestHTTPConnectDialer(t *testing.T) {
+	tests := []struct {
+		name     string
+		proxyURL string // "%s" is replaced by the proxy address
+		status   int
+		wantAuth string
+		wantErr  bool
+	}{
+		{name: "tunnel", proxyURL: "http://%s", status: http.StatusOK},
+		{name: "basic auth", proxyURL: "http://user:pass@%s", status: http.StatusOK, wantAuth: "Basic dXNlcjpwYXNz"},
+		{name: "refused", proxyURL: "http://%s", status: http.StatusProxyAuthRequired, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, done := connectProxy(t, tt.status, tt.wantAuth)
+			d, err := NewDialer(fmt.Sprintf(tt.proxyURL, addr), 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := d.DialContext(ctx, "tcp", "example.com:25")
+			if srvErr := <-done; srvErr != nil {
+				t.Fatalf("proxy: %v", srvErr)
+			}
+			if tt.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dial failed: %v", err)
+			}
+			defer conn.Close()
+
+			// Bytes the server sent right after the 200 must not be lost in the handshake buffer
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil || line != "220 mail.example.com ESMTP\r\n" {
+				t.Fatalf("greeting = %q, %v", line, err)
+			}
+		})
+	}
+}
+
+func TestDialerSetMandatoryProxy(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultProxy string
+		mandatory    bool
+		wantDirect   bool
+	}{
+		{name: "no proxy configured", mandatory: true, wantDirect: true},
+		{name: "optional proxy", defaultProxy: "socks5://127.0.0.1:1080", wantDirect: true},
+		{name: "mandatory proxy", defaultProxy: "socks5://127.0.0.1:1080", mandatory: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := NewDialerSet(tt.defaultProxy, nil, tt.mandatory, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := set.For(ProxyDirect); (err == nil) != tt.wantDirect {
+				t.Fatalf("For(direct) error = %v, want direct allowed: %v", err, tt.wantDirect)
+			}
+		})
+	}
+}
+
+// recordingDialer stands in for a proxy dialer: it records the address it was asked to reach.
+type recordingDialer struct {
+	addr string
+}
+
+func (d *recordingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.addr = addr
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func TestGuardedDialerThroughProxy(t *testing.T) {
+	policy, err := egress.NewPolicy(true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.AllowProxyResolved([]string{".corp.example", "intranet.example"})
+	notFound := &net.DNSError{Err: "no such host", Name: "unknown.example", IsNotFound: true}
+
+	tests := []struct {
+		name        string
+		addr        string
+		resolver    staticResolver
+		wantBlocked bool
+		wantErr     bool
+		wantAddr    string
+	}{
+		{name: "public name goes to the proxy as its vetted ip", addr: "example.com:443", resolver: staticResolver{addrs: []string{"93.184.215.14"}}, wantAddr: "93.184.215.14:443"},
+		{name: "private answers are skipped", addr: "example.com:443", resolver: staticResolver{addrs: []string{"10.0.0.5", "93.184.215.14"}}, wantAddr: "93.184.215.14:443"},
+		{name: "rebinding name", addr: "example.com:443", resolver: staticResolver{addrs: []string{"127.0.0.1"}}, wantBlocked: true},
+		{name: "metadata ip", addr: "169.254.169.254:80", wantBlocked: true},
+		{name: "name that doesn't resolve here", addr: "unknown.example:443", resolver: staticResolver{err: notFound}, wantErr: true},
+		{name: "allow-listed zone goes by name", addr: "app.corp.example:443", resolver: staticResolver{err: notFound}, wantAddr: "app.corp.example:443"},
+		{name: "allow-listed name goes by name", addr: "intranet.example:443", resolver: staticResolver{err: notFound}, wantAddr: "intranet.example:443"},
+		{name: "allow-listed name still vetted locally", addr: "app.corp.example:443", resolver: staticResolver{addrs: []string{"169.254.169.254"}}, wantBlocked: true},
+		{name: "allow-list doesn't cover the parent", addr: "corp.example:443", resolver: staticResolver{err: notFound}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &recordingDialer{}
+			g := &guardedDialer{next: proxy, policy: policy, resolver: tt.resolver}
+
+			conn, err := g.DialContext(context.Background(), "tcp", tt.addr)
+			if tt.wantBlocked || tt.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatalf("expected an error, proxy asked for %q", proxy.addr)
+				}
+				var blocked *egress.BlockedError
+				if tt.wantBlocked && !errors.As(err, &blocked) {
+					t.Fatalf("expected a BlockedError, got %v", err)
+				}
+				if proxy.addr != "" {
+					t.Fatalf("rejected destination reached the proxy as %q", proxy.addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Close()
+			if proxy.addr != tt.wantAddr {
+				t.Fatalf("proxy asked for %q, want %q", proxy.addr, tt.wantAddr)
+			}
+		})
+	}
+}
+
+// tunnelProxy is an HTTP CONNECT proxy that tunnels to whatever it is asked for, recording the CONNECT targets.
+func tunnelProxy(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	targets := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err != nil {
+					return
+				}
+				targets <- req.Host
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					fmt.Fprintf(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer upstream.Close()
+				fmt.Fprintf(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+				go io.Copy(upstream, br)
+				io.Copy(conn, upstream)
+			}(conn)
+		}
+	}()
+	return ln.Addr().String(), targets
+}
+
+// TestScanThroughProxyByIP scans a name through a CONNECT proxy: the proxy is asked for the vetted IP,
+// and the server still sees the name as SNI.
+func TestScanThroughProxyByIP(t *testing.T) {
+	cert := newTestPKI(t).issue(t, "app.example.test", 301, "")
+	sni := make(chan string, 10)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			sni <- hello.ServerName
+			return &cert, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				c.(*tls.Conn).Handshake()
+			}(conn)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	proxyAddr, targets := tunnelProxy(t)
+	s := NewTLSScanner(5 * time.Second)
+	if s.Dialers, err = NewDialerSet("http://"+proxyAddr, nil, true, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := egress.NewPolicy(true, []string{"127.0.0.1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Dialers.Guard(policy, staticResolver{addrs: []string{"127.0.0.1"}})
+
+	certs, err := s.Scan(context.Background(), model.Target{ID: "t1", TargetURL: "app.example.test:" + port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) == 0 || certs[0].Subject.CN != "app.example.test" {
+		t.Fatalf("unexpected certificates %+v", certs)
+	}
+	if got := <-targets; got != "127.0.0.1:"+port {
+		t.Fatalf("proxy asked for %q, want the vetted ip 127.0.0.1:%s", got, port)
+	}
+	if got := <-sni; got != "app.example.test" {
+		t.Fatalf("server saw SNI %q, want app.example.test", got)
+	}
+}
@@ -0,0 +1,303 @@
+package scanner
+
+import (
+	"bytes"
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxDocumentSize caps a fetched SAML metadata / JWKS document (federation metadata can be large).
+const maxDocumentSize = 10 << 20
+
+// embeddedKey is one key published by a document: its certificate chain (leaf first) and stable identity.
+type embeddedKey struct {
+	ID    string // "entityID/use" or the JWKS kid
+	Certs []*x509.Certificate
+}
+
+// scanDocument fetches a SAML metadata / JWKS document and converts every embedded certificate.
+// The document is fetched through the target's proxy and the egress guard, like a handshake.
+func (s *TLSScanner) scanDocument(ctx context.Context, t model.Target) ([]model.Certificate, error) {
+	timing := &model.ScanTiming{}
+	body, err := s.fetchDocument(withDialTiming(ctx, timing), t, timing)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Extract the keys
+	var keys []embeddedKey
+	switch t.Kind {
+	case model.TargetKindSAMLMetadata:
+		keys, err = parseSAMLMetadata(body)
+	case model.TargetKindJWKS:
+		keys, err = parseJWKS(body)
+	default:
+		return nil, fmt.Errorf("unsupported target kind %q", t.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no certificates found in document")
+	}
+
+	// 2. Convert: one source per key, its x5c / X509Data certs form the chain.
+	// Trust is not judged here: relying parties pin these keys, they are not verified against a CA.
+	seen := map[string]int{}
+	var certs []model.Certificate
+	for _, key := range keys {
+		sourceUID := t.TargetURL + "#" + key.ID
+		if seen[sourceUID]++; seen[sourceUID] > 1 {
+			sourceUID += "#" + strconv.Itoa(seen[sourceUID]) // e.g. two signing keys during a rollover
+		}
+
+		for i, c := range key.Certs {
+			cert := CertificateFromX509(c, sourceUID)
+			cert.SourceType = model.SourceTypeMetadata
+			cert.ChainPosition = i
+			cert.IsTrusted = true
+			if i == 0 && len(certs) == 0 {
+				cert.Timing = timing
+			}
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+// fetchDocument GETs the document, verifying the server against the System Roots and the owner's Trust Store.
+func (s *TLSScanner) fetchDocument(ctx context.Context, t model.Target, timing *model.ScanTiming) ([]byte, error) {
+	dialer, err := s.Dialers.For(t.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{RootCAs: trustRoots(t.CustomRootsPEM)}
+	if t.ClientCert != nil {
+		tlsCfg.Certificates = []tls.Certificate{*t.ClientCert}
+	}
+	client := &http.Client{
+		Timeout: s.Timeout,
+		Transport: &http.Transport{
+			DialContext:       dialer.DialContext,
+			TLSClientConfig:   tlsCfg,
+			DisableKeepAlives: true,
+		},
+		// Redirects are dialed through the same guarded transport
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			return nil
+		},
+	}
+
+	var handshakeStart time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		TLSHandshakeStart: func() { handshakeStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timing.Handshake = time.Since(handshakeStart)
+		},
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.TargetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/samlmetadata+xml, application/xml, application/json;q=0.9, */*;q=0.8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch failed: unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+	if len(body) > maxDocumentSize {
+		return nil, fmt.Errorf("document exceeds %d MB", maxDocumentSize>>20)
+	}
+	return body, nil
+}
+
+// parseSAMLMetadata extracts the certificates of every KeyDescriptor (and of the metadata's own signature).
+// Keys are identified as "entityID/use"; a KeyDescriptor without a use attribute serves both purposes.
+func parseSAMLMetadata(body []byte) ([]embeddedKey, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+
+	var (
+		keys     []embeddedKey
+		entities []string // entityID / Name of the enclosing (Entity|Entities)Descriptor elements
+		current  *embeddedKey
+		inCert   bool
+		certText strings.Builder
+		depth    int
+		keyDepth int // depth of the KeyDescriptor / Signature that owns current
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid SAML metadata: %w", err)
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch el.Name.Local {
+			case "EntityDescriptor":
+				entities = append(entities, xmlAttr(el, "entityID"))
+			case "EntitiesDescriptor":
+				entities = append(entities, xmlAttr(el, "Name"))
+			case "KeyDescriptor":
+				use := xmlAttr(el, "use")
+				if use == "" {
+					use = "signing+encryption"
+				}
+				current, keyDepth = &embeddedKey{ID: lastOf(entities) + "/" + use}, depth
+			case "Signature":
+				if current == nil {
+					current, keyDepth = &embeddedKey{ID: lastOf(entities) + "/metadata-signature"}, depth
+				}
+			case "X509Certificate":
+				inCert = current != nil
+				certText.Reset()
+			}
+
+		case xml.CharData:
+			if inCert {
+				certText.Write(el)
+			}
+
+		case xml.EndElement:
+			switch el.Name.Local {
+			case "EntityDescriptor", "EntitiesDescriptor":
+				if len(entities) > 0 {
+					entities = entities[:len(entities)-1]
+				}
+			case "X509Certificate":
+				if inCert {
+					c, err := parseBase64Cert(certText.String())
+					if err != nil {
+						return nil, fmt.Errorf("invalid certificate for %s: %w", current.ID, err)
+					}
+					current.Certs = append(current.Certs, c)
+					inCert = false
+				}
+			}
+			if current != nil && depth == keyDepth {
+				if len(current.Certs) > 0 {
+					keys = append(keys, *current)
+				}
+				current = nil
+			}
+			depth--
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("invalid SAML metadata: unexpected end of document")
+	}
+	return keys, nil
+}
+
+// jwks is the subset of an RFC 7517 JWK Set we need.
+type jwks struct {
+	Keys []struct {
+		Kid string   `json:"kid"`
+		Use string   `json:"use"`
+		X5c []string `json:"x5c"`
+		X5t string   `json:"x5t"`
+	} `json:"keys"`
+}
+
+// parseJWKS extracts the x5c chain of every key. Keys without x5c (bare public keys) carry no certificate.
+// Keys are identified by kid, falling back to x5t, then to the SHA-1 thumbprint of the leaf.
+func parseJWKS(body []byte) ([]embeddedKey, error) {
+	var set jwks
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	var keys []embeddedKey
+	for i, k := range set.Keys {
+		if len(k.X5c) == 0 {
+			continue
+		}
+
+		key := embeddedKey{ID: k.Kid}
+		for _, raw := range k.X5c {
+			c, err := parseBase64Cert(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid x5c in key %d: %w", i, err)
+			}
+			key.Certs = append(key.Certs, c)
+		}
+
+		if key.ID == "" {
+			key.ID = k.X5t
+		}
+		if key.ID == "" {
+			thumbprint := sha1.Sum(key.Certs[0].Raw)
+			key.ID = hex.EncodeToString(thumbprint[:])
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parseBase64Cert decodes a base64 DER certificate (line breaks & indentation allowed, as in XML).
+func parseBase64Cert(raw string) (*x509.Certificate, error) {
+	raw = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, raw)
+
+	der, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
+
+func lastOf(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[len(list)-1]
+}
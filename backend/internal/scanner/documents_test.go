@@ -0,0 +1,186 @@
+package scanner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertB64 returns a fresh self-signed certificate, base64 encoded as in SAML metadata / x5c.
+func testCertB64(t *testing.T, cn string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// wrapped splits b64 into indented 64 character lines, as XML signers emit it.
+func wrapped(b64 string) string {
+	var sb strings.Builder
+	for len(b64) > 64 {
+		sb.WriteString("\n        " + b64[:64])
+		b64 = b64[64:]
+	}
+	sb.WriteString("\n        " + b64 + "\n      ")
+	return sb.String()
+}
+
+func TestParseSAMLMetadata(t *testing.T) {
+	signing, encryption, both, metaSig := testCertB64(t, "signing"), testCertB64(t, "encryption"), testCertB64(t, "both"), testCertB64(t, "meta")
+
+	tests := []struct {
+		name    string
+		doc     string
+		want    map[string]string // key ID -> CN of its certificate
+		wantErr bool
+	}{
+		{
+			name: "signing and encryption keys",
+			doc: fmt.Sprintf(`<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor>
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor use="encryption"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`, wrapped(signing), encryption),
+			want: map[string]string{"https://idp.example.com/signing": "signing", "https://idp.example.com/encryption": "encryption"},
+		},
+		{
+			name: "key without use, metadata signature, nested entities",
+			doc: fmt.Sprintf(`<EntitiesDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" Name="federation">
+  <Signature xmlns="http://www.w3.org/2000/09/xmldsig#"><KeyInfo><X509Data><X509Certificate>%s</X509Certificate></X509Data></KeyInfo></Signature>
+  <EntityDescriptor entityID="https://sp.example.com">
+    <SPSSODescriptor><KeyDescriptor><KeyInfo><X509Data><X509Certificate>%s</X509Certificate></X509Data></KeyInfo></KeyDescriptor></SPSSODescriptor>
+  </EntityDescriptor>
+</EntitiesDescriptor>`, metaSig, both),
+			want: map[string]string{"federation/metadata-signature": "meta", "https://sp.example.com/signing+encryption": "both"},
+		},
+		{
+			name: "no keys",
+			doc:  `<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://empty.example.com"/>`,
+			want: map[string]string{},
+		},
+		{
+			name:    "invalid certificate",
+			doc:     `<EntityDescriptor entityID="x"><KeyDescriptor use="signing"><X509Certificate>bm90IGEgY2VydA==</X509Certificate></KeyDescriptor></EntityDescriptor>`,
+			wantErr: true,
+		},
+		{
+			name:    "truncated document",
+			doc:     `<EntityDescriptor entityID="x"><KeyDescriptor use="signing">`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseSAMLMetadata([]byte(tt.doc))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d keys", len(keys))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertKeys(t, keys, tt.want)
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	leaf, intermediate := testCertB64(t, "leaf"), testCertB64(t, "intermediate")
+	leafDER, _ := base64.StdEncoding.DecodeString(leaf)
+	thumbprint := sha1.Sum(leafDER)
+
+	tests := []struct {
+		name      string
+		doc       string
+		want      map[string]string
+		wantChain int
+		wantErr   bool
+	}{
+		{
+			name:      "kid with an x5c chain",
+			doc:       fmt.Sprintf(`{"keys":[{"kty":"EC","kid":"key-1","x5c":["%s","%s"]}]}`, leaf, intermediate),
+			want:      map[string]string{"key-1": "leaf"},
+			wantChain: 2,
+		},
+		{
+			name: "falls back to x5t",
+			doc:  fmt.Sprintf(`{"keys":[{"kty":"EC","x5t":"abc123","x5c":["%s"]}]}`, leaf),
+			want: map[string]string{"abc123": "leaf"},
+		},
+		{
+			name: "falls back to the leaf thumbprint",
+			doc:  fmt.Sprintf(`{"keys":[{"kty":"EC","x5c":["%s"]}]}`, leaf),
+			want: map[string]string{hex.EncodeToString(thumbprint[:]): "leaf"},
+		},
+		{
+			name: "bare public keys carry no certificate",
+			doc:  `{"keys":[{"kty":"RSA","kid":"bare","n":"AQAB","e":"AQAB"}]}`,
+			want: map[string]string{},
+		},
+		{name: "invalid x5c", doc: `{"keys":[{"kid":"k","x5c":["bm90IGEgY2VydA=="]}]}`, wantErr: true},
+		{name: "not json", doc: `<html>`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseJWKS([]byte(tt.doc))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d keys", len(keys))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertKeys(t, keys, tt.want)
+			if tt.wantChain > 0 && len(keys[0].Certs) != tt.wantChain {
+				t.Fatalf("chain length = %d, want %d", len(keys[0].Certs), tt.wantChain)
+			}
+		})
+	}
+}
+
+// assertKeys checks the key IDs and the CN of each key's first certificate.
+func assertKeys(t *testing.T, keys []embeddedKey, want map[string]string) {
+	t.Helper()
+	if len(keys) != len(want) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(want))
+	}
+	for _, k := range keys {
+		cn, ok := want[k.ID]
+		if !ok {
+			t.Fatalf("unexpected key %q", k.ID)
+		}
+		if got := k.Certs[0].Subject.CommonName; got != cn {
+			t.Fatalf("key %q: CN = %q, want %q", k.ID, got, cn)
+		}
+	}
+}
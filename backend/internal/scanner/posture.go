@@ -0,0 +1,213 @@
+package scanner
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// probeVersions is the set of protocol versions we test, oldest first.
+var probeVersions = []uint16{
+	tls.VersionTLS10,
+	tls.VersionTLS11,
+	tls.VersionTLS12,
+	tls.VersionTLS13,
+}
+
+// PostureCoverage states what the audit cannot see. It is returned with every posture.
+const PostureCoverage = "Cipher suites are probed with Go's crypto/tls, which only offers the suites it implements " +
+	"(ECDHE and static RSA key exchange with AES-GCM, AES-CBC, ChaCha20-Poly1305, 3DES or RC4). " +
+	"Suites it lacks (DHE, CAMELLIA, ARIA, AES-CCM...) are never offered: a version the server accepts only with those " +
+	"is reported as unsupported, and they don't count towards forward secrecy."
+
+// AuditPosture probes which TLS versions and cipher suites the target accepts, then grades the result.
+// Every probe is a separate handshake, so this is considerably slower than Scan.
+// Only the primary endpoint (TargetURL's port, as dialed by Scan) is audited.
+func (s *TLSScanner) AuditPosture(ctx context.Context, t model.Target) (*model.TLSPosture, error) {
+	ep := t.Endpoints()[0]
+	addr, host := ep.Addr, ep.ServerName
+
+	posture := &model.TLSPosture{
+		TargetID:  t.ID,
+		ScannedAt: time.Now(),
+		Coverage:  PostureCoverage,
+	}
+
+	for _, v := range probeVersions {
+		support := model.TLSVersionSupport{Version: tls.VersionName(v)}
+
+		if v == tls.VersionTLS13 {
+			// TLS 1.3 suites are not configurable in crypto/tls: record the negotiated one.
+			res, err := s.handshake(ctx, t, addr, probeConfig(t, host, v, nil))
+			if err == nil {
+				support.Supported = true
+				support.CipherSuites = []string{tls.CipherSuiteName(res.State.CipherSuite)}
+			}
+		} else {
+			// TLS 1.0 - 1.2: offer one suite at a time to enumerate everything the server accepts.
+			for _, suite := range suitesForVersion(v) {
+				if _, err := s.handshake(ctx, t, addr, probeConfig(t, host, v, []uint16{suite.ID})); err == nil {
+					support.Supported = true
+					support.CipherSuites = append(support.CipherSuites, suite.Name)
+				}
+			}
+		}
+
+		// Abort early if the caller gave up (Timeout / Shutdown)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("posture audit aborted: %w", ctx.Err())
+		}
+
+		posture.Versions = append(posture.Versions, support)
+	}
+
+	supported := false
+	for _, v := range posture.Versions {
+		supported = supported || v.Supported
+	}
+	if !supported {
+		return nil, fmt.Errorf("posture audit failed: no TLS version could be negotiated")
+	}
+
+	posture.Findings = evaluatePosture(posture.Versions)
+	posture.Grade = gradeFindings(posture.Findings)
+	return posture, nil
+}
+
+// probeConfig pins the handshake to exactly one version (and optionally a suite list).
+// A handshake rejected for lack of a client cert still counts: version and suite were negotiated.
+func probeConfig(t model.Target, host string, version uint16, suites []uint16) *tls.Config {
+	cfg := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         version,
+		MaxVersion:         version,
+		CipherSuites:       suites,
+	}
+	if t.ClientCert != nil {
+		cfg.Certificates = []tls.Certificate{*t.ClientCert}
+	}
+	return cfg
+}
+
+// suitesForVersion lists every suite crypto/tls can offer (secure and insecure) for a pre-1.3 version.
+func suitesForVersion(version uint16) []*tls.CipherSuite {
+	var out []*tls.CipherSuite
+	all := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	for _, cs := range all {
+		for _, sv := range cs.SupportedVersions {
+			if sv == version {
+				out = append(out, cs)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// evaluatePosture turns the raw probe results into findings.
+func evaluatePosture(versions []model.TLSVersionSupport) []model.PostureFinding {
+	findings := []model.PostureFinding{}
+
+	var legacy []string
+	var rc4, tripleDES, noFS []string
+	fsAvailable := false
+	tls13 := false
+
+	for _, v := range versions {
+		if !v.Supported {
+			continue
+		}
+		switch v.Version {
+		case tls.VersionName(tls.VersionTLS10), tls.VersionName(tls.VersionTLS11):
+			legacy = append(legacy, v.Version)
+		case tls.VersionName(tls.VersionTLS13):
+			tls13 = true
+			fsAvailable = true // TLS 1.3 key exchange is always ephemeral
+			continue
+		}
+
+		for _, name := range v.CipherSuites {
+			switch {
+			case strings.Contains(name, "_RC4_"):
+				rc4 = appendUnique(rc4, name)
+			case strings.Contains(name, "_3DES_"):
+				tripleDES = appendUnique(tripleDES, name)
+			}
+			// Static RSA key exchange ("TLS_RSA_WITH_...") has no forward secrecy
+			if strings.HasPrefix(name, "TLS_RSA_") {
+				noFS = appendUnique(noFS, name)
+			} else {
+				fsAvailable = true
+			}
+		}
+	}
+
+	if len(rc4) > 0 {
+		findings = append(findings, model.PostureFinding{
+			Code: "RC4", Severity: model.SeverityCritical,
+			Message: "RC4 cipher suites accepted: " + strings.Join(rc4, ", "),
+		})
+	}
+	if len(tripleDES) > 0 {
+		findings = append(findings, model.PostureFinding{
+			Code: "3DES", Severity: model.SeverityHigh,
+			Message: "3DES cipher suites accepted (SWEET32): " + strings.Join(tripleDES, ", "),
+		})
+	}
+	if len(legacy) > 0 {
+		findings = append(findings, model.PostureFinding{
+			Code: "LEGACY_TLS", Severity: model.SeverityHigh,
+			Message: "Deprecated protocol versions accepted: " + strings.Join(legacy, ", "),
+		})
+	}
+	if !fsAvailable {
+		findings = append(findings, model.PostureFinding{
+			Code: "NO_FORWARD_SECRECY", Severity: model.SeverityHigh,
+			Message: "No cipher suite with forward secrecy (ECDHE) is accepted. DHE suites are not probed",
+		})
+	} else if len(noFS) > 0 {
+		findings = append(findings, model.PostureFinding{
+			Code: "NO_FORWARD_SECRECY", Severity: model.SeverityMedium,
+			Message: "Cipher suites without forward secrecy accepted: " + strings.Join(noFS, ", "),
+		})
+	}
+	if !tls13 {
+		findings = append(findings, model.PostureFinding{
+			Code: "NO_TLS13", Severity: model.SeverityLow,
+			Message: "TLS 1.3 is not supported",
+		})
+	}
+
+	return findings
+}
+
+// gradeFindings maps the worst finding severity to a letter grade.
+func gradeFindings(findings []model.PostureFinding) string {
+	grade := "A"
+	for _, f := range findings {
+		switch f.Severity {
+		case model.SeverityCritical:
+			return "F"
+		case model.SeverityHigh:
+			grade = "C"
+		case model.SeverityMedium:
+			if grade == "A" {
+				grade = "B"
+			}
+		}
+	}
+	return grade
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, existing := range list {
+		if existing == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
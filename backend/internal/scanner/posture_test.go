@@ -0,0 +1,187 @@
+package scanner
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluatePosture(t *testing.T) {
+	v := func(version uint16, suites ...string) model.TLSVersionSupport {
+		return model.TLSVersionSupport{Version: tls.VersionName(version), Supported: true, CipherSuites: suites}
+	}
+	off := func(version uint16) model.TLSVersionSupport {
+		return model.TLSVersionSupport{Version: tls.VersionName(version)}
+	}
+	const (
+		ecdhe  = "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+		rsaKex = "TLS_RSA_WITH_AES_128_GCM_SHA256"
+		rc4    = "TLS_ECDHE_RSA_WITH_RC4_128_SHA"
+		des3   = "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA"
+		tls13  = "TLS_AES_128_GCM_SHA256"
+	)
+
+	tests := []struct {
+		name      string
+		versions  []model.TLSVersionSupport
+		wantCodes map[string]model.FindingSeverity
+		wantGrade string
+	}{
+		{
+			name:      "modern",
+			versions:  []model.TLSVersionSupport{off(tls.VersionTLS10), off(tls.VersionTLS11), v(tls.VersionTLS12, ecdhe), v(tls.VersionTLS13, tls13)},
+			wantCodes: map[string]model.FindingSeverity{},
+			wantGrade: "A",
+		},
+		{
+			name:      "no tls 1.3",
+			versions:  []model.TLSVersionSupport{v(tls.VersionTLS12, ecdhe), off(tls.VersionTLS13)},
+			wantCodes: map[string]model.FindingSeverity{"NO_TLS13": model.SeverityLow},
+			wantGrade: "A",
+		},
+		{
+			name:      "static rsa next to ecdhe",
+			versions:  []model.TLSVersionSupport{v(tls.VersionTLS12, ecdhe, rsaKex), v(tls.VersionTLS13, tls13)},
+			wantCodes: map[string]model.FindingSeverity{"NO_FORWARD_SECRECY": model.SeverityMedium},
+			wantGrade: "B",
+		},
+		{
+			name:      "static rsa only",
+			versions:  []model.TLSVersionSupport{v(tls.VersionTLS12, rsaKex), off(tls.VersionTLS13)},
+			wantCodes: map[string]model.FindingSeverity{"NO_FORWARD_SECRECY": model.SeverityHigh, "NO_TLS13": model.SeverityLow},
+			wantGrade: "C",
+		},
+		{
+			name:      "tls 1.3 provides forward secrecy",
+			versions:  []model.TLSVersionSupport{v(tls.VersionTLS12, rsaKex), v(tls.VersionTLS13, tls13)},
+			wantCodes: map[string]model.FindingSeverity{"NO_FORWARD_SECRECY": model.SeverityMedium},
+			wantGrade: "B",
+		},
+		{
+			name:      "legacy versions",
+			versions:  []model.TLSVersionSupport{v(tls.VersionTLS10, ecdhe), v(tls.VersionTLS11, ecdhe), v(tls.VersionTLS12, ecdhe), v(tls.VersionTLS13, tls13)},
+			wantCodes: map[string]model.FindingSeverity{"LEGACY_TLS": model.SeverityHigh},
+			wantGrade: "C",
+		},
+		{
+			name:      "3des",
+			versions:  []model.TLSVersionSupport{v(tls.VersionTLS12, ecdhe, des3), v(tls.VersionTLS13, tls13)},
+			wantCodes: map[string]model.FindingSeverity{"3DES": model.SeverityHigh},
+			wantGrade: "C",
+		},
+		{
+			name:      "rc4 fails the grade",
+			versions:  []model.TLSVersionSupport{v(tls.VersionTLS10, rc4), v(tls.VersionTLS12, ecdhe, des3), v(tls.VersionTLS13, tls13)},
+			wantCodes: map[string]model.FindingSeverity{"RC4": model.SeverityCritical, "3DES": model.SeverityHigh, "LEGACY_TLS": model.SeverityHigh},
+			wantGrade: "F",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := evaluatePosture(tt.versions)
+			got := map[string]model.FindingSeverity{}
+			for _, f := range findings {
+				got[f.Code] = f.Severity
+			}
+			if !reflect.DeepEqual(got, tt.wantCodes) {
+				t.Fatalf("findings = %v, want %v", got, tt.wantCodes)
+			}
+			if grade := gradeFindings(findings); grade != tt.wantGrade {
+				t.Fatalf("grade = %s, want %s", grade, tt.wantGrade)
+			}
+		})
+	}
+}
+
+// TestAuditPosture audits a local TLS 1.2 only server accepting one ECDHE and one static RSA suite.
+func TestAuditPosture(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "posture.test"},
+		DNSNames:     []string{"posture.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_GCM_SHA256},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				c.(*tls.Conn).Handshake()
+			}(conn)
+		}
+	}()
+
+	s := NewTLSScanner(5 * time.Second)
+	posture, err := s.AuditPosture(context.Background(), model.Target{ID: "t1", TargetURL: ln.Addr().String(), ServerName: "posture.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range posture.Versions {
+		wantSupported := v.Version == tls.VersionName(tls.VersionTLS12)
+		if v.Supported != wantSupported {
+			t.Fatalf("%s supported = %v, want %v", v.Version, v.Supported, wantSupported)
+		}
+		if wantSupported {
+			want := []string{"TLS_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}
+			if !sameSet(v.CipherSuites, want) {
+				t.Fatalf("TLS 1.2 suites = %q, want %q", v.CipherSuites, want)
+			}
+		}
+	}
+	if posture.Grade != "B" {
+		t.Fatalf("grade = %s, want B (findings: %v)", posture.Grade, posture.Findings)
+	}
+	if posture.Coverage != PostureCoverage {
+		t.Fatalf("coverage note missing: %q", posture.Coverage)
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := map[string]bool{}
+	for _, s := range a {
+		seen[s] = true
+	}
+	for _, s := range b {
+		if !seen[s] {
+			return false
+		}
+	}
+	return true
+}
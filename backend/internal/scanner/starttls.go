@@ -0,0 +1,334 @@
+package scanner
+
+import (
+	"bufio"
+	"cert-manager-backend/internal/model"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// upgradeFunc speaks the plaintext part of a protocol until the server is ready for a ClientHello.
+type upgradeFunc func(conn net.Conn) error
+
+// upgraders maps each STARTTLS-capable protocol to its negotiation logic.
+// ProtocolTLS (and empty) is absent on purpose: implicit TLS needs no upgrade.
+var upgraders = map[model.ScanProtocol]upgradeFunc{
+	model.ProtocolSMTP:     upgradeSMTP,
+	model.ProtocolIMAP:     upgradeIMAP,
+	model.ProtocolPOP3:     upgradePOP3,
+	model.ProtocolFTP:      upgradeFTP,
+	model.ProtocolLDAP:     upgradeLDAP,
+	model.ProtocolPostgres: upgradePostgres,
+	model.ProtocolMySQL:    upgradeMySQL,
+}
+
+// startTLS performs the protocol-specific upgrade on a freshly dialed connection.
+func startTLS(conn net.Conn, protocol model.ScanProtocol) error {
+	if protocol == "" || protocol == model.ProtocolTLS {
+		return nil
+	}
+
+	upgrade, ok := upgraders[protocol]
+	if !ok {
+		return fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+
+	if err := upgrade(conn); err != nil {
+		return fmt.Errorf("%s starttls failed: %w", strings.ToLower(string(protocol)), err)
+	}
+	return nil
+}
+
+// --- Line Based Protocols ---
+
+// upgradeSMTP: 220 Greeting -> EHLO -> STARTTLS -> 220 (RFC 3207)
+func upgradeSMTP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+
+	if _, err := readReplyCode(r, "220"); err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if err := writeLine(conn, "EHLO certmonitor.local"); err != nil {
+		return err
+	}
+	ehlo, err := readReplyCode(r, "250")
+	if err != nil {
+		return fmt.Errorf("ehlo: %w", err)
+	}
+	if !strings.Contains(strings.ToUpper(ehlo), "STARTTLS") {
+		return fmt.Errorf("server does not advertise STARTTLS")
+	}
+	if err := writeLine(conn, "STARTTLS"); err != nil {
+		return err
+	}
+	_, err = readReplyCode(r, "220")
+	return err
+}
+
+// upgradeFTP: 220 Greeting -> AUTH TLS -> 234 (RFC 4217)
+func upgradeFTP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+
+	if _, err := readReplyCode(r, "220"); err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if err := writeLine(conn, "AUTH TLS"); err != nil {
+		return err
+	}
+	_, err := readReplyCode(r, "234")
+	return err
+}
+
+// upgradeIMAP: * OK Greeting -> a001 STARTTLS -> a001 OK (RFC 2595)
+func upgradeIMAP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+
+	greeting, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected greeting: %q", strings.TrimSpace(greeting))
+	}
+	if err := writeLine(conn, "a001 STARTTLS"); err != nil {
+		return err
+	}
+
+	// Skip untagged responses until our tagged completion arrives
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "a001 ") {
+			if !strings.HasPrefix(line, "a001 OK") {
+				return fmt.Errorf("server refused STARTTLS: %q", strings.TrimSpace(line))
+			}
+			return nil
+		}
+	}
+}
+
+// upgradePOP3: +OK Greeting -> STLS -> +OK (RFC 2595)
+func upgradePOP3(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+
+	greeting, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("unexpected greeting: %q", strings.TrimSpace(greeting))
+	}
+	if err := writeLine(conn, "STLS"); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("server refused STLS: %q", strings.TrimSpace(line))
+	}
+	return nil
+}
+
+// readReplyCode reads a (possibly multi-line) SMTP/FTP style reply and checks its code.
+// Multi-line replies use "250-..." for continuation and "250 ..." for the final line.
+func readReplyCode(r *bufio.Reader, want string) (string, error) {
+	var sb strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(line)
+
+		if len(line) < 4 || line[:3] != want {
+			return "", fmt.Errorf("expected %s, got %q", want, strings.TrimSpace(line))
+		}
+		if line[3] == ' ' || line[3] == '\r' || line[3] == '\n' {
+			return sb.String(), nil
+		}
+	}
+}
+
+func writeLine(conn net.Conn, line string) error {
+	_, err := io.WriteString(conn, line+"\r\n")
+	return err
+}
+
+// --- Binary Protocols ---
+
+// ldapStartTLSRequest is a pre-encoded LDAPv3 ExtendedRequest (messageID 1) for
+// the StartTLS OID 1.3.6.1.4.1.1466.20037 (RFC 4511, Section 4.14).
+var ldapStartTLSRequest = append([]byte{
+	0x30, 0x1d, // LDAPMessage SEQUENCE
+	0x02, 0x01, 0x01, // messageID INTEGER 1
+	0x77, 0x18, // [APPLICATION 23] ExtendedRequest
+	0x80, 0x16, // [0] requestName (22 bytes)
+}, []byte("1.3.6.1.4.1.1466.20037")...)
+
+// upgradeLDAP sends the StartTLS ExtendedRequest and checks the ExtendedResponse resultCode.
+func upgradeLDAP(conn net.Conn) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+
+	// LDAPMessage SEQUENCE
+	if err := expectBERTag(r, 0x30); err != nil {
+		return err
+	}
+	// messageID INTEGER
+	if err := expectBERTag(r, 0x02); err != nil {
+		return err
+	}
+	idLen, err := readBERLength(r)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Discard(idLen); err != nil {
+		return err
+	}
+	// [APPLICATION 24] ExtendedResponse
+	if err := expectBERTag(r, 0x78); err != nil {
+		return err
+	}
+	// resultCode ENUMERATED
+	if err := expectBERTag(r, 0x0a); err != nil {
+		return err
+	}
+	codeLen, err := readBERLength(r)
+	if err != nil {
+		return err
+	}
+	if codeLen != 1 {
+		return fmt.Errorf("unexpected resultCode length %d", codeLen)
+	}
+	code, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("server refused StartTLS (resultCode %d)", code)
+	}
+	return nil
+}
+
+// expectBERTag reads a tag byte and, for constructed tags, skips the length header.
+func expectBERTag(r *bufio.Reader, want byte) error {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if tag != want {
+		return fmt.Errorf("unexpected BER tag 0x%02x (want 0x%02x)", tag, want)
+	}
+	// Constructed types (SEQUENCE / APPLICATION): we descend into them, so just consume the length
+	if tag&0x20 != 0 {
+		_, err = readBERLength(r)
+	}
+	return err
+}
+
+func readBERLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b&0x80 == 0 {
+		return int(b), nil
+	}
+
+	// Long form: low bits hold the number of length octets
+	n := int(b & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, fmt.Errorf("unsupported BER length encoding")
+	}
+	length := 0
+	for i := 0; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
+
+// upgradePostgres sends an SSLRequest and expects 'S' (Protocol 3.0 message flow).
+func upgradePostgres(conn net.Conn) error {
+	req := make([]byte, 8)
+	binary.BigEndian.PutUint32(req[0:4], 8)
+	binary.BigEndian.PutUint32(req[4:8], 80877103) // SSLRequest code
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	resp := make([]byte, 1)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[0] != 'S' {
+		return fmt.Errorf("server does not accept SSL (responded %q)", resp[0])
+	}
+	return nil
+}
+
+// MySQL capability flags used during the SSL upgrade
+const (
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+)
+
+// upgradeMySQL reads the initial Handshake packet and answers with an SSLRequest packet.
+func upgradeMySQL(conn net.Conn) error {
+	// Packet header: 3 byte payload length + 1 byte sequence ID
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+
+	if len(payload) > 0 && payload[0] == 0xff {
+		return fmt.Errorf("server sent error packet")
+	}
+	if len(payload) == 0 || payload[0] != 10 {
+		return fmt.Errorf("unsupported handshake protocol version")
+	}
+
+	// Layout (v10): version(1) server_version(NUL-terminated) conn_id(4) auth_data(8) filler(1) caps_lower(2)
+	nul := strings.IndexByte(string(payload[1:]), 0)
+	if nul < 0 {
+		return fmt.Errorf("malformed handshake packet")
+	}
+	capsOffset := 1 + nul + 1 + 4 + 8 + 1
+	if len(payload) < capsOffset+2 {
+		return fmt.Errorf("malformed handshake packet")
+	}
+	caps := binary.LittleEndian.Uint16(payload[capsOffset : capsOffset+2])
+	if caps&mysqlClientSSL == 0 {
+		return fmt.Errorf("server does not support SSL")
+	}
+
+	// SSLRequest: caps(4) max_packet(4) charset(1) reserved(23)
+	req := make([]byte, 4+32)
+	req[0] = 32 // payload length
+	req[3] = header[3] + 1
+	binary.LittleEndian.PutUint32(req[4:8], mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(req[8:12], 16*1024*1024)
+	req[12] = 0x21 // utf8_general_ci
+
+	_, err := conn.Write(req)
+	return err
+}
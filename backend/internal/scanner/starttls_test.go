@@ -0,0 +1,217 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lineServer plays a line based conversation: "S: text" is sent by the server, "C: text" is expected from the client.
+func lineServer(steps ...string) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		r := bufio.NewReader(conn)
+		for _, step := range steps {
+			switch {
+			case strings.HasPrefix(step, "S: "):
+				if _, err := io.WriteString(conn, step[3:]+"\r\n"); err != nil {
+					return err
+				}
+			case strings.HasPrefix(step, "C: "):
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return err
+				}
+				if got := strings.TrimRight(line, "\r\n"); got != step[3:] {
+					return fmt.Errorf("client sent %q, want %q", got, step[3:])
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// ldapServer answers the StartTLS ExtendedRequest with resultCode code.
+func ldapServer(code byte) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		req := make([]byte, len(ldapStartTLSRequest))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return err
+		}
+		if !bytes.Equal(req, ldapStartTLSRequest) {
+			return fmt.Errorf("unexpected request % x", req)
+		}
+		// LDAPMessage { messageID 1, ExtendedResponse { resultCode, matchedDN "", diagnosticMessage "" } }
+		_, err := conn.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, code, 0x04, 0x00, 0x04, 0x00})
+		return err
+	}
+}
+
+// postgresServer checks the SSLRequest and answers with reply ('S' or 'N').
+func postgresServer(reply byte) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint32(req[0:4]) != 8 || binary.BigEndian.Uint32(req[4:8]) != 80877103 {
+			return fmt.Errorf("unexpected SSLRequest % x", req)
+		}
+		_, err := conn.Write([]byte{reply})
+		return err
+	}
+}
+
+// mysqlServer sends a v10 Handshake advertising caps, then expects an SSLRequest if SSL is offered.
+func mysqlServer(caps uint16) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		payload := []byte{10}
+		payload = append(payload, "8.0.36\x00"...)
+		payload = append(payload, 1, 0, 0, 0)    // connection id
+		payload = append(payload, "abcdefgh"...) // auth-plugin-data part 1
+		payload = append(payload, 0)             // filler
+		payload = binary.LittleEndian.AppendUint16(payload, caps)
+		packet := append([]byte{byte(len(payload)), 0, 0, 0}, payload...)
+		if _, err := conn.Write(packet); err != nil {
+			return err
+		}
+		if caps&mysqlClientSSL == 0 {
+			return nil
+		}
+
+		req := make([]byte, 4+32)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return err
+		}
+		if req[0] != 32 || req[3] != 1 {
+			return fmt.Errorf("unexpected SSLRequest header % x", req[:4])
+		}
+		if binary.LittleEndian.Uint32(req[4:8])&mysqlClientSSL == 0 {
+			return fmt.Errorf("SSLRequest without CLIENT_SSL")
+		}
+		return nil
+	}
+}
+
+func TestUpgraders(t *testing.T) {
+	tests := []struct {
+		name    string
+		upgrade upgradeFunc
+		server  func(conn net.Conn) error
+		wantErr bool
+	}{
+		{
+			name:    "smtp",
+			upgrade: upgradeSMTP,
+			server: lineServer("S: 220 mail.example.com ESMTP", "C: EHLO certmonitor.local",
+				"S: 250-mail.example.com", "S: 250-SIZE 35882577", "S: 250 STARTTLS", "C: STARTTLS", "S: 220 2.0.0 Ready"),
+		},
+		{
+			name:    "smtp without starttls",
+			upgrade: upgradeSMTP,
+			server:  lineServer("S: 220 mail.example.com ESMTP", "C: EHLO certmonitor.local", "S: 250 SIZE 35882577"),
+			wantErr: true,
+		},
+		{
+			name:    "smtp rejected greeting",
+			upgrade: upgradeSMTP,
+			server:  lineServer("S: 554 No SMTP service here"),
+			wantErr: true,
+		},
+		{
+			name:    "ftp",
+			upgrade: upgradeFTP,
+			server:  lineServer("S: 220 FTP ready", "C: AUTH TLS", "S: 234 AUTH TLS successful"),
+		},
+		{
+			name:    "ftp refused",
+			upgrade: upgradeFTP,
+			server:  lineServer("S: 220 FTP ready", "C: AUTH TLS", "S: 530 Not allowed"),
+			wantErr: true,
+		},
+		{
+			name:    "imap skips untagged responses",
+			upgrade: upgradeIMAP,
+			server: lineServer("S: * OK IMAP4rev1 ready", "C: a001 STARTTLS",
+				"S: * CAPABILITY IMAP4rev1", "S: a001 OK Begin TLS negotiation now"),
+		},
+		{
+			name:    "imap refused",
+			upgrade: upgradeIMAP,
+			server:  lineServer("S: * OK IMAP4rev1 ready", "C: a001 STARTTLS", "S: a001 NO STARTTLS disabled"),
+			wantErr: true,
+		},
+		{
+			name:    "imap bad greeting",
+			upgrade: upgradeIMAP,
+			server:  lineServer("S: * BYE overloaded"),
+			wantErr: true,
+		},
+		{
+			name:    "pop3",
+			upgrade: upgradePOP3,
+			server:  lineServer("S: +OK POP3 ready", "C: STLS", "S: +OK Begin TLS"),
+		},
+		{
+			name:    "pop3 refused",
+			upgrade: upgradePOP3,
+			server:  lineServer("S: +OK POP3 ready", "C: STLS", "S: -ERR Command not permitted"),
+			wantErr: true,
+		},
+		{name: "ldap", upgrade: upgradeLDAP, server: ldapServer(0)},
+		{name: "ldap refused", upgrade: upgradeLDAP, server: ldapServer(2), wantErr: true},
+		{name: "postgres", upgrade: upgradePostgres, server: postgresServer('S')},
+		{name: "postgres without ssl", upgrade: upgradePostgres, server: postgresServer('N'), wantErr: true},
+		{name: "mysql", upgrade: upgradeMySQL, server: mysqlServer(mysqlClientProtocol41 | mysqlClientSSL)},
+		{name: "mysql without ssl", upgrade: upgradeMySQL, server: mysqlServer(mysqlClientProtocol41), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			client.SetDeadline(time.Now().Add(5 * time.Second))
+			server.SetDeadline(time.Now().Add(5 * time.Second))
+
+			serverErr := make(chan error, 1)
+			go func() {
+				defer server.Close()
+				serverErr <- tt.server(server)
+			}()
+
+			err := tt.upgrade(client)
+			client.Close()
+			srvErr := <-serverErr
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("upgrade failed: %v", err)
+			}
+			if srvErr != nil {
+				t.Fatalf("server: %v", srvErr)
+			}
+		})
+	}
+}
+
+func TestStartTLSUnsupportedProtocol(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	if err := startTLS(client, "GOPHER"); err == nil {
+		t.Fatal("expected an error for an unknown protocol")
+	}
+	if err := startTLS(client, ""); err != nil {
+		t.Fatalf("implicit TLS needs no upgrade: %v", err)
+	}
+}
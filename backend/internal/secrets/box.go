@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// Box encrypts small secrets (e.g. client private keys) for storage at rest with AES-256-GCM.
+// Sealed values are "v1:" + base64(nonce || ciphertext).
+type Box struct {
+	aead cipher.AEAD
+}
+
+const sealedPrefix = "v1:"
+
+// NewBox derives the AES-256 key from a passphrase (SHA-256), so any non-empty string is accepted.
+func NewBox(passphrase string) (*Box, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("encryption key cannot be empty")
+	}
+
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext with a random nonce.
+func (b *Box) Seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, plaintext, nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal. Fails if the key changed or the value was tampered with.
+func (b *Box) Open(sealed string) ([]byte, error) {
+	if len(sealed) < len(sealedPrefix) || sealed[:len(sealedPrefix)] != sealedPrefix {
+		return nil, fmt.Errorf("unsupported secret format")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(sealed[len(sealedPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret encoding: %w", err)
+	}
+	if len(raw) < b.aead.NonceSize() {
+		return nil, fmt.Errorf("secret too short")
+	}
+
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret (wrong key?)")
+	}
+	return plaintext, nil
+}
@@ -3,21 +3,31 @@ package service
 import (
 	"cert-manager-backend/internal/model"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
+// ErrAgentUnauthorized rejects an agent report or enrollment whose credential doesn't check out.
+var ErrAgentUnauthorized = errors.New("agent authentication failed")
+
 type PostgresAgentService struct {
 	DB                    *sql.DB
 	AgentOfflineThreshold time.Duration
+	EnrollmentTTL         time.Duration // Lifetime of an unused enrollment token
 }
 
 // NewAgentService constructor now accepts the threshold config
-func NewAgentService(db *sql.DB, offlineThreshold time.Duration) *PostgresAgentService {
+func NewAgentService(db *sql.DB, offlineThreshold, enrollmentTTL time.Duration) *PostgresAgentService {
 	return &PostgresAgentService{
 		DB:                    db,
 		AgentOfflineThreshold: offlineThreshold,
+		EnrollmentTTL:         enrollmentTTL,
 	}
 }
 
@@ -31,11 +41,13 @@ func (s *PostgresAgentService) ListAgents(ctx context.Context, userID string) ([
             COALESCE(a.ip_address, ''), 
             a.last_seen_at,
             a.is_virtual,
-            COUNT(ci.id) as cert_count
+            COUNT(ci.id) as cert_count,
+            a.credential_hash IS NOT NULL,
+            a.revoked_at
         FROM agents a
         LEFT JOIN certificate_instances ci ON a.id = ci.agent_id
         WHERE a.user_id = $1
-        GROUP BY a.id, a.hostname, a.ip_address, a.last_seen_at, a.is_virtual
+        GROUP BY a.id, a.hostname, a.ip_address, a.last_seen_at, a.is_virtual, a.credential_hash, a.revoked_at
         ORDER BY a.is_virtual DESC, a.last_seen_at DESC
     `
 	// Note: ORDER BY is_virtual DESC puts Cloud Agents at the top of the list
@@ -49,16 +61,20 @@ func (s *PostgresAgentService) ListAgents(ctx context.Context, userID string) ([
 	var agents []model.AgentResponse
 	for rows.Next() {
 		var a model.AgentResponse
+		var revokedAt sql.NullTime
 
 		// Ensure your model.AgentResponse has the IsVirtual field!
-		err := rows.Scan(&a.ID, &a.Hostname, &a.IPAddress, &a.LastSeenAt, &a.IsVirtual, &a.CertCount)
+		err := rows.Scan(&a.ID, &a.Hostname, &a.IPAddress, &a.LastSeenAt, &a.IsVirtual, &a.CertCount, &a.Enrolled, &revokedAt)
 		if err != nil {
 			return nil, err
 		}
+		a.RevokedAt = nullTimePtr(revokedAt)
 
 		// Calculate Status Logic
 		timeDiff := time.Since(a.LastSeenAt)
-		if timeDiff < s.AgentOfflineThreshold {
+		if a.RevokedAt != nil {
+			a.Status = model.StatusAgentRevoked
+		} else if timeDiff < s.AgentOfflineThreshold {
 			a.Status = model.StatusAgentOnline
 		} else {
 			a.Status = model.StatusAgentOffline
@@ -113,18 +129,151 @@ func (s *PostgresAgentService) DeleteAgent(ctx context.Context, userID, agentID
 	return tx.Commit()
 }
 
+// --- Enrollment & Credentials ---
+
+// CreateEnrollmentToken issues a one-time token that install.sh exchanges for a new agent's credential.
+func (s *PostgresAgentService) CreateEnrollmentToken(ctx context.Context, userID string) (*model.AgentEnrollmentToken, error) {
+	token, err := randomSecret("crt_enroll_")
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(s.EnrollmentTTL)
+
+	_, err = s.DB.ExecContext(ctx, `
+        INSERT INTO agent_enrollment_tokens (user_id, token_hash, expires_at)
+        VALUES ($1, $2, $3)
+    `, userID, hashSecret(token), expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create enrollment token: %w", err)
+	}
+	return &model.AgentEnrollmentToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// EnrollAgent redeems an enrollment token: it registers a new agent under the token's user and returns
+// the agent's ID and secret. The token can't be used again.
+func (s *PostgresAgentService) EnrollAgent(ctx context.Context, token, hostname, ipAddress string) (*model.AgentCredential, error) {
+	hostname = strings.TrimSpace(hostname)
+	if token == "" || hostname == "" {
+		return nil, fmt.Errorf("enrollment_token and hostname are required")
+	}
+
+	secret, err := randomSecret("crt_agent_")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1. Redeem the Token (atomically: two installs racing on one token can't both win)
+	var tokenID, userID string
+	err = tx.QueryRowContext(ctx, `
+        UPDATE agent_enrollment_tokens
+        SET used_at = NOW()
+        WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+        RETURNING id, user_id
+    `, hashSecret(token)).Scan(&tokenID, &userID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: invalid, expired or already used enrollment token", ErrAgentUnauthorized)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to redeem enrollment token: %w", err)
+	}
+
+	// 2. Register the Agent with its own Credential
+	var agentID string
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO agents (id, user_id, hostname, ip_address, is_virtual, credential_hash, enrolled_at, last_seen_at)
+        VALUES (gen_random_uuid(), $1, $2, $3, FALSE, $4, NOW(), NOW())
+        RETURNING id
+    `, userID, hostname, ipAddress, hashSecret(secret)).Scan(&agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE agent_enrollment_tokens SET agent_id = $1 WHERE id = $2", agentID, tokenID); err != nil {
+		return nil, fmt.Errorf("failed to record enrollment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &model.AgentCredential{AgentID: agentID, AgentSecret: secret}, nil
+}
+
+// RevokeAgent stops accepting reports from a single agent. Its certificates stay until it is deleted
+// (or cleaned up as dead); reinstalling needs a new enrollment token.
+func (s *PostgresAgentService) RevokeAgent(ctx context.Context, userID, agentID string) error {
+	result, err := s.DB.ExecContext(ctx, `
+        UPDATE agents SET revoked_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND is_virtual = FALSE AND revoked_at IS NULL
+    `, agentID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke agent: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("agent not found, already revoked or not revocable")
+	}
+	return nil
+}
+
 // CleanupDeadAgents deletes "Physical" agents that haven't been seen since the threshold.
 // Virtual Agents are EXCLUDED from this cleanup to prevent configuration loss during inactive periods.
+// Enrolled agents keep their row (and credential), so they can report again after an outage;
+// only their certificate instances are dropped. Revoked ones are deleted like legacy agents.
 func (s *PostgresAgentService) CleanupDeadAgents(ctx context.Context, threshold time.Duration) (int64, error) {
 	cutoff := time.Now().Add(-threshold)
 
-	// UPDATED: Added 'AND is_virtual = FALSE'
-	query := `DELETE FROM agents WHERE last_seen_at < $1 AND is_virtual = FALSE`
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 1. Enrolled agents: drop what they reported, keep the identity
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM certificate_instances ci
+        USING agents a
+        WHERE ci.agent_id = a.id
+          AND a.last_seen_at < $1 AND a.is_virtual = FALSE
+          AND a.credential_hash IS NOT NULL AND a.revoked_at IS NULL
+    `, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup instances of dead agents: %w", err)
+	}
 
-	result, err := s.DB.ExecContext(ctx, query, cutoff)
+	// 2. Legacy (account key) and revoked agents go entirely
+	result, err := tx.ExecContext(ctx, `
+        DELETE FROM agents
+        WHERE last_seen_at < $1 AND is_virtual = FALSE
+          AND (credential_hash IS NULL OR revoked_at IS NOT NULL)
+    `, cutoff)
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup dead agents: %w", err)
 	}
 
-	return result.RowsAffected()
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return deleted, tx.Commit()
+}
+
+// --- Helpers ---
+
+// randomSecret returns prefix + 32 random bytes in hex.
+func randomSecret(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// hashSecret is how tokens and keys are stored: random secrets need no salt or stretching.
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
 }
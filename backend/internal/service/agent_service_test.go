@@ -0,0 +1,142 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEnrollAgent(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := NewAgentService(conn, time.Hour, time.Hour)
+	ctx := context.Background()
+
+	token, err := svc.CreateEnrollmentToken(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := NewAgentService(conn, time.Hour, -time.Minute).CreateEnrollmentToken(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		hostname string
+		wantAuth bool // The failure is ErrAgentUnauthorized
+		wantErr  bool
+	}{
+		{name: "missing hostname", token: token.Token, hostname: "  ", wantErr: true},
+		{name: "unknown token", token: "crt_enroll_unknown", hostname: "web-1", wantErr: true, wantAuth: true},
+		{name: "expired token", token: expired.Token, hostname: "web-1", wantErr: true, wantAuth: true},
+		{name: "valid token", token: token.Token, hostname: "web-1"},
+		{name: "reused token", token: token.Token, hostname: "web-2", wantErr: true, wantAuth: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, err := svc.EnrollAgent(ctx, tt.token, tt.hostname, "192.0.2.1")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", cred)
+				}
+				if errors.Is(err, ErrAgentUnauthorized) != tt.wantAuth {
+					t.Fatalf("err = %v, want ErrAgentUnauthorized: %v", err, tt.wantAuth)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cred.AgentID == "" || cred.AgentSecret == "" {
+				t.Fatalf("incomplete credential %+v", cred)
+			}
+
+			var owner, hostname string
+			err = conn.QueryRowContext(ctx, "SELECT user_id, hostname FROM agents WHERE id = $1", cred.AgentID).Scan(&owner, &hostname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner != userID || hostname != tt.hostname {
+				t.Fatalf("agent registered for %s as %q, want %s as %q", owner, hostname, userID, tt.hostname)
+			}
+		})
+	}
+}
+
+// TestAgentCredentials enrolls two agents, then checks their reports as the ingest endpoint does.
+func TestAgentCredentials(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	agents := NewAgentService(conn, time.Hour, time.Hour)
+	certs := testCertService(t, conn)
+	ctx := context.Background()
+
+	enroll := func(hostname string) *model.AgentCredential {
+		t.Helper()
+		token, err := agents.CreateEnrollmentToken(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cred, err := agents.EnrollAgent(ctx, token.Token, hostname, "192.0.2.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cred
+	}
+	web, mail := enroll("web-1"), enroll("mail-1")
+
+	report := func(agentID, secret string) error {
+		_, err := certs.authenticateReport(ctx, model.AgentReport{AgentID: agentID, Hostname: "host", APIKey: secret}, "192.0.2.1")
+		return err
+	}
+
+	steps := []struct {
+		name    string
+		revoke  string // Agent revoked before the checks
+		agentID string
+		secret  string
+		wantOK  bool
+	}{
+		{name: "own credential", agentID: web.AgentID, secret: web.AgentSecret, wantOK: true},
+		{name: "the other agent's credential", agentID: web.AgentID, secret: mail.AgentSecret},
+		{name: "credential for an unknown agent id", agentID: "9b2f6f3c-0000-4000-8000-000000000000", secret: web.AgentSecret},
+		{name: "revoked agent", revoke: web.AgentID, agentID: web.AgentID, secret: web.AgentSecret},
+		{name: "other agent keeps working", agentID: mail.AgentID, secret: mail.AgentSecret, wantOK: true},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.revoke != "" {
+				if err := agents.RevokeAgent(ctx, userID, step.revoke); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := report(step.agentID, step.secret)
+			if step.wantOK {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrAgentUnauthorized) {
+				t.Fatalf("expected ErrAgentUnauthorized, got %v", err)
+			}
+		})
+	}
+
+	// Revocation is per owner and happens once
+	if err := agents.RevokeAgent(ctx, testUser(t, conn), mail.AgentID); err == nil {
+		t.Fatal("another user revoked the agent")
+	}
+	if err := agents.RevokeAgent(ctx, userID, web.AgentID); err == nil {
+		t.Fatal("expected an error revoking an agent twice")
+	}
+	if err := report(mail.AgentID, mail.AgentSecret); err != nil {
+		t.Fatalf("agent rejected after a foreign revoke attempt: %v", err)
+	}
+}
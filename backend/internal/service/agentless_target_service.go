@@ -2,68 +2,157 @@ package service
 
 import (
 	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/scanner"
+	"cert-manager-backend/internal/secrets"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type PostgresAgentLessTargetService struct {
 	DB                            *sql.DB
 	Scanner                       NetworkScanner
 	CertSvc                       CertificateService
+	Secrets                       *secrets.Box // Encrypts mTLS client certificates at rest
 	UserScanDefaultFrequencyHours int
+	Failures                      FailurePolicy
+}
+
+// FailurePolicy drives the HEALTHY -> DEGRADED -> DOWN transitions and the early re-scan of failing targets.
+type FailurePolicy struct {
+	DegradedAfter int           // Consecutive failed scans before a target is DEGRADED
+	DownAfter     int           // Consecutive failed scans before a target is DOWN
+	RetryAfter    time.Duration // First early re-scan; doubles with every failure, capped at the target's frequency
 }
 
-func NewAgentLessTargetService(db *sql.DB, sc NetworkScanner, certSvc CertificateService, userScanDefaultFrequencyHours int) *PostgresAgentLessTargetService {
+func NewAgentLessTargetService(db *sql.DB, sc NetworkScanner, certSvc CertificateService, box *secrets.Box, userScanDefaultFrequencyHours int, failures FailurePolicy) *PostgresAgentLessTargetService {
 	return &PostgresAgentLessTargetService{
 		DB:                            db,
 		Scanner:                       sc,
 		CertSvc:                       certSvc,
+		Secrets:                       box,
 		UserScanDefaultFrequencyHours: userScanDefaultFrequencyHours,
+		Failures:                      failures,
 	}
 }
 
 // --- User Facing Logic ---
-func (s *PostgresAgentLessTargetService) AddTarget(ctx context.Context, userID, rawURL string, frequency int) (*model.Target, error) {
+
+// AddTarget validates the spec (raw URL, protocol...), scans it immediately, saves it and ingests the result.
+func (s *PostgresAgentLessTargetService) AddTarget(ctx context.Context, userID string, spec model.Target) (*model.Target, error) {
 	// frequency Validation
 	// Default to 12 hours if user sends 0 or omits field
+	frequency := spec.FrequencyHours
 	if frequency <= 0 {
 		frequency = s.UserScanDefaultFrequencyHours
 	}
 
 	// 1. Normalize
-	targetAddr, err := normalizeTarget(rawURL)
+	kind, err := resolveKind(spec.Kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetAddr, serverName, connectAddr string
+	var proto model.ScanProtocol
+	var ports []int
+	if kind.IsDocument() {
+		// Documents are fetched over HTTPS: the handshake overrides do not apply
+		if spec.ServerName != "" || spec.ConnectAddress != "" || len(spec.Ports) > 0 || spec.ScanAllAddresses {
+			return nil, fmt.Errorf("server name, connect address, ports and scan all addresses only apply to TLS targets")
+		}
+		targetAddr, err = normalizeDocumentURL(spec.TargetURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid document URL: %v", err)
+		}
+		proto = model.ProtocolTLS
+	} else {
+		targetAddr, err = normalizeTarget(spec.TargetURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target format: %v", err)
+		}
+
+		proto, err = resolveProtocol(string(spec.Protocol), targetAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		serverName, err = normalizeHost(spec.ServerName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server name: %v", err)
+		}
+		connectAddr, err = normalizeHost(spec.ConnectAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid connect address: %v", err)
+		}
+		ports, err = normalizePorts(spec.Ports, targetAddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t := &model.Target{
+		UserID:           userID,
+		TargetURL:        targetAddr,
+		Kind:             kind,
+		Protocol:         proto,
+		FrequencyHours:   frequency,
+		ServerName:       serverName,
+		ConnectAddress:   connectAddr,
+		Ports:            ports,
+		ScanAllAddresses: spec.ScanAllAddresses,
+		Proxy:            strings.TrimSpace(spec.Proxy), // Unknown names fail the scan-on-create below
+		Status:           "SUCCESS",
+	}
+
+	// mTLS: Validate & encrypt the client certificate before the first handshake uses it
+	var sealedClientCert sql.NullString
+	if spec.ClientCertPEM != "" || spec.ClientKeyPEM != "" {
+		sealed, err := s.prepareClientCert(t, spec.ClientCertPEM, spec.ClientKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		sealedClientCert = sql.NullString{String: sealed, Valid: true}
+	}
+
+	// Private CAs: verify the chain against the user's Trust Store as well
+	t.CustomRootsPEM, err = customRootsPEM(ctx, s.DB, userID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid target format: %v", err)
+		return nil, fmt.Errorf("failed to load trust store: %w", err)
 	}
 
 	// 2. Scan-on-Create (Immediate Feedback)
-	scanResults, scanErr := s.Scanner.Scan(ctx, targetAddr)
+	var report scanner.ScanReport
+	scanResults, scanErr := s.Scanner.Scan(scanner.WithScanReport(ctx, &report), *t)
 	if scanErr != nil {
-		return nil, fmt.Errorf("scan failed (target unreachable?): %v", scanErr)
+		return nil, fmt.Errorf("scan failed (target unreachable?): %w", scanErr)
 	}
 
 	for i := range scanResults {
-		scanResults[i].SourceType = "CLOUD"
+		scanResults[i].SourceType = SourceTypeFor(t.Kind)
 	}
 
 	// 3. Save to DB
-	t := &model.Target{
-		UserID:         userID,
-		TargetURL:      targetAddr,
-		FrequencyHours: frequency,
-		Status:         "SUCCESS",
-	}
-
 	query := `
-        INSERT INTO monitored_targets (user_id, target_url, frequency_hours, last_scanned_at, last_status)
-        VALUES ($1, $2, $3, NOW(), 'SUCCESS')
+        INSERT INTO monitored_targets (user_id, target_url, protocol, frequency_hours, server_name, connect_address, ports,
+                                       scan_all_addresses, proxy, client_cert_enc, client_cert_subject, client_cert_expires_at,
+                                       kind, last_scanned_at, last_status)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), 'SUCCESS')
         RETURNING id, created_at
     `
-	err = s.DB.QueryRowContext(ctx, query, userID, targetAddr, t.FrequencyHours).Scan(&t.ID, &t.CreatedAt)
+	err = s.DB.QueryRowContext(ctx, query, userID, targetAddr, t.Protocol, t.FrequencyHours,
+		t.ServerName, t.ConnectAddress, pq.Array(toInt64s(t.Ports)), t.ScanAllAddresses, t.Proxy,
+		sealedClientCert, nullString(t.ClientCertSubject), t.ClientCertExpiresAt, t.Kind).Scan(&t.ID, &t.CreatedAt)
 	if err != nil {
 		if strings.Contains(err.Error(), "unique constraint") {
 			return nil, fmt.Errorf("you are already monitoring this target")
@@ -80,6 +169,19 @@ func (s *PostgresAgentLessTargetService) AddTarget(ctx context.Context, userID,
 		return nil, fmt.Errorf("failed to ingest certificate data: %v", err)
 	}
 
+	if err := s.RecordScanResult(ctx, t.ID, scanResults, nil); err != nil {
+		log.Printf("⚠️ Failed to record scan history for %s: %v", t.TargetURL, err)
+	}
+
+	if err := s.RecordScanDetails(ctx, t.ID, scanResults, report.Unreachable); err != nil {
+		log.Printf("⚠️ Failed to record scan details for %s: %v", t.TargetURL, err)
+	}
+	t.NodeMismatches = describeNodeMismatches(scanResults)
+	t.UnreachableNodes = describeUnreachable(report.Unreachable)
+	t.NodesDisagree = t.NodeMismatches != ""
+	t.RequiresClientCert = clientCertRequested(scanResults)
+
+	// The posture audit (many handshakes) is left to the worker: it picks up targets without one on their next scan
 	return t, nil
 }
 
@@ -114,7 +216,10 @@ func (s *PostgresAgentLessTargetService) UpdateTarget(ctx context.Context, userI
 
 func (s *PostgresAgentLessTargetService) ListTargets(ctx context.Context, userID string) ([]model.Target, error) {
 	query := `
-        SELECT id, target_url, frequency_hours, last_scanned_at, last_status, last_error
+        SELECT id, target_url, kind, protocol, frequency_hours, last_scanned_at, last_status, last_error,
+               server_name, connect_address, ports, scan_all_addresses, proxy, nodes_disagree, node_mismatches, unreachable_nodes,
+               client_cert_enc IS NOT NULL, client_cert_subject, client_cert_expires_at, requires_client_cert,
+               health, consecutive_failures, retry_at
         FROM monitored_targets
         WHERE user_id = $1
         ORDER BY created_at DESC
@@ -129,10 +234,25 @@ func (s *PostgresAgentLessTargetService) ListTargets(ctx context.Context, userID
 	for rows.Next() {
 		var t model.Target
 		var lastScanned sql.NullTime
-		var lastErr sql.NullString
-		if err := rows.Scan(&t.ID, &t.TargetURL, &t.FrequencyHours, &lastScanned, &t.Status, &lastErr); err != nil {
+		var lastErr, mismatches, unreachable, clientSubject sql.NullString
+		var clientExpires, retryAt sql.NullTime
+		var ports pq.Int64Array
+		if err := rows.Scan(&t.ID, &t.TargetURL, &t.Kind, &t.Protocol, &t.FrequencyHours, &lastScanned, &t.Status, &lastErr,
+			&t.ServerName, &t.ConnectAddress, &ports, &t.ScanAllAddresses, &t.Proxy, &t.NodesDisagree, &mismatches, &unreachable,
+			&t.HasClientCert, &clientSubject, &clientExpires, &t.RequiresClientCert,
+			&t.Health, &t.ConsecutiveFailures, &retryAt); err != nil {
 			return nil, err
 		}
+		if retryAt.Valid {
+			t.RetryAt = &retryAt.Time
+		}
+		t.ClientCertSubject = clientSubject.String
+		if clientExpires.Valid {
+			t.ClientCertExpiresAt = &clientExpires.Time
+		}
+		t.Ports = toInts(ports)
+		t.NodeMismatches = mismatches.String
+		t.UnreachableNodes = unreachable.String
 		if lastScanned.Valid {
 			t.LastScannedAt = lastScanned.Time
 		}
@@ -150,13 +270,14 @@ func (s *PostgresAgentLessTargetService) DeleteTarget(ctx context.Context, userI
 	}
 	defer tx.Rollback()
 
-	// 2. Resolve Target URL & User's Virtual Agent ID
-	// We need the Target URL because that is how we tagged the certificate instances (SourceUID).
-	var targetURL string
+	// 2. Resolve the Target's Endpoints & User's Virtual Agent ID
+	// We need the Endpoints because that is how we tagged the certificate instances (SourceUID).
+	var t model.Target
+	var ports pq.Int64Array
 	err = tx.QueryRowContext(ctx, `
-		SELECT target_url FROM monitored_targets 
+		SELECT target_url, kind, server_name, connect_address, ports FROM monitored_targets 
 		WHERE id = $1 AND user_id = $2
-	`, targetID, userID).Scan(&targetURL)
+	`, targetID, userID).Scan(&t.TargetURL, &t.Kind, &t.ServerName, &t.ConnectAddress, &ports)
 
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("target not found or access denied")
@@ -171,12 +292,31 @@ func (s *PostgresAgentLessTargetService) DeleteTarget(ctx context.Context, userI
 	`, userID).Scan(&agentID)
 
 	// If agent exists, delete the specific instances associated with this target
-	if err == nil {
-		// This removes the "Leaf", "Intermediate", and "Root" entries for this specific URL
+	if err == nil && t.Kind.IsDocument() {
+		// Embedded keys are tagged "<url>#<key>"
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM certificate_instances
+			WHERE agent_id = $1 AND starts_with(source_uid, $2)
+		`, agentID, t.TargetURL+"#")
+
+		if err != nil {
+			return fmt.Errorf("failed to cleanup certificate instances: %w", err)
+		}
+	} else if err == nil {
+		t.ID = targetID
+		t.Ports = toInts(ports)
+		var sourceUIDs []string
+		for _, ep := range t.Endpoints() {
+			sourceUIDs = append(sourceUIDs, ep.SourceUID)
+		}
+
+		// This removes the "Leaf", "Intermediate", and "Root" entries of every endpoint,
+		// including the per-node entries ("host:port@ip#<target id>") of "Scan all addresses" targets.
 		_, err = tx.ExecContext(ctx, `
 			DELETE FROM certificate_instances 
-			WHERE agent_id = $1 AND source_uid = $2
-		`, agentID, targetURL)
+			WHERE agent_id = $1
+			  AND (source_uid = ANY($2) OR source_uid LIKE '%' || $3)
+		`, agentID, pq.Array(sourceUIDs), model.TargetUIDSuffix(targetID))
 
 		if err != nil {
 			return fmt.Errorf("failed to cleanup certificate instances: %w", err)
@@ -204,12 +344,16 @@ func (s *PostgresAgentLessTargetService) DeleteTarget(ctx context.Context, userI
 // --- Worker Facing Logic ---
 
 func (s *PostgresAgentLessTargetService) GetStaleTargets(ctx context.Context) ([]model.Target, error) {
-	// Select targets that have NEVER been scanned OR are past their frequency interval
+	// Select targets that have NEVER been scanned, are past their frequency interval OR are due for an early retry
 	query := `
-        SELECT id, user_id, target_url, frequency_hours
+        SELECT id, user_id, target_url, kind, protocol, frequency_hours, server_name, connect_address, ports, scan_all_addresses,
+		       proxy, client_cert_enc,
+		       (SELECT string_agg(pem, '') FROM trusted_roots tr WHERE tr.user_id = monitored_targets.user_id),
+		       (SELECT scanned_at FROM target_tls_posture p WHERE p.target_id = monitored_targets.id)
 		FROM monitored_targets
 		WHERE last_scanned_at IS NULL
 		OR (last_scanned_at + (frequency_hours * INTERVAL '1 hour')) < NOW()
+		OR retry_at <= NOW()
 		ORDER BY last_scanned_at ASC NULLS FIRST
 		LIMIT 50
     `
@@ -222,27 +366,91 @@ func (s *PostgresAgentLessTargetService) GetStaleTargets(ctx context.Context) ([
 	var targets []model.Target
 	for rows.Next() {
 		var t model.Target
-		if err := rows.Scan(&t.ID, &t.UserID, &t.TargetURL, &t.FrequencyHours); err != nil {
+		var roots, sealedClientCert sql.NullString
+		var ports pq.Int64Array
+		var postureAuditedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.UserID, &t.TargetURL, &t.Kind, &t.Protocol, &t.FrequencyHours,
+			&t.ServerName, &t.ConnectAddress, &ports, &t.ScanAllAddresses, &t.Proxy, &sealedClientCert, &roots,
+			&postureAuditedAt); err != nil {
 			return nil, err
 		}
+		if postureAuditedAt.Valid {
+			t.PostureAuditedAt = &postureAuditedAt.Time
+		}
+		t.Ports = toInts(ports)
+		if sealedClientCert.Valid {
+			// A broken client cert (e.g. rotated encryption key) must not stop the scan: it runs without one
+			if err := s.openClientCert(&t, sealedClientCert.String); err != nil {
+				log.Printf("⚠️ Failed to load client certificate for %s: %v", t.TargetURL, err)
+			}
+		}
+		if roots.Valid {
+			t.CustomRootsPEM = []byte(roots.String)
+		}
 		targets = append(targets, t)
 	}
 	return targets, nil
 }
 
-func (s *PostgresAgentLessTargetService) UpdateTargetStatus(ctx context.Context, targetID, status, errStr string) error {
+// UpdateTargetStatus records the outcome of a scan and moves the target through HEALTHY / DEGRADED / DOWN.
+// A failure also schedules an early re-scan: RetryAfter, doubling per consecutive failure, capped at the frequency.
+func (s *PostgresAgentLessTargetService) UpdateTargetStatus(ctx context.Context, targetID, status, errStr string) (*model.TargetHealthChange, error) {
 	query := `
-        UPDATE monitored_targets
+        WITH prev AS (
+            SELECT id, health FROM monitored_targets WHERE id = $3 FOR UPDATE
+        )
+        UPDATE monitored_targets t
         SET last_scanned_at = NOW(),
             last_status = $1,
-            last_error = $2
-        WHERE id = $3
+            last_error = $2,
+            consecutive_failures = CASE WHEN $4::BOOLEAN THEN t.consecutive_failures + 1 ELSE 0 END,
+            health = CASE
+                WHEN NOT $4 THEN 'HEALTHY'
+                WHEN t.consecutive_failures + 1 >= $5::INTEGER THEN 'DOWN'
+                WHEN t.consecutive_failures + 1 >= $6::INTEGER THEN 'DEGRADED'
+                ELSE 'HEALTHY'
+            END,
+            retry_at = CASE
+                WHEN $4 THEN NOW() + LEAST(
+                    $7::DOUBLE PRECISION * power(2, LEAST(t.consecutive_failures, 16)) * INTERVAL '1 second',
+                    t.frequency_hours * INTERVAL '1 hour')
+                ELSE NULL
+            END
+        FROM prev
+        WHERE t.id = prev.id
+        RETURNING prev.health, t.health, t.consecutive_failures, t.retry_at,
+            (t.health = 'DOWN' AND (t.last_alert_health IS DISTINCT FROM 'DOWN' OR t.last_alerted_at < NOW() - $8::DOUBLE PRECISION * INTERVAL '1 second'))
+            OR (t.health = 'HEALTHY' AND t.last_alert_health = 'DOWN')
     `
 	var dbErr sql.NullString
 	if errStr != "" {
 		dbErr = sql.NullString{String: errStr, Valid: true}
 	}
-	_, err := s.DB.ExecContext(ctx, query, status, dbErr, targetID)
+
+	var change model.TargetHealthChange
+	var retryAt sql.NullTime
+	failed := status == "FAILED"
+	err := s.DB.QueryRowContext(ctx, query, status, dbErr, targetID, failed,
+		s.Failures.DownAfter, s.Failures.DegradedAfter, s.Failures.RetryAfter.Seconds(), model.FrequencyDaily.Seconds()).
+		Scan(&change.Previous, &change.Current, &change.ConsecutiveFailures, &retryAt, &change.AlertDue)
+	if err != nil {
+		return nil, err
+	}
+	if retryAt.Valid {
+		change.RetryAt = &retryAt.Time
+	}
+	return &change, nil
+}
+
+// MarkHealthAlerted remembers which health the owner was last told about (DOWN alerts have a 1 day cooldown).
+// A recovery clears it, so the next outage is alerted right away.
+func (s *PostgresAgentLessTargetService) MarkHealthAlerted(ctx context.Context, targetID string, health model.TargetHealth) error {
+	_, err := s.DB.ExecContext(ctx, `
+        UPDATE monitored_targets
+        SET last_alerted_at = NOW(),
+            last_alert_health = CASE WHEN $2::TEXT = 'HEALTHY' THEN NULL ELSE $2::TEXT END
+        WHERE id = $1
+    `, targetID, health)
 	return err
 }
 
@@ -287,3 +495,429 @@ func normalizeTarget(input string) (string, error) {
 	// 6. Return strict "host:port" format
 	return net.JoinHostPort(host, port), nil
 }
+
+// normalizeDocumentURL validates the URL of a SAML metadata / JWKS document (https only, kept verbatim otherwise).
+func normalizeDocumentURL(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("target URL cannot be empty")
+	}
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL format: %w", err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("only https:// documents can be monitored")
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("could not identify a valid hostname")
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("credentials in the URL are not supported")
+	}
+
+	// The fragment separates the URL from the key in source UIDs
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = strings.ToLower(u.Host)
+	return u.String(), nil
+}
+
+// resolveKind validates the target kind ("" = TLS).
+func resolveKind(input model.TargetKind) (model.TargetKind, error) {
+	kind := model.TargetKind(strings.ToUpper(strings.TrimSpace(string(input))))
+	switch kind {
+	case "":
+		return model.TargetKindTLS, nil
+	case model.TargetKindTLS, model.TargetKindSAMLMetadata, model.TargetKindJWKS:
+		return kind, nil
+	}
+	return "", fmt.Errorf("unsupported target kind %q (supported: TLS, SAML_METADATA, JWKS)", input)
+}
+
+// SourceTypeFor returns the source_type of the certificates a target of this kind yields.
+func SourceTypeFor(kind model.TargetKind) string {
+	if kind.IsDocument() {
+		return model.SourceTypeMetadata
+	}
+	return "CLOUD"
+}
+
+// maxTargetPorts caps the extra ports of a single target.
+const maxTargetPorts = 20
+
+// normalizeHost validates an optional SNI / connect override: a bare hostname or IP, no scheme or port.
+func normalizeHost(input string) (string, error) {
+	host := strings.ToLower(strings.TrimSpace(input))
+	if host == "" {
+		return "", nil
+	}
+
+	// Accept bracketed IPv6 ("[::1]") as typed in URLs
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+
+	if strings.ContainsAny(host, ":/@ ") {
+		return "", fmt.Errorf("%q must be a bare hostname or IP (no scheme, port or path)", input)
+	}
+	return strings.TrimSuffix(host, "."), nil
+}
+
+// normalizePorts validates the extra ports and drops duplicates (including the TargetURL's own port).
+func normalizePorts(ports []int, targetAddr string) ([]int, error) {
+	if len(ports) > maxTargetPorts {
+		return nil, fmt.Errorf("a target may list at most %d ports", maxTargetPorts)
+	}
+
+	_, primary, _ := net.SplitHostPort(targetAddr)
+	seen := map[int]bool{}
+	var out []int
+	for _, p := range ports {
+		if p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid port: %d", p)
+		}
+		if seen[p] || fmt.Sprint(p) == primary {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func toInt64s(in []int) []int64 {
+	out := make([]int64, len(in))
+	for i, v := range in {
+		out[i] = int64(v)
+	}
+	return out
+}
+
+func toInts(in []int64) []int {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]int, len(in))
+	for i, v := range in {
+		out[i] = int(v)
+	}
+	return out
+}
+
+// defaultPortProtocols maps well-known plaintext ports to the STARTTLS protocol they speak.
+// Implicit TLS ports (443, 465, 636, 993, 995...) are not listed and fall back to TLS.
+var defaultPortProtocols = map[string]model.ScanProtocol{
+	"21":   model.ProtocolFTP,
+	"25":   model.ProtocolSMTP,
+	"110":  model.ProtocolPOP3,
+	"143":  model.ProtocolIMAP,
+	"389":  model.ProtocolLDAP,
+	"587":  model.ProtocolSMTP,
+	"3306": model.ProtocolMySQL,
+	"5432": model.ProtocolPostgres,
+}
+
+// DefaultProtocol picks the protocol a "host:port" most likely speaks: STARTTLS for well-known plaintext ports, else TLS.
+func DefaultProtocol(targetAddr string) model.ScanProtocol {
+	_, port, _ := net.SplitHostPort(targetAddr)
+	if p, ok := defaultPortProtocols[port]; ok {
+		return p
+	}
+	return model.ProtocolTLS
+}
+
+// resolveProtocol validates the user's protocol choice, or picks a default from the port.
+func resolveProtocol(input, targetAddr string) (model.ScanProtocol, error) {
+	proto := model.ScanProtocol(strings.ToUpper(strings.TrimSpace(input)))
+
+	switch proto {
+	case "":
+		return DefaultProtocol(targetAddr), nil
+	case model.ProtocolTLS, model.ProtocolSMTP, model.ProtocolIMAP, model.ProtocolPOP3,
+		model.ProtocolFTP, model.ProtocolLDAP, model.ProtocolPostgres, model.ProtocolMySQL:
+		return proto, nil
+	default:
+		return "", fmt.Errorf("unsupported protocol: %s", input)
+	}
+}
+
+// --- Scan Details (Node Consistency & mTLS) ---
+
+// RecordScanDetails stores what a successful scan revealed about the target itself:
+// whether its nodes serve different leaf certificates, which endpoints / nodes it couldn't reach,
+// and whether it demands a client certificate.
+// Nodes that no longer resolve are gone from the load balancer: their instances are marked MISSING
+// (unreachable nodes still resolve, so they keep their last known certificate).
+func (s *PostgresAgentLessTargetService) RecordScanDetails(ctx context.Context, targetID string, certs []model.Certificate, unreachable []model.UnreachableNode) error {
+	mismatches := describeNodeMismatches(certs)
+	unreachableSummary := describeUnreachable(unreachable)
+
+	query := `
+        UPDATE monitored_targets
+        SET nodes_disagree = $1,
+            node_mismatches = $2,
+            requires_client_cert = $3,
+            unreachable_nodes = $4
+        WHERE id = $5
+    `
+	_, err := s.DB.ExecContext(ctx, query, mismatches != "", nullString(mismatches), clientCertRequested(certs),
+		nullString(unreachableSummary), targetID)
+	if err != nil {
+		return err
+	}
+
+	// Vanished Nodes: only endpoints that resolved this time (one of their nodes answered) are considered,
+	// so a DNS outage doesn't turn every node MISSING
+	seen := make([]string, 0, len(certs)+len(unreachable))
+	var endpoints []string
+	for _, c := range certs {
+		seen = append(seen, c.SourceUID)
+		if base, _, isNode := strings.Cut(c.SourceUID, "@"); isNode {
+			endpoints = append(endpoints, base)
+		}
+	}
+	for _, n := range unreachable {
+		seen = append(seen, n.SourceUID)
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	result, err := s.DB.ExecContext(ctx, `
+        UPDATE certificate_instances ci
+        SET current_status = 'MISSING'
+        FROM monitored_targets t
+        JOIN agents a ON a.user_id = t.user_id AND a.is_virtual = TRUE
+        WHERE t.id = $1
+          AND ci.agent_id = a.id
+          AND ci.current_status = 'ACTIVE'
+          AND ci.source_uid LIKE '%@%' || $2
+          AND split_part(ci.source_uid, '@', 1) = ANY($3)
+          AND NOT (ci.source_uid = ANY($4))
+    `, targetID, model.TargetUIDSuffix(targetID), pq.Array(endpoints), pq.Array(seen))
+	if err != nil {
+		return fmt.Errorf("failed to mark vanished nodes: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		log.Printf("👻 Target %s: %d instances of vanished nodes marked MISSING", targetID, rows)
+	}
+	return nil
+}
+
+// describeUnreachable summarizes the endpoints / nodes a scan couldn't reach ("addr: error; ...").
+func describeUnreachable(nodes []model.UnreachableNode) string {
+	parts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		parts = append(parts, fmt.Sprintf("%s: %s", n.Addr, n.Error))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// clientCertRequested reports whether any endpoint / node sent a CertificateRequest.
+func clientCertRequested(certs []model.Certificate) bool {
+	for _, c := range certs {
+		if c.ClientCertRequested {
+			return true
+		}
+	}
+	return false
+}
+
+// describeNodeMismatches compares the leaf served by each node of the same endpoint (port).
+// Returns "" when all nodes agree, otherwise a "ip: serial (issuer)" summary grouped by cert.
+func describeNodeMismatches(certs []model.Certificate) string {
+	type endpointNodes struct {
+		order       []string
+		nodesByLeaf map[string][]string
+	}
+	var endpoints []string
+	byEndpoint := make(map[string]*endpointNodes)
+
+	for _, c := range certs {
+		if c.ChainPosition != 0 || c.ResolvedIP == "" {
+			continue
+		}
+		base, _, _ := strings.Cut(c.SourceUID, "@")
+		ep, ok := byEndpoint[base]
+		if !ok {
+			ep = &endpointNodes{nodesByLeaf: make(map[string][]string)}
+			byEndpoint[base] = ep
+			endpoints = append(endpoints, base)
+		}
+
+		key := fmt.Sprintf("serial %s (issuer %s)", c.Serial, c.Issuer.CN)
+		if _, ok := ep.nodesByLeaf[key]; !ok {
+			ep.order = append(ep.order, key)
+		}
+		ep.nodesByLeaf[key] = append(ep.nodesByLeaf[key], c.ResolvedIP)
+	}
+
+	var parts []string
+	for _, base := range endpoints {
+		ep := byEndpoint[base]
+		if len(ep.order) < 2 {
+			continue
+		}
+		for _, key := range ep.order {
+			part := fmt.Sprintf("%s: %s", strings.Join(ep.nodesByLeaf[key], ", "), key)
+			if len(endpoints) > 1 {
+				part = base + " " + part
+			}
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// --- mTLS Client Certificates ---
+
+// SetClientCert attaches (or replaces) the client certificate presented to an mTLS target.
+// The target is re-scheduled so the next scan uses the new certificate.
+func (s *PostgresAgentLessTargetService) SetClientCert(ctx context.Context, userID, targetID, certPEM, keyPEM string) error {
+	var t model.Target
+	sealed, err := s.prepareClientCert(&t, certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE monitored_targets
+        SET client_cert_enc = $1,
+            client_cert_subject = $2,
+            client_cert_expires_at = $3,
+            last_scanned_at = NULL
+        WHERE id = $4 AND user_id = $5
+    `
+	result, err := s.DB.ExecContext(ctx, query, sealed, nullString(t.ClientCertSubject), t.ClientCertExpiresAt, targetID, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("target not found or access denied")
+	}
+	return nil
+}
+
+// RemoveClientCert deletes the stored client certificate of a target.
+func (s *PostgresAgentLessTargetService) RemoveClientCert(ctx context.Context, userID, targetID string) error {
+	query := `
+        UPDATE monitored_targets
+        SET client_cert_enc = NULL,
+            client_cert_subject = NULL,
+            client_cert_expires_at = NULL,
+            last_scanned_at = NULL
+        WHERE id = $1 AND user_id = $2
+    `
+	result, err := s.DB.ExecContext(ctx, query, targetID, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("target not found or access denied")
+	}
+	return nil
+}
+
+// prepareClientCert validates a PEM cert/key pair, loads it into t and returns the sealed (encrypted) bundle.
+func (s *PostgresAgentLessTargetService) prepareClientCert(t *model.Target, certPEM, keyPEM string) (string, error) {
+	if certPEM == "" || keyPEM == "" {
+		return "", fmt.Errorf("client certificate and private key are both required")
+	}
+
+	bundle := strings.TrimSpace(certPEM) + "\n" + strings.TrimSpace(keyPEM) + "\n"
+	if err := loadClientCert(t, []byte(bundle)); err != nil {
+		return "", err
+	}
+
+	sealed, err := s.Secrets.Seal([]byte(bundle))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt client certificate: %w", err)
+	}
+	return sealed, nil
+}
+
+// openClientCert decrypts a stored bundle into t.ClientCert.
+func (s *PostgresAgentLessTargetService) openClientCert(t *model.Target, sealed string) error {
+	bundle, err := s.Secrets.Open(sealed)
+	if err != nil {
+		return err
+	}
+	return loadClientCert(t, bundle)
+}
+
+// loadClientCert parses a PEM bundle (certificate chain + private key) into t.
+func loadClientCert(t *model.Target, bundle []byte) error {
+	pair, err := tls.X509KeyPair(bundle, bundle)
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %v", err)
+	}
+
+	t.ClientCert = &pair
+	t.HasClientCert = true
+	t.ClientCertSubject = leaf.Subject.CommonName
+	t.ClientCertExpiresAt = &leaf.NotAfter
+	return nil
+}
+
+// --- TLS Posture ---
+
+// GetPosture returns the latest TLS posture audit for a target owned by the user.
+func (s *PostgresAgentLessTargetService) GetPosture(ctx context.Context, userID, targetID string) (*model.TLSPosture, error) {
+	query := `
+        SELECT p.target_id, p.grade, p.versions, p.findings, p.scanned_at
+        FROM target_tls_posture p
+        JOIN monitored_targets t ON p.target_id = t.id
+        WHERE p.target_id = $1 AND t.user_id = $2
+    `
+	var p model.TLSPosture
+	var versions, findings []byte
+	err := s.DB.QueryRowContext(ctx, query, targetID, userID).Scan(&p.TargetID, &p.Grade, &versions, &findings, &p.ScannedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no posture data for this target yet")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(versions, &p.Versions); err != nil {
+		return nil, fmt.Errorf("corrupt posture versions: %w", err)
+	}
+	if err := json.Unmarshal(findings, &p.Findings); err != nil {
+		return nil, fmt.Errorf("corrupt posture findings: %w", err)
+	}
+	p.Coverage = scanner.PostureCoverage
+	return &p, nil
+}
+
+// SavePosture stores the latest audit result (one row per target, overwritten on each audit).
+func (s *PostgresAgentLessTargetService) SavePosture(ctx context.Context, p *model.TLSPosture) error {
+	versions, err := json.Marshal(p.Versions)
+	if err != nil {
+		return err
+	}
+	findings, err := json.Marshal(p.Findings)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO target_tls_posture (target_id, grade, versions, findings, scanned_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (target_id) DO UPDATE
+        SET grade = EXCLUDED.grade,
+            versions = EXCLUDED.versions,
+            findings = EXCLUDED.findings,
+            scanned_at = EXCLUDED.scanned_at
+    `
+	_, err = s.DB.ExecContext(ctx, query, p.TargetID, p.Grade, versions, findings, p.ScannedAt)
+	return err
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"cert-manager-backend/internal/db"
+	"cert-manager-backend/internal/model"
+	"context"
+	"database/sql"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nodeLeaf is the leaf a node of ep serves, as the scanner reports it.
+func nodeLeaf(ep model.Endpoint, ip, serial, issuer string) model.Certificate {
+	return model.Certificate{
+		SourceUID:  model.NodeSourceUID(ep, ip),
+		ResolvedIP: ip,
+		Serial:     serial,
+		Issuer:     model.DN{CN: issuer},
+	}
+}
+
+func TestDescribeNodeMismatches(t *testing.T) {
+	target := model.Target{ID: "tgt-1", TargetURL: "lb.example.test:443", Ports: []int{8443}, ScanAllAddresses: true}
+	eps := target.Endpoints()
+	intermediate := nodeLeaf(eps[0], "10.0.0.2", "999", "Root")
+	intermediate.ChainPosition = 1
+
+	tests := []struct {
+		name  string
+		certs []model.Certificate
+		want  string
+	}{
+		{
+			name:  "nodes agree",
+			certs: []model.Certificate{nodeLeaf(eps[0], "10.0.0.1", "1", "CA"), nodeLeaf(eps[0], "10.0.0.2", "1", "CA")},
+		},
+		{
+			name: "one stale node",
+			certs: []model.Certificate{
+				nodeLeaf(eps[0], "10.0.0.1", "1", "CA"), nodeLeaf(eps[0], "10.0.0.2", "2", "CA"), nodeLeaf(eps[0], "10.0.0.3", "1", "CA"),
+			},
+			want: "10.0.0.1, 10.0.0.3: serial 1 (issuer CA); 10.0.0.2: serial 2 (issuer CA)",
+		},
+		{
+			name:  "intermediates are not compared",
+			certs: []model.Certificate{nodeLeaf(eps[0], "10.0.0.1", "1", "CA"), nodeLeaf(eps[0], "10.0.0.2", "1", "CA"), intermediate},
+		},
+		{
+			name: "ports are compared separately",
+			certs: []model.Certificate{
+				nodeLeaf(eps[0], "10.0.0.1", "1", "CA"), nodeLeaf(eps[0], "10.0.0.2", "1", "CA"),
+				nodeLeaf(eps[1], "10.0.0.1", "1", "CA"), nodeLeaf(eps[1], "10.0.0.2", "3", "Other CA"),
+			},
+			want: "lb.example.test:8443 10.0.0.1: serial 1 (issuer CA); lb.example.test:8443 10.0.0.2: serial 3 (issuer Other CA)",
+		},
+		{
+			name:  "single address targets are never compared",
+			certs: []model.Certificate{{SourceUID: "lb.example.test:443", Serial: "1"}, {SourceUID: "lb.example.test:443", Serial: "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeNodeMismatches(tt.certs); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// instanceStatuses returns current_status by source_uid for the user's leaf instances.
+func instanceStatuses(t *testing.T, conn *sql.DB, userID string) map[string]string {
+	t.Helper()
+	rows, err := conn.QueryContext(context.Background(), `
+        SELECT ci.source_uid, ci.current_status
+        FROM certificate_instances ci
+        JOIN agents a ON ci.agent_id = a.id
+        WHERE a.user_id = $1 AND ci.chain_position = 0
+    `, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	statuses := map[string]string{}
+	for rows.Next() {
+		var uid, status string
+		if err := rows.Scan(&uid, &status); err != nil {
+			t.Fatal(err)
+		}
+		statuses[uid] = status
+	}
+	return statuses
+}
+
+// testTarget inserts a scan-all-addresses target (connectAddress keeps two targets of one host apart).
+func testTarget(t *testing.T, conn *sql.DB, userID, targetURL, connectAddress string) model.Target {
+	t.Helper()
+	target := model.Target{UserID: userID, TargetURL: targetURL, ConnectAddress: connectAddress, ScanAllAddresses: true}
+	err := conn.QueryRowContext(context.Background(), `
+        INSERT INTO monitored_targets (user_id, target_url, connect_address, scan_all_addresses, last_scanned_at, last_status)
+        VALUES ($1, $2, $3, TRUE, NOW(), 'SUCCESS')
+        RETURNING id
+    `, userID, targetURL, connectAddress).Scan(&target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestRecordScanDetails(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	certSvc := testCertService(t, conn)
+	svc := &PostgresAgentLessTargetService{DB: conn, CertSvc: certSvc}
+	ctx := context.Background()
+
+	target := testTarget(t, conn, userID, "lb.example.test:443", "")
+	other := testTarget(t, conn, userID, "lb.example.test:443", "lb-origin.example.test") // Shares the host, not the nodes
+	ep, otherEp := target.Endpoints()[0], other.Endpoints()[0]
+
+	expiry := time.Now().Add(90 * 24 * time.Hour)
+	current := testCertificate(t, "", expiry, "lb.example.test")
+	stale := testCertificate(t, "", expiry, "lb.example.test")
+	served := func(c model.Certificate, ep model.Endpoint, ip string) model.Certificate {
+		c.SourceUID, c.ResolvedIP = model.NodeSourceUID(ep, ip), ip
+		return c
+	}
+	node := func(ip string) string { return model.NodeSourceUID(ep, ip) }
+	addr := func(ip string) string { return net.JoinHostPort(ip, "443") }
+
+	steps := []struct {
+		name            string
+		certs           []model.Certificate
+		unreachable     []model.UnreachableNode
+		wantDisagree    bool
+		wantUnreachable string
+		wantStatuses    map[string]string
+	}{
+		{
+			name: "stale node disagrees",
+			certs: []model.Certificate{
+				served(current, ep, "10.0.0.1"), served(stale, ep, "10.0.0.2"), served(current, ep, "10.0.0.3"),
+				served(current, otherEp, "10.0.0.9"),
+			},
+			wantDisagree: true,
+			wantStatuses: map[string]string{node("10.0.0.1"): "ACTIVE", node("10.0.0.2"): "ACTIVE", node("10.0.0.3"): "ACTIVE"},
+		},
+		{
+			name:            "unreachable node keeps its instance, vanished node goes MISSING",
+			certs:           []model.Certificate{served(current, ep, "10.0.0.1")},
+			unreachable:     []model.UnreachableNode{{SourceUID: node("10.0.0.2"), Addr: addr("10.0.0.2"), Error: "connection refused"}},
+			wantUnreachable: addr("10.0.0.2") + ": connection refused",
+			wantStatuses:    map[string]string{node("10.0.0.1"): "ACTIVE", node("10.0.0.2"): "ACTIVE", node("10.0.0.3"): "MISSING"},
+		},
+		{
+			name:         "node back in rotation",
+			certs:        []model.Certificate{served(current, ep, "10.0.0.1"), served(current, ep, "10.0.0.3")},
+			wantStatuses: map[string]string{node("10.0.0.1"): "ACTIVE", node("10.0.0.2"): "MISSING", node("10.0.0.3"): "ACTIVE"},
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := certSvc.IngestScanResults(ctx, userID, step.certs); err != nil {
+				t.Fatal(err)
+			}
+			var ownCerts []model.Certificate
+			for _, c := range step.certs {
+				if strings.HasSuffix(c.SourceUID, model.TargetUIDSuffix(target.ID)) {
+					ownCerts = append(ownCerts, c)
+				}
+			}
+			if err := svc.RecordScanDetails(ctx, target.ID, ownCerts, step.unreachable); err != nil {
+				t.Fatal(err)
+			}
+
+			var disagree bool
+			var unreachable sql.NullString
+			err := conn.QueryRowContext(ctx, "SELECT nodes_disagree, unreachable_nodes FROM monitored_targets WHERE id = $1", target.ID).
+				Scan(&disagree, &unreachable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if disagree != step.wantDisagree || unreachable.String != step.wantUnreachable {
+				t.Fatalf("nodes_disagree = %v, unreachable_nodes = %q; want %v, %q", disagree, unreachable.String, step.wantDisagree, step.wantUnreachable)
+			}
+
+			statuses := instanceStatuses(t, conn, userID)
+			for uid, want := range step.wantStatuses {
+				if statuses[uid] != want {
+					t.Fatalf("%s is %q, want %q (all: %v)", uid, statuses[uid], want, statuses)
+				}
+			}
+			// The other target's node is never touched by this target's scans
+			if got := statuses[model.NodeSourceUID(otherEp, "10.0.0.9")]; got != "ACTIVE" {
+				t.Fatalf("other target's node is %q, want ACTIVE", got)
+			}
+		})
+	}
+}
+
+// TestSchemaMigrationsRunOnce restarts on an already migrated database: the one-time data migrations
+// must not rescan targets (36) again, though this one has no target-qualified instance yet.
+func TestSchemaMigrationsRunOnce(t *testing.T) {
+	conn := testDB(t) // Applies the schema, recording the migrations
+	userID := testUser(t, conn)
+	target := testTarget(t, conn, userID, "restart.example.test:443", "")
+
+	if err := (&db.Store{Conn: conn}).InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	var scanned sql.NullTime
+	err := conn.QueryRowContext(context.Background(),
+		"SELECT last_scanned_at FROM monitored_targets WHERE id = $1", target.ID,
+	).Scan(&scanned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scanned.Valid {
+		t.Fatal("restart reset last_scanned_at: the source UID migration ran again")
+	}
+
+	var applied int
+	err = conn.QueryRowContext(context.Background(), `
+        SELECT COUNT(*) FROM schema_migrations
+        WHERE name IN ('34_ct_own_observations', '36_target_qualified_source_uids')
+    `).Scan(&applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 2 {
+		t.Fatalf("%d of 2 one-time migrations recorded", applied)
+	}
+}
+
+func TestUpdateTargetStatus(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := &PostgresAgentLessTargetService{DB: conn, Failures: FailurePolicy{DegradedAfter: 2, DownAfter: 4, RetryAfter: time.Minute}}
+	ctx := context.Background()
+	target := testTarget(t, conn, userID, "health.example.test:443", "")
+
+	// Each step records one scan outcome, then optionally acts on the alert like the worker does
+	steps := []struct {
+		name       string
+		status     string
+		before     string // SQL run first ($1 = target id)
+		want       model.TargetHealth
+		wantFails  int
+		wantRetry  time.Duration // 0 = no early re-scan
+		wantAlert  bool
+		markHealth model.TargetHealth // "" = not alerted
+	}{
+		{name: "first failure stays healthy", status: "FAILED", want: model.TargetHealthy, wantFails: 1, wantRetry: time.Minute},
+		{name: "second failure degrades", status: "FAILED", want: model.TargetDegraded, wantFails: 2, wantRetry: 2 * time.Minute},
+		{name: "third failure", status: "FAILED", want: model.TargetDegraded, wantFails: 3, wantRetry: 4 * time.Minute},
+		{name: "fourth failure: down and alerted", status: "FAILED", want: model.TargetDown, wantFails: 4, wantRetry: 8 * time.Minute,
+			wantAlert: true, markHealth: model.TargetDown},
+		{name: "still down within the cooldown", status: "FAILED", want: model.TargetDown, wantFails: 5, wantRetry: 16 * time.Minute},
+		{name: "still down after the cooldown", status: "FAILED", want: model.TargetDown, wantFails: 6, wantRetry: 32 * time.Minute,
+			before:    "UPDATE monitored_targets SET last_alerted_at = NOW() - INTERVAL '25 hours' WHERE id = $1",
+			wantAlert: true, markHealth: model.TargetDown},
+		{name: "recovery is alerted", status: "SUCCESS", want: model.TargetHealthy, wantAlert: true, markHealth: model.TargetHealthy},
+		{name: "a new failure after recovery", status: "FAILED", want: model.TargetHealthy, wantFails: 1, wantRetry: time.Minute},
+		{name: "retry capped at the frequency", status: "FAILED", want: model.TargetDown, wantFails: 21, wantRetry: time.Hour,
+			before:    "UPDATE monitored_targets SET frequency_hours = 1, consecutive_failures = 20 WHERE id = $1",
+			wantAlert: true},
+	}
+
+	prev := model.TargetHealthy
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.before != "" {
+				if _, err := conn.ExecContext(ctx, step.before, target.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			errStr := ""
+			if step.status == "FAILED" {
+				errStr = "i/o timeout"
+			}
+			start := time.Now()
+			change, err := svc.UpdateTargetStatus(ctx, target.ID, step.status, errStr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if change.Previous != prev || change.Current != step.want || change.ConsecutiveFailures != step.wantFails {
+				t.Fatalf("%s -> %s after %d failures, want %s -> %s after %d",
+					change.Previous, change.Current, change.ConsecutiveFailures, prev, step.want, step.wantFails)
+			}
+			if change.AlertDue != step.wantAlert {
+				t.Fatalf("alert due = %v, want %v", change.AlertDue, step.wantAlert)
+			}
+			if step.wantRetry == 0 {
+				if change.RetryAt != nil {
+					t.Fatalf("retry at %v, want none", change.RetryAt)
+				}
+			} else if change.RetryAt == nil || change.RetryAt.Sub(start) < step.wantRetry-time.Minute/2 ||
+				change.RetryAt.Sub(start) > step.wantRetry+time.Minute/2 {
+				t.Fatalf("retry at %v, want about %v from now", change.RetryAt, step.wantRetry)
+			}
+
+			if step.markHealth != "" {
+				if err := svc.MarkHealthAlerted(ctx, target.ID, step.markHealth); err != nil {
+					t.Fatal(err)
+				}
+			}
+			prev = change.Current
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix marks account API keys; the first apiKeyDisplayLen characters are kept for display.
+const (
+	apiKeyPrefix     = "crt_live_"
+	apiKeyDisplayLen = len(apiKeyPrefix) + 8
+)
+
+// ErrInvalidAPIKey rejects an unknown, revoked or expired key.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// ErrAPIKeyScope rejects an active key used outside its scope.
+var ErrAPIKeyScope = errors.New("api key scope not allowed")
+
+// PostgresAPIKeyService manages a user's named API keys. Several can be active at once,
+// so keys are rotated by creating the new one, moving clients over, then revoking the old one.
+type PostgresAPIKeyService struct {
+	DB *sql.DB
+}
+
+func NewAPIKeyService(db *sql.DB) *PostgresAPIKeyService {
+	return &PostgresAPIKeyService{DB: db}
+}
+
+const apiKeyColumns = `
+	id, name, prefix, scope, created_at, expires_at, last_used_at, last_used_ip, revoked_at`
+
+// CreateKey issues a new key. The plaintext key is only part of this response.
+func (s *PostgresAPIKeyService) CreateKey(ctx context.Context, userID string, req model.CreateAPIKeyRequest) (*model.CreatedAPIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if req.Scope != model.APIKeyScopeIngest && req.Scope != model.APIKeyScopeRead {
+		return nil, fmt.Errorf("scope must be %q or %q", model.APIKeyScopeIngest, model.APIKeyScopeRead)
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, fmt.Errorf("expires_in_days cannot be negative")
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+	return createAPIKey(ctx, s.DB, userID, name, req.Scope, expiresAt)
+}
+
+func (s *PostgresAPIKeyService) ListKeys(ctx context.Context, userID string) ([]model.APIKey, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+        SELECT `+apiKeyColumns+`
+        FROM api_keys
+        WHERE user_id = $1
+        ORDER BY revoked_at IS NOT NULL, created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeKey disables a key for good. The row is kept so its last use stays visible.
+func (s *PostgresAPIKeyService) RevokeKey(ctx context.Context, userID, keyID string) error {
+	result, err := s.DB.ExecContext(ctx, `
+        UPDATE api_keys SET revoked_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+    `, keyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("api key not found or already revoked")
+	}
+	return nil
+}
+
+// Authenticate resolves a key to its user if it is active and carries the scope, recording the use.
+func (s *PostgresAPIKeyService) Authenticate(ctx context.Context, key string, scope model.APIKeyScope, ipAddress string) (string, error) {
+	return authenticateAPIKey(ctx, s.DB, key, scope, ipAddress)
+}
+
+// --- Helpers (shared with Auth & Ingestion) ---
+
+func createAPIKey(ctx context.Context, db *sql.DB, userID, name string, scope model.APIKeyScope, expiresAt *time.Time) (*model.CreatedAPIKey, error) {
+	key, err := randomSecret(apiKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	row := db.QueryRowContext(ctx, `
+        INSERT INTO api_keys (user_id, name, prefix, key_hash, scope, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING `+apiKeyColumns,
+		userID, name, key[:apiKeyDisplayLen], hashSecret(key), scope, expiresAt)
+	k, err := scanAPIKey(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+	return &model.CreatedAPIKey{APIKey: *k, Key: key}, nil
+}
+
+// authenticateAPIKey resolves an active key of the given scope to its user.
+// Only a successful use is recorded: a key tried outside its scope keeps its last_used values.
+func authenticateAPIKey(ctx context.Context, db *sql.DB, key string, scope model.APIKeyScope, ipAddress string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("%w: missing key", ErrInvalidAPIKey)
+	}
+
+	var userID string
+	err := db.QueryRowContext(ctx, `
+        UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2
+        WHERE key_hash = $1 AND scope = $3 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+        RETURNING user_id
+    `, hashSecret(key), ipAddress, scope).Scan(&userID)
+	if err == nil {
+		return userID, nil
+	} else if err != sql.ErrNoRows {
+		return "", fmt.Errorf("api key check failed: %w", err)
+	}
+
+	// Tell an active key of another scope apart from an unknown one
+	var keyScope model.APIKeyScope
+	err = db.QueryRowContext(ctx, `
+        SELECT scope FROM api_keys
+        WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+    `, hashSecret(key)).Scan(&keyScope)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: unknown, revoked or expired", ErrInvalidAPIKey)
+	} else if err != nil {
+		return "", fmt.Errorf("api key check failed: %w", err)
+	}
+	return "", fmt.Errorf("%w: key has the %q scope, %q required", ErrAPIKeyScope, keyScope, scope)
+}
+
+// IsAPIKey tells API keys apart from session tokens (JWTs) in an Authorization header.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var k model.APIKey
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	var lastUsedIP sql.NullString
+
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scope, &k.CreatedAt, &expiresAt, &lastUsedAt, &lastUsedIP, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	k.ExpiresAt = nullTimePtr(expiresAt)
+	k.LastUsedAt = nullTimePtr(lastUsedAt)
+	k.LastUsedIP = lastUsedIP.String
+	k.RevokedAt = nullTimePtr(revokedAt)
+	return &k, nil
+}
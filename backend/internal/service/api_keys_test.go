@@ -0,0 +1,131 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCreateKeyValidation(t *testing.T) {
+	svc := &PostgresAPIKeyService{} // Rejected before any query
+
+	tests := []struct {
+		name string
+		req  model.CreateAPIKeyRequest
+	}{
+		{name: "missing name", req: model.CreateAPIKeyRequest{Name: "  ", Scope: model.APIKeyScopeRead}},
+		{name: "unknown scope", req: model.CreateAPIKeyRequest{Name: "ci", Scope: "admin"}},
+		{name: "negative expiry", req: model.CreateAPIKeyRequest{Name: "ci", Scope: model.APIKeyScopeRead, ExpiresInDays: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.CreateKey(context.Background(), "user-1", tt.req); err == nil {
+				t.Fatal("expected a validation error")
+			}
+		})
+	}
+
+	if _, err := authenticateAPIKey(context.Background(), nil, "", model.APIKeyScopeRead, ""); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("missing key: %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := NewAPIKeyService(conn)
+	ctx := context.Background()
+
+	create := func(name string, scope model.APIKeyScope, expiresInDays int) *model.CreatedAPIKey {
+		t.Helper()
+		k, err := svc.CreateKey(ctx, userID, model.CreateAPIKeyRequest{Name: name, Scope: scope, ExpiresInDays: expiresInDays})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsAPIKey(k.Key) || !strings.HasPrefix(k.Key, k.Prefix) {
+			t.Fatalf("key %q doesn't start with its prefix %q", k.Key, k.Prefix)
+		}
+		return k
+	}
+	read := create("dashboard", model.APIKeyScopeRead, 0)
+	ingest := create("agents", model.APIKeyScopeIngest, 30)
+	expired := create("old dashboard", model.APIKeyScopeRead, 1)
+	revoked := create("leaked", model.APIKeyScopeRead, 0)
+
+	if _, err := conn.ExecContext(ctx, "UPDATE api_keys SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1", expired.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RevokeKey(ctx, userID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RevokeKey(ctx, testUser(t, conn), read.ID); err == nil {
+		t.Fatal("another user revoked the key")
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		scope   model.APIKeyScope
+		wantErr error // nil = authenticated as userID
+	}{
+		{name: "read key, read scope", key: read.Key, scope: model.APIKeyScopeRead},
+		{name: "ingest key, ingest scope", key: ingest.Key, scope: model.APIKeyScopeIngest},
+		{name: "read key, ingest scope", key: read.Key, scope: model.APIKeyScopeIngest, wantErr: ErrAPIKeyScope},
+		{name: "ingest key, read scope", key: ingest.Key, scope: model.APIKeyScopeRead, wantErr: ErrAPIKeyScope},
+		{name: "expired key", key: expired.Key, scope: model.APIKeyScopeRead, wantErr: ErrInvalidAPIKey},
+		{name: "revoked key", key: revoked.Key, scope: model.APIKeyScopeRead, wantErr: ErrInvalidAPIKey},
+		{name: "revoked key, other scope", key: revoked.Key, scope: model.APIKeyScopeIngest, wantErr: ErrInvalidAPIKey},
+		{name: "unknown key", key: apiKeyPrefix + "unknown", scope: model.APIKeyScopeRead, wantErr: ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.Authenticate(ctx, tt.key, tt.scope, "203.0.113.7")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != userID {
+				t.Fatalf("user = %q, want %q", got, userID)
+			}
+		})
+	}
+
+	// Only successful uses are recorded
+	if _, err := svc.Authenticate(ctx, read.Key, model.APIKeyScopeRead, "198.51.100.20"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Authenticate(ctx, ingest.Key, model.APIKeyScopeRead, "192.0.2.99"); !errors.Is(err, ErrAPIKeyScope) {
+		t.Fatalf("err = %v, want ErrAPIKeyScope", err)
+	}
+	keys, err := svc.ListKeys(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]model.APIKey{}
+	for _, k := range keys {
+		byID[k.ID] = k
+	}
+	if k := byID[read.ID]; k.LastUsedAt == nil || k.LastUsedIP != "198.51.100.20" {
+		t.Fatalf("read key last used %v from %q, want now from 198.51.100.20", k.LastUsedAt, k.LastUsedIP)
+	}
+	if k := byID[ingest.ID]; k.LastUsedAt == nil || k.LastUsedIP != "203.0.113.7" {
+		t.Fatalf("ingest key last used %v from %q, want its ingest use (not the out-of-scope one)", k.LastUsedAt, k.LastUsedIP)
+	}
+	for _, id := range []string{expired.ID, revoked.ID} {
+		if k := byID[id]; k.LastUsedAt != nil {
+			t.Fatalf("rejected key %s recorded as used at %v", k.Name, k.LastUsedAt)
+		}
+	}
+	if k := byID[revoked.ID]; k.RevokedAt == nil {
+		t.Fatal("revoked key listed without revoked_at")
+	}
+}
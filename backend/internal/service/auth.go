@@ -84,7 +84,7 @@ func (s *PostgresAuthService) Login(ctx context.Context, req model.LoginRequest)
 	var hash string
 
 	query := `
-        SELECT id, email, organization_name, password_hash, (api_key_hash IS NOT NULL), email_enabled, is_verified
+        SELECT id, email, organization_name, password_hash, EXISTS (SELECT 1 FROM api_keys k WHERE k.user_id = users.id AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW())), email_enabled, is_verified
         FROM users 
         WHERE email = $1
     `
@@ -206,22 +206,14 @@ func (s *PostgresAuthService) ResetPassword(ctx context.Context, token, newPassw
 	return nil
 }
 
-// RegenerateAPIKey (Existing)
+// RegenerateAPIKey issues a new ingest key. Existing keys stay active (agents keep reporting) until
+// revoked under /api/keys.
 func (s *PostgresAuthService) RegenerateAPIKey(ctx context.Context, userID string) (string, error) {
-	apiKeyBytes := make([]byte, 16)
-	rand.Read(apiKeyBytes)
-	plainApiKey := "crt_live_" + hex.EncodeToString(apiKeyBytes)
-
-	hash := sha256.Sum256([]byte(plainApiKey))
-	apiKeyHash := hex.EncodeToString(hash[:])
-
-	query := `UPDATE users SET api_key_hash = $1 WHERE id = $2`
-	_, err := s.DB.ExecContext(ctx, query, apiKeyHash, userID)
+	created, err := createAPIKey(ctx, s.DB, userID, "Agent key "+time.Now().Format("2006-01-02"), model.APIKeyScopeIngest, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to update api key: %w", err)
+		return "", err
 	}
-
-	return plainApiKey, nil
+	return created.Key, nil
 }
 
 // GetUsersByIDs (Existing)
@@ -232,7 +224,7 @@ func (s *PostgresAuthService) GetUsersByIDs(ctx context.Context, userIDs []strin
 	}
 
 	query := `
-		SELECT id, email, organization_name, (api_key_hash IS NOT NULL), email_enabled, is_verified
+		SELECT id, email, organization_name, EXISTS (SELECT 1 FROM api_keys k WHERE k.user_id = users.id AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW())), email_enabled, is_verified
 		FROM users 
 		WHERE id = ANY($1)
 	`
@@ -258,7 +250,7 @@ func (s *PostgresAuthService) GetUsersByIDs(ctx context.Context, userIDs []strin
 func (s *PostgresAuthService) GetProfile(ctx context.Context, userID string) (*model.User, error) {
 	var user model.User
 	query := `
-        SELECT id, email, organization_name, (api_key_hash IS NOT NULL), email_enabled, is_verified 
+        SELECT id, email, organization_name, EXISTS (SELECT 1 FROM api_keys k WHERE k.user_id = users.id AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW())), email_enabled, is_verified 
         FROM users 
         WHERE id = $1
     `
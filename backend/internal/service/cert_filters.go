@@ -4,6 +4,7 @@ import "time"
 
 // CertFilter holds the criteria for filtering certificates.
 type CertFilter struct {
+	InstanceID  string // Single instance lookup (Detail view)
 	AgentID     string
 	SearchQuery string
 	ValidAfter  *time.Time
@@ -12,11 +13,24 @@ type CertFilter struct {
 	Offset      int
 	IsTrusted   *bool  // nil=All, true=Trusted, false=Untrusted
 	Status      string // ""=All, "ACTIVE", "MISSING"
+
+	HostnameMismatch *bool // nil=All, true=Mismatched only, false=Matching only
+
+	CryptoFinding string // ""=All, CryptoFindingAny, or a finding code (e.g. "WEAK_RSA_KEY")
+
+	SAN string // ""=All, or a DNS name / IP / email / URI the cert must cover (wildcard-aware)
 }
 
+// CryptoFindingAny matches certificates with at least one weak-crypto finding.
+const CryptoFindingAny = "ANY"
+
 // FilterOption is the function type for the Functional Options pattern.
 type FilterOption func(*CertFilter)
 
+func WithInstance(instanceID string) FilterOption {
+	return func(f *CertFilter) { f.InstanceID = instanceID }
+}
+
 func WithAgent(agentID string) FilterOption {
 	return func(f *CertFilter) { f.AgentID = agentID }
 }
@@ -56,3 +70,24 @@ func WithStatus(status string) FilterOption {
 		f.Status = status
 	}
 }
+
+// Filter by Hostname Mismatch (Independent of Trust)
+func WithHostnameMismatch(mismatch *bool) FilterOption {
+	return func(f *CertFilter) {
+		f.HostnameMismatch = mismatch
+	}
+}
+
+// Filter by Subject Alternative Name: a hostname also matches the wildcard covering it
+func WithSAN(name string) FilterOption {
+	return func(f *CertFilter) {
+		f.SAN = name
+	}
+}
+
+// Filter by Weak Crypto finding (CryptoFindingAny or a specific code)
+func WithCryptoFinding(code string) FilterOption {
+	return func(f *CertFilter) {
+		f.CryptoFinding = code
+	}
+}
@@ -4,11 +4,60 @@ import (
 	"cert-manager-backend/internal/model"
 	"context"
 	"database/sql"
+	"encoding/pem"
 	"fmt"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// instanceRevocation is true when the instance's own reported revocation result outranks the definition's
+// (written by the CRL job only): REVOKED > GOOD > UNKNOWN > never checked. Ties go to the definition.
+const instanceRevocation = `COALESCE(array_position(ARRAY['UNKNOWN','GOOD','REVOKED'], ci.revocation_status) >
+                COALESCE(array_position(ARRAY['UNKNOWN','GOOD','REVOKED'], c.revocation_status), 0), FALSE)`
+
+// revocationColumns selects the effective revocation status, reason, time and source of an instance.
+const revocationColumns = `
+            CASE WHEN ` + instanceRevocation + ` THEN ci.revocation_status ELSE c.revocation_status END,
+            CASE WHEN ` + instanceRevocation + ` THEN ci.revocation_reason ELSE c.revocation_reason END,
+            CASE WHEN ` + instanceRevocation + ` THEN ci.revoked_at ELSE c.revoked_at END,
+            CASE WHEN ` + instanceRevocation + ` THEN ci.revocation_source ELSE c.revocation_source END`
+
+// instanceColumns is the shared SELECT list for certificate instance rows (see scanInstance).
+// Expects aliases: ci = certificate_instances, a = agents, c = certificates.
+const instanceColumns = `
+            ci.id, 
+            ci.agent_id,
+            a.hostname, 
+            ci.source_uid, 
+            ci.source_type,
+            ci.current_status,
+            c.subject_cn, c.subject_org, c.subject_ou,
+            c.issuer_cn, c.issuer_org, c.issuer_ou,
+            c.valid_from,
+            c.valid_until, 
+            ci.is_trusted,
+            ci.trust_error,
+            ci.chain_position,
+            ci.leaf_instance_id,` + revocationColumns + `,
+            ci.ocsp_stapled,
+            ci.hostname_mismatch,
+            ci.hostname_error,
+            ci.resolved_ip,
+            c.sct_count,
+            c.ct_required_scts,
+            c.ct_compliant,
+            c.signature_algo,
+            c.key_algo,
+            c.key_size,
+            c.key_curve,
+            c.spki_sha256,
+            c.crypto_findings,
+            c.san_dns, c.san_ips, c.san_emails, c.san_uris,
+            c.fingerprint_sha256, c.fingerprint_sha1`
+
 // ListCertificates fetches certificates using Functional Options.
+// Rows are leaves; the intermediates served alongside each leaf are attached as its Chain.
 func (s *PostgresCertificateService) ListCertificates(ctx context.Context, userID string, opts ...FilterOption) (*model.PaginatedCerts, error) {
 	// A. Apply Defaults
 	filter := &CertFilter{
@@ -23,19 +72,7 @@ func (s *PostgresCertificateService) ListCertificates(ctx context.Context, userI
 
 	// C. Build Dynamic SQL
 	baseQuery := `
-        SELECT 
-            ci.id, 
-            ci.agent_id,
-            a.hostname, 
-            ci.source_uid, 
-            ci.source_type,
-            ci.current_status,
-            c.subject_cn, c.subject_org, c.subject_ou,
-            c.issuer_cn, c.issuer_org, c.issuer_ou,
-            c.valid_from,
-            c.valid_until, 
-            ci.is_trusted,
-            ci.trust_error,
+        SELECT ` + instanceColumns + `,
             COUNT(*) OVER() as full_count 
         FROM certificate_instances ci
         JOIN agents a ON ci.agent_id = a.id
@@ -45,6 +82,15 @@ func (s *PostgresCertificateService) ListCertificates(ctx context.Context, userI
 	args := []interface{}{userID}
 	argCounter := 2
 
+	// Single Instance lookup (Detail view) may target an intermediate; lists only show leaves.
+	if filter.InstanceID != "" {
+		baseQuery += fmt.Sprintf(" AND ci.id = $%d", argCounter)
+		args = append(args, filter.InstanceID)
+		argCounter++
+	} else {
+		baseQuery += " AND ci.chain_position = 0"
+	}
+
 	// Dynamic Filters
 	if filter.AgentID != "" {
 		baseQuery += fmt.Sprintf(" AND ci.agent_id = $%d", argCounter)
@@ -55,12 +101,27 @@ func (s *PostgresCertificateService) ListCertificates(ctx context.Context, userI
 	if filter.SearchQuery != "" {
 		// FIXED: Use distinct placeholders ($2, $3, $4, $5) and increment counter by 4.
 		// This prevents "search string" arguments from bleeding into subsequent date filters.
-		baseQuery += fmt.Sprintf(" AND (c.subject_cn ILIKE $%d OR c.issuer_cn ILIKE $%d OR a.hostname ILIKE $%d OR ci.source_uid ILIKE $%d)",
+		baseQuery += fmt.Sprintf(" AND (c.subject_cn ILIKE $%d OR c.issuer_cn ILIKE $%d OR a.hostname ILIKE $%d OR ci.source_uid ILIKE $%d",
 			argCounter, argCounter+1, argCounter+2, argCounter+3)
 
 		val := "%" + filter.SearchQuery + "%"
 		args = append(args, val, val, val, val)
 		argCounter += 4
+
+		// SANs: substring match on every kind, plus the wildcard covering a hostname query
+		baseQuery += fmt.Sprintf(` OR EXISTS (SELECT 1 FROM unnest(c.san_dns || c.san_ips || c.san_emails || c.san_uris) AS san WHERE san ILIKE $%d)
+            OR c.san_dns && $%d::text[])`, argCounter, argCounter+1)
+		args = append(args, val, pq.Array(sanCandidates(filter.SearchQuery)))
+		argCounter += 2
+	}
+
+	// SAN Filter (Exact, normalized; a hostname also matches its covering wildcard)
+	if filter.SAN != "" {
+		baseQuery += fmt.Sprintf(" AND (c.san_dns && $%d::text[] OR c.san_ips && $%d::text[] OR c.san_emails && $%d::text[] OR c.san_uris && $%d::text[])",
+			argCounter, argCounter+1, argCounter+2, argCounter+3)
+		candidates := pq.Array(sanCandidates(filter.SAN))
+		args = append(args, candidates, candidates, candidates, candidates)
+		argCounter += 4
 	}
 
 	if filter.ValidAfter != nil {
@@ -82,6 +143,22 @@ func (s *PostgresCertificateService) ListCertificates(ctx context.Context, userI
 		argCounter++
 	}
 
+	// Hostname Mismatch Filter
+	if filter.HostnameMismatch != nil {
+		baseQuery += fmt.Sprintf(" AND ci.hostname_mismatch = $%d", argCounter)
+		args = append(args, *filter.HostnameMismatch)
+		argCounter++
+	}
+
+	// Weak Crypto Filter ("ANY" = at least one finding, otherwise a specific finding code)
+	if filter.CryptoFinding == CryptoFindingAny {
+		baseQuery += " AND cardinality(c.crypto_findings) > 0"
+	} else if filter.CryptoFinding != "" {
+		baseQuery += fmt.Sprintf(" AND $%d = ANY(c.crypto_findings)", argCounter)
+		args = append(args, filter.CryptoFinding)
+		argCounter++
+	}
+
 	// Status Filter (Active vs Missing)
 	if filter.Status != "" {
 		baseQuery += fmt.Sprintf(" AND ci.current_status = $%d", argCounter)
@@ -104,65 +181,17 @@ func (s *PostgresCertificateService) ListCertificates(ctx context.Context, userI
 	var total int
 
 	for rows.Next() {
-		var r model.CertResponse
-		var sOrg, sOU, iOrg, iOU, tErr, sourceType, curStatus sql.NullString
-
-		err := rows.Scan(
-			&r.ID, &r.AgentID, &r.AgentHostname, &r.SourceUID,
-			&sourceType, &curStatus,
-			&r.Subject.CN, &sOrg, &sOU,
-			&r.Issuer.CN, &iOrg, &iOU,
-			&r.ValidFrom, &r.ValidUntil, &r.IsTrusted,
-			&tErr,
-			&total,
-		)
+		r, err := scanInstance(rows, &total)
 		if err != nil {
 			return nil, err
 		}
-
-		r.Subject.Org = sOrg.String
-		r.Subject.OU = sOU.String
-		r.Issuer.Org = iOrg.String
-		r.Issuer.OU = iOU.String
-		r.TrustError = tErr.String
-		r.SourceType = sourceType.String
-		r.CurrentStatus = curStatus.String
-
-		// Logic to determine Priority Status
-		now := time.Now()
-		// Calculate thresholds based on "End of Day" logic, identical to ListCertificates
-		y, m, d := now.Date()
-		loc := now.Location()
-
-		// Midnight tonight (The boundary for "Today")
-		endOfToday := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
-
-		// Midnight tomorrow (The boundary for "Tomorrow")
-		endOfTomorrow := time.Date(y, m, d+2, 0, 0, 0, 0, loc)
-
-		if now.After(r.ValidUntil) {
-			r.Status = model.StatusExpired
-		} else if !r.IsTrusted {
-			r.Status = model.StatusUntrusted
-		} else if now.Before(r.ValidFrom) {
-			r.Status = model.StatusNotYetValid
-		} else if now.AddDate(0, 0, 30).After(r.ValidUntil) {
-			if r.ValidUntil.Before(endOfToday) {
-				r.Status = model.StatusExpiringToday
-			} else if r.ValidUntil.Before(endOfTomorrow) {
-				r.Status = model.StatusExpiringTomorrow
-			} else if time.Until(r.ValidUntil).Hours() < 168 {
-				r.Status = model.StatusExpiringThisWeek
-			} else {
-				r.Status = model.StatusExpiringSoon
-			}
-		} else {
-			r.Status = model.StatusValid
-		}
-
 		list = append(list, r)
 	}
 
+	if err := s.attachChains(ctx, list); err != nil {
+		return nil, err
+	}
+
 	if list == nil {
 		list = []model.CertResponse{}
 	}
@@ -175,6 +204,241 @@ func (s *PostgresCertificateService) ListCertificates(ctx context.Context, userI
 	}, nil
 }
 
+// GetCertificate returns a single instance (the Detail view), including its served chain.
+func (s *PostgresCertificateService) GetCertificate(ctx context.Context, userID, instanceID string) (*model.CertResponse, error) {
+	resp, err := s.ListCertificates(ctx, userID, WithInstance(instanceID), WithPagination(1, 0))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("certificate not found or access denied")
+	}
+
+	cert := &resp.Data[0]
+	if cert.SCTs, err = s.listSCTs(ctx, cert.ID); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// GetCertificatePEM returns an instance's certificate as PEM, followed by the chain served with it when withChain.
+// Chain certificates reported before DER collection are skipped; the certificate itself must have its DER.
+func (s *PostgresCertificateService) GetCertificatePEM(ctx context.Context, userID, instanceID string, withChain bool) ([]byte, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+        SELECT ci.id, c.raw_der
+        FROM certificate_instances ci
+        JOIN agents a ON ci.agent_id = a.id
+        JOIN certificates c ON ci.certificate_id = c.id
+        WHERE a.user_id = $1
+          AND (ci.id = $2 OR ($3 AND ci.leaf_instance_id = $2))
+        ORDER BY ci.chain_position ASC
+    `, userID, instanceID, withChain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	defer rows.Close()
+
+	var out []byte
+	found := false
+	for rows.Next() {
+		var id string
+		var der []byte
+		if err := rows.Scan(&id, &der); err != nil {
+			return nil, err
+		}
+		if id == instanceID {
+			found = true
+			if len(der) == 0 {
+				return nil, fmt.Errorf("certificate was reported without its DER encoding, download available after the next scan")
+			}
+		}
+		if len(der) > 0 {
+			out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("certificate not found or access denied")
+	}
+	return out, nil
+}
+
+// listSCTs returns the SCTs recorded for the certificate behind an instance (ownership checked by the caller).
+func (s *PostgresCertificateService) listSCTs(ctx context.Context, instanceID string) ([]model.SCT, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+        SELECT s.log_id, s.timestamp, s.source
+        FROM certificate_scts s
+        JOIN certificate_instances ci ON ci.certificate_id = s.certificate_id
+        WHERE ci.id = $1
+        ORDER BY s.timestamp ASC
+    `, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scts: %w", err)
+	}
+	defer rows.Close()
+
+	var scts []model.SCT
+	for rows.Next() {
+		var sct model.SCT
+		if err := rows.Scan(&sct.LogID, &sct.Timestamp, &sct.Source); err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+	}
+	return scts, rows.Err()
+}
+
+// attachChains bulk-loads the intermediates of every leaf in the list (1 query instead of N).
+func (s *PostgresCertificateService) attachChains(ctx context.Context, list []model.CertResponse) error {
+	index := make(map[string]int)
+	var leafIDs []string
+	for i, r := range list {
+		if r.ChainPosition == 0 {
+			index[r.ID] = i
+			leafIDs = append(leafIDs, r.ID)
+		}
+	}
+	if len(leafIDs) == 0 {
+		return nil
+	}
+
+	query := `
+        SELECT ` + instanceColumns + `
+        FROM certificate_instances ci
+        JOIN agents a ON ci.agent_id = a.id
+        JOIN certificates c ON ci.certificate_id = c.id
+        WHERE ci.leaf_instance_id = ANY($1::uuid[])
+        ORDER BY ci.chain_position ASC
+    `
+	rows, err := s.DB.QueryContext(ctx, query, pq.Array(leafIDs))
+	if err != nil {
+		return fmt.Errorf("failed to load certificate chains: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanInstance(rows)
+		if err != nil {
+			return err
+		}
+		leaf := &list[index[r.LeafInstanceID]]
+		leaf.Chain = append(leaf.Chain, r)
+	}
+	return rows.Err()
+}
+
+// scanInstance reads one row selected with instanceColumns (plus any trailing extras) and derives its Status.
+func scanInstance(rows *sql.Rows, extra ...interface{}) (model.CertResponse, error) {
+	var r model.CertResponse
+	var sOrg, sOU, iOrg, iOU, tErr, sourceType, curStatus, leafID sql.NullString
+	var revStatus, revReason, revSource sql.NullString
+	var revokedAt sql.NullTime
+	var stapled, hostMismatch sql.NullBool
+	var hostErr, resolvedIP sql.NullString
+	var sctCount, ctRequired sql.NullInt64
+	var ctCompliant sql.NullBool
+	var sigAlgo, keyAlgo, keyCurve, spki sql.NullString
+	var keySize sql.NullInt64
+	var cryptoFindings pq.StringArray
+	var sanDNS, sanIPs, sanEmails, sanURIs pq.StringArray
+	var fpSHA256, fpSHA1 sql.NullString
+
+	dest := []interface{}{
+		&r.ID, &r.AgentID, &r.AgentHostname, &r.SourceUID,
+		&sourceType, &curStatus,
+		&r.Subject.CN, &sOrg, &sOU,
+		&r.Issuer.CN, &iOrg, &iOU,
+		&r.ValidFrom, &r.ValidUntil, &r.IsTrusted,
+		&tErr,
+		&r.ChainPosition, &leafID,
+		&revStatus, &revReason, &revokedAt, &revSource, &stapled,
+		&hostMismatch, &hostErr, &resolvedIP,
+		&sctCount, &ctRequired, &ctCompliant,
+		&sigAlgo, &keyAlgo, &keySize, &keyCurve, &spki, &cryptoFindings,
+		&sanDNS, &sanIPs, &sanEmails, &sanURIs,
+		&fpSHA256, &fpSHA1,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return r, err
+	}
+
+	r.Subject.Org = sOrg.String
+	r.Subject.OU = sOU.String
+	r.Issuer.Org = iOrg.String
+	r.Issuer.OU = iOU.String
+	r.TrustError = tErr.String
+	r.SourceType = sourceType.String
+	r.CurrentStatus = curStatus.String
+	r.LeafInstanceID = leafID.String
+	r.RevocationStatus = model.RevocationStatus(revStatus.String)
+	r.RevocationReason = revReason.String
+	if revokedAt.Valid {
+		r.RevokedAt = &revokedAt.Time
+	}
+	r.RevocationSource = revSource.String
+	r.OCSPStapled = stapled.Bool
+	r.HostnameMismatch = hostMismatch.Bool
+	r.HostnameError = hostErr.String
+	r.ResolvedIP = resolvedIP.String
+	r.SCTCount = int(sctCount.Int64)
+	r.CTRequired = int(ctRequired.Int64)
+	if ctCompliant.Valid {
+		r.CTCompliant = &ctCompliant.Bool
+	}
+	r.SignatureAlgo = sigAlgo.String
+	r.KeyAlgo = keyAlgo.String
+	r.KeySize = int(keySize.Int64)
+	r.KeyCurve = keyCurve.String
+	r.SPKISHA256 = spki.String
+	r.CryptoFindings = cryptoFindings
+	r.SANs = model.SubjectAltNames{DNSNames: sanDNS, IPAddresses: sanIPs, EmailAddresses: sanEmails, URIs: sanURIs}
+	r.FingerprintSHA256 = fpSHA256.String
+	r.FingerprintSHA1 = fpSHA1.String
+
+	r.Status = computeStatus(r, time.Now())
+	return r, nil
+}
+
+// computeStatus determines the Priority Status of a certificate instance.
+func computeStatus(r model.CertResponse, now time.Time) model.CertStatus {
+	// Calculate thresholds based on "End of Day" logic
+	y, m, d := now.Date()
+	loc := now.Location()
+
+	// Midnight tonight (The boundary for "Today")
+	endOfToday := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+
+	// Midnight tomorrow (The boundary for "Tomorrow")
+	endOfTomorrow := time.Date(y, m, d+2, 0, 0, 0, 0, loc)
+
+	// Revocation trumps everything: the cert must not be used regardless of its dates
+	if r.RevocationStatus == model.RevocationRevoked {
+		return model.StatusRevoked
+	} else if now.After(r.ValidUntil) {
+		return model.StatusExpired
+	} else if r.HostnameMismatch {
+		// Checked before trust: a self-signed / private-CA cert for the wrong host must still report the
+		// misconfiguration (is_trusted & hostname_mismatch are both in the response for the full picture)
+		return model.StatusHostnameMismatch
+	} else if !r.IsTrusted {
+		return model.StatusUntrusted
+	} else if now.Before(r.ValidFrom) {
+		return model.StatusNotYetValid
+	} else if now.AddDate(0, 0, 30).After(r.ValidUntil) {
+		if r.ValidUntil.Before(endOfToday) {
+			return model.StatusExpiringToday
+		} else if r.ValidUntil.Before(endOfTomorrow) {
+			return model.StatusExpiringTomorrow
+		} else if r.ValidUntil.Sub(now).Hours() < 168 {
+			return model.StatusExpiringThisWeek
+		}
+		return model.StatusExpiringSoon
+	}
+	return model.StatusValid
+}
+
 // DeleteInstance removes a specific certificate instance (Hard Delete).
 func (s *PostgresCertificateService) DeleteInstance(ctx context.Context, userID, instanceID string) error {
 	// We use a JOIN to ensure the instance actually belongs to an agent owned by the requesting user.
@@ -225,6 +489,10 @@ func (s *PostgresCertificateService) CleanupOrphanedCerts(ctx context.Context) (
         WHERE id NOT IN (
             SELECT DISTINCT certificate_id FROM certificate_instances
         )
+        AND id NOT IN (
+            -- Replaced certificates stay for the instance's history (pruned by PruneReplacements)
+            SELECT old_certificate_id FROM certificate_replacements WHERE old_certificate_id IS NOT NULL
+        )
     `
 	result, err := s.DB.ExecContext(ctx, query)
 	if err != nil {
@@ -234,6 +502,8 @@ func (s *PostgresCertificateService) CleanupOrphanedCerts(ctx context.Context) (
 }
 
 // GetExpiringCertificates fetches certificates expiring within the threshold.
+// Revoked certificates are always included: the Alerter treats them as critical.
+// Hostname mismatches are included too, reported as their own failure class.
 func (s *PostgresCertificateService) GetExpiringCertificates(ctx context.Context, threshold time.Duration) ([]model.CertResponse, error) {
 	cutoff := time.Now().Add(threshold)
 
@@ -243,12 +513,15 @@ func (s *PostgresCertificateService) GetExpiringCertificates(ctx context.Context
             c.subject_cn, c.subject_org, c.subject_ou,
             c.issuer_cn, c.issuer_org, c.issuer_ou,
             ci.source_uid, ci.is_trusted,
-            a.id AS agent_id, a.hostname, a.user_id
+            a.id AS agent_id, a.hostname, a.user_id,
+            CASE WHEN ` + instanceRevocation + ` THEN ci.revocation_status ELSE c.revocation_status END,
+            CASE WHEN ` + instanceRevocation + ` THEN ci.revocation_reason ELSE c.revocation_reason END,
+            CASE WHEN ` + instanceRevocation + ` THEN ci.revoked_at ELSE c.revoked_at END,
+            ci.hostname_mismatch, ci.hostname_error
         FROM certificate_instances ci
         JOIN certificates c ON ci.certificate_id = c.id
         JOIN agents a ON ci.agent_id = a.id
-        WHERE c.valid_until < $1 
-          AND c.valid_until > NOW()
+        WHERE ((c.valid_until < $1 AND c.valid_until > NOW()) OR c.revocation_status = 'REVOKED' OR ci.revocation_status = 'REVOKED' OR ci.hostname_mismatch = TRUE)
           AND ci.current_status = 'ACTIVE' 
     `
 
@@ -265,6 +538,10 @@ func (s *PostgresCertificateService) GetExpiringCertificates(ctx context.Context
 		var subCN, subOrg, subOU sql.NullString
 		var issCN, issOrg, issOU sql.NullString
 		var serial sql.NullString
+		var revStatus, revReason sql.NullString
+		var revokedAt sql.NullTime
+		var hostMismatch sql.NullBool
+		var hostErr sql.NullString
 
 		err := rows.Scan(
 			&cr.ID, &serial, &cr.ValidFrom, &cr.ValidUntil,
@@ -272,6 +549,8 @@ func (s *PostgresCertificateService) GetExpiringCertificates(ctx context.Context
 			&issCN, &issOrg, &issOU,
 			&cr.SourceUID, &cr.IsTrusted,
 			&cr.AgentID, &cr.AgentHostname, &cr.OwnerID,
+			&revStatus, &revReason, &revokedAt,
+			&hostMismatch, &hostErr,
 		)
 		if err != nil {
 			return nil, err
@@ -279,9 +558,20 @@ func (s *PostgresCertificateService) GetExpiringCertificates(ctx context.Context
 
 		cr.Subject = model.DN{CN: subCN.String, Org: subOrg.String, OU: subOU.String}
 		cr.Issuer = model.DN{CN: issCN.String, Org: issOrg.String, OU: issOU.String}
+		cr.RevocationStatus = model.RevocationStatus(revStatus.String)
+		cr.RevocationReason = revReason.String
+		if revokedAt.Valid {
+			cr.RevokedAt = &revokedAt.Time
+		}
+		cr.HostnameMismatch = hostMismatch.Bool
+		cr.HostnameError = hostErr.String
 
 		hoursUntil := time.Until(cr.ValidUntil).Hours()
-		if hoursUntil < 24 {
+		if cr.RevocationStatus == model.RevocationRevoked {
+			cr.Status = model.StatusRevoked
+		} else if cr.HostnameMismatch {
+			cr.Status = model.StatusHostnameMismatch
+		} else if hoursUntil < 24 {
 			cr.Status = model.StatusExpiringToday
 		} else if hoursUntil < 48 {
 			cr.Status = model.StatusExpiringTomorrow
@@ -297,11 +587,71 @@ func (s *PostgresCertificateService) GetExpiringCertificates(ctx context.Context
 	return alerts, nil
 }
 
+// GetRevocationCandidates returns every certificate with CRL distribution points that is still in use.
+// Only certificates whose issuer's DER is known qualify: a CRL is only trusted once its signature checks out.
+func (s *PostgresCertificateService) GetRevocationCandidates(ctx context.Context) ([]model.RevocationCheck, error) {
+	query := `
+        SELECT c.id, c.serial_number, c.issuer_cn, c.issuer_org, c.issuer_ou, c.crl_urls, i.raw_der
+        FROM certificates c
+        JOIN certificates i ON c.issuer_certificate_id = i.id
+        WHERE c.crl_urls IS NOT NULL
+          AND i.raw_der IS NOT NULL
+          AND c.valid_until > NOW()
+          AND EXISTS (
+              SELECT 1 FROM certificate_instances ci
+              WHERE ci.certificate_id = c.id AND ci.current_status = 'ACTIVE'
+          )
+    `
+	rows, err := s.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revocation candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []model.RevocationCheck
+	for rows.Next() {
+		var rc model.RevocationCheck
+		var iOrg, iOU sql.NullString
+		if err := rows.Scan(&rc.CertificateID, &rc.Serial, &rc.Issuer.CN, &iOrg, &iOU, pq.Array(&rc.CRLURLs), &rc.IssuerDER); err != nil {
+			return nil, err
+		}
+		rc.Issuer.Org = iOrg.String
+		rc.Issuer.OU = iOU.String
+		checks = append(checks, rc)
+	}
+	return checks, rows.Err()
+}
+
+// UpdateRevocation stores the result of a CRL lookup.
+// It is the only writer of the definition's revocation columns; reporters' results stay on their instances.
+// A CRL saying GOOD never clears a REVOKED verdict from OCSP (revocation is permanent),
+// and an inconclusive UNKNOWN never overwrites a conclusive result.
+func (s *PostgresCertificateService) UpdateRevocation(ctx context.Context, check model.RevocationCheck) error {
+	query := `
+        UPDATE certificates
+        SET revocation_status = $1,
+            revocation_reason = $2,
+            revoked_at = $3,
+            revocation_source = $4,
+            revocation_checked_at = NOW()
+        WHERE id = $5
+          AND ($1 <> 'UNKNOWN' OR revocation_status IS NULL OR revocation_status = 'UNKNOWN')
+          AND NOT ($1 = 'GOOD' AND revocation_status = 'REVOKED' AND revocation_source <> $4)
+    `
+	_, err := s.DB.ExecContext(ctx, query,
+		check.Status, nullString(check.Reason), check.RevokedAt, check.Source, check.CertificateID)
+	if err != nil {
+		return fmt.Errorf("failed to update revocation status: %w", err)
+	}
+	return nil
+}
+
 // GetDashboardStats returns summary counts for the dashboard.
 func (s *PostgresCertificateService) GetDashboardStats(ctx context.Context, userID string) (*model.DashboardStats, error) {
 	stats := &model.DashboardStats{}
 
-	// Uses 'current_status' = 'ACTIVE' to ensure we don't count missing certs
+	// Uses 'current_status' = 'ACTIVE' to ensure we don't count missing certs,
+	// and chain_position = 0 so intermediates / roots reported alongside a leaf aren't counted as certificates
 	queryCerts := `
         SELECT 
             COUNT(*) FILTER (WHERE ci.current_status = 'ACTIVE') AS total,
@@ -310,7 +660,7 @@ func (s *PostgresCertificateService) GetDashboardStats(ctx context.Context, user
         FROM certificate_instances ci
         JOIN agents a ON ci.agent_id = a.id
         JOIN certificates c ON ci.certificate_id = c.id
-        WHERE a.user_id = $1
+        WHERE a.user_id = $1 AND ci.chain_position = 0
     `
 	err := s.DB.QueryRowContext(ctx, queryCerts, userID).Scan(&stats.TotalCerts, &stats.ExpiringSoon, &stats.Expired)
 	if err != nil {
@@ -334,5 +684,55 @@ func (s *PostgresCertificateService) GetDashboardStats(ctx context.Context, user
 
 	stats.OfflineAgents = stats.TotalAgents - stats.OnlineAgents
 
+	// Query 3: Weak Crypto (Active instances per finding code)
+	queryCrypto := `
+        SELECT f.code, COUNT(*)
+        FROM certificate_instances ci
+        JOIN agents a ON ci.agent_id = a.id
+        JOIN certificates c ON ci.certificate_id = c.id
+        CROSS JOIN LATERAL unnest(c.crypto_findings) AS f(code)
+        WHERE a.user_id = $1 AND ci.current_status = 'ACTIVE' AND ci.chain_position = 0
+        GROUP BY f.code
+    `
+	rows, err := s.DB.QueryContext(ctx, queryCrypto, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count crypto findings: %w", err)
+	}
+	defer rows.Close()
+
+	stats.CryptoFindings = map[string]int{}
+	for rows.Next() {
+		var code string
+		var count int
+		if err := rows.Scan(&code, &count); err != nil {
+			return nil, err
+		}
+		stats.CryptoFindings[code] = count
+	}
+
+	err = s.DB.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM certificate_instances ci
+        JOIN agents a ON ci.agent_id = a.id
+        JOIN certificates c ON ci.certificate_id = c.id
+        WHERE a.user_id = $1 AND ci.current_status = 'ACTIVE' AND ci.chain_position = 0 AND cardinality(c.crypto_findings) > 0
+    `, userID).Scan(&stats.WeakCrypto)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count weak crypto: %w", err)
+	}
+
+	// Query 4: Domain Registrations (Same windows as the certificate counts)
+	err = s.DB.QueryRowContext(ctx, `
+        SELECT
+            COUNT(*),
+            COUNT(*) FILTER (WHERE expires_at < NOW() + INTERVAL '30 days' AND expires_at > NOW()),
+            COUNT(*) FILTER (WHERE expires_at < NOW())
+        FROM domain_registrations
+        WHERE user_id = $1
+    `, userID).Scan(&stats.TotalDomains, &stats.DomainsExpiringSoon, &stats.DomainsExpired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count domains: %w", err)
+	}
+
 	return stats, nil
 }
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"testing"
+	"time"
+)
+
+func TestComputeStatus(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	valid := model.CertResponse{
+		ValidFrom:  now.AddDate(0, -1, 0),
+		ValidUntil: now.AddDate(1, 0, 0),
+		IsTrusted:  true,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *model.CertResponse)
+		want   model.CertStatus
+	}{
+		{name: "valid", modify: func(r *model.CertResponse) {}, want: model.StatusValid},
+		{name: "untrusted", modify: func(r *model.CertResponse) { r.IsTrusted = false }, want: model.StatusUntrusted},
+		{name: "hostname mismatch", modify: func(r *model.CertResponse) { r.HostnameMismatch = true }, want: model.StatusHostnameMismatch},
+		{
+			name:   "untrusted cert for the wrong host reports the mismatch",
+			modify: func(r *model.CertResponse) { r.IsTrusted, r.HostnameMismatch = false, true },
+			want:   model.StatusHostnameMismatch,
+		},
+		{
+			name:   "revoked beats everything",
+			modify: func(r *model.CertResponse) { r.RevocationStatus, r.HostnameMismatch = model.RevocationRevoked, true },
+			want:   model.StatusRevoked,
+		},
+		{name: "expired", modify: func(r *model.CertResponse) { r.ValidUntil = now.Add(-time.Hour) }, want: model.StatusExpired},
+		{name: "expiring today", modify: func(r *model.CertResponse) { r.ValidUntil = now.Add(2 * time.Hour) }, want: model.StatusExpiringToday},
+		{name: "expiring tomorrow", modify: func(r *model.CertResponse) { r.ValidUntil = now.Add(24 * time.Hour) }, want: model.StatusExpiringTomorrow},
+		{name: "expiring soon", modify: func(r *model.CertResponse) { r.ValidUntil = now.AddDate(0, 0, 20) }, want: model.StatusExpiringSoon},
+		{name: "not yet valid", modify: func(r *model.CertResponse) { r.ValidFrom = now.Add(time.Hour) }, want: model.StatusNotYetValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			if got := computeStatus(r, now); got != tt.want {
+				t.Fatalf("computeStatus = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"cert-manager-backend/internal/egress"
+	"cert-manager-backend/internal/model"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Discovery limits per job. The per-tenant address caps (MaxAddresses, MaxAddressesPerDay) are configurable.
+const (
+	maxDiscoveryCIDRs = 16
+	maxDiscoveryPorts = 16
+
+	discoveryQuotaWindow = 24 * time.Hour // MaxAddressesPerDay counts jobs created within this window
+)
+
+type PostgresDiscoveryService struct {
+	DB      *sql.DB
+	CertSvc CertificateService
+	Egress  *egress.Policy // Ranges must be entirely allowed (the scanner enforces it per connection anyway)
+
+	// MaxAddresses caps the addresses a user may have queued or running across all their jobs.
+	// MaxAddressesPerDay caps the addresses of all jobs a user created in the last 24h, whatever their
+	// status, so finished jobs can't be chained into a continuous sweep.
+	MaxAddresses                  int
+	MaxAddressesPerDay            int
+	UserScanDefaultFrequencyHours int
+}
+
+func NewDiscoveryService(db *sql.DB, certSvc CertificateService, policy *egress.Policy, maxAddresses, maxAddressesPerDay, userScanDefaultFrequencyHours int) *PostgresDiscoveryService {
+	return &PostgresDiscoveryService{
+		DB:                            db,
+		CertSvc:                       certSvc,
+		Egress:                        policy,
+		MaxAddresses:                  maxAddresses,
+		MaxAddressesPerDay:            maxAddressesPerDay,
+		UserScanDefaultFrequencyHours: userScanDefaultFrequencyHours,
+	}
+}
+
+// --- User Facing Logic ---
+
+// CreateJob validates the ranges & ports, enforces the address cap and queues the sweep.
+func (s *PostgresDiscoveryService) CreateJob(ctx context.Context, userID string, cidrs []string, ports []int, addTargets bool) (*model.DiscoveryJob, error) {
+	// 1. Validate
+	prefixes, addresses, err := parseDiscoveryRanges(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	ports, err = normalizeDiscoveryPorts(ports)
+	if err != nil {
+		return nil, err
+	}
+	if addresses > s.MaxAddresses {
+		return nil, fmt.Errorf("ranges contain %d addresses, the limit is %d", addresses, s.MaxAddresses)
+	}
+	for _, p := range prefixes {
+		if !s.Egress.AllowsPrefix(p) {
+			return nil, fmt.Errorf("%s: range %s is not (entirely) allowed by the egress policy", egress.ErrorCode, p)
+		}
+	}
+
+	job := &model.DiscoveryJob{
+		UserID:       userID,
+		Ports:        ports,
+		AddTargets:   addTargets,
+		Status:       model.DiscoveryPending,
+		AddressCount: addresses,
+		TotalProbes:  addresses * len(ports),
+	}
+	for _, p := range prefixes {
+		job.CIDRs = append(job.CIDRs, p.String())
+	}
+
+	// 2. Enforce the per-tenant caps (Atomic: lock the user row so parallel requests can't both pass)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, err
+	}
+
+	var active, recent int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(address_count) FILTER (WHERE status IN ('PENDING', 'RUNNING')), 0),
+		       COALESCE(SUM(address_count) FILTER (WHERE created_at > $2), 0)
+		FROM discovery_jobs
+		WHERE user_id = $1
+	`, userID, time.Now().Add(-discoveryQuotaWindow)).Scan(&active, &recent)
+	if err != nil {
+		return nil, err
+	}
+	if active+addresses > s.MaxAddresses {
+		return nil, fmt.Errorf("address limit exceeded: %d addresses already queued or running, the limit is %d", active, s.MaxAddresses)
+	}
+	if recent+addresses > s.MaxAddressesPerDay {
+		return nil, fmt.Errorf("daily address limit exceeded: %d addresses swept in the last 24h, the limit is %d", recent, s.MaxAddressesPerDay)
+	}
+
+	// 3. Queue
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO discovery_jobs (user_id, cidrs, ports, add_targets, address_count, total_probes, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, userID, pq.Array(job.CIDRs), pq.Array(toInt64s(ports)), addTargets, addresses, job.TotalProbes, job.Status,
+	).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+const discoveryJobColumns = `
+	id, user_id, cidrs, ports, add_targets, status, address_count, total_probes, probes_done, found,
+	error, created_at, started_at, finished_at`
+
+func (s *PostgresDiscoveryService) ListJobs(ctx context.Context, userID string) ([]model.DiscoveryJob, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+discoveryJobColumns+`
+		FROM discovery_jobs
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []model.DiscoveryJob{}
+	for rows.Next() {
+		job, err := scanDiscoveryJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, nil
+}
+
+// GetJob returns a job with the endpoints it found so far.
+func (s *PostgresDiscoveryService) GetJob(ctx context.Context, userID, jobID string) (*model.DiscoveryJob, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT `+discoveryJobColumns+`
+		FROM discovery_jobs
+		WHERE id = $1 AND user_id = $2
+	`, jobID, userID)
+	job, err := scanDiscoveryJob(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("discovery job not found or access denied")
+	} else if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT address, protocol, subject_cn, issuer_cn, valid_until, target_id, found_at
+		FROM discovery_results
+		WHERE job_id = $1
+		ORDER BY found_at
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r model.DiscoveryResult
+		var subjectCN, issuerCN, targetID sql.NullString
+		if err := rows.Scan(&r.Address, &r.Protocol, &subjectCN, &issuerCN, &r.ValidUntil, &targetID, &r.FoundAt); err != nil {
+			return nil, err
+		}
+		r.SubjectCN = subjectCN.String
+		r.IssuerCN = issuerCN.String
+		r.TargetID = targetID.String
+		job.Results = append(job.Results, r)
+	}
+	return job, nil
+}
+
+// CancelJob stops a queued or running job. The worker notices on its next progress update.
+func (s *PostgresDiscoveryService) CancelJob(ctx context.Context, userID, jobID string) error {
+	result, err := s.DB.ExecContext(ctx, `
+		UPDATE discovery_jobs
+		SET status = 'CANCELLED', finished_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status IN ('PENDING', 'RUNNING')
+	`, jobID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("discovery job not found or already finished")
+	}
+	return nil
+}
+
+// --- Worker Facing Logic ---
+
+// ClaimJob moves the oldest queued job to RUNNING. Returns (nil, nil) when the queue is empty.
+func (s *PostgresDiscoveryService) ClaimJob(ctx context.Context) (*model.DiscoveryJob, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		UPDATE discovery_jobs
+		SET status = 'RUNNING', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM discovery_jobs
+			WHERE status = 'PENDING'
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+discoveryJobColumns)
+
+	job, err := scanDiscoveryJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// UpdateProgress stores how many probes are done and returns the job's current status,
+// so the worker learns about a cancellation in the same round trip.
+func (s *PostgresDiscoveryService) UpdateProgress(ctx context.Context, jobID string, probesDone int) (model.DiscoveryStatus, error) {
+	var status model.DiscoveryStatus
+	err := s.DB.QueryRowContext(ctx, `
+		UPDATE discovery_jobs
+		SET probes_done = $2
+		WHERE id = $1
+		RETURNING status
+	`, jobID, probesDone).Scan(&status)
+	return status, err
+}
+
+// RecordEndpoint ingests the chain of a found endpoint, optionally promotes it to a Monitored Target,
+// and adds it to the job's results.
+func (s *PostgresDiscoveryService) RecordEndpoint(ctx context.Context, job model.DiscoveryJob, address string, protocol model.ScanProtocol, certs []model.Certificate) error {
+	// 1. Ingest (same path as the Cloud Monitor)
+	for i := range certs {
+		certs[i].SourceType = "CLOUD"
+	}
+	if err := s.CertSvc.IngestScanResults(ctx, job.UserID, certs); err != nil {
+		return fmt.Errorf("ingest failed: %w", err)
+	}
+
+	// 2. Promote (Optional). The target's SourceUID equals the address, so it adopts the ingested instances.
+	var targetID sql.NullString
+	if job.AddTargets {
+		id, err := s.promote(ctx, job.UserID, address, protocol)
+		if err != nil {
+			return fmt.Errorf("failed to add target: %w", err)
+		}
+		targetID = sql.NullString{String: id, Valid: true}
+	}
+
+	// 3. Record the Result
+	var leaf model.Certificate
+	for _, c := range certs {
+		if c.ChainPosition == 0 {
+			leaf = c
+			break
+		}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO discovery_results (job_id, address, protocol, subject_cn, issuer_cn, valid_until, target_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (job_id, address) DO NOTHING
+	`, job.ID, address, protocol, nullString(leaf.Subject.CN), nullString(leaf.Issuer.CN), leaf.ValidUntil, targetID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE discovery_jobs SET found = found + 1 WHERE id = $1`, job.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// promote adds address as a Monitored Target (already scanned now), or returns the existing one.
+func (s *PostgresDiscoveryService) promote(ctx context.Context, userID, address string, protocol model.ScanProtocol) (string, error) {
+	var id string
+	err := s.DB.QueryRowContext(ctx, `
+		INSERT INTO monitored_targets (user_id, target_url, protocol, frequency_hours, last_scanned_at, last_status)
+		VALUES ($1, $2, $3, $4, NOW(), 'SUCCESS')
+		ON CONFLICT (user_id, target_url, server_name, connect_address) DO NOTHING
+		RETURNING id
+	`, userID, address, protocol, s.UserScanDefaultFrequencyHours).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = s.DB.QueryRowContext(ctx, `
+			SELECT id FROM monitored_targets
+			WHERE user_id = $1 AND target_url = $2 AND server_name = '' AND connect_address = ''
+		`, userID, address).Scan(&id)
+	}
+	return id, err
+}
+
+// FinishJob records the final state of a RUNNING job. A job cancelled meanwhile keeps its CANCELLED status.
+func (s *PostgresDiscoveryService) FinishJob(ctx context.Context, jobID string, probesDone int, status model.DiscoveryStatus, errStr string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE discovery_jobs
+		SET probes_done = $2,
+		    status = CASE WHEN status = 'RUNNING' THEN $3 ELSE status END,
+		    error = $4,
+		    finished_at = COALESCE(finished_at, NOW())
+		WHERE id = $1
+	`, jobID, probesDone, status, nullString(errStr))
+	return err
+}
+
+// FailInterruptedJobs marks jobs left RUNNING by a previous process (crash / redeploy) as FAILED,
+// so they stop counting against the address cap.
+func (s *PostgresDiscoveryService) FailInterruptedJobs(ctx context.Context) (int64, error) {
+	result, err := s.DB.ExecContext(ctx, `
+		UPDATE discovery_jobs
+		SET status = 'FAILED', error = 'interrupted by a server restart', finished_at = NOW()
+		WHERE status = 'RUNNING'
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// --- Helpers ---
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDiscoveryJob(row rowScanner) (*model.DiscoveryJob, error) {
+	var job model.DiscoveryJob
+	var ports pq.Int64Array
+	var errStr sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.UserID, pq.Array(&job.CIDRs), &ports, &job.AddTargets, &job.Status,
+		&job.AddressCount, &job.TotalProbes, &job.ProbesDone, &job.Found,
+		&errStr, &job.CreatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Ports = toInts(ports)
+	job.Error = errStr.String
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// parseDiscoveryRanges accepts CIDR blocks and bare IPs, and counts the addresses they cover.
+// Overlapping ranges are rejected: they would probe the same address twice.
+func parseDiscoveryRanges(cidrs []string) ([]netip.Prefix, int, error) {
+	if len(cidrs) == 0 {
+		return nil, 0, fmt.Errorf("at least one CIDR range is required")
+	}
+	if len(cidrs) > maxDiscoveryCIDRs {
+		return nil, 0, fmt.Errorf("too many ranges (max %d)", maxDiscoveryCIDRs)
+	}
+
+	var prefixes []netip.Prefix
+	total := 0
+	for _, raw := range cidrs {
+		prefix, err := egress.ParsePrefix(raw)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Guard the shift: anything beyond 2^24 addresses is far above any sane cap
+		hostBits := prefix.Addr().BitLen() - prefix.Bits()
+		if hostBits > 24 {
+			return nil, 0, fmt.Errorf("range %s is too large", prefix)
+		}
+
+		for _, other := range prefixes {
+			if other.Overlaps(prefix) {
+				return nil, 0, fmt.Errorf("ranges %s and %s overlap", other, prefix)
+			}
+		}
+		prefixes = append(prefixes, prefix)
+		total += 1 << hostBits
+	}
+	return prefixes, total, nil
+}
+
+// normalizeDiscoveryPorts validates and de-duplicates the port list. Defaults to 443.
+func normalizeDiscoveryPorts(ports []int) ([]int, error) {
+	if len(ports) == 0 {
+		return []int{443}, nil
+	}
+
+	seen := map[int]bool{}
+	var out []int
+	for _, p := range ports {
+		if p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid port: %d", p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	if len(out) > maxDiscoveryPorts {
+		return nil, fmt.Errorf("too many ports (max %d)", maxDiscoveryPorts)
+	}
+	return out, nil
+}
+
+// DiscoveryAddresses enumerates every address of a job's ranges, in order.
+// Stops early when yield returns false.
+func DiscoveryAddresses(cidrs []string, yield func(netip.Addr) bool) error {
+	for _, raw := range cidrs {
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q", raw)
+		}
+		for addr := prefix.Masked().Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+			if !yield(addr) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseDiscoveryRanges(t *testing.T) {
+	tooMany := make([]string, maxDiscoveryCIDRs+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("10.0.%d.0/24", i)
+	}
+
+	tests := []struct {
+		name      string
+		cidrs     []string
+		wantCount int
+		wantTotal int
+		wantErr   bool
+	}{
+		{name: "single range", cidrs: []string{"10.0.0.0/24"}, wantCount: 1, wantTotal: 256},
+		{name: "bare ip", cidrs: []string{"192.0.2.7"}, wantCount: 1, wantTotal: 1},
+		{name: "unmasked cidr", cidrs: []string{"10.0.0.77/30"}, wantCount: 1, wantTotal: 4},
+		{name: "several ranges", cidrs: []string{"10.0.0.0/24", " 10.0.1.0/25 ", "2001:db8::/120"}, wantCount: 3, wantTotal: 256 + 128 + 256},
+		{name: "largest accepted", cidrs: []string{"10.0.0.0/8"}, wantCount: 1, wantTotal: 1 << 24},
+		{name: "too large", cidrs: []string{"10.0.0.0/7"}, wantErr: true},
+		{name: "too large ipv6", cidrs: []string{"2001:db8::/64"}, wantErr: true},
+		{name: "overlapping", cidrs: []string{"10.0.0.0/24", "10.0.0.128/25"}, wantErr: true},
+		{name: "duplicate ip", cidrs: []string{"192.0.2.7", "192.0.2.7/32"}, wantErr: true},
+		{name: "invalid", cidrs: []string{"10.0.0.0/24", "not-a-cidr"}, wantErr: true},
+		{name: "empty", wantErr: true},
+		{name: "too many ranges", cidrs: tooMany, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, total, err := parseDiscoveryRanges(tt.cidrs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v (%d addresses)", prefixes, total)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prefixes) != tt.wantCount || total != tt.wantTotal {
+				t.Fatalf("got %d ranges / %d addresses, want %d / %d", len(prefixes), total, tt.wantCount, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"cert-manager-backend/internal/domains"
+	"cert-manager-backend/internal/model"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// domainFailureRetry re-checks a domain whose last lookup failed sooner than CheckInterval.
+const domainFailureRetry = time.Hour
+
+// domainExpiringSoon matches the dashboard's "expiring soon" window for certificates.
+const domainExpiringSoon = 30 * 24 * time.Hour
+
+// PostgresDomainService tracks the registration expiry of the domains behind each user's hostnames.
+type PostgresDomainService struct {
+	DB     *sql.DB
+	Client DomainLookupClient
+
+	CheckInterval time.Duration // Between successful lookups of a domain
+}
+
+func NewDomainService(db *sql.DB, client DomainLookupClient, checkInterval time.Duration) *PostgresDomainService {
+	return &PostgresDomainService{
+		DB:            db,
+		Client:        client,
+		CheckInterval: checkInterval,
+	}
+}
+
+const domainColumns = `
+	id, user_id, domain, expires_at, registrar, statuses, source, last_checked_at, last_error, created_at`
+
+// --- User Facing Logic ---
+
+func (s *PostgresDomainService) ListDomains(ctx context.Context, userID string) ([]model.DomainRegistration, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+domainColumns+`
+		FROM domain_registrations
+		WHERE user_id = $1
+		ORDER BY expires_at ASC NULLS LAST, domain ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.DomainRegistration{}
+	now := time.Now()
+	for rows.Next() {
+		d, err := scanDomain(rows, now)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *d)
+	}
+	return list, rows.Err()
+}
+
+// --- Worker Facing Logic ---
+
+// SyncDomains derives every user's registrable domains from their Monitored Targets and the hostnames
+// of their active certificates, adds the new ones and drops those nothing refers to anymore.
+// A domain another user already tracks starts out with that user's lookup result.
+func (s *PostgresDomainService) SyncDomains(ctx context.Context) (added, removed int64, err error) {
+	// 1. Collect Hostnames: targets, SANs & CNs (CNs that aren't hostnames are filtered out by domains.Registrable)
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT user_id, target_url FROM monitored_targets
+		UNION
+		SELECT a.user_id, host
+		FROM certificate_instances ci
+		JOIN agents a ON ci.agent_id = a.id
+		JOIN certificates c ON ci.certificate_id = c.id
+		CROSS JOIN LATERAL unnest(array_append(c.san_dns, c.subject_cn)) AS host
+		WHERE ci.current_status = 'ACTIVE' AND ci.chain_position = 0 AND a.user_id IS NOT NULL
+	`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to collect hostnames: %w", err)
+	}
+	defer rows.Close()
+
+	type pair struct{ userID, domain string }
+	seen := map[pair]bool{}
+	var userIDs, names []string
+	for rows.Next() {
+		var userID, host string
+		if err := rows.Scan(&userID, &host); err != nil {
+			return 0, 0, err
+		}
+		domain, ok := domains.Registrable(host)
+		if !ok || seen[pair{userID, domain}] {
+			continue
+		}
+		seen[pair{userID, domain}] = true
+		userIDs = append(userIDs, userID)
+		names = append(names, domain)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	// 2. Reconcile
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO domain_registrations (user_id, domain, expires_at, registrar, statuses, source, last_checked_at, last_error)
+		SELECT s.user_id, s.domain, k.expires_at, k.registrar, k.statuses, k.source, k.last_checked_at, k.last_error
+		FROM unnest($1::uuid[], $2::text[]) AS s(user_id, domain)
+		LEFT JOIN LATERAL (
+			SELECT * FROM domain_registrations k
+			WHERE k.domain = s.domain AND k.last_checked_at IS NOT NULL
+			ORDER BY k.last_checked_at DESC
+			LIMIT 1
+		) k ON TRUE
+		ON CONFLICT (user_id, domain) DO NOTHING
+	`, pq.Array(userIDs), pq.Array(names))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to add domains: %w", err)
+	}
+	added, _ = result.RowsAffected()
+
+	result, err = tx.ExecContext(ctx, `
+		DELETE FROM domain_registrations d
+		WHERE NOT EXISTS (
+			SELECT 1 FROM unnest($1::uuid[], $2::text[]) AS s(user_id, domain)
+			WHERE s.user_id = d.user_id AND s.domain = d.domain
+		)
+	`, pq.Array(userIDs), pq.Array(names))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prune domains: %w", err)
+	}
+	removed, _ = result.RowsAffected()
+
+	return added, removed, tx.Commit()
+}
+
+// GetDueDomains returns distinct domains whose lookup is due: never checked, older than CheckInterval,
+// or failed more than domainFailureRetry ago. The most overdue come first.
+func (s *PostgresDomainService) GetDueDomains(ctx context.Context, limit int) ([]string, error) {
+	now := time.Now()
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT domain
+		FROM domain_registrations
+		GROUP BY domain
+		HAVING MIN(COALESCE(last_checked_at, '-infinity')) < $1
+		    OR (BOOL_OR(last_error IS NOT NULL) AND MIN(last_checked_at) < $2)
+		ORDER BY MIN(COALESCE(last_checked_at, '-infinity')) ASC
+		LIMIT $3
+	`, now.Add(-s.CheckInterval), now.Add(-domainFailureRetry), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// CheckDomain looks the domain up once and records the result for every user tracking it.
+// A failed lookup keeps the last known expiry and only records the error.
+func (s *PostgresDomainService) CheckDomain(ctx context.Context, domain string) (*model.DomainLookup, error) {
+	lookup, lookupErr := s.Client.Lookup(ctx, domain)
+
+	var err error
+	if lookupErr != nil {
+		_, err = s.DB.ExecContext(ctx, `
+			UPDATE domain_registrations
+			SET last_checked_at = NOW(), last_error = $2
+			WHERE domain = $1
+		`, domain, lookupErr.Error())
+	} else {
+		_, err = s.DB.ExecContext(ctx, `
+			UPDATE domain_registrations
+			SET expires_at = $2, registrar = $3, statuses = $4, source = $5,
+			    last_checked_at = NOW(), last_error = NULL
+			WHERE domain = $1
+		`, domain, lookup.ExpiresAt, nullString(lookup.Registrar), pq.Array(lookup.Statuses), lookup.Source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record lookup of %s: %w", domain, err)
+	}
+	return lookup, lookupErr
+}
+
+// GetExpiringDomains returns domains expiring within the threshold (or already lapsed)
+// that haven't been alerted on within the cooldown.
+func (s *PostgresDomainService) GetExpiringDomains(ctx context.Context, threshold, cooldown time.Duration) ([]model.DomainRegistration, error) {
+	now := time.Now()
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+domainColumns+`
+		FROM domain_registrations
+		WHERE expires_at < $1
+		  AND (last_alerted_at IS NULL OR last_alerted_at < $2)
+		ORDER BY expires_at ASC
+	`, now.Add(threshold), now.Add(-cooldown))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch expiring domains: %w", err)
+	}
+	defer rows.Close()
+
+	var list []model.DomainRegistration
+	for rows.Next() {
+		d, err := scanDomain(rows, now)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *d)
+	}
+	return list, rows.Err()
+}
+
+// MarkAlerted starts the alert cooldown of the given domain registrations.
+func (s *PostgresDomainService) MarkAlerted(ctx context.Context, ids []string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE domain_registrations SET last_alerted_at = NOW() WHERE id = ANY($1::uuid[])
+	`, pq.Array(ids))
+	return err
+}
+
+// --- Helpers ---
+
+func scanDomain(row rowScanner, now time.Time) (*model.DomainRegistration, error) {
+	var d model.DomainRegistration
+	var expiresAt, checkedAt sql.NullTime
+	var registrar, source, lastErr sql.NullString
+	var statuses pq.StringArray
+
+	err := row.Scan(&d.ID, &d.UserID, &d.Domain, &expiresAt, &registrar, &statuses, &source,
+		&checkedAt, &lastErr, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	d.ExpiresAt = nullTimePtr(expiresAt)
+	d.Registrar = registrar.String
+	d.Statuses = statuses
+	d.Source = source.String
+	d.LastCheckedAt = nullTimePtr(checkedAt)
+	d.LastError = lastErr.String
+	d.Status = domainStatus(d.ExpiresAt, now)
+	return &d, nil
+}
+
+// domainStatus buckets a registration expiry like a certificate's ("" while unknown).
+func domainStatus(expiresAt *time.Time, now time.Time) model.CertStatus {
+	if expiresAt == nil {
+		return ""
+	}
+	left := expiresAt.Sub(now)
+	switch {
+	case left <= 0:
+		return model.StatusExpired
+	case left < 24*time.Hour:
+		return model.StatusExpiringToday
+	case left < 48*time.Hour:
+		return model.StatusExpiringTomorrow
+	case left < 7*24*time.Hour:
+		return model.StatusExpiringThisWeek
+	case left < domainExpiringSoon:
+		return model.StatusExpiringSoon
+	}
+	return model.StatusValid
+}
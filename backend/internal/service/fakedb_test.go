@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+// --- A minimal database/sql driver: every query is answered by a Go func ---
+
+// queryFunc returns the columns & rows of a query (no rows = sql.ErrNoRows for QueryRow).
+type queryFunc func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error)
+
+type fakeConnector struct{ handle queryFunc }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn fakeConnector
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver: prepared statements not supported")
+}
+func (c fakeConn) Close() error { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake driver: transactions not supported")
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	cols, rows, err := c.handle(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{cols: cols, rows: rows}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
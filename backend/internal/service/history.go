@@ -24,6 +24,7 @@ func NewHistoryService(db *sql.DB) *PostgresHistoryService {
 
 // FilterByCertID implements the "Bulk Check" logic with a configurable cooldown.
 // It takes a list of certs and returns ONLY the ones that haven't been sent within the cooldown window.
+// Resolved alerts don't count: a certificate that shows up again after its renewal was resolved is news.
 func (s *PostgresHistoryService) FilterByCertID(ctx context.Context, certs []model.CertResponse, alertType string, cooldown time.Duration) ([]model.CertResponse, error) {
 	if len(certs) == 0 {
 		return []model.CertResponse{}, nil
@@ -46,6 +47,7 @@ func (s *PostgresHistoryService) FilterByCertID(ctx context.Context, certs []mod
 		FROM alert_history 
 		WHERE alert_type = $1 
 		  AND sent_at > $2
+		  AND resolved_at IS NULL
 		  AND certificate_id = ANY($3::uuid[])
 	`
 
@@ -1,44 +1,44 @@
 package service
 
 import (
+	"cert-manager-backend/internal/cryptostrength"
+	"cert-manager-backend/internal/ct"
 	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/scanner"
 	"context"
-	"crypto/sha256"
+	"crypto/sha1"
+	"crypto/x509"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type PostgresCertificateService struct {
 	DB                    *sql.DB
 	AgentOfflineThreshold time.Duration
+	CTPolicy              ct.Policy // Minimum SCTs by lifetime for publicly trusted leaves
 }
 
-func NewCertificateService(db *sql.DB, offlineThreshold time.Duration) *PostgresCertificateService {
+func NewCertificateService(db *sql.DB, offlineThreshold time.Duration, ctPolicy ct.Policy) *PostgresCertificateService {
 	return &PostgresCertificateService{
 		DB:                    db,
 		AgentOfflineThreshold: offlineThreshold,
+		CTPolicy:              ctPolicy,
 	}
 }
 
 // --- 1. External Agent Ingestion (Physical) ---
 func (s *PostgresCertificateService) ProcessReport(ctx context.Context, report model.AgentReport, ipAddress string) error {
 	// 1. Auth Check
-	if report.APIKey == "" {
-		return fmt.Errorf("missing api_key")
-	}
-
-	hash := sha256.Sum256([]byte(report.APIKey))
-	apiKeyHash := hex.EncodeToString(hash[:])
-
-	var userID string
-	err := s.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE api_key_hash = $1", apiKeyHash).Scan(&userID)
-	if err == sql.ErrNoRows {
-		return fmt.Errorf("invalid api_key: authentication failed")
-	} else if err != nil {
-		return fmt.Errorf("auth check failed: %w", err)
+	userID, err := s.authenticateReport(ctx, report, ipAddress)
+	if err != nil {
+		return err
 	}
 
 	// 2. Start Transaction
@@ -51,23 +51,26 @@ func (s *PostgresCertificateService) ProcessReport(ctx context.Context, report m
 	batchTime := time.Now()
 
 	// 3. Upsert Physical Agent
-	// Note: is_virtual defaults to FALSE here
+	// Note: is_virtual defaults to FALSE here. An agent never changes owner.
 	queryAgent := `
         INSERT INTO agents (id, user_id, hostname, last_seen_at, is_virtual, ip_address)
         VALUES ($1, $2, $3, $4, FALSE, $5)
         ON CONFLICT (id) DO UPDATE 
         SET last_seen_at = EXCLUDED.last_seen_at, 
             hostname = EXCLUDED.hostname,
-            user_id = EXCLUDED.user_id,
-			ip_address = EXCLUDED.ip_address;
+			ip_address = EXCLUDED.ip_address
+        WHERE agents.user_id = EXCLUDED.user_id;
     `
 
-	_, err = tx.ExecContext(ctx, queryAgent, report.AgentID, userID, report.Hostname, batchTime, ipAddress)
+	result, err := tx.ExecContext(ctx, queryAgent, report.AgentID, userID, report.Hostname, batchTime, ipAddress)
 	if err != nil {
 		return fmt.Errorf("failed to upsert agent: %w", err)
 	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: agent belongs to another account", ErrAgentUnauthorized)
+	}
 	// 4. Process Certificates (Shared Logic)
-	if err := s.upsertCertificates(ctx, tx, report.AgentID, report.Certificates, batchTime); err != nil {
+	if err := s.upsertCertificates(ctx, tx, report.AgentID, report.Certificates, batchTime, false); err != nil {
 		return err
 	}
 
@@ -92,6 +95,56 @@ func (s *PostgresCertificateService) ProcessReport(ctx context.Context, report m
 	return nil
 }
 
+// authenticateReport returns the user a report is from. The api_key is normally the agent's own secret,
+// valid only with the agent_id it was issued for. An account API key ("ingest" scope, for installs that
+// predate enrollment) is still accepted, but only for physical agents of that user that never enrolled (or new ones).
+func (s *PostgresCertificateService) authenticateReport(ctx context.Context, report model.AgentReport, ipAddress string) (string, error) {
+	if report.APIKey == "" {
+		return "", fmt.Errorf("%w: missing api_key", ErrAgentUnauthorized)
+	}
+	keyHash := hashSecret(report.APIKey)
+
+	// 1. Per-Agent Credential
+	var agentID, userID string
+	var revoked bool
+	err := s.DB.QueryRowContext(ctx,
+		"SELECT id, user_id, revoked_at IS NOT NULL FROM agents WHERE credential_hash = $1", keyHash,
+	).Scan(&agentID, &userID, &revoked)
+	switch {
+	case err == nil && agentID != report.AgentID:
+		return "", fmt.Errorf("%w: credential was issued to another agent", ErrAgentUnauthorized)
+	case err == nil && revoked:
+		return "", fmt.Errorf("%w: agent has been revoked", ErrAgentUnauthorized)
+	case err == nil:
+		return userID, nil
+	case err != sql.ErrNoRows:
+		return "", fmt.Errorf("auth check failed: %w", err)
+	}
+
+	// 2. Account API Key
+	userID, err = authenticateAPIKey(ctx, s.DB, report.APIKey, model.APIKeyScopeIngest, ipAddress)
+	if errors.Is(err, ErrInvalidAPIKey) || errors.Is(err, ErrAPIKeyScope) {
+		return "", fmt.Errorf("%w: %v", ErrAgentUnauthorized, err)
+	} else if err != nil {
+		return "", err
+	}
+
+	var ownerID sql.NullString
+	var locked bool
+	err = s.DB.QueryRowContext(ctx, `
+        SELECT user_id, (credential_hash IS NOT NULL OR revoked_at IS NOT NULL OR is_virtual)
+        FROM agents WHERE id = $1
+    `, report.AgentID).Scan(&ownerID, &locked)
+	if err == nil && (ownerID.String != userID || locked) {
+		return "", fmt.Errorf("%w: agent requires its own credential", ErrAgentUnauthorized)
+	} else if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("auth check failed: %w", err)
+	}
+
+	log.Printf("⚠️ Ingest: Agent %s (%s) reports with an account API key; reinstall it with an enrollment token", report.AgentID, report.Hostname)
+	return userID, nil
+}
+
 // --- 2. Internal Cloud Ingestion (Virtual) ---
 
 func (s *PostgresCertificateService) IngestScanResults(ctx context.Context, userID string, certs []model.Certificate) error {
@@ -113,7 +166,7 @@ func (s *PostgresCertificateService) IngestScanResults(ctx context.Context, user
 
 	// 2. Process Certificates (Shared Logic)
 	// Note: We do NOT perform Ghost Pruning here because cloud scans are partial updates.
-	if err := s.upsertCertificates(ctx, tx, agentID, certs, batchTime); err != nil {
+	if err := s.upsertCertificates(ctx, tx, agentID, certs, batchTime, true); err != nil {
 		return err
 	}
 
@@ -159,71 +212,425 @@ func (s *PostgresCertificateService) ensureVirtualAgent(ctx context.Context, tx
 	return agentID, nil
 }
 
-// upsertCertificates handles the core logic of saving Definitions and Instances
-func (s *PostgresCertificateService) upsertCertificates(ctx context.Context, tx *sql.Tx, agentID string, certs []model.Certificate, batchTime time.Time) error {
-	for _, cert := range certs {
-		var certID string
+// upsertCertificates handles the core logic of saving Definitions and Instances.
+// Certs sharing a SourceUID are treated as one served chain (ordered by ChainPosition).
+// serverScan marks results of our own cloud scanner; agent reports are taken with less trust.
+func (s *PostgresCertificateService) upsertCertificates(ctx context.Context, tx *sql.Tx, agentID string, certs []model.Certificate, batchTime time.Time, serverScan bool) error {
+	for _, chain := range groupChains(certs) {
+		sourceUID := chain[0].SourceUID
+		if sourceUID == "" {
+			continue // Skip invalid items
+		}
+
+		// A. Deduplicate Certificate Definitions (whole chain first, so we can link issuers)
+		certIDs := make([]string, len(chain))
+		for i, cert := range chain {
+			certID, err := s.upsertDefinition(ctx, tx, cert, serverScan)
+			if err != nil {
+				return err
+			}
+			certIDs[i] = certID
+		}
+
+		// B. Record Parent/Child links between definitions (shared across users).
+		// A cert's parent is the next cert in the chain, if that cert's key verifies our signature:
+		// a matching Subject / Issuer name alone is a claim of the reporter.
+		for i := 0; i < len(chain)-1; i++ {
+			if certIDs[i] == certIDs[i+1] || !signedBy(chain[i], chain[i+1]) {
+				continue
+			}
+			_, err := tx.ExecContext(ctx,
+				"UPDATE certificates SET issuer_certificate_id = $1 WHERE id = $2",
+				certIDs[i+1], certIDs[i])
+			if err != nil {
+				return fmt.Errorf("failed to link issuer for %s: %w", chain[i].Serial, err)
+			}
+		}
+
+		// C. Upsert Instances (Leaf first, intermediates point back to it)
+		var leafInstanceID sql.NullString
+		for i, cert := range chain {
+			instanceID, err := s.upsertInstance(ctx, tx, agentID, certIDs[i], cert, leafInstanceID, batchTime)
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				leafInstanceID = sql.NullString{String: instanceID, Valid: true}
+			}
+		}
+
+		// D. Drop chain entries the source no longer serves (e.g. an intermediate was removed)
+		_, err := tx.ExecContext(ctx, `
+            DELETE FROM certificate_instances
+            WHERE agent_id = $1 AND source_uid = $2 AND chain_position > $3
+        `, agentID, sourceUID, chain[len(chain)-1].ChainPosition)
+		if err != nil {
+			return fmt.Errorf("failed to trim chain for %s: %w", sourceUID, err)
+		}
+	}
+	return nil
+}
+
+// upsertDefinition finds or creates the certificate definition and returns its ID.
+// Definitions are shared across users, so when the reporter sent the DER, identity (SHA-256 fingerprint)
+// and the definition's fields come from the parsed DER, not from the reporter's JSON.
+// Without DER, serial + issuer (narrowed by subject & expiry) identifies the certificate.
+func (s *PostgresCertificateService) upsertDefinition(ctx context.Context, tx *sql.Tx, cert model.Certificate, serverScan bool) (string, error) {
+	var certID string
+	cert, fp := withDERFields(cert)
+
+	// 1. By Fingerprint (computed by us from the DER)
+	err := sql.ErrNoRows
+	if fp.SHA256 != "" {
+		err = tx.QueryRowContext(ctx,
+			"SELECT id FROM certificates WHERE fingerprint_sha256 = $1", fp.SHA256,
+		).Scan(&certID)
+		if err != nil && err != sql.ErrNoRows {
+			return "", fmt.Errorf("failed to query cert existence: %w", err)
+		}
+	}
 
-		// A. Deduplicate Certificate Definition
-		err := tx.QueryRowContext(ctx,
+	// 2. By Serial + Issuer. With DER, only a row without a fingerprint is adopted, and the values matched
+	// are the DER's own: a row with another fingerprint is another cert from a CA that reuses serials.
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx,
 			`SELECT id FROM certificates 
              WHERE serial_number = $1 
              AND issuer_cn = $2
              AND COALESCE(issuer_org, '') = $3
-             AND COALESCE(issuer_ou, '') = $4`,
-			cert.Serial, cert.Issuer.CN, cert.Issuer.Org, cert.Issuer.OU,
+             AND COALESCE(issuer_ou, '') = $4
+             AND subject_cn = $5
+             AND valid_until = $6
+             AND ($7 = '' OR (fingerprint_sha256 IS NULL
+                              AND COALESCE(subject_org, '') = $8
+                              AND COALESCE(subject_ou, '') = $9))
+             ORDER BY created_at ASC
+             LIMIT 1`,
+			cert.Serial, cert.Issuer.CN, cert.Issuer.Org, cert.Issuer.OU, cert.Subject.CN, cert.ValidUntil, fp.SHA256,
+			cert.Subject.Org, cert.Subject.OU,
 		).Scan(&certID)
+	}
 
-		if err == sql.ErrNoRows {
-			err = tx.QueryRowContext(ctx, `
-                INSERT INTO certificates 
-                (serial_number, issuer_cn, issuer_org, issuer_ou, subject_cn, subject_org, subject_ou, valid_from, valid_until, signature_algo)
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-                RETURNING id
-            `,
-				cert.Serial,
-				cert.Issuer.CN, cert.Issuer.Org, cert.Issuer.OU,
-				cert.Subject.CN, cert.Subject.Org, cert.Subject.OU,
-				cert.ValidFrom, cert.ValidUntil, cert.SignatureAlgo,
-			).Scan(&certID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, `
+            INSERT INTO certificates 
+            (serial_number, issuer_cn, issuer_org, issuer_ou, subject_cn, subject_org, subject_ou, valid_from, valid_until, signature_algo,
+             fingerprint_sha256, fingerprint_sha1, raw_der)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+            RETURNING id
+        `,
+			cert.Serial,
+			cert.Issuer.CN, cert.Issuer.Org, cert.Issuer.OU,
+			cert.Subject.CN, cert.Subject.Org, cert.Subject.OU,
+			cert.ValidFrom, cert.ValidUntil, cert.SignatureAlgo,
+			nullString(fp.SHA256), nullString(fp.SHA1), fp.DER,
+		).Scan(&certID)
 
-			if err != nil {
-				return fmt.Errorf("failed to insert cert definition %s: %w", cert.Serial, err)
-			}
-		} else if err != nil {
-			return fmt.Errorf("failed to query cert existence: %w", err)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert cert definition %s: %w", cert.Serial, err)
 		}
+	} else if err != nil {
+		return "", fmt.Errorf("failed to query cert existence: %w", err)
+	} else if fp.SHA256 != "" {
+		// Migration path: a row stored before fingerprinting (matched on the DER's own values above)
+		// takes the DER, and the fields its reporter claimed are replaced by the DER's
+		_, err = tx.ExecContext(ctx, `
+            UPDATE certificates
+            SET fingerprint_sha256 = $1, fingerprint_sha1 = $2, raw_der = $3,
+                subject_org = $4, subject_ou = $5, valid_from = $6, signature_algo = $7
+            WHERE id = $8 AND fingerprint_sha256 IS NULL
+        `, fp.SHA256, fp.SHA1, fp.DER,
+			cert.Subject.Org, cert.Subject.OU, cert.ValidFrom, cert.SignatureAlgo, certID)
+		if err != nil {
+			return "", fmt.Errorf("failed to record fingerprint for %s: %w", cert.Serial, err)
+		}
+	}
 
-		// B. Upsert Instance
-		// Logic: Always mark as ACTIVE and update scanned_at
-		sourceType := cert.SourceType
-		if sourceType == "" {
-			sourceType = "FILE"
+	// Remember where to fetch CRLs from (Used by the CRL check job).
+	// Only the DER's own distribution points: the job fetches them on behalf of every user.
+	if fp.DER != nil {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE certificates SET crl_urls = $1 WHERE id = $2",
+			nullArray(cert.CRLDistributionPoints), certID)
+		if err != nil {
+			return "", fmt.Errorf("failed to record crl urls for %s: %w", cert.Serial, err)
 		}
-		sourceUID := cert.SourceUID
-		if sourceUID == "" {
-			continue // Skip invalid items
+	}
+
+	if err := recordKeyStrength(ctx, tx, certID, cert, fp.DER != nil); err != nil {
+		return "", err
+	}
+
+	if err := recordSANs(ctx, tx, certID, cert, fp.DER != nil); err != nil {
+		return "", err
+	}
+
+	// Certificate Transparency (Leaves only).
+	// Only our own scans judge public trust and count handshake SCTs; a report contributes the SCTs
+	// embedded in its DER (signed by the CA), never the SCTs or the trust verdict it claims.
+	if cert.ChainPosition == 0 {
+		if !serverScan {
+			cert.SCTs, cert.PubliclyTrusted = embeddedSCTs(fp.DER, cert.SourceUID), false
 		}
+		if len(cert.SCTs) > 0 || cert.PubliclyTrusted {
+			if err := s.recordSCTs(ctx, tx, certID, cert); err != nil {
+				return "", err
+			}
+		}
+	}
 
-		_, err = tx.ExecContext(ctx, `
-            INSERT INTO certificate_instances (agent_id, certificate_id, source_uid, source_type, is_trusted, trust_error, current_status, scanned_at)
-            VALUES ($1, $2, $3, $4, $5, $6, 'ACTIVE', $7)
-            ON CONFLICT (agent_id, source_uid) DO UPDATE
-            SET certificate_id = EXCLUDED.certificate_id,
-                source_type = EXCLUDED.source_type,
-                is_trusted = EXCLUDED.is_trusted,
-                trust_error = EXCLUDED.trust_error,
-                current_status = 'ACTIVE',
-                scanned_at = EXCLUDED.scanned_at;
-        `, agentID, certID, sourceUID, sourceType, cert.IsTrusted, cert.TrustError, batchTime)
+	return certID, nil
+}
+
+// recordKeyStrength stores the public key details and re-evaluates the crypto findings.
+// Key details parsed from the DER (fromDER) always win. The reporter's own values only fill a row
+// without DER (first reporter wins), so they can't override what the DER says.
+// Findings are computed from the stored values, so a reporter that omits them cannot clear an earlier finding.
+func recordKeyStrength(ctx context.Context, tx *sql.Tx, certID string, cert model.Certificate, fromDER bool) error {
+	var keyAlgo, keyCurve, spki, sigAlgo sql.NullString
+	var keySize sql.NullInt64
+	err := tx.QueryRowContext(ctx, `
+        UPDATE certificates
+        SET key_algo = CASE WHEN $6 THEN $1 WHEN raw_der IS NULL THEN COALESCE(key_algo, $1) ELSE key_algo END,
+            key_size = CASE WHEN $6 THEN $2 WHEN raw_der IS NULL THEN COALESCE(key_size, $2) ELSE key_size END,
+            key_curve = CASE WHEN $6 THEN $3 WHEN raw_der IS NULL THEN COALESCE(key_curve, $3) ELSE key_curve END,
+            spki_sha256 = CASE WHEN $6 THEN $4 WHEN raw_der IS NULL THEN COALESCE(spki_sha256, $4) ELSE spki_sha256 END
+        WHERE id = $5
+        RETURNING key_algo, key_size, key_curve, spki_sha256, signature_algo
+    `, nullString(cert.KeyAlgo), sql.NullInt64{Int64: int64(cert.KeySize), Valid: cert.KeySize > 0},
+		nullString(cert.KeyCurve), nullString(cert.SPKISHA256), certID, fromDER,
+	).Scan(&keyAlgo, &keySize, &keyCurve, &spki, &sigAlgo)
+	if err != nil {
+		return fmt.Errorf("failed to record public key for %s: %w", cert.Serial, err)
+	}
+
+	cert.KeyAlgo = keyAlgo.String
+	cert.KeySize = int(keySize.Int64)
+	cert.KeyCurve = keyCurve.String
+	cert.SignatureAlgo = sigAlgo.String
 
+	_, err = tx.ExecContext(ctx,
+		"UPDATE certificates SET crypto_findings = $1 WHERE id = $2",
+		pq.Array(cryptostrength.Evaluate(cert)), certID)
+	if err != nil {
+		return fmt.Errorf("failed to record crypto findings for %s: %w", cert.Serial, err)
+	}
+	return nil
+}
+
+// recordSCTs stores the SCTs seen for a certificate and re-evaluates it against the CT policy.
+// SCTs accumulate across sources (embedded + every scanned server's handshake); distinct logs are counted.
+// The requirement is only set by a scan that found the cert publicly trusted; later SCTs re-check against it.
+func (s *PostgresCertificateService) recordSCTs(ctx context.Context, tx *sql.Tx, certID string, cert model.Certificate) error {
+	for _, sct := range cert.SCTs {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO certificate_scts (certificate_id, log_id, timestamp, source)
+            VALUES ($1, $2, $3, $4)
+            ON CONFLICT (certificate_id, log_id, source) DO NOTHING
+        `, certID, sct.LogID, sct.Timestamp, sct.Source)
 		if err != nil {
-			return fmt.Errorf("failed to link instance %s: %w", sourceUID, err)
+			return fmt.Errorf("failed to record sct for %s: %w", cert.Serial, err)
 		}
 	}
+
+	// Only publicly trusted certs are judged; private CA certs keep ct_compliant NULL
+	required := s.CTPolicy.Required(cert.ValidFrom, cert.ValidUntil)
+	_, err := tx.ExecContext(ctx, `
+        UPDATE certificates c
+        SET sct_count = n.logs,
+            ct_required_scts = CASE WHEN $2 THEN $3 ELSE c.ct_required_scts END,
+            ct_compliant = CASE WHEN $2 THEN n.logs >= $3
+                                WHEN c.ct_required_scts IS NOT NULL THEN n.logs >= c.ct_required_scts
+                                ELSE c.ct_compliant END
+        FROM (SELECT COUNT(DISTINCT log_id) AS logs FROM certificate_scts WHERE certificate_id = $1) n
+        WHERE c.id = $1
+    `, certID, cert.PubliclyTrusted, required)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate ct policy for %s: %w", cert.Serial, err)
+	}
 	return nil
 }
 
+// embeddedSCTs returns the SCTs embedded in a (validated) DER, nil without DER.
+func embeddedSCTs(der []byte, sourceUID string) []model.SCT {
+	if der == nil {
+		return nil
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil
+	}
+	scts, err := ct.EmbeddedSCTs(parsed)
+	if err != nil {
+		log.Printf("⚠️ Ingest: Ignoring embedded SCTs of %s: %v", sourceUID, err)
+		return nil
+	}
+	return scts
+}
+
+// certFingerprints identifies a certificate by its DER encoding.
+type certFingerprints struct {
+	SHA256 string
+	SHA1   string
+	DER    []byte // nil without (valid) DER
+}
+
+// withDERFields parses the reported DER and replaces the certificate's definition fields with the parsed ones.
+// A DER that doesn't parse or belongs to another serial is dropped: the certificate is then handled
+// as if reported without DER, and gets no fingerprint (a fingerprint the reporter claims is never trusted).
+func withDERFields(cert model.Certificate) (model.Certificate, certFingerprints) {
+	if len(cert.RawDER) == 0 {
+		return cert, certFingerprints{}
+	}
+
+	parsed, err := x509.ParseCertificate(cert.RawDER)
+	if err != nil || parsed.SerialNumber.String() != cert.Serial {
+		log.Printf("⚠️ Ingest: Ignoring raw_der of %s (%s): does not match the reported serial", cert.SourceUID, cert.Serial)
+		cert.RawDER = nil
+		return cert, certFingerprints{}
+	}
+
+	d := scanner.CertificateFromX509(parsed, cert.SourceUID)
+	cert.Subject, cert.Issuer = d.Subject, d.Issuer
+	cert.SignatureAlgo = d.SignatureAlgo
+	cert.ValidFrom, cert.ValidUntil = d.ValidFrom, d.ValidUntil
+	cert.DNSNames, cert.IPAddresses, cert.EmailAddresses, cert.URIs = d.DNSNames, d.IPAddresses, d.EmailAddresses, d.URIs
+	cert.FingerprintSHA256 = d.FingerprintSHA256
+	cert.KeyAlgo, cert.KeySize, cert.KeyCurve, cert.SPKISHA256 = d.KeyAlgo, d.KeySize, d.KeyCurve, d.SPKISHA256
+	cert.CRLDistributionPoints = d.CRLDistributionPoints
+
+	sha1Sum := sha1.Sum(cert.RawDER)
+	return cert, certFingerprints{
+		SHA256: d.FingerprintSHA256,
+		SHA1:   hex.EncodeToString(sha1Sum[:]),
+		DER:    cert.RawDER,
+	}
+}
+
+// nullString maps "" to SQL NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// upsertInstance links a definition to an agent's source and returns the instance ID.
+// Logic: Always mark as ACTIVE and update scanned_at
+func (s *PostgresCertificateService) upsertInstance(ctx context.Context, tx *sql.Tx, agentID, certID string, cert model.Certificate, leafInstanceID sql.NullString, batchTime time.Time) (string, error) {
+	sourceType := cert.SourceType
+	if sourceType == "" {
+		sourceType = "FILE"
+	}
+
+	// The certificate this source served at this position until now (a different leaf is a renewal or a swap)
+	var previousCertID sql.NullString
+	err := tx.QueryRowContext(ctx, `
+        SELECT certificate_id FROM certificate_instances
+        WHERE agent_id = $1 AND source_uid = $2 AND chain_position = $3
+    `, agentID, cert.SourceUID, cert.ChainPosition).Scan(&previousCertID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to look up instance %s: %w", cert.SourceUID, err)
+	}
+	changed := !previousCertID.Valid || previousCertID.String != certID
+
+	var instanceID string
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO certificate_instances (agent_id, certificate_id, source_uid, source_type, is_trusted, trust_error, current_status, scanned_at, chain_position, leaf_instance_id, ocsp_stapled, hostname_mismatch, hostname_error, resolved_ip)
+        VALUES ($1, $2, $3, $4, $5, $6, 'ACTIVE', $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (agent_id, source_uid, chain_position) DO UPDATE
+        SET certificate_id = EXCLUDED.certificate_id,
+            source_type = EXCLUDED.source_type,
+            is_trusted = EXCLUDED.is_trusted,
+            trust_error = EXCLUDED.trust_error,
+            current_status = 'ACTIVE',
+            scanned_at = EXCLUDED.scanned_at,
+            leaf_instance_id = EXCLUDED.leaf_instance_id,
+            ocsp_stapled = EXCLUDED.ocsp_stapled,
+            hostname_mismatch = EXCLUDED.hostname_mismatch,
+            hostname_error = EXCLUDED.hostname_error,
+            resolved_ip = EXCLUDED.resolved_ip
+        RETURNING id;
+    `, agentID, certID, cert.SourceUID, sourceType, cert.IsTrusted, cert.TrustError, batchTime, cert.ChainPosition, leafInstanceID, cert.OCSPStapled,
+		cert.HostnameMismatch, nullString(cert.HostnameError), nullString(cert.ResolvedIP)).Scan(&instanceID)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to link instance %s: %w", cert.SourceUID, err)
+	}
+
+	// Record the revocation result, if the reporter checked it.
+	// Kept on the instance: a reporter only speaks for its own user, the shared definition is left to the CRL job.
+	// A new certificate starts unchecked; an inconclusive UNKNOWN never overwrites a conclusive GOOD/REVOKED.
+	if cert.RevocationStatus != "" || changed {
+		_, err = tx.ExecContext(ctx, `
+            UPDATE certificate_instances
+            SET revocation_status = $1::text,
+                revocation_reason = $2,
+                revoked_at = $3,
+                revocation_source = $4,
+                revocation_checked_at = CASE WHEN $1::text IS NULL THEN NULL ELSE $5::timestamptz END
+            WHERE id = $6
+              AND ($7 OR $1::text <> 'UNKNOWN' OR revocation_status IS NULL OR revocation_status = 'UNKNOWN')
+        `, nullString(string(cert.RevocationStatus)), nullString(cert.RevocationReason), cert.RevokedAt, nullString(cert.RevocationSource),
+			batchTime, instanceID, changed)
+		if err != nil {
+			return "", fmt.Errorf("failed to record revocation for %s: %w", cert.SourceUID, err)
+		}
+	}
+
+	if cert.ChainPosition == 0 && previousCertID.Valid && previousCertID.String != certID {
+		if err := recordReplacement(ctx, tx, agentID, instanceID, previousCertID.String, certID, cert.SourceUID, batchTime); err != nil {
+			return "", err
+		}
+	}
+	return instanceID, nil
+}
+
+// groupChains buckets certs by SourceUID (preserving first-seen order) and sorts each bucket by ChainPosition.
+func groupChains(certs []model.Certificate) [][]model.Certificate {
+	var order []string
+	buckets := make(map[string][]model.Certificate)
+	for _, c := range certs {
+		if _, ok := buckets[c.SourceUID]; !ok {
+			order = append(order, c.SourceUID)
+		}
+		buckets[c.SourceUID] = append(buckets[c.SourceUID], c)
+	}
+
+	chains := make([][]model.Certificate, 0, len(order))
+	for _, uid := range order {
+		chain := buckets[uid]
+		sort.SliceStable(chain, func(i, j int) bool { return chain[i].ChainPosition < chain[j].ChainPosition })
+
+		// Positions are renumbered 0..n-1: duplicates (e.g. two "leaves") would upsert onto one instance
+		// and a gap would leave the chain without a leaf
+		renumbered := false
+		for i := range chain {
+			if chain[i].ChainPosition != i {
+				chain[i].ChainPosition, renumbered = i, true
+			}
+		}
+		if renumbered {
+			log.Printf("⚠️ Ingest: Renumbered the chain of %s (duplicate or missing positions)", uid)
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
+// signedBy reports whether parent's key verifies child's signature. Both need a DER that parses.
+func signedBy(child, parent model.Certificate) bool {
+	if len(child.RawDER) == 0 || len(parent.RawDER) == 0 {
+		return false
+	}
+	c, err := x509.ParseCertificate(child.RawDER)
+	if err != nil {
+		return false
+	}
+	p, err := x509.ParseCertificate(parent.RawDER)
+	if err != nil {
+		return false
+	}
+	return c.CheckSignatureFrom(p) == nil
+}
+
 // CleanupMissingInstances deletes instances that have been MISSING for too long.
 func (s *PostgresCertificateService) CleanupMissingInstances(ctx context.Context, gracePeriod time.Duration) (int64, error) {
 	cutoff := time.Now().Add(-gracePeriod)
@@ -0,0 +1,419 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/scanner"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// readInstanceRevocation reads the revocation columns of the user's leaf instance at sourceUID.
+func readInstanceRevocation(t *testing.T, conn *sql.DB, userID, sourceUID string) (status, source sql.NullString, checkedAt sql.NullTime) {
+	t.Helper()
+	err := conn.QueryRowContext(context.Background(), `
+        SELECT ci.revocation_status, ci.revocation_source, ci.revocation_checked_at
+        FROM certificate_instances ci
+        JOIN agents a ON ci.agent_id = a.id
+        WHERE a.user_id = $1 AND ci.source_uid = $2 AND ci.chain_position = 0
+    `, userID, sourceUID).Scan(&status, &source, &checkedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, source, checkedAt
+}
+
+func TestIngestRevocationPerInstance(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := testCertService(t, conn)
+	ctx := context.Background()
+
+	expiry := time.Now().Add(90 * 24 * time.Hour)
+	checked := testCertificate(t, "checked.example.test:443", expiry, "checked.example.test")
+	unchecked := testCertificate(t, "unchecked.example.test:443", expiry, "unchecked.example.test")
+	renewed := testCertificate(t, "checked.example.test:443", expiry.Add(24*time.Hour), "checked.example.test")
+
+	with := func(c model.Certificate, status model.RevocationStatus, source string) model.Certificate {
+		c.RevocationStatus, c.RevocationSource = status, source
+		return c
+	}
+
+	// Each step ingests certs (every one a new or an already known certificate), then checks both sources
+	steps := []struct {
+		name  string
+		certs []model.Certificate
+		want  map[string]string // Source UID -> "status/source" ("" = unchecked)
+	}{
+		{
+			name:  "new certificates with and without a result",
+			certs: []model.Certificate{with(checked, model.RevocationGood, "OCSP"), unchecked},
+			want:  map[string]string{checked.SourceUID: "GOOD/OCSP", unchecked.SourceUID: ""},
+		},
+		{
+			name:  "inconclusive result keeps the conclusive one",
+			certs: []model.Certificate{with(checked, model.RevocationUnknown, "OCSP"), unchecked},
+			want:  map[string]string{checked.SourceUID: "GOOD/OCSP", unchecked.SourceUID: ""},
+		},
+		{
+			name:  "known certificate checked for the first time",
+			certs: []model.Certificate{with(unchecked, model.RevocationRevoked, "OCSP_STAPLE")},
+			want:  map[string]string{checked.SourceUID: "GOOD/OCSP", unchecked.SourceUID: "REVOKED/OCSP_STAPLE"},
+		},
+		{
+			name:  "replaced certificate starts unchecked",
+			certs: []model.Certificate{renewed},
+			want:  map[string]string{checked.SourceUID: "", unchecked.SourceUID: "REVOKED/OCSP_STAPLE"},
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := svc.IngestScanResults(ctx, userID, step.certs); err != nil {
+				t.Fatalf("ingest failed: %v", err)
+			}
+			for uid, want := range step.want {
+				status, source, checkedAt := readInstanceRevocation(t, conn, userID, uid)
+				got := ""
+				if status.Valid {
+					got = status.String + "/" + source.String
+				}
+				if got != want {
+					t.Fatalf("%s: revocation = %q, want %q", uid, got, want)
+				}
+				if checkedAt.Valid != (want != "") {
+					t.Fatalf("%s: revocation_checked_at = %v for %q", uid, checkedAt, want)
+				}
+			}
+		})
+	}
+}
+
+// TestIngestCTDistinctLogs checks that compliance counts distinct logs, not SCTs: a log that delivered
+// an SCT both embedded and in the handshake counts once.
+func TestIngestCTDistinctLogs(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := testCertService(t, conn)
+	ctx := context.Background()
+
+	sct := func(log, source string) model.SCT {
+		return model.SCT{LogID: log, Timestamp: time.Now().UTC().Truncate(time.Millisecond), Source: source}
+	}
+
+	tests := []struct {
+		name          string
+		lifetime      time.Duration
+		trusted       bool
+		scts          []model.SCT
+		wantCount     int
+		wantRequired  sql.NullInt64
+		wantCompliant sql.NullBool
+	}{
+		{
+			name:          "two logs",
+			lifetime:      90 * 24 * time.Hour,
+			trusted:       true,
+			scts:          []model.SCT{sct("log-a", model.SCTSourceEmbedded), sct("log-b", model.SCTSourceTLS)},
+			wantCount:     2,
+			wantRequired:  sql.NullInt64{Int64: 2, Valid: true},
+			wantCompliant: sql.NullBool{Bool: true, Valid: true},
+		},
+		{
+			name:          "one log embedded and in the handshake",
+			lifetime:      90 * 24 * time.Hour,
+			trusted:       true,
+			scts:          []model.SCT{sct("log-a", model.SCTSourceEmbedded), sct("log-a", model.SCTSourceTLS)},
+			wantCount:     1,
+			wantRequired:  sql.NullInt64{Int64: 2, Valid: true},
+			wantCompliant: sql.NullBool{Bool: false, Valid: true},
+		},
+		{
+			name:          "long lifetime needs a third log",
+			lifetime:      365 * 24 * time.Hour,
+			trusted:       true,
+			scts:          []model.SCT{sct("log-a", model.SCTSourceEmbedded), sct("log-b", model.SCTSourceEmbedded)},
+			wantCount:     2,
+			wantRequired:  sql.NullInt64{Int64: 3, Valid: true},
+			wantCompliant: sql.NullBool{Bool: false, Valid: true},
+		},
+		{
+			name:      "private CA: counted, not judged",
+			lifetime:  90 * 24 * time.Hour,
+			scts:      []model.SCT{sct("log-a", model.SCTSourceTLS), sct("log-b", model.SCTSourceTLS)},
+			wantCount: 2,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := fmt.Sprintf("ct%d.example.test", i)
+			cert := testCertificate(t, host+":443", time.Now().Add(tt.lifetime), host)
+			cert.SCTs, cert.PubliclyTrusted = tt.scts, tt.trusted
+			if err := svc.IngestScanResults(ctx, userID, []model.Certificate{cert}); err != nil {
+				t.Fatalf("ingest failed: %v", err)
+			}
+
+			var count int
+			var required sql.NullInt64
+			var compliant sql.NullBool
+			err := conn.QueryRowContext(ctx, `
+                SELECT sct_count, ct_required_scts, ct_compliant FROM certificates WHERE raw_der = $1
+            `, cert.RawDER).Scan(&count, &required, &compliant)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.wantCount || required != tt.wantRequired || compliant != tt.wantCompliant {
+				t.Fatalf("sct_count = %d, required = %v, compliant = %v; want %d, %v, %v",
+					count, required, compliant, tt.wantCount, tt.wantRequired, tt.wantCompliant)
+			}
+		})
+	}
+}
+
+// TestIngestCryptoFindings checks the weak-crypto findings stored for scanned leaves (judged on their DER)
+// and for a report without DER, whose later reports can't clear a finding by omitting the key.
+func TestIngestCryptoFindings(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := testCertService(t, conn)
+	ctx := context.Background()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Crypto Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	issue := func(host string, key crypto.Signer, sigAlgo x509.SignatureAlgorithm) model.Certificate {
+		serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:       serial,
+			Subject:            pkix.Name{CommonName: host},
+			DNSNames:           []string{host},
+			NotBefore:          time.Now().Add(-time.Hour),
+			NotAfter:           time.Now().Add(90 * 24 * time.Hour),
+			SignatureAlgorithm: sigAlgo,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, key.Public(), caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return scanner.CertificateFromX509(parsed, host+":443")
+	}
+	rsaKey := func(bits int) crypto.Signer {
+		k, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	ecKey := func(curve elliptic.Curve) crypto.Signer {
+		k, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reported without DER: the reporter's key details are all there is
+	reported := model.Certificate{
+		SourceUID:     "/etc/ssl/legacy.pem",
+		SourceType:    "AGENT",
+		Serial:        fmt.Sprintf("%d", time.Now().UnixNano()),
+		Subject:       model.DN{CN: "legacy.example.test"},
+		Issuer:        model.DN{CN: "Crypto Test CA"},
+		SignatureAlgo: "SHA256-RSA",
+		ValidFrom:     time.Now().Add(-time.Hour).Truncate(time.Second),
+		ValidUntil:    time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second),
+		KeyAlgo:       "RSA",
+		KeySize:       1024,
+	}
+	omitted := reported
+	omitted.KeyAlgo, omitted.KeySize = "", 0
+
+	tests := []struct {
+		name string
+		cert model.Certificate
+		want []string
+	}{
+		{name: "rsa 1024", cert: issue("rsa1024.example.test", rsaKey(1024), x509.SHA256WithRSA), want: []string{model.CryptoFindingWeakRSAKey}},
+		{name: "rsa 2048", cert: issue("rsa2048.example.test", rsaKey(2048), x509.SHA256WithRSA), want: []string{}},
+		{name: "ec p-224", cert: issue("p224.example.test", ecKey(elliptic.P224()), x509.SHA256WithRSA), want: []string{model.CryptoFindingDeprecatedCurve}},
+		{name: "ec p-256", cert: issue("p256.example.test", ecKey(elliptic.P256()), x509.SHA256WithRSA), want: []string{}},
+		{name: "ed25519", cert: issue("ed25519.example.test", edKey, x509.SHA256WithRSA), want: []string{}},
+		{name: "sha1 signature", cert: issue("sha1.example.test", ecKey(elliptic.P256()), x509.SHA1WithRSA), want: []string{model.CryptoFindingWeakSignature}},
+		{name: "reported without der", cert: reported, want: []string{model.CryptoFindingWeakRSAKey}},
+		{name: "reported again without the key", cert: omitted, want: []string{model.CryptoFindingWeakRSAKey}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.IngestScanResults(ctx, userID, []model.Certificate{tt.cert}); err != nil {
+				t.Fatalf("ingest failed: %v", err)
+			}
+
+			var findings []string
+			err := conn.QueryRowContext(ctx, `
+                SELECT crypto_findings FROM certificates
+                WHERE serial_number = $1 AND issuer_cn = $2
+            `, tt.cert.Serial, tt.cert.Issuer.CN).Scan(pq.Array(&findings))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if findings == nil {
+				findings = []string{}
+			}
+			if !reflect.DeepEqual(findings, tt.want) {
+				t.Fatalf("crypto_findings = %v, want %v", findings, tt.want)
+			}
+		})
+	}
+}
+
+// --- authenticateReport ---
+
+// authFixture is the state authenticateReport reads: enrolled agents (by credential) and account API keys.
+type authFixture struct {
+	credentials map[string]fakeAgent  // credential -> agent
+	apiKeys     map[string]fakeAPIKey // key -> owner & scope
+	agents      map[string]fakeAgent  // agent id -> agent (owner lookup for account keys)
+}
+
+type fakeAgent struct {
+	id, userID string
+	revoked    bool
+	locked     bool // Enrolled, revoked or virtual: account keys may not report for it
+}
+
+type fakeAPIKey struct {
+	userID string
+	scope  model.APIKeyScope
+}
+
+func (f authFixture) handle(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+	arg := func(i int) string { return fmt.Sprint(args[i].Value) }
+	switch {
+	case strings.Contains(query, "FROM agents WHERE credential_hash"):
+		for secret, a := range f.credentials {
+			if hashSecret(secret) == arg(0) {
+				return []string{"id", "user_id", "revoked"}, [][]driver.Value{{a.id, a.userID, a.revoked}}, nil
+			}
+		}
+		return []string{"id", "user_id", "revoked"}, nil, nil
+
+	case strings.Contains(query, "UPDATE api_keys SET last_used_at"):
+		for key, k := range f.apiKeys {
+			if hashSecret(key) == arg(0) && string(k.scope) == arg(2) {
+				return []string{"user_id"}, [][]driver.Value{{k.userID}}, nil
+			}
+		}
+		return []string{"user_id"}, nil, nil
+
+	case strings.Contains(query, "SELECT scope FROM api_keys"):
+		for key, k := range f.apiKeys {
+			if hashSecret(key) == arg(0) {
+				return []string{"scope"}, [][]driver.Value{{string(k.scope)}}, nil
+			}
+		}
+		return []string{"scope"}, nil, nil
+
+	case strings.Contains(query, "FROM agents WHERE id"):
+		if a, ok := f.agents[arg(0)]; ok {
+			return []string{"user_id", "locked"}, [][]driver.Value{{a.userID, a.locked}}, nil
+		}
+		return []string{"user_id", "locked"}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unexpected query: %s", query)
+}
+
+func TestAuthenticateReport(t *testing.T) {
+	fixture := authFixture{
+		credentials: map[string]fakeAgent{
+			"cma_agent_secret":   {id: "agent-1", userID: "user-1"},
+			"cma_revoked_secret": {id: "agent-2", userID: "user-1", revoked: true},
+		},
+		apiKeys: map[string]fakeAPIKey{
+			"cmk_ingest": {userID: "user-1", scope: model.APIKeyScopeIngest},
+			"cmk_read":   {userID: "user-1", scope: model.APIKeyScopeRead},
+		},
+		agents: map[string]fakeAgent{
+			"legacy-agent":   {userID: "user-1"},
+			"agent-1":        {userID: "user-1", locked: true},
+			"foreign-legacy": {userID: "user-2"},
+		},
+	}
+	svc := &PostgresCertificateService{DB: sql.OpenDB(fakeConnector{handle: fixture.handle})}
+	defer svc.DB.Close()
+
+	tests := []struct {
+		name     string
+		agentID  string
+		apiKey   string
+		wantUser string // "" = ErrAgentUnauthorized
+	}{
+		{name: "missing api_key", agentID: "agent-1"},
+		{name: "agent credential", agentID: "agent-1", apiKey: "cma_agent_secret", wantUser: "user-1"},
+		{name: "credential of another agent", agentID: "agent-9", apiKey: "cma_agent_secret"},
+		{name: "revoked agent", agentID: "agent-2", apiKey: "cma_revoked_secret"},
+		{name: "account key for a legacy agent", agentID: "legacy-agent", apiKey: "cmk_ingest", wantUser: "user-1"},
+		{name: "account key for a new agent", agentID: "brand-new", apiKey: "cmk_ingest", wantUser: "user-1"},
+		{name: "account key for an enrolled agent", agentID: "agent-1", apiKey: "cmk_ingest"},
+		{name: "account key for another user's agent", agentID: "foreign-legacy", apiKey: "cmk_ingest"},
+		{name: "read scoped key", agentID: "brand-new", apiKey: "cmk_read"},
+		{name: "unknown key", agentID: "brand-new", apiKey: "cmk_unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := model.AgentReport{AgentID: tt.agentID, Hostname: "host", APIKey: tt.apiKey}
+			userID, err := svc.authenticateReport(context.Background(), report, "192.0.2.1")
+			if tt.wantUser == "" {
+				if !errors.Is(err, ErrAgentUnauthorized) {
+					t.Fatalf("expected ErrAgentUnauthorized, got user %q, err %v", userID, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if userID != tt.wantUser {
+				t.Fatalf("user = %q, want %q", userID, tt.wantUser)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"cert-manager-backend/internal/model"
 	"context"
+	"crypto/x509"
+	"math/big"
 	"time"
 )
 
@@ -24,6 +26,16 @@ type AuthService interface {
 	ResetPassword(ctx context.Context, token, newPassword string) error
 }
 
+// APIKeyService manages a user's named, scoped API keys.
+type APIKeyService interface {
+	CreateKey(ctx context.Context, userID string, req model.CreateAPIKeyRequest) (*model.CreatedAPIKey, error)
+	ListKeys(ctx context.Context, userID string) ([]model.APIKey, error)
+	RevokeKey(ctx context.Context, userID, keyID string) error
+
+	// Authenticate returns the key's user (ErrInvalidAPIKey unless active, ErrAPIKeyScope if of another scope) and records the use
+	Authenticate(ctx context.Context, key string, scope model.APIKeyScope, ipAddress string) (string, error)
+}
+
 // EmailService Interface
 // This abstracts the email sending provider (Brevo) from the business logic
 type EmailService interface {
@@ -42,13 +54,31 @@ type CertificateService interface {
 
 	CleanupOrphanedCerts(ctx context.Context) (int64, error)
 	CleanupMissingInstances(ctx context.Context, gracePeriod time.Duration) (int64, error)
+	// PruneReplacements deletes replacement history older than the retention (releasing old definitions)
+	PruneReplacements(ctx context.Context, retention time.Duration) (int64, error)
 
 	// Uses Functional Options for flexible filtering
 	ListCertificates(ctx context.Context, userID string, opts ...FilterOption) (*model.PaginatedCerts, error)
 
+	// GetCertificate returns a single instance along with its served chain (Detail view)
+	GetCertificate(ctx context.Context, userID, instanceID string) (*model.CertResponse, error)
+
+	// GetCertificatePEM returns the stored DER of an instance as PEM (plus its served chain if withChain)
+	GetCertificatePEM(ctx context.Context, userID, instanceID string, withChain bool) ([]byte, error)
+
+	// GetReplacementHistory lists the renewals / swaps of the certificate served at an instance's source
+	GetReplacementHistory(ctx context.Context, userID, instanceID string) ([]model.CertReplacement, error)
+
+	// GetAlertHistory lists the alerts sent for the certificate at an instance, with their resolution
+	GetAlertHistory(ctx context.Context, userID, instanceID string) ([]model.AlertRecord, error)
+
 	// Returns a flat list of certs. Grouping happens in the Notifier.
 	GetExpiringCertificates(ctx context.Context, threshold time.Duration) ([]model.CertResponse, error)
 
+	// Revocation (CRL Job): Certs with distribution points, and a sink for lookup results
+	GetRevocationCandidates(ctx context.Context) ([]model.RevocationCheck, error)
+	UpdateRevocation(ctx context.Context, check model.RevocationCheck) error
+
 	// GetDashboardStats calculates summary counts for the dashboard
 	GetDashboardStats(ctx context.Context, userID string) (*model.DashboardStats, error)
 
@@ -63,21 +93,110 @@ type CertificateService interface {
 // This interface allows us to mock the scanner in tests.
 type NetworkScanner interface {
 	// Scan performs the handshake and returns the certificate chain.
-	// target should be in "host:port" format.
-	Scan(ctx context.Context, target string) ([]model.Certificate, error)
+	// target.TargetURL should be in "host:port" format; target.Protocol selects any STARTTLS upgrade.
+	Scan(ctx context.Context, target model.Target) ([]model.Certificate, error)
+
+	// AuditPosture probes the accepted TLS versions / cipher suites and grades the configuration.
+	AuditPosture(ctx context.Context, target model.Target) (*model.TLSPosture, error)
 }
 
 type AgentLessTargetService interface {
 	// --- User Facing ---
 	// AddTarget: Validates, Scans immediately, Saves to DB, Ingests result.
-	AddTarget(ctx context.Context, userID, rawURL string, frequency int) (*model.Target, error)
+	// spec.TargetURL holds the raw user input; Protocol/FrequencyHours may be empty for defaults.
+	AddTarget(ctx context.Context, userID string, spec model.Target) (*model.Target, error)
 	UpdateTarget(ctx context.Context, userID, targetID string, frequency int) error
 	ListTargets(ctx context.Context, userID string) ([]model.Target, error)
 	DeleteTarget(ctx context.Context, userID, targetID string) error
+	GetPosture(ctx context.Context, userID, targetID string) (*model.TLSPosture, error)
+	// mTLS: Client certificate (PEM cert chain + key) presented to the target, stored encrypted
+	SetClientCert(ctx context.Context, userID, targetID, certPEM, keyPEM string) error
+	RemoveClientCert(ctx context.Context, userID, targetID string) error
+	// GetTargetStats: uptime & latency percentiles over the last window, plus the raw series
+	GetTargetStats(ctx context.Context, userID, targetID string, window time.Duration) (*model.TargetStats, error)
 
 	// --- Worker Facing ---
 	GetStaleTargets(ctx context.Context) ([]model.Target, error)
-	UpdateTargetStatus(ctx context.Context, targetID, status, errStr string) error
+	// UpdateTargetStatus records a scan outcome against the failure counter (HEALTHY / DEGRADED / DOWN)
+	UpdateTargetStatus(ctx context.Context, targetID, status, errStr string) (*model.TargetHealthChange, error)
+	// MarkHealthAlerted starts the alert cooldown for the target's current health
+	MarkHealthAlerted(ctx context.Context, targetID string, health model.TargetHealth) error
+	SavePosture(ctx context.Context, posture *model.TLSPosture) error
+	// RecordScanDetails stores target-level scan facts: node disagreement, unreachable nodes, mTLS requirement.
+	// Instances of nodes that vanished from DNS are marked MISSING.
+	RecordScanDetails(ctx context.Context, targetID string, certs []model.Certificate, unreachable []model.UnreachableNode) error
+	// RecordScanResult appends a scan (success or failure) to the target's history
+	RecordScanResult(ctx context.Context, targetID string, certs []model.Certificate, scanErr error) error
+	PruneScanHistory(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// TrustStoreService manages each user's private CAs used to verify Cloud Monitor chains.
+type TrustStoreService interface {
+	// AddRoots stores every certificate of a PEM bundle and re-schedules the user's targets.
+	AddRoots(ctx context.Context, userID, name, pemBundle string) ([]model.TrustedRoot, error)
+	ListRoots(ctx context.Context, userID string) ([]model.TrustedRoot, error)
+	DeleteRoot(ctx context.Context, userID, rootID string) error
+}
+
+// DiscoveryService manages CIDR / port sweeps that find TLS endpoints nobody registered.
+type DiscoveryService interface {
+	// --- User Facing ---
+	// CreateJob queues a sweep of cidrs x ports, within the user's address cap.
+	CreateJob(ctx context.Context, userID string, cidrs []string, ports []int, addTargets bool) (*model.DiscoveryJob, error)
+	ListJobs(ctx context.Context, userID string) ([]model.DiscoveryJob, error)
+	GetJob(ctx context.Context, userID, jobID string) (*model.DiscoveryJob, error)
+	CancelJob(ctx context.Context, userID, jobID string) error
+
+	// --- Worker Facing ---
+	ClaimJob(ctx context.Context) (*model.DiscoveryJob, error)
+	// UpdateProgress returns the job's current status (CANCELLED tells the worker to stop).
+	UpdateProgress(ctx context.Context, jobID string, probesDone int) (model.DiscoveryStatus, error)
+	RecordEndpoint(ctx context.Context, job model.DiscoveryJob, address string, protocol model.ScanProtocol, certs []model.Certificate) error
+	FinishJob(ctx context.Context, jobID string, probesDone int, status model.DiscoveryStatus, errStr string) error
+	FailInterruptedJobs(ctx context.Context) (int64, error)
+}
+
+// ResponderProber checks CRL distribution points and OCSP responders (revocation.Prober).
+type ResponderProber interface {
+	ProbeCRL(ctx context.Context, url string, issuer *x509.Certificate) (*model.ResponderCheck, error)
+	ProbeOCSP(ctx context.Context, url string, issuer *x509.Certificate, serial *big.Int) (*model.ResponderCheck, error)
+}
+
+// ResponderService monitors the health of a CA's CRL and OCSP endpoints (Responder Targets).
+type ResponderService interface {
+	// --- User Facing ---
+	CreateTarget(ctx context.Context, userID string, spec model.ResponderTarget) (*model.ResponderTarget, error)
+	ListTargets(ctx context.Context, userID string) ([]model.ResponderTarget, error)
+	DeleteTarget(ctx context.Context, userID, targetID string) error
+
+	// --- Worker Facing ---
+	GetDueTargets(ctx context.Context) ([]model.ResponderTarget, error)
+	// CheckTarget probes & records; alertDue reports an unhealthy state the owner wasn't told about (1 day cooldown)
+	CheckTarget(ctx context.Context, t model.ResponderTarget) (updated *model.ResponderTarget, alertDue bool, err error)
+	MarkAlerted(ctx context.Context, targetIDs []string) error
+}
+
+// DomainLookupClient asks a registry when a domain expires (domains.FallbackClient: RDAP, then WHOIS).
+// Pluggable so a local RDAP stub can stand in for the registries.
+type DomainLookupClient interface {
+	Lookup(ctx context.Context, domain string) (*model.DomainLookup, error)
+}
+
+// DomainService tracks the registration expiry of the domains behind each user's hostnames.
+type DomainService interface {
+	// --- User Facing ---
+	ListDomains(ctx context.Context, userID string) ([]model.DomainRegistration, error)
+
+	// --- Worker Facing ---
+	// SyncDomains derives the registrable domains of every user's targets & certificates
+	SyncDomains(ctx context.Context) (added, removed int64, err error)
+	GetDueDomains(ctx context.Context, limit int) ([]string, error)
+	// CheckDomain looks a domain up and records the result for every user tracking it
+	CheckDomain(ctx context.Context, domain string) (*model.DomainLookup, error)
+
+	// --- Alerter ---
+	GetExpiringDomains(ctx context.Context, threshold, cooldown time.Duration) ([]model.DomainRegistration, error)
+	MarkAlerted(ctx context.Context, ids []string) error
 }
 
 // AgentService
@@ -85,6 +204,11 @@ type AgentService interface {
 	ListAgents(ctx context.Context, userID string) ([]model.AgentResponse, error)
 	DeleteAgent(ctx context.Context, userID, agentID string) error
 	CleanupDeadAgents(ctx context.Context, threshold time.Duration) (int64, error)
+
+	// Enrollment: a one-time token is exchanged for a per-agent credential (see install.sh)
+	CreateEnrollmentToken(ctx context.Context, userID string) (*model.AgentEnrollmentToken, error)
+	EnrollAgent(ctx context.Context, token, hostname, ipAddress string) (*model.AgentCredential, error)
+	RevokeAgent(ctx context.Context, userID, agentID string) error
 }
 
 // HistoryService handles alert deduplication and logging.
@@ -101,3 +225,18 @@ type HistoryService interface {
 type Notifier interface {
 	Notify(ctx context.Context, certs []model.CertResponse, users map[string]model.User) error
 }
+
+// ResponderNotifier alerts owners about CRL / OCSP endpoints that are stale, failing or unreachable.
+type ResponderNotifier interface {
+	NotifyResponders(ctx context.Context, targets []model.ResponderTarget, users map[string]model.User) error
+}
+
+// TargetHealthNotifier alerts owners about Monitored Targets that went DOWN, or recovered.
+type TargetHealthNotifier interface {
+	NotifyTargetHealth(ctx context.Context, alerts []model.TargetHealthAlert, users map[string]model.User) error
+}
+
+// DomainNotifier alerts owners about domain registrations that are about to lapse (or have).
+type DomainNotifier interface {
+	NotifyDomains(ctx context.Context, domains []model.DomainRegistration, users map[string]model.User) error
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// recordReplacement logs that the leaf served at a source changed from oldCertID to newCertID.
+// It is a renewal when the new certificate keeps the subject and SANs (where known) and expires later,
+// otherwise a swap. A renewal resolves the old certificate's alerts on this agent, unless the agent
+// still serves it at another source.
+func recordReplacement(ctx context.Context, tx *sql.Tx, agentID, instanceID, oldCertID, newCertID, sourceUID string, at time.Time) error {
+	// 1. Classify & Record
+	var eventID string
+	var kind model.ReplacementKind
+	err := tx.QueryRowContext(ctx, `
+        INSERT INTO certificate_replacements (instance_id, old_certificate_id, new_certificate_id, kind, replaced_at)
+        SELECT $1, o.id, n.id,
+               CASE WHEN n.subject_cn = o.subject_cn
+                     AND COALESCE(n.subject_org, '') = COALESCE(o.subject_org, '')
+                     AND (o.san_dns IS NULL OR o.san_dns = n.san_dns)
+                     AND (o.san_ips IS NULL OR o.san_ips = n.san_ips)
+                     AND n.valid_until > o.valid_until
+                    THEN 'RENEWAL' ELSE 'SWAP' END,
+               $4
+        FROM certificates o, certificates n
+        WHERE o.id = $2 AND n.id = $3
+        RETURNING id, kind
+    `, instanceID, oldCertID, newCertID, at).Scan(&eventID, &kind)
+	if err != nil {
+		return fmt.Errorf("failed to record replacement at %s: %w", sourceUID, err)
+	}
+
+	if kind != model.ReplacementRenewal {
+		log.Printf("🔀 Ingest: Certificate at %s was swapped for a different one", sourceUID)
+		return nil
+	}
+
+	// 2. Resolve the Old Certificate's Alerts
+	result, err := tx.ExecContext(ctx, `
+        UPDATE alert_history SET resolved_at = $3, resolution = $4
+        WHERE certificate_id = $1 AND agent_id = $2 AND resolved_at IS NULL
+          AND NOT EXISTS (
+              SELECT 1 FROM certificate_instances ci
+              WHERE ci.agent_id = $2 AND ci.certificate_id = $1 AND ci.current_status = 'ACTIVE'
+          )
+    `, oldCertID, agentID, at, model.AlertResolutionRenewed)
+	if err != nil {
+		return fmt.Errorf("failed to resolve alerts at %s: %w", sourceUID, err)
+	}
+
+	resolved, _ := result.RowsAffected()
+	if resolved > 0 {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE certificate_replacements SET alerts_resolved = $1 WHERE id = $2", resolved, eventID); err != nil {
+			return fmt.Errorf("failed to record resolved alerts at %s: %w", sourceUID, err)
+		}
+	}
+	log.Printf("🔄 Ingest: Certificate at %s was renewed (%d alerts resolved)", sourceUID, resolved)
+	return nil
+}
+
+// GetReplacementHistory lists the certificates an instance's source has served before, newest first.
+func (s *PostgresCertificateService) GetReplacementHistory(ctx context.Context, userID, instanceID string) ([]model.CertReplacement, error) {
+	// 1. Ownership Check
+	if err := s.checkInstanceOwner(ctx, userID, instanceID); err != nil {
+		return nil, err
+	}
+
+	// 2. Events (either definition may have been deleted since)
+	rows, err := s.DB.QueryContext(ctx, `
+        SELECT r.id, r.instance_id, r.kind, r.replaced_at, r.alerts_resolved,
+               o.id, o.serial_number, o.subject_cn, o.subject_org, o.issuer_cn, o.issuer_org, o.valid_from, o.valid_until, o.fingerprint_sha256, o.san_dns,
+               n.id, n.serial_number, n.subject_cn, n.subject_org, n.issuer_cn, n.issuer_org, n.valid_from, n.valid_until, n.fingerprint_sha256, n.san_dns
+        FROM certificate_replacements r
+        LEFT JOIN certificates o ON r.old_certificate_id = o.id
+        LEFT JOIN certificates n ON r.new_certificate_id = n.id
+        WHERE r.instance_id = $1
+        ORDER BY r.replaced_at DESC
+    `, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch replacement history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []model.CertReplacement{}
+	for rows.Next() {
+		var r model.CertReplacement
+		var oldCert, newCert replacedCertColumns
+		dest := append([]any{&r.ID, &r.InstanceID, &r.Kind, &r.ReplacedAt, &r.AlertsResolved}, oldCert.dest()...)
+		if err := rows.Scan(append(dest, newCert.dest()...)...); err != nil {
+			return nil, err
+		}
+		r.Old = oldCert.toModel()
+		r.New = newCert.toModel()
+		history = append(history, r)
+	}
+	return history, rows.Err()
+}
+
+// GetAlertHistory lists the alerts sent for the certificate an instance serves (on its agent), newest first.
+// Alerts closed by a renewal carry their resolution.
+func (s *PostgresCertificateService) GetAlertHistory(ctx context.Context, userID, instanceID string) ([]model.AlertRecord, error) {
+	// 1. Ownership Check
+	if err := s.checkInstanceOwner(ctx, userID, instanceID); err != nil {
+		return nil, err
+	}
+
+	// 2. Alerts
+	rows, err := s.DB.QueryContext(ctx, `
+        SELECT h.id, h.alert_type, h.sent_at, h.resolved_at, h.resolution
+        FROM certificate_instances ci
+        JOIN alert_history h ON h.certificate_id = ci.certificate_id AND h.agent_id = ci.agent_id
+        WHERE ci.id = $1
+        ORDER BY h.sent_at DESC
+    `, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alert history: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []model.AlertRecord{}
+	for rows.Next() {
+		var a model.AlertRecord
+		var resolvedAt sql.NullTime
+		var resolution sql.NullString
+		if err := rows.Scan(&a.ID, &a.AlertType, &a.SentAt, &resolvedAt, &resolution); err != nil {
+			return nil, err
+		}
+		a.ResolvedAt = nullTimePtr(resolvedAt)
+		a.Resolution = model.AlertResolution(resolution.String)
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// PruneReplacements deletes replacement events older than the retention.
+// The old definitions they kept are then released to CleanupOrphanedCerts.
+func (s *PostgresCertificateService) PruneReplacements(ctx context.Context, retention time.Duration) (int64, error) {
+	result, err := s.DB.ExecContext(ctx,
+		"DELETE FROM certificate_replacements WHERE replaced_at < $1", time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune replacement history: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// checkInstanceOwner fails unless the instance belongs to one of the user's agents.
+func (s *PostgresCertificateService) checkInstanceOwner(ctx context.Context, userID, instanceID string) error {
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM certificate_instances ci
+            JOIN agents a ON ci.agent_id = a.id
+            WHERE ci.id = $1 AND a.user_id = $2
+        )
+    `, instanceID, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("certificate not found or access denied")
+	}
+	return nil
+}
+
+// replacedCertColumns scans one side of a replacement (all NULL when the definition is gone).
+type replacedCertColumns struct {
+	id, serial, subjectCN, subjectOrg, issuerCN, issuerOrg, fingerprint sql.NullString
+	validFrom, validUntil                                               sql.NullTime
+	dnsNames                                                            pq.StringArray
+}
+
+func (c *replacedCertColumns) dest() []any {
+	return []any{&c.id, &c.serial, &c.subjectCN, &c.subjectOrg, &c.issuerCN, &c.issuerOrg,
+		&c.validFrom, &c.validUntil, &c.fingerprint, &c.dnsNames}
+}
+
+func (c *replacedCertColumns) toModel() *model.ReplacedCertificate {
+	if !c.id.Valid {
+		return nil
+	}
+	return &model.ReplacedCertificate{
+		ID:                c.id.String,
+		Serial:            c.serial.String,
+		Subject:           model.DN{CN: c.subjectCN.String, Org: c.subjectOrg.String},
+		Issuer:            model.DN{CN: c.issuerCN.String, Org: c.issuerOrg.String},
+		ValidFrom:         c.validFrom.Time,
+		ValidUntil:        c.validUntil.Time,
+		FingerprintSHA256: c.fingerprint.String,
+		DNSNames:          c.dnsNames,
+	}
+}
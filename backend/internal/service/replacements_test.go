@@ -0,0 +1,192 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// lastReplacement reads the newest replacement recorded at the user's sourceUID.
+func lastReplacement(t *testing.T, conn *sql.DB, userID, sourceUID string) (kind model.ReplacementKind, alertsResolved int) {
+	t.Helper()
+	err := conn.QueryRowContext(context.Background(), `
+        SELECT r.kind, r.alerts_resolved
+        FROM certificate_replacements r
+        JOIN certificate_instances ci ON r.instance_id = ci.id
+        JOIN agents a ON ci.agent_id = a.id
+        WHERE a.user_id = $1 AND ci.source_uid = $2
+        ORDER BY r.replaced_at DESC
+        LIMIT 1
+    `, userID, sourceUID).Scan(&kind, &alertsResolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kind, alertsResolved
+}
+
+func TestReplacementClassification(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := testCertService(t, conn)
+	ctx := context.Background()
+	expiry := time.Now().Add(60 * 24 * time.Hour)
+
+	tests := []struct {
+		name      string
+		oldNames  []string
+		newNames  []string
+		newExpiry time.Time
+		want      model.ReplacementKind
+	}{
+		{name: "same subject and SANs, later expiry", oldNames: []string{"%s"}, newNames: []string{"%s"}, newExpiry: expiry.Add(90 * 24 * time.Hour), want: model.ReplacementRenewal},
+		{name: "SAN added", oldNames: []string{"%s"}, newNames: []string{"%s", "www.%s"}, newExpiry: expiry.Add(90 * 24 * time.Hour), want: model.ReplacementSwap},
+		{name: "SAN dropped", oldNames: []string{"%s", "www.%s"}, newNames: []string{"%s"}, newExpiry: expiry.Add(90 * 24 * time.Hour), want: model.ReplacementSwap},
+		{name: "another subject", oldNames: []string{"%s"}, newNames: []string{"other.%s"}, newExpiry: expiry.Add(90 * 24 * time.Hour), want: model.ReplacementSwap},
+		{name: "earlier expiry", oldNames: []string{"%s"}, newNames: []string{"%s"}, newExpiry: expiry.Add(-24 * time.Hour), want: model.ReplacementSwap},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := fmt.Sprintf("replace%d.example.test", i)
+			names := func(patterns []string) []string {
+				out := make([]string, len(patterns))
+				for j, p := range patterns {
+					out[j] = fmt.Sprintf(p, host)
+				}
+				return out
+			}
+			uid := host + ":443"
+
+			for _, cert := range []model.Certificate{
+				testCertificate(t, uid, expiry, names(tt.oldNames)...),
+				testCertificate(t, uid, tt.newExpiry, names(tt.newNames)...),
+			} {
+				if err := svc.IngestScanResults(ctx, userID, []model.Certificate{cert}); err != nil {
+					t.Fatalf("ingest failed: %v", err)
+				}
+			}
+
+			if kind, _ := lastReplacement(t, conn, userID, uid); kind != tt.want {
+				t.Fatalf("kind = %s, want %s", kind, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenewalKeepsAlertsWhileServed renews a certificate served at two sources of the same agent:
+// its alerts stay open until no source serves it anymore.
+func TestRenewalKeepsAlertsWhileServed(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := testCertService(t, conn)
+	ctx := context.Background()
+
+	expiry := time.Now().Add(10 * 24 * time.Hour)
+	old := testCertificate(t, "", expiry, "shared.example.test")
+	renewed := testCertificate(t, "", expiry.Add(90*24*time.Hour), "shared.example.test")
+	at := func(c model.Certificate, uid string) model.Certificate {
+		c.SourceUID = uid
+		return c
+	}
+	const primary, secondary = "shared.example.test:443", "shared.example.test:8443"
+
+	if err := svc.IngestScanResults(ctx, userID, []model.Certificate{at(old, primary), at(old, secondary)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The expiry alert sent for the old certificate
+	var alertID string
+	err := conn.QueryRowContext(ctx, `
+        INSERT INTO alert_history (certificate_id, agent_id, alert_type)
+        SELECT DISTINCT ci.certificate_id, ci.agent_id, 'EMAIL'
+        FROM certificate_instances ci JOIN agents a ON ci.agent_id = a.id
+        WHERE a.user_id = $1 AND ci.source_uid = $2
+        RETURNING id
+    `, userID, primary).Scan(&alertID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alertResolution := func() sql.NullString {
+		var resolution sql.NullString
+		if err := conn.QueryRowContext(ctx, "SELECT resolution FROM alert_history WHERE id = $1", alertID).Scan(&resolution); err != nil {
+			t.Fatal(err)
+		}
+		return resolution
+	}
+
+	// 1. Renewed at one source only: the old certificate is still served at the other
+	if err := svc.IngestScanResults(ctx, userID, []model.Certificate{at(renewed, primary)}); err != nil {
+		t.Fatal(err)
+	}
+	if kind, resolved := lastReplacement(t, conn, userID, primary); kind != model.ReplacementRenewal || resolved != 0 {
+		t.Fatalf("primary: %s resolving %d alerts, want a RENEWAL resolving none", kind, resolved)
+	}
+	if r := alertResolution(); r.Valid {
+		t.Fatalf("alert resolved as %q while the old certificate is still served", r.String)
+	}
+
+	// 2. Renewed at the last source: the alert is closed by this renewal
+	if err := svc.IngestScanResults(ctx, userID, []model.Certificate{at(renewed, secondary)}); err != nil {
+		t.Fatal(err)
+	}
+	if kind, resolved := lastReplacement(t, conn, userID, secondary); kind != model.ReplacementRenewal || resolved != 1 {
+		t.Fatalf("secondary: %s resolving %d alerts, want a RENEWAL resolving 1", kind, resolved)
+	}
+	if r := alertResolution(); r.String != string(model.AlertResolutionRenewed) {
+		t.Fatalf("alert resolution = %q, want %s", r.String, model.AlertResolutionRenewed)
+	}
+}
+
+func TestPruneReplacements(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := testCertService(t, conn)
+	ctx := context.Background()
+
+	uid := "pruned.example.test:443"
+	expiry := time.Now().Add(30 * 24 * time.Hour)
+	for _, c := range []model.Certificate{
+		testCertificate(t, uid, expiry, "pruned.example.test"),
+		testCertificate(t, uid, expiry.Add(24*time.Hour), "pruned.example.test"),
+		testCertificate(t, uid, expiry.Add(48*time.Hour), "pruned.example.test"),
+	} {
+		if err := svc.IngestScanResults(ctx, userID, []model.Certificate{c}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Backdate the first of the two replacements beyond the retention
+	_, err := conn.ExecContext(ctx, `
+        UPDATE certificate_replacements SET replaced_at = NOW() - INTERVAL '500 days'
+        WHERE id = (
+            SELECT r.id FROM certificate_replacements r
+            JOIN certificate_instances ci ON r.instance_id = ci.id
+            JOIN agents a ON ci.agent_id = a.id
+            WHERE a.user_id = $1 AND ci.source_uid = $2
+            ORDER BY r.replaced_at ASC LIMIT 1
+        )
+    `, userID, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.PruneReplacements(ctx, 400*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var left int
+	err = conn.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM certificate_replacements r
+        JOIN certificate_instances ci ON r.instance_id = ci.id
+        JOIN agents a ON ci.agent_id = a.id
+        WHERE a.user_id = $1 AND ci.source_uid = $2
+    `, userID, uid).Scan(&left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left != 1 {
+		t.Fatalf("%d replacements left, want the recent one", left)
+	}
+}
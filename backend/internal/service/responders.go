@@ -0,0 +1,396 @@
+package service
+
+import (
+	"cert-manager-backend/internal/egress"
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresResponderService monitors CRL distribution points and OCSP responders (Responder Targets).
+type PostgresResponderService struct {
+	DB     *sql.DB
+	Prober ResponderProber
+
+	// Thresholds: a CRL this close to nextUpdate, or a signer this close to expiry, is DEGRADED
+	NextUpdateWarning   time.Duration
+	SignerExpiryWarning time.Duration
+
+	DefaultFrequencyMinutes int
+}
+
+func NewResponderService(db *sql.DB, prober ResponderProber, nextUpdateWarning, signerExpiryWarning time.Duration, defaultFrequencyMinutes int) *PostgresResponderService {
+	return &PostgresResponderService{
+		DB:                      db,
+		Prober:                  prober,
+		NextUpdateWarning:       nextUpdateWarning,
+		SignerExpiryWarning:     signerExpiryWarning,
+		DefaultFrequencyMinutes: defaultFrequencyMinutes,
+	}
+}
+
+const responderColumns = `
+	id, user_id, kind, url, issuer_pem, issuer_subject, serial_number, frequency_minutes,
+	health, problems, last_checked_at, last_error,
+	latency_ms, this_update, next_update, signer_subject, signer_expires_at,
+	crl_number, crl_size_bytes, revoked_count, ocsp_status, produced_at, created_at`
+
+// --- User Facing Logic ---
+
+// CreateTarget validates the spec, saves it and probes it once for immediate feedback.
+// An unreachable endpoint is still saved (that is what is being monitored), but an egress-blocked one is not.
+func (s *PostgresResponderService) CreateTarget(ctx context.Context, userID string, spec model.ResponderTarget) (*model.ResponderTarget, error) {
+	// 1. Validate
+	if spec.Kind != model.ResponderCRL && spec.Kind != model.ResponderOCSP {
+		return nil, fmt.Errorf("unsupported kind %q (supported: CRL, OCSP)", spec.Kind)
+	}
+
+	u, err := url.Parse(strings.TrimSpace(spec.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url must be an http:// or https:// address")
+	}
+
+	issuer, err := parseIssuerPEM(spec.IssuerPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	serial := ""
+	if spec.Kind == model.ResponderOCSP {
+		n, err := parseSerial(spec.SerialNumber)
+		if err != nil {
+			return nil, err
+		}
+		serial = n.String()
+	}
+
+	frequency := spec.FrequencyMinutes
+	if frequency <= 0 {
+		frequency = s.DefaultFrequencyMinutes
+	}
+
+	// 2. Save
+	t := &model.ResponderTarget{
+		UserID:           userID,
+		Kind:             spec.Kind,
+		URL:              u.String(),
+		IssuerPEM:        string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuer.Raw})),
+		IssuerSubject:    issuer.Subject.String(),
+		SerialNumber:     serial,
+		FrequencyMinutes: frequency,
+		Health:           model.TargetHealthy,
+	}
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO responder_targets (user_id, kind, url, issuer_pem, issuer_subject, serial_number, frequency_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, userID, t.Kind, t.URL, t.IssuerPEM, t.IssuerSubject, t.SerialNumber, t.FrequencyMinutes).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "unique constraint") {
+			return nil, fmt.Errorf("you are already monitoring this responder")
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	// 3. Check-on-Create
+	checked, _, probeErr := s.CheckTarget(ctx, *t)
+	if probeErr != nil && egress.IsBlocked(probeErr) {
+		s.DB.ExecContext(ctx, "DELETE FROM responder_targets WHERE id = $1", t.ID)
+		return nil, fmt.Errorf("responder unreachable: %w", probeErr)
+	}
+	if checked != nil {
+		t = checked
+	}
+	return t, nil
+}
+
+func (s *PostgresResponderService) ListTargets(ctx context.Context, userID string) ([]model.ResponderTarget, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+responderColumns+`
+		FROM responder_targets
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []model.ResponderTarget{}
+	for rows.Next() {
+		t, err := scanResponderTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, nil
+}
+
+func (s *PostgresResponderService) DeleteTarget(ctx context.Context, userID, targetID string) error {
+	result, err := s.DB.ExecContext(ctx, `
+		DELETE FROM responder_targets
+		WHERE id = $1 AND user_id = $2
+	`, targetID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("responder not found or access denied")
+	}
+	return nil
+}
+
+// --- Worker Facing Logic ---
+
+// GetDueTargets returns targets never checked or past their check interval.
+func (s *PostgresResponderService) GetDueTargets(ctx context.Context) ([]model.ResponderTarget, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+responderColumns+`
+		FROM responder_targets
+		WHERE last_checked_at IS NULL
+		   OR last_checked_at + (frequency_minutes * INTERVAL '1 minute') < NOW()
+		ORDER BY last_checked_at ASC NULLS FIRST
+		LIMIT 100
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []model.ResponderTarget
+	for rows.Next() {
+		t, err := scanResponderTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, nil
+}
+
+// CheckTarget probes the endpoint, evaluates its health and records the outcome.
+// alertDue is set when the target is unhealthy and its owner hasn't been told about this state in the last day.
+// The returned error is the probe failure, if any (already recorded as DOWN).
+func (s *PostgresResponderService) CheckTarget(ctx context.Context, t model.ResponderTarget) (*model.ResponderTarget, bool, error) {
+	// 1. Probe
+	var check *model.ResponderCheck
+	issuer, probeErr := parseIssuerPEM(t.IssuerPEM)
+	if probeErr == nil {
+		switch t.Kind {
+		case model.ResponderCRL:
+			check, probeErr = s.Prober.ProbeCRL(ctx, t.URL, issuer)
+		case model.ResponderOCSP:
+			serial, ok := new(big.Int).SetString(t.SerialNumber, 10)
+			if !ok {
+				probeErr = fmt.Errorf("invalid serial number %q", t.SerialNumber)
+				break
+			}
+			check, probeErr = s.Prober.ProbeOCSP(ctx, t.URL, issuer, serial)
+		default:
+			probeErr = fmt.Errorf("unsupported kind %q", t.Kind)
+		}
+	}
+
+	// 2. Evaluate
+	health, problems := evaluateResponder(t.Kind, check, probeErr, time.Now(), s.NextUpdateWarning, s.SignerExpiryWarning)
+	var lastErr sql.NullString
+	if probeErr != nil {
+		lastErr = nullString(probeErr.Error())
+	}
+
+	// 3. Record (a failed probe keeps the last successful details)
+	if check == nil {
+		check = &model.ResponderCheck{}
+	}
+	row := s.DB.QueryRowContext(ctx, `
+		UPDATE responder_targets
+		SET last_checked_at = NOW(),
+		    health = $2,
+		    problems = $3,
+		    last_error = $4,
+		    last_alert_health = CASE WHEN $2 = 'HEALTHY' THEN NULL ELSE last_alert_health END,
+		    latency_ms = CASE WHEN $5 THEN $6 ELSE latency_ms END,
+		    this_update = CASE WHEN $5 THEN $7 ELSE this_update END,
+		    next_update = CASE WHEN $5 THEN $8 ELSE next_update END,
+		    signer_subject = CASE WHEN $5 THEN $9 ELSE signer_subject END,
+		    signer_expires_at = CASE WHEN $5 THEN $10 ELSE signer_expires_at END,
+		    crl_number = CASE WHEN $5 THEN $11 ELSE crl_number END,
+		    crl_size_bytes = CASE WHEN $5 THEN $12 ELSE crl_size_bytes END,
+		    revoked_count = CASE WHEN $5 THEN $13 ELSE revoked_count END,
+		    ocsp_status = CASE WHEN $5 THEN $14 ELSE ocsp_status END,
+		    produced_at = CASE WHEN $5 THEN $15 ELSE produced_at END
+		WHERE id = $1
+		RETURNING `+responderColumns+`,
+		    COALESCE(health <> 'HEALTHY' AND (last_alert_health IS DISTINCT FROM health
+		                                      OR last_alerted_at < NOW() - INTERVAL '1 day'), FALSE)
+	`, t.ID, health, pq.Array(problems), lastErr, probeErr == nil,
+		check.LatencyMs, check.ThisUpdate, check.NextUpdate, nullString(check.SignerSubject), check.SignerExpiresAt,
+		nullString(check.CRLNumber), check.SizeBytes, check.RevokedCount, nullString(string(check.OCSPStatus)), check.ProducedAt)
+
+	var alertDue bool
+	updated, err := scanResponderTarget(row, &alertDue)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record check: %w", err)
+	}
+	return updated, alertDue, probeErr
+}
+
+// MarkAlerted remembers that the owners were notified of the targets' current health (1 day cooldown).
+func (s *PostgresResponderService) MarkAlerted(ctx context.Context, targetIDs []string) error {
+	if len(targetIDs) == 0 {
+		return nil
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE responder_targets
+		SET last_alerted_at = NOW(),
+		    last_alert_health = health
+		WHERE id = ANY($1::uuid[])
+	`, pq.Array(targetIDs))
+	return err
+}
+
+// evaluateResponder turns a probe outcome into a health state and the reasons behind it.
+// DOWN: unreachable, invalid, stale (past nextUpdate) or signed by an expired certificate.
+// DEGRADED: CRL nextUpdate or signer expiry within the warning window, or an OCSP "unknown" for a known serial.
+func evaluateResponder(kind model.ResponderKind, check *model.ResponderCheck, probeErr error, now time.Time, nextUpdateWarning, signerExpiryWarning time.Duration) (model.TargetHealth, []string) {
+	if probeErr != nil {
+		return model.TargetDown, []string{probeErr.Error()}
+	}
+
+	health := model.TargetHealthy
+	var problems []string
+	flag := func(h model.TargetHealth, format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+		if h == model.TargetDown || health == model.TargetHealthy {
+			health = h
+		}
+	}
+
+	what := "CRL"
+	if kind == model.ResponderOCSP {
+		what = "OCSP response"
+	}
+
+	// A. Freshness
+	if check.NextUpdate != nil {
+		if now.After(*check.NextUpdate) {
+			flag(model.TargetDown, "%s is stale: nextUpdate was %s", what, check.NextUpdate.Format(time.RFC3339))
+		} else if kind == model.ResponderCRL && check.NextUpdate.Sub(now) < nextUpdateWarning {
+			// OCSP responses are minted on demand: a short nextUpdate is normal for them
+			flag(model.TargetDegraded, "%s nextUpdate is in %s", what, check.NextUpdate.Sub(now).Round(time.Minute))
+		}
+	}
+
+	// B. Signer
+	if check.SignerExpiresAt != nil {
+		if now.After(*check.SignerExpiresAt) {
+			flag(model.TargetDown, "signer certificate expired on %s", check.SignerExpiresAt.Format("2006-01-02"))
+		} else if check.SignerExpiresAt.Sub(now) < signerExpiryWarning {
+			flag(model.TargetDegraded, "signer certificate expires on %s", check.SignerExpiresAt.Format("2006-01-02"))
+		}
+	}
+
+	// C. OCSP Answer
+	if kind == model.ResponderOCSP && check.OCSPStatus == model.RevocationUnknown {
+		flag(model.TargetDegraded, "responder does not know the probed serial")
+	}
+
+	return health, problems
+}
+
+// parseIssuerPEM reads the first certificate of a PEM bundle.
+func parseIssuerPEM(input string) (*x509.Certificate, error) {
+	rest := []byte(strings.TrimSpace(input))
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("issuer_pem must contain a PEM certificate")
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid issuer certificate: %w", err)
+		}
+		return c, nil
+	}
+}
+
+// parseSerial accepts a decimal serial (as listed by the API) or hex ("0x1a2b", "1A:2B", "1a2b").
+func parseSerial(input string) (*big.Int, error) {
+	raw := strings.TrimSpace(input)
+	if raw == "" {
+		return nil, fmt.Errorf("serial_number is required for OCSP responders")
+	}
+
+	base := 10
+	lower := strings.ToLower(raw)
+	if strings.HasPrefix(lower, "0x") || strings.ContainsAny(lower, ":abcdef") {
+		base = 16
+		lower = strings.TrimPrefix(lower, "0x")
+		lower = strings.NewReplacer(":", "", " ", "").Replace(lower)
+	}
+
+	n, ok := new(big.Int).SetString(lower, base)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid serial number %q", input)
+	}
+	return n, nil
+}
+
+func scanResponderTarget(row rowScanner, extra ...any) (*model.ResponderTarget, error) {
+	var t model.ResponderTarget
+	var serial, issuerSubject, lastErr, signerSubject, crlNumber, ocspStatus sql.NullString
+	var problems pq.StringArray
+	var lastChecked, thisUpdate, nextUpdate, signerExpires, producedAt sql.NullTime
+	var latency sql.NullFloat64
+	var size, revoked sql.NullInt64
+
+	dest := []any{&t.ID, &t.UserID, &t.Kind, &t.URL, &t.IssuerPEM, &issuerSubject, &serial, &t.FrequencyMinutes,
+		&t.Health, &problems, &lastChecked, &lastErr,
+		&latency, &thisUpdate, &nextUpdate, &signerSubject, &signerExpires,
+		&crlNumber, &size, &revoked, &ocspStatus, &producedAt, &t.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	t.IssuerSubject = issuerSubject.String
+	t.SerialNumber = serial.String
+	t.Problems = problems
+	t.LastError = lastErr.String
+	t.LatencyMs = floatPtr(latency)
+	t.SignerSubject = signerSubject.String
+	t.CRLNumber = crlNumber.String
+	t.SizeBytes = int(size.Int64)
+	t.RevokedCount = int(revoked.Int64)
+	t.OCSPStatus = model.RevocationStatus(ocspStatus.String)
+	t.LastCheckedAt = nullTimePtr(lastChecked)
+	t.ThisUpdate = nullTimePtr(thisUpdate)
+	t.NextUpdate = nullTimePtr(nextUpdate)
+	t.SignerExpiresAt = nullTimePtr(signerExpires)
+	t.ProducedAt = nullTimePtr(producedAt)
+	return &t, nil
+}
+
+func nullTimePtr(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
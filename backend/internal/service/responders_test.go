@@ -0,0 +1,96 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEvaluateResponder(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(d)
+		return &ts
+	}
+	const (
+		nextUpdateWarning   = 24 * time.Hour
+		signerExpiryWarning = 30 * 24 * time.Hour
+	)
+
+	tests := []struct {
+		name         string
+		kind         model.ResponderKind
+		check        *model.ResponderCheck
+		probeErr     error
+		want         model.TargetHealth
+		wantProblems int
+	}{
+		{
+			name:     "unreachable",
+			kind:     model.ResponderCRL,
+			probeErr: errors.New("connection refused"),
+			want:     model.TargetDown, wantProblems: 1,
+		},
+		{
+			name:  "healthy crl",
+			kind:  model.ResponderCRL,
+			check: &model.ResponderCheck{NextUpdate: at(72 * time.Hour), SignerExpiresAt: at(365 * 24 * time.Hour)},
+			want:  model.TargetHealthy,
+		},
+		{
+			name:  "stale crl",
+			kind:  model.ResponderCRL,
+			check: &model.ResponderCheck{NextUpdate: at(-time.Hour)},
+			want:  model.TargetDown, wantProblems: 1,
+		},
+		{
+			name:  "crl close to its next update",
+			kind:  model.ResponderCRL,
+			check: &model.ResponderCheck{NextUpdate: at(2 * time.Hour)},
+			want:  model.TargetDegraded, wantProblems: 1,
+		},
+		{
+			name:  "short lived ocsp response is normal",
+			kind:  model.ResponderOCSP,
+			check: &model.ResponderCheck{NextUpdate: at(2 * time.Hour), OCSPStatus: model.RevocationGood},
+			want:  model.TargetHealthy,
+		},
+		{
+			name:  "ocsp unknown serial",
+			kind:  model.ResponderOCSP,
+			check: &model.ResponderCheck{NextUpdate: at(72 * time.Hour), OCSPStatus: model.RevocationUnknown},
+			want:  model.TargetDegraded, wantProblems: 1,
+		},
+		{
+			name:  "signer expiring soon",
+			kind:  model.ResponderCRL,
+			check: &model.ResponderCheck{NextUpdate: at(72 * time.Hour), SignerExpiresAt: at(10 * 24 * time.Hour)},
+			want:  model.TargetDegraded, wantProblems: 1,
+		},
+		{
+			name:  "down wins over degraded",
+			kind:  model.ResponderCRL,
+			check: &model.ResponderCheck{NextUpdate: at(2 * time.Hour), SignerExpiresAt: at(-time.Hour)},
+			want:  model.TargetDown, wantProblems: 2,
+		},
+		{
+			name:  "degraded does not hide an earlier down",
+			kind:  model.ResponderOCSP,
+			check: &model.ResponderCheck{NextUpdate: at(-time.Hour), OCSPStatus: model.RevocationUnknown},
+			want:  model.TargetDown, wantProblems: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health, problems := evaluateResponder(tt.kind, tt.check, tt.probeErr, now, nextUpdateWarning, signerExpiryWarning)
+			if health != tt.want {
+				t.Fatalf("health = %s, want %s (problems: %q)", health, tt.want, problems)
+			}
+			if len(problems) != tt.wantProblems {
+				t.Fatalf("problems = %q, want %d", problems, tt.wantProblems)
+			}
+		})
+	}
+}
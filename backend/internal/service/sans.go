@@ -0,0 +1,142 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// recordSANs stores the certificate's normalized SANs. SANs parsed from the DER (fromDER) replace whatever
+// is stored. The reporter's own lists only fill a row without DER, each kind once (first reporter wins):
+// a reporter that omits a kind (older agents only send DNS names) leaves it to the next one.
+func recordSANs(ctx context.Context, tx *sql.Tx, certID string, cert model.Certificate, fromDER bool) error {
+	sans := normalizeSANs(cert)
+	_, err := tx.ExecContext(ctx, `
+        UPDATE certificates
+        SET san_dns = CASE WHEN $6 THEN $1 ELSE COALESCE(san_dns, $1) END,
+            san_ips = CASE WHEN $6 THEN $2 ELSE COALESCE(san_ips, $2) END,
+            san_emails = CASE WHEN $6 THEN $3 ELSE COALESCE(san_emails, $3) END,
+            san_uris = CASE WHEN $6 THEN $4 ELSE COALESCE(san_uris, $4) END
+        WHERE id = $5 AND ($6 OR raw_der IS NULL)
+    `, nullArray(sans.DNSNames), nullArray(sans.IPAddresses), nullArray(sans.EmailAddresses), nullArray(sans.URIs), certID, fromDER)
+	if err != nil {
+		return fmt.Errorf("failed to record sans for %s: %w", cert.Serial, err)
+	}
+	return nil
+}
+
+// normalizeSANs canonicalizes, deduplicates and sorts every SAN kind. Unparseable IPs / URIs are dropped.
+func normalizeSANs(cert model.Certificate) model.SubjectAltNames {
+	var sans model.SubjectAltNames
+	for _, n := range cert.DNSNames {
+		if n = normalizeDNSName(n); n != "" {
+			sans.DNSNames = append(sans.DNSNames, n)
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if parsed := net.ParseIP(strings.TrimSpace(ip)); parsed != nil {
+			sans.IPAddresses = append(sans.IPAddresses, parsed.String())
+		}
+	}
+	for _, e := range cert.EmailAddresses {
+		if e = normalizeEmail(e); e != "" {
+			sans.EmailAddresses = append(sans.EmailAddresses, e)
+		}
+	}
+	for _, u := range cert.URIs {
+		if u = normalizeURI(u); u != "" {
+			sans.URIs = append(sans.URIs, u)
+		}
+	}
+
+	sans.DNSNames = sortedUnique(sans.DNSNames)
+	sans.IPAddresses = sortedUnique(sans.IPAddresses)
+	sans.EmailAddresses = sortedUnique(sans.EmailAddresses)
+	sans.URIs = sortedUnique(sans.URIs)
+	return sans
+}
+
+// sanCandidates returns the stored SAN values that match name, whatever its kind:
+// the normalized name itself and, for a hostname, the wildcard covering it ("api.example.com" -> "*.example.com").
+func sanCandidates(name string) []string {
+	name = strings.TrimSpace(name)
+	if ip := net.ParseIP(name); ip != nil {
+		return []string{ip.String()}
+	}
+	if strings.Contains(name, "://") {
+		if u := normalizeURI(name); u != "" {
+			return []string{u}
+		}
+		return nil
+	}
+	if strings.Contains(name, "@") {
+		if e := normalizeEmail(name); e != "" {
+			return []string{e}
+		}
+		return nil
+	}
+
+	host := normalizeDNSName(name)
+	if host == "" {
+		return nil
+	}
+	candidates := []string{host}
+	// A wildcard covers exactly one label, and never a wildcard pattern itself
+	if _, parent, ok := strings.Cut(host, "."); ok && !strings.HasPrefix(host, "*.") && strings.Contains(parent, ".") {
+		candidates = append(candidates, "*."+parent)
+	}
+	return candidates
+}
+
+func normalizeDNSName(n string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(n)), ".")
+}
+
+// normalizeEmail lowercases the domain; the local part is case-sensitive (RFC 5321).
+func normalizeEmail(e string) string {
+	e = strings.TrimSpace(e)
+	local, domain, ok := strings.Cut(e, "@")
+	if !ok || local == "" || domain == "" {
+		return ""
+	}
+	return local + "@" + normalizeDNSName(domain)
+}
+
+func normalizeURI(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme == "" {
+		return ""
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+func sortedUnique(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+	sort.Strings(list)
+	out := list[:1]
+	for _, v := range list[1:] {
+		if v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// nullArray maps an empty list to SQL NULL ("not reported"), unlike pq.Array's empty array.
+func nullArray(list []string) interface{} {
+	if len(list) == 0 {
+		return nil
+	}
+	return pq.Array(list)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSANCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "api.example.com", want: []string{"api.example.com", "*.example.com"}},
+		{name: " API.Example.COM. ", want: []string{"api.example.com", "*.example.com"}},
+		{name: "example.com", want: []string{"example.com"}}, // "*.com" is never a candidate
+		{name: "*.example.com", want: []string{"*.example.com"}},
+		{name: "192.0.2.1", want: []string{"192.0.2.1"}},
+		{name: "::ffff:192.0.2.1", want: []string{"192.0.2.1"}},
+		{name: "2001:DB8::1", want: []string{"2001:db8::1"}},
+		{name: "Admin@Example.COM", want: []string{"Admin@example.com"}}, // The local part keeps its case
+		{name: "spiffe://Cluster.Local/ns/prod/sa/api", want: []string{"spiffe://cluster.local/ns/prod/sa/api"}},
+		{name: "admin@"},
+		{name: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanCandidates(tt.name)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("sanCandidates(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
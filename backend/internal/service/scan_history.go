@@ -0,0 +1,237 @@
+package service
+
+import (
+	"cert-manager-backend/internal/egress"
+	"cert-manager-backend/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxStatsResults caps the raw series returned with TargetStats (newest kept).
+const maxStatsResults = 2000
+
+// --- Scan History (Availability & Latency) ---
+
+// RecordScanResult appends one scan to the target's history.
+// On success the timings & fingerprint come from the primary endpoint's leaf; on failure the error is classified.
+func (s *PostgresAgentLessTargetService) RecordScanResult(ctx context.Context, targetID string, certs []model.Certificate, scanErr error) error {
+	var (
+		outcome                 = "SUCCESS"
+		errorClass, errStr      sql.NullString
+		dnsMs, connectMs, hsMs  sql.NullFloat64
+		resolvedIP, fingerprint sql.NullString
+	)
+
+	if scanErr != nil {
+		outcome = "FAILED"
+		errorClass = nullString(scanErrorClass(scanErr))
+		errStr = nullString(scanErr.Error())
+	} else {
+		for _, c := range certs {
+			if c.ChainPosition != 0 {
+				continue
+			}
+			fingerprint = nullString(c.FingerprintSHA256)
+			if c.Timing != nil {
+				if c.Timing.DNS > 0 {
+					dnsMs = sql.NullFloat64{Float64: millis(c.Timing.DNS), Valid: true}
+				}
+				connectMs = sql.NullFloat64{Float64: millis(c.Timing.Connect), Valid: true}
+				hsMs = sql.NullFloat64{Float64: millis(c.Timing.Handshake), Valid: true}
+				resolvedIP = nullString(c.Timing.RemoteIP)
+			}
+			break // Leaf of the first endpoint scanned (TargetURL's port)
+		}
+	}
+
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO target_scan_results (target_id, outcome, error_class, error, dns_ms, connect_ms, handshake_ms,
+		                                 resolved_ip, fingerprint_sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, targetID, outcome, errorClass, errStr, dnsMs, connectMs, hsMs, resolvedIP, fingerprint)
+	return err
+}
+
+// GetTargetStats computes uptime and latency percentiles of a target over the last window.
+func (s *PostgresAgentLessTargetService) GetTargetStats(ctx context.Context, userID, targetID string, window time.Duration) (*model.TargetStats, error) {
+	// 1. Ownership Check
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM monitored_targets WHERE id = $1 AND user_id = $2)
+	`, targetID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("target not found or access denied")
+	}
+
+	now := time.Now()
+	stats := &model.TargetStats{
+		TargetID:     targetID,
+		From:         now.Add(-window),
+		To:           now,
+		ErrorClasses: map[string]int{},
+		Results:      []model.TargetScanResult{},
+	}
+
+	// 2. Aggregates (Percentiles over successful scans only)
+	var dnsP, connectP, hsP pq.Float64Array
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE outcome = 'SUCCESS'),
+		       percentile_cont(ARRAY[0.5, 0.9, 0.95, 0.99]) WITHIN GROUP (ORDER BY dns_ms)
+		           FILTER (WHERE outcome = 'SUCCESS' AND dns_ms IS NOT NULL),
+		       percentile_cont(ARRAY[0.5, 0.9, 0.95, 0.99]) WITHIN GROUP (ORDER BY connect_ms)
+		           FILTER (WHERE outcome = 'SUCCESS' AND connect_ms IS NOT NULL),
+		       percentile_cont(ARRAY[0.5, 0.9, 0.95, 0.99]) WITHIN GROUP (ORDER BY handshake_ms)
+		           FILTER (WHERE outcome = 'SUCCESS' AND handshake_ms IS NOT NULL)
+		FROM target_scan_results
+		WHERE target_id = $1 AND scanned_at >= $2
+	`, targetID, stats.From).Scan(&stats.Scans, &stats.Successes, &dnsP, &connectP, &hsP)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Scans > 0 {
+		uptime := float64(stats.Successes) * 100 / float64(stats.Scans)
+		stats.UptimePercent = &uptime
+	}
+	stats.DNS = toPercentiles(dnsP)
+	stats.Connect = toPercentiles(connectP)
+	stats.Handshake = toPercentiles(hsP)
+
+	// 3. Failure Breakdown
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT error_class, COUNT(*)
+		FROM target_scan_results
+		WHERE target_id = $1 AND scanned_at >= $2 AND outcome = 'FAILED'
+		GROUP BY error_class
+	`, targetID, stats.From)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var class sql.NullString
+		var count int
+		if err := rows.Scan(&class, &count); err != nil {
+			return nil, err
+		}
+		key := class.String
+		if key == "" {
+			key = model.ScanErrorOther
+		}
+		stats.ErrorClasses[key] += count
+	}
+
+	// 4. Raw Series (for charts)
+	series, err := s.DB.QueryContext(ctx, `
+		SELECT scanned_at, outcome, error_class, error, dns_ms, connect_ms, handshake_ms, resolved_ip, fingerprint_sha256
+		FROM (
+			SELECT * FROM target_scan_results
+			WHERE target_id = $1 AND scanned_at >= $2
+			ORDER BY scanned_at DESC
+			LIMIT $3
+		) recent
+		ORDER BY scanned_at ASC
+	`, targetID, stats.From, maxStatsResults)
+	if err != nil {
+		return nil, err
+	}
+	defer series.Close()
+	for series.Next() {
+		var r model.TargetScanResult
+		var errorClass, errStr, resolvedIP, fingerprint sql.NullString
+		var dnsMs, connectMs, hsMs sql.NullFloat64
+		if err := series.Scan(&r.ScannedAt, &r.Outcome, &errorClass, &errStr, &dnsMs, &connectMs, &hsMs,
+			&resolvedIP, &fingerprint); err != nil {
+			return nil, err
+		}
+		r.ErrorClass = errorClass.String
+		r.Error = errStr.String
+		r.DNSMs = floatPtr(dnsMs)
+		r.ConnectMs = floatPtr(connectMs)
+		r.HandshakeMs = floatPtr(hsMs)
+		r.ResolvedIP = resolvedIP.String
+		r.FingerprintSHA256 = fingerprint.String
+		stats.Results = append(stats.Results, r)
+	}
+
+	return stats, nil
+}
+
+// PruneScanHistory deletes history entries older than retention (Janitor).
+func (s *PostgresAgentLessTargetService) PruneScanHistory(ctx context.Context, retention time.Duration) (int64, error) {
+	result, err := s.DB.ExecContext(ctx, `
+		DELETE FROM target_scan_results
+		WHERE scanned_at < $1
+	`, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanErrorClass buckets a scan error for the availability breakdown.
+// Typed errors are checked first; aggregated multi-endpoint errors only keep their text.
+func scanErrorClass(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+
+	switch {
+	case egress.IsBlocked(err):
+		return model.ScanErrorBlocked
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return model.ScanErrorTimeout
+	case errors.As(err, &dnsErr):
+		return model.ScanErrorDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return model.ScanErrorRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return model.ScanErrorUnreachable
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "i/o timeout"), strings.Contains(msg, "deadline exceeded"):
+		return model.ScanErrorTimeout
+	case strings.Contains(msg, "no such host"), strings.Contains(msg, "dns lookup failed"):
+		return model.ScanErrorDNS
+	case strings.Contains(msg, "connection refused"):
+		return model.ScanErrorRefused
+	case strings.Contains(msg, "proxy"):
+		return model.ScanErrorProxy
+	case strings.Contains(msg, "starttls failed"):
+		return model.ScanErrorStartTLS
+	case strings.Contains(msg, "tls handshake failed"), strings.Contains(msg, "no certificates found"):
+		return model.ScanErrorTLS
+	}
+	return model.ScanErrorOther
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func floatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func toPercentiles(p pq.Float64Array) *model.LatencyPercentiles {
+	if len(p) != 4 {
+		return nil
+	}
+	return &model.LatencyPercentiles{P50: p[0], P90: p[1], P95: p[2], P99: p[3]}
+}
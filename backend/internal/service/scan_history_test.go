@@ -0,0 +1,148 @@
+package service
+
+import (
+	"cert-manager-backend/internal/egress"
+	"cert-manager-backend/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestScanErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "egress block", err: &egress.BlockedError{Host: "10.0.0.5", IP: netip.MustParseAddr("10.0.0.5")}, want: model.ScanErrorBlocked},
+		{name: "deadline", err: fmt.Errorf("dial: %w", context.DeadlineExceeded), want: model.ScanErrorTimeout},
+		{name: "net timeout", err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, want: model.ScanErrorTimeout},
+		{name: "dns", err: &net.DNSError{Err: "no such host", Name: "gone.example.test", IsNotFound: true}, want: model.ScanErrorDNS},
+		{name: "refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: model.ScanErrorRefused},
+		{name: "unreachable", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, want: model.ScanErrorUnreachable},
+		{name: "aggregated timeouts", err: errors.New("all 2 endpoints failed: a:443: i/o timeout; b:443: i/o timeout"), want: model.ScanErrorTimeout},
+		{name: "aggregated block", err: errors.New("all 2 endpoints failed: a:443: EGRESS_BLOCKED: ..."), want: model.ScanErrorBlocked},
+		{name: "proxy", err: errors.New("proxy refused connect to a:443: 403 Forbidden"), want: model.ScanErrorProxy},
+		{name: "starttls", err: errors.New("starttls failed: 502 not implemented"), want: model.ScanErrorStartTLS},
+		{name: "handshake", err: errors.New("tls handshake failed: remote error"), want: model.ScanErrorTLS},
+		{name: "other", err: errors.New("something else"), want: model.ScanErrorOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanErrorClass(tt.err); got != tt.want {
+				t.Fatalf("scanErrorClass(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTargetStats(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := &PostgresAgentLessTargetService{DB: conn}
+	ctx := context.Background()
+	target := testTarget(t, conn, userID, "stats.example.test:443", "")
+
+	// 10 successes: handshakes of 10..100 ms, a 5 ms connect, no DNS phase (connect by IP)
+	for i := 1; i <= 10; i++ {
+		leaf := model.Certificate{FingerprintSHA256: "ab", Timing: &model.ScanTiming{
+			Connect:   5 * time.Millisecond,
+			Handshake: time.Duration(i*10) * time.Millisecond,
+			RemoteIP:  "192.0.2.10",
+		}}
+		if err := svc.RecordScanResult(ctx, target.ID, []model.Certificate{leaf}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	failures := []error{
+		fmt.Errorf("dial: %w", context.DeadlineExceeded),
+		&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+		errors.New("all 2 endpoints failed: a:443: i/o timeout; b:443: i/o timeout"),
+	}
+	for _, scanErr := range failures {
+		if err := svc.RecordScanResult(ctx, target.ID, nil, scanErr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Outside the window: neither counted nor aggregated
+	_, err := conn.ExecContext(ctx, `
+        INSERT INTO target_scan_results (target_id, outcome, handshake_ms, scanned_at)
+        VALUES ($1, 'SUCCESS', 5000, NOW() - INTERVAL '3 days')
+    `, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := svc.GetTargetStats(ctx, userID, target.ID, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Scans != 13 || stats.Successes != 10 {
+		t.Fatalf("scans = %d, successes = %d, want 13 and 10", stats.Scans, stats.Successes)
+	}
+	if stats.UptimePercent == nil || math.Abs(*stats.UptimePercent-1000.0/13) > 1e-9 {
+		t.Fatalf("uptime = %v, want %.4f", stats.UptimePercent, 1000.0/13)
+	}
+
+	// percentile_cont interpolates: over 10..100, p50 = 55, p90 = 91, p95 = 95.5, p99 = 99.1
+	want := model.LatencyPercentiles{P50: 55, P90: 91, P95: 95.5, P99: 99.1}
+	got := stats.Handshake
+	if got == nil || math.Abs(got.P50-want.P50) > 1e-6 || math.Abs(got.P90-want.P90) > 1e-6 ||
+		math.Abs(got.P95-want.P95) > 1e-6 || math.Abs(got.P99-want.P99) > 1e-6 {
+		t.Fatalf("handshake percentiles = %+v, want %+v", got, want)
+	}
+	if stats.Connect == nil || stats.Connect.P50 != 5 || stats.Connect.P99 != 5 {
+		t.Fatalf("connect percentiles = %+v, want 5 ms throughout", stats.Connect)
+	}
+	if stats.DNS != nil {
+		t.Fatalf("dns percentiles = %+v, want none (no scan resolved a name)", stats.DNS)
+	}
+
+	wantClasses := map[string]int{model.ScanErrorTimeout: 2, model.ScanErrorRefused: 1}
+	if len(stats.ErrorClasses) != len(wantClasses) {
+		t.Fatalf("error classes = %v, want %v", stats.ErrorClasses, wantClasses)
+	}
+	for class, n := range wantClasses {
+		if stats.ErrorClasses[class] != n {
+			t.Fatalf("error classes = %v, want %v", stats.ErrorClasses, wantClasses)
+		}
+	}
+
+	if len(stats.Results) != 13 {
+		t.Fatalf("%d results in the series, want 13", len(stats.Results))
+	}
+	for i := 1; i < len(stats.Results); i++ {
+		if stats.Results[i].ScannedAt.Before(stats.Results[i-1].ScannedAt) {
+			t.Fatal("series is not oldest first")
+		}
+	}
+
+	// Another user's target
+	if _, err := svc.GetTargetStats(ctx, testUser(t, conn), target.ID, 24*time.Hour); err == nil {
+		t.Fatal("expected another user's target to be rejected")
+	}
+}
+
+func TestGetTargetStatsEmptyWindow(t *testing.T) {
+	conn := testDB(t)
+	userID := testUser(t, conn)
+	svc := &PostgresAgentLessTargetService{DB: conn}
+	target := testTarget(t, conn, userID, "quiet.example.test:443", "")
+
+	stats, err := svc.GetTargetStats(context.Background(), userID, target.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scans != 0 || stats.UptimePercent != nil || stats.Handshake != nil || len(stats.Results) != 0 {
+		t.Fatalf("empty window: %+v", stats)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"cert-manager-backend/internal/model"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// maxTrustStoreUpload caps how many certificates a single PEM bundle may contain.
+const maxTrustStoreUpload = 50
+
+type PostgresTrustStoreService struct {
+	DB *sql.DB
+}
+
+func NewTrustStoreService(db *sql.DB) *PostgresTrustStoreService {
+	return &PostgresTrustStoreService{DB: db}
+}
+
+// AddRoots parses a PEM bundle and stores every certificate in the user's Trust Store.
+// Certificates already present (same SHA-256 fingerprint) are skipped.
+// Returns the newly added entries.
+func (s *PostgresTrustStoreService) AddRoots(ctx context.Context, userID, name, pemBundle string) ([]model.TrustedRoot, error) {
+	// 1. Parse & Validate the whole bundle before touching the DB
+	certs, err := parsePEMBundle(pemBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+
+	// 2. Insert (Atomic: a bundle is either fully stored or not at all)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO trusted_roots (user_id, name, subject_cn, subject_org, subject_ou, issuer_cn, issuer_org, issuer_ou,
+                                   is_ca, fingerprint_sha256, valid_until, pem)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (user_id, fingerprint_sha256) DO NOTHING
+        RETURNING id, created_at
+    `
+
+	added := []model.TrustedRoot{}
+	for _, c := range certs {
+		root := toTrustedRoot(c)
+		root.Name = name
+		if root.Name == "" {
+			// Default the display name to the CA's own name
+			root.Name = root.Subject.CN
+		}
+
+		pemText := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}))
+
+		err := tx.QueryRowContext(ctx, query,
+			userID, root.Name,
+			root.Subject.CN, root.Subject.Org, root.Subject.OU,
+			root.Issuer.CN, root.Issuer.Org, root.Issuer.OU,
+			root.IsCA, root.FingerprintSHA256, root.ValidUntil, pemText,
+		).Scan(&root.ID, &root.CreatedAt)
+
+		if err == sql.ErrNoRows {
+			continue // Duplicate
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to store certificate %q: %w", root.Subject.CN, err)
+		}
+		added = append(added, root)
+	}
+
+	// 3. Re-evaluate the user's targets against the new trust anchors
+	if len(added) > 0 {
+		if err := rescheduleTargets(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// ListRoots returns the user's Trust Store (metadata only, no PEM).
+func (s *PostgresTrustStoreService) ListRoots(ctx context.Context, userID string) ([]model.TrustedRoot, error) {
+	query := `
+        SELECT id, name, subject_cn, subject_org, subject_ou, issuer_cn, issuer_org, issuer_ou,
+               is_ca, fingerprint_sha256, valid_until, created_at
+        FROM trusted_roots
+        WHERE user_id = $1
+        ORDER BY created_at DESC
+    `
+	rows, err := s.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roots := []model.TrustedRoot{}
+	for rows.Next() {
+		var r model.TrustedRoot
+		var subCN, subOrg, subOU, issCN, issOrg, issOU sql.NullString
+		if err := rows.Scan(&r.ID, &r.Name, &subCN, &subOrg, &subOU, &issCN, &issOrg, &issOU,
+			&r.IsCA, &r.FingerprintSHA256, &r.ValidUntil, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Subject = model.DN{CN: subCN.String, Org: subOrg.String, OU: subOU.String}
+		r.Issuer = model.DN{CN: issCN.String, Org: issOrg.String, OU: issOU.String}
+		roots = append(roots, r)
+	}
+	return roots, nil
+}
+
+// DeleteRoot removes a CA from the user's Trust Store and re-evaluates their targets.
+func (s *PostgresTrustStoreService) DeleteRoot(ctx context.Context, userID, rootID string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM trusted_roots
+		WHERE id = $1 AND user_id = $2
+	`, rootID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("trusted root not found or access denied")
+	}
+
+	if err := rescheduleTargets(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// customRootsPEM returns the user's Trust Store as a single PEM bundle (nil if empty).
+func customRootsPEM(ctx context.Context, db *sql.DB, userID string) ([]byte, error) {
+	var bundle sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT string_agg(pem, '') FROM trusted_roots WHERE user_id = $1
+	`, userID).Scan(&bundle)
+	if err != nil {
+		return nil, err
+	}
+	if !bundle.Valid {
+		return nil, nil
+	}
+	return []byte(bundle.String), nil
+}
+
+// rescheduleTargets marks all of the user's Cloud Monitors as due,
+// so the worker re-verifies their chains with the changed Trust Store on its next tick.
+func rescheduleTargets(ctx context.Context, tx *sql.Tx, userID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE monitored_targets
+		SET last_scanned_at = NULL
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule targets: %w", err)
+	}
+	return nil
+}
+
+// parsePEMBundle decodes every CERTIFICATE block. Other block types (e.g. keys) are rejected.
+func parsePEMBundle(bundle string) ([]*x509.Certificate, error) {
+	rest := []byte(strings.TrimSpace(bundle))
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("PEM bundle cannot be empty")
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %q: only certificates are accepted", block.Type)
+		}
+
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in bundle: %w", err)
+		}
+		certs = append(certs, c)
+
+		if len(certs) > maxTrustStoreUpload {
+			return nil, fmt.Errorf("bundle contains more than %d certificates", maxTrustStoreUpload)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+	if len(strings.TrimSpace(string(rest))) > 0 {
+		return nil, fmt.Errorf("trailing data after the last certificate")
+	}
+	return certs, nil
+}
+
+func toTrustedRoot(c *x509.Certificate) model.TrustedRoot {
+	fp := sha256.Sum256(c.Raw)
+	return model.TrustedRoot{
+		Subject: model.DN{
+			CN:  c.Subject.CommonName,
+			Org: firstOf(c.Subject.Organization),
+			OU:  firstOf(c.Subject.OrganizationalUnit),
+		},
+		Issuer: model.DN{
+			CN:  c.Issuer.CommonName,
+			Org: firstOf(c.Issuer.Organization),
+			OU:  firstOf(c.Issuer.OrganizationalUnit),
+		},
+		IsCA:              c.IsCA,
+		FingerprintSHA256: hex.EncodeToString(fp[:]),
+		ValidUntil:        c.NotAfter,
+	}
+}
+
+func firstOf(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0]
+}
@@ -1,7 +1,9 @@
 package worker
 
 import (
+	"cert-manager-backend/internal/egress"
 	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/scanner"
 	"cert-manager-backend/internal/service"
 	"context"
 	"fmt"
@@ -16,8 +18,13 @@ func StartAgentlessScanner(
 	targetSvc service.AgentLessTargetService,
 	sc service.NetworkScanner,
 	certSvc service.CertificateService,
+	authSvc service.AuthService,
+	notifiers []service.TargetHealthNotifier,
 	interval time.Duration,
 	concurrency int,
+	retries int,
+	retryBackoff time.Duration,
+	postureInterval time.Duration,
 ) {
 	// 1. Define the work logic as a reusable function
 	runScanBatch := func() {
@@ -38,6 +45,8 @@ func StartAgentlessScanner(
 
 		// Process Batch with Worker Pool
 		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var alerts []model.TargetHealthAlert
 		sem := make(chan struct{}, concurrency) // Semaphore
 
 		for _, t := range targets {
@@ -69,7 +78,7 @@ func StartAgentlessScanner(
 							updateCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 							defer cancel()
 
-							if err := targetSvc.UpdateTargetStatus(updateCtx, target.ID, "FAILED", errMsg); err != nil {
+							if _, err := targetSvc.UpdateTargetStatus(updateCtx, target.ID, "FAILED", errMsg); err != nil {
 								log.Printf("⚠️ Failed to mark target %s as FAILED after panic: %v", target.TargetURL, err)
 							}
 						}()
@@ -77,17 +86,26 @@ func StartAgentlessScanner(
 				}()
 				// -----------------------------
 
-				processTarget(ctx, target, sc, certSvc, targetSvc)
+				if alert := processTarget(ctx, target, sc, certSvc, targetSvc, retries, retryBackoff, postureInterval); alert != nil {
+					mu.Lock()
+					alerts = append(alerts, *alert)
+					mu.Unlock()
+				}
 			}(t)
 		}
 
 		wg.Wait()
 		log.Printf("☁️ Agentless Worker: Batch complete.")
+
+		// Alert owners about targets that went DOWN (or recovered), then start their cooldown
+		if len(alerts) > 0 {
+			notifyTargetHealth(ctx, alerts, targetSvc, authSvc, notifiers)
+		}
 	}
 
 	// 2. Launch the loop
 	go func() {
-		log.Printf("☁️ Agentless Scanner started. Interval: %v | Concurrency: %d", interval, concurrency)
+		log.Printf("☁️ Agentless Scanner started. Interval: %v | Concurrency: %d | Retries: %d", interval, concurrency, retries)
 
 		// A. Run IMMEDIATELY on startup (Don't wait for first tick)
 		runScanBatch()
@@ -108,12 +126,13 @@ func processTarget(
 	sc service.NetworkScanner,
 	certSvc service.CertificateService,
 	targetSvc service.AgentLessTargetService,
-) {
-	// A. Perform Scan (with 10s timeout)
-	scanCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	results, err := sc.Scan(scanCtx, t.TargetURL)
+	retries int,
+	retryBackoff time.Duration,
+	postureInterval time.Duration,
+) *model.TargetHealthAlert {
+	// A. Perform Scan (with retries, so a dropped packet doesn't fail the target)
+	var report scanner.ScanReport
+	results, err := scanWithRetry(scanner.WithScanReport(ctx, &report), t, sc, retries, retryBackoff)
 
 	status := "SUCCESS"
 	errStr := ""
@@ -122,12 +141,17 @@ func processTarget(
 	if err != nil {
 		status = "FAILED"
 		errStr = err.Error()
-		log.Printf("❌ Failed to scan %s: %v", t.TargetURL, err)
+		if egress.IsBlocked(err) {
+			// The target (or what its DNS now points to) is off-limits: the error keeps the EGRESS_BLOCKED code
+			log.Printf("🚫 Egress policy blocked scan of %s: %v", t.TargetURL, err)
+		} else {
+			log.Printf("❌ Failed to scan %s: %v", t.TargetURL, err)
+		}
 	} else {
-		// --- DEFENSIVE FIX: Ensure SourceType is CLOUD ---
+		// --- DEFENSIVE FIX: Ensure SourceType is CLOUD (METADATA for SAML / JWKS documents) ---
 		// Even if the scanner sets it, we enforce it here to be safe.
 		for i := range results {
-			results[i].SourceType = "CLOUD"
+			results[i].SourceType = service.SourceTypeFor(t.Kind)
 		}
 
 		// C. Ingest if successful
@@ -135,10 +159,129 @@ func processTarget(
 			log.Printf("⚠️ Scanned %s but ingest failed: %v", t.TargetURL, ingestErr)
 			// Status remains SUCCESS because the scan worked, but we log the system error
 		}
+
+		// Flag load-balanced targets whose nodes serve different certificates (or are gone / unreachable), and mTLS endpoints
+		if detailErr := targetSvc.RecordScanDetails(ctx, t.ID, results, report.Unreachable); detailErr != nil {
+			log.Printf("⚠️ Failed to record scan details for %s: %v", t.TargetURL, detailErr)
+		}
+
+		// D. Posture Audit (Versions & Cipher Suites). TLS endpoints only: documents have no posture of their own.
+		// Dozens of handshakes, so only when the last audit is older than postureInterval (or there is none yet).
+		// Uses its own, longer timeout: every version/suite probe is a separate handshake.
+		if !t.Kind.IsDocument() && postureDue(t, postureInterval, time.Now()) {
+			postureCtx, postureCancel := context.WithTimeout(ctx, 2*time.Minute)
+			posture, postureErr := sc.AuditPosture(postureCtx, t)
+			postureCancel()
+
+			if postureErr != nil {
+				log.Printf("⚠️ Posture audit failed for %s: %v", t.TargetURL, postureErr)
+			} else if saveErr := targetSvc.SavePosture(ctx, posture); saveErr != nil {
+				log.Printf("⚠️ Failed to save posture for %s: %v", t.TargetURL, saveErr)
+			}
+		}
+	}
+
+	// E. Append to the Scan History (Availability & Latency)
+	if historyErr := targetSvc.RecordScanResult(ctx, t.ID, results, err); historyErr != nil {
+		log.Printf("⚠️ Failed to record scan history for %s: %v", t.TargetURL, historyErr)
 	}
 
-	// D. Update Target State
-	if updateErr := targetSvc.UpdateTargetStatus(ctx, t.ID, status, errStr); updateErr != nil {
+	// F. Update Target State (Failure Counter & Health)
+	change, updateErr := targetSvc.UpdateTargetStatus(ctx, t.ID, status, errStr)
+	if updateErr != nil {
 		log.Printf("⚠️ Failed to update status for %s: %v", t.ID, updateErr)
+		return nil
+	}
+	if change.Current != change.Previous {
+		switch change.Current {
+		case model.TargetDown:
+			log.Printf("🔴 Target %s is DOWN after %d consecutive failed scans", t.TargetURL, change.ConsecutiveFailures)
+		case model.TargetDegraded:
+			log.Printf("🟠 Target %s is DEGRADED (%d consecutive failed scans)", t.TargetURL, change.ConsecutiveFailures)
+		case model.TargetHealthy:
+			log.Printf("🟢 Target %s recovered (was %s)", t.TargetURL, change.Previous)
+		}
+	}
+	if change.RetryAt != nil {
+		log.Printf("🔁 Re-scanning %s at %s", t.TargetURL, change.RetryAt.Format(time.RFC3339))
+	}
+
+	// G. DOWN (outside its cooldown) or recovered from an alerted DOWN: the owner is told
+	if !change.AlertDue {
+		return nil
+	}
+	return &model.TargetHealthAlert{
+		TargetID:            t.ID,
+		UserID:              t.UserID,
+		TargetURL:           t.TargetURL,
+		Health:              change.Current,
+		ConsecutiveFailures: change.ConsecutiveFailures,
+		LastError:           errStr,
+	}
+}
+
+// postureDue reports whether t has no posture audit yet, or one older than interval.
+func postureDue(t model.Target, interval time.Duration, now time.Time) bool {
+	return t.PostureAuditedAt == nil || now.Sub(*t.PostureAuditedAt) >= interval
+}
+
+// notifyTargetHealth sends the batch's health alerts to every notifier, then records them for the cooldown.
+func notifyTargetHealth(
+	ctx context.Context,
+	alerts []model.TargetHealthAlert,
+	targetSvc service.AgentLessTargetService,
+	authSvc service.AuthService,
+	notifiers []service.TargetHealthNotifier,
+) {
+	userIDs := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		userIDs = append(userIDs, a.UserID)
+	}
+	users, err := authSvc.GetUsersByIDs(ctx, userIDs)
+	if err != nil {
+		log.Printf("⚠️ Agentless Worker: Failed to fetch alert owners: %v", err)
+		return
+	}
+
+	for _, n := range notifiers {
+		if err := n.NotifyTargetHealth(ctx, alerts, users); err != nil {
+			log.Printf("⚠️ Agentless Worker: Target health notifier failed: %v", err)
+		}
+	}
+
+	for _, a := range alerts {
+		if err := targetSvc.MarkHealthAlerted(ctx, a.TargetID, a.Health); err != nil {
+			log.Printf("⚠️ Failed to record health alert for %s: %v", a.TargetURL, err)
+		}
+	}
+}
+
+// scanWithRetry scans t up to 1+retries times, waiting retryBackoff, 2x retryBackoff... between attempts.
+// Egress policy rejections are final: retrying cannot change the verdict.
+func scanWithRetry(
+	ctx context.Context,
+	t model.Target,
+	sc service.NetworkScanner,
+	retries int,
+	retryBackoff time.Duration,
+) ([]model.Certificate, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		// Each attempt gets its own 10s timeout
+		scanCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		results, err := sc.Scan(scanCtx, t)
+		cancel()
+
+		if err == nil || attempt >= retries || egress.IsBlocked(err) {
+			return results, err
+		}
+
+		log.Printf("🔁 Scan of %s failed (attempt %d/%d), retrying in %v: %v", t.TargetURL, attempt+1, retries+1, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
 	}
 }
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"cert-manager-backend/internal/egress"
+	"cert-manager-backend/internal/model"
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeScanner fails the first len(errs) scans with errs, in order, then succeeds. It records when each scan started.
+type fakeScanner struct {
+	mu       sync.Mutex
+	errs     []error
+	attempts []time.Time
+	deadline []bool // Whether each attempt ran under a deadline
+}
+
+func (f *fakeScanner) Scan(ctx context.Context, target model.Target) ([]model.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := len(f.attempts)
+	f.attempts = append(f.attempts, time.Now())
+	_, ok := ctx.Deadline()
+	f.deadline = append(f.deadline, ok)
+	if n < len(f.errs) {
+		return nil, f.errs[n]
+	}
+	return []model.Certificate{{SourceUID: target.TargetURL}}, nil
+}
+
+func (f *fakeScanner) AuditPosture(ctx context.Context, target model.Target) (*model.TLSPosture, error) {
+	return nil, errors.New("not audited")
+}
+
+func TestScanWithRetry(t *testing.T) {
+	const backoff = 20 * time.Millisecond
+	timeout := errors.New("i/o timeout")
+	blocked := &egress.BlockedError{Host: "10.0.0.5", IP: netip.MustParseAddr("10.0.0.5")}
+
+	tests := []struct {
+		name     string
+		errs     []error
+		retries  int
+		wantErr  error
+		wantGaps []time.Duration // Minimum wait before each retry
+	}{
+		{name: "first attempt succeeds", retries: 3},
+		{name: "succeeds on the third attempt", errs: []error{timeout, timeout}, retries: 3, wantGaps: []time.Duration{backoff, 2 * backoff}},
+		{name: "retries exhausted", errs: []error{timeout, timeout, timeout, timeout}, retries: 3, wantErr: timeout,
+			wantGaps: []time.Duration{backoff, 2 * backoff, 4 * backoff}},
+		{name: "no retries configured", errs: []error{timeout}, wantErr: timeout},
+		{name: "egress block is final", errs: []error{blocked}, retries: 3, wantErr: blocked},
+		{name: "egress block after a timeout", errs: []error{timeout, blocked}, retries: 3, wantErr: blocked, wantGaps: []time.Duration{backoff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := &fakeScanner{errs: tt.errs}
+			certs, err := scanWithRetry(context.Background(), model.Target{TargetURL: "retry.example.test:443"}, sc, tt.retries, backoff)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && len(certs) != 1 {
+				t.Fatalf("got %d certificates, want the successful scan's", len(certs))
+			}
+
+			if len(sc.attempts) != len(tt.wantGaps)+1 {
+				t.Fatalf("%d attempts, want %d", len(sc.attempts), len(tt.wantGaps)+1)
+			}
+			for i, want := range tt.wantGaps {
+				gap := sc.attempts[i+1].Sub(sc.attempts[i])
+				// The backoff doubles: each wait is at least its step (the upper bound only catches runaway waits)
+				if gap < want || gap >= want+time.Second {
+					t.Fatalf("wait before retry %d = %v, want %v (doubling from %v)", i+1, gap, want, backoff)
+				}
+			}
+			for i, ok := range sc.deadline {
+				if !ok {
+					t.Fatalf("attempt %d ran without its own timeout", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestScanWithRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sc := &fakeScanner{errs: []error{errors.New("i/o timeout")}}
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := scanWithRetry(ctx, model.Target{TargetURL: "retry.example.test:443"}, sc, 3, time.Hour)
+	if err == nil {
+		t.Fatal("expected the last scan error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("cancelled retry still waited %v", elapsed)
+	}
+	if len(sc.attempts) != 1 {
+		t.Fatalf("%d attempts after cancel, want 1", len(sc.attempts))
+	}
+}
+
+func TestPostureDue(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(-d)
+		return &ts
+	}
+	const interval = 7 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		auditedAt *time.Time
+		want      bool
+	}{
+		{name: "never audited", want: true},
+		{name: "audited yesterday", auditedAt: at(24 * time.Hour)},
+		{name: "audited just under the interval", auditedAt: at(interval - time.Minute)},
+		{name: "audited one interval ago", auditedAt: at(interval), want: true},
+		{name: "audited long ago", auditedAt: at(30 * 24 * time.Hour), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := model.Target{PostureAuditedAt: tt.auditedAt}
+			if got := postureDue(target, interval, now); got != tt.want {
+				t.Fatalf("postureDue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,16 +9,22 @@ import (
 )
 
 // NewAlerterJob returns a function that performs the notification logic ONCE.
+// Domain registrations expiring within the same window are alerted on afterwards (see alertDomains).
 func NewAlerterJob(
 	certSvc service.CertificateService,
+	domainSvc service.DomainService,
 	authSvc service.AuthService,
 	notifiers []service.Notifier,
+	domainNotifiers []service.DomainNotifier,
 	expiryWindow time.Duration,
 ) func() {
 	return func() {
 		log.Println("🔔 Alerter: Starting check...")
 		ctx := context.Background()
 
+		// Domains don't depend on the certificate phase: a cert failure must not silence them
+		defer alertDomains(ctx, domainSvc, authSvc, domainNotifiers, expiryWindow)
+
 		// --- PHASE 1: Fetch Data (The "What") ---
 		certs, err := certSvc.GetExpiringCertificates(ctx, expiryWindow)
 		if err != nil {
@@ -58,6 +64,58 @@ func NewAlerterJob(
 	}
 }
 
+// alertDomains notifies owners of domain registrations expiring within the window (or lapsed),
+// at most once a day per domain.
+func alertDomains(
+	ctx context.Context,
+	domainSvc service.DomainService,
+	authSvc service.AuthService,
+	notifiers []service.DomainNotifier,
+	expiryWindow time.Duration,
+) {
+	domains, err := domainSvc.GetExpiringDomains(ctx, expiryWindow, model.FrequencyDaily)
+	if err != nil {
+		log.Printf("⚠️ Alerter Error: Failed to fetch expiring domains: %v", err)
+		return
+	}
+	if len(domains) == 0 {
+		log.Println("✅ Alerter: No expiring domains found.")
+		return
+	}
+
+	seen := make(map[string]bool)
+	var ownerIDs []string
+	for _, d := range domains {
+		if !seen[d.UserID] {
+			seen[d.UserID] = true
+			ownerIDs = append(ownerIDs, d.UserID)
+		}
+	}
+
+	userMap, err := authSvc.GetUsersByIDs(ctx, ownerIDs)
+	if err != nil {
+		log.Printf("⚠️ Alerter Error: Failed to fetch user context: %v", err)
+		return
+	}
+
+	log.Printf("🔔 Alerter: Processing %d domains for %d users across %d channels.",
+		len(domains), len(ownerIDs), len(notifiers))
+
+	for _, n := range notifiers {
+		if err := n.NotifyDomains(ctx, domains, userMap); err != nil {
+			log.Printf("⚠️ Alerter: A notifier failed: %v", err)
+		}
+	}
+
+	ids := make([]string, len(domains))
+	for i, d := range domains {
+		ids[i] = d.ID
+	}
+	if err := domainSvc.MarkAlerted(ctx, ids); err != nil {
+		log.Printf("⚠️ Alerter: Failed to record domain alerts: %v", err)
+	}
+}
+
 // Helper to extract unique IDs from the certificate list
 func getUniqueOwnerIDs(certs []model.CertResponse) []string {
 	seen := make(map[string]bool)
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/revocation"
+	"cert-manager-backend/internal/service"
+	"context"
+	"crypto/x509"
+	"log"
+)
+
+// NewCRLCheckJob returns a function that checks every stored certificate against its CRLs ONCE.
+// It covers both agent-reported and cloud-scanned certs, since it works on the stored definitions.
+// The CRLCache is shared across runs, so each CRL is only downloaded again after its nextUpdate.
+func NewCRLCheckJob(
+	certSvc service.CertificateService,
+	crlCache *revocation.CRLCache,
+) func() {
+	return func() {
+		log.Println("📜 CRL Check: Starting...")
+		ctx := context.Background()
+
+		checks, err := certSvc.GetRevocationCandidates(ctx)
+		if err != nil {
+			log.Printf("⚠️ CRL Check Error: Failed to fetch candidates: %v", err)
+			return
+		}
+
+		var revoked, failed int
+		for _, check := range checks {
+			issuer, err := x509.ParseCertificate(check.IssuerDER)
+			if err != nil {
+				failed++
+				log.Printf("⚠️ CRL Check: Serial %s (%s): invalid issuer certificate: %v", check.Serial, check.Issuer.CN, err)
+				continue
+			}
+
+			res, err := crlCache.Check(ctx, check.Serial, issuer, check.CRLURLs)
+			if err != nil {
+				failed++
+				log.Printf("⚠️ CRL Check: Serial %s (%s): %v", check.Serial, check.Issuer.CN, err)
+				continue
+			}
+			if res == nil {
+				continue // No HTTP(S) distribution point
+			}
+
+			check.Status = res.Status
+			check.Reason = res.Reason
+			check.RevokedAt = res.RevokedAt
+			check.Source = res.Source
+
+			if err := certSvc.UpdateRevocation(ctx, check); err != nil {
+				log.Printf("⚠️ CRL Check: Failed to save result for %s: %v", check.CertificateID, err)
+				continue
+			}
+			if res.Status == model.RevocationRevoked {
+				revoked++
+			}
+		}
+
+		log.Printf("📜 CRL Check: Complete. Checked=%d Revoked=%d Failed=%d", len(checks), revoked, failed)
+	}
+}
@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/service"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// discoveryProgressInterval is how often a running job reports progress (and checks for cancellation).
+const discoveryProgressInterval = 3 * time.Second
+
+// StartDiscoveryWorker launches the poller that runs queued Discovery Jobs, one job at a time.
+func StartDiscoveryWorker(
+	discoverySvc service.DiscoveryService,
+	sc service.NetworkScanner,
+	interval time.Duration,
+	concurrency int,
+	probeTimeout time.Duration,
+) {
+	go func() {
+		log.Printf("🔭 Discovery Worker started. Interval: %v | Concurrency: %d", interval, concurrency)
+
+		// Jobs still RUNNING belong to a previous process: nobody will finish them
+		if n, err := discoverySvc.FailInterruptedJobs(context.Background()); err != nil {
+			log.Printf("⚠️ Discovery Worker: Failed to clean up interrupted jobs: %v", err)
+		} else if n > 0 {
+			log.Printf("🔭 Discovery Worker: Marked %d interrupted jobs as FAILED", n)
+		}
+
+		// Drain the queue: run immediately, then on schedule
+		runQueued := func() {
+			for {
+				job, err := discoverySvc.ClaimJob(context.Background())
+				if err != nil {
+					log.Printf("⚠️ Discovery Worker: Failed to claim job: %v", err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				runDiscoveryJob(*job, discoverySvc, sc, concurrency, probeTimeout)
+			}
+		}
+		runQueued()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			runQueued()
+		}
+	}()
+}
+
+func runDiscoveryJob(
+	job model.DiscoveryJob,
+	discoverySvc service.DiscoveryService,
+	sc service.NetworkScanner,
+	concurrency int,
+	probeTimeout time.Duration,
+) {
+	log.Printf("🔭 Discovery Worker: Job %s started (%d addresses x %d ports)", job.ID, job.AddressCount, len(job.Ports))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var done atomic.Int64
+	var cancelled atomic.Bool
+	finalStatus := model.DiscoveryCompleted
+	finalErr := ""
+
+	// A. Progress Reporter (also detects cancellation)
+	reporterDone := make(chan struct{})
+	go func() {
+		defer close(reporterDone)
+		ticker := time.NewTicker(discoveryProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				status, err := discoverySvc.UpdateProgress(ctx, job.ID, int(done.Load()))
+				if err != nil {
+					log.Printf("⚠️ Discovery Worker: Failed to update progress of %s: %v", job.ID, err)
+					continue
+				}
+				if status == model.DiscoveryCancelled {
+					log.Printf("🛑 Discovery Worker: Job %s cancelled", job.ID)
+					cancelled.Store(true)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	// B. Probe Pool
+	probes := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range probes {
+				probeEndpoint(ctx, job, addr, discoverySvc, sc, probeTimeout)
+				done.Add(1)
+			}
+		}()
+	}
+
+	// C. Feed every address x port (stops as soon as the job is cancelled)
+	err := service.DiscoveryAddresses(job.CIDRs, func(ip netip.Addr) bool {
+		for _, port := range job.Ports {
+			select {
+			case probes <- net.JoinHostPort(ip.String(), strconv.Itoa(port)):
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	})
+	close(probes)
+	wg.Wait()
+
+	cancel()
+	<-reporterDone
+
+	if cancelled.Load() {
+		finalStatus = model.DiscoveryCancelled
+	} else if err != nil {
+		finalStatus, finalErr = model.DiscoveryFailed, err.Error()
+	}
+
+	// D. Final State (a CANCELLED job keeps its status)
+	finishCtx, finishCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer finishCancel()
+	if err := discoverySvc.FinishJob(finishCtx, job.ID, int(done.Load()), finalStatus, finalErr); err != nil {
+		log.Printf("⚠️ Discovery Worker: Failed to finish job %s: %v", job.ID, err)
+	}
+	log.Printf("🔭 Discovery Worker: Job %s finished (%d/%d probes)", job.ID, done.Load(), job.TotalProbes)
+}
+
+// probeEndpoint attempts a handshake with addr and records it if one completes.
+// Unreachable addresses are the norm in a sweep and are not logged.
+func probeEndpoint(
+	ctx context.Context,
+	job model.DiscoveryJob,
+	addr string,
+	discoverySvc service.DiscoveryService,
+	sc service.NetworkScanner,
+	probeTimeout time.Duration,
+) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("🔥 CRITICAL PANIC in Discovery Worker [Endpoint: %s]: %v", addr, r)
+			fmt.Println(string(debug.Stack()))
+		}
+	}()
+
+	protocol := service.DefaultProtocol(addr)
+
+	scanCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	certs, err := sc.Scan(scanCtx, model.Target{TargetURL: addr, Protocol: protocol})
+	cancel()
+	if err != nil || len(certs) == 0 {
+		return
+	}
+
+	if err := discoverySvc.RecordEndpoint(ctx, job, addr, protocol, certs); err != nil {
+		log.Printf("⚠️ Discovery Worker: Found %s but failed to record it: %v", addr, err)
+		return
+	}
+	log.Printf("🔭 Discovery Worker: Found TLS endpoint %s (job %s)", addr, job.ID)
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"cert-manager-backend/internal/service"
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// domainBatchSize caps the lookups per run: registries rate-limit RDAP and WHOIS clients.
+const domainBatchSize = 50
+
+// StartDomainMonitor launches the poller that derives each user's registrable domains and looks up
+// their registration expiry (RDAP, falling back to WHOIS). Alerts go out with the daily Alerter.
+func StartDomainMonitor(
+	domainSvc service.DomainService,
+	interval time.Duration,
+	concurrency int,
+) {
+	// 1. Define the work logic as a reusable function
+	runCheckBatch := func() {
+		ctx := context.Background()
+
+		// A. Derive Domains from Targets & Certificates
+		added, removed, err := domainSvc.SyncDomains(ctx)
+		if err != nil {
+			log.Printf("⚠️ Domain Monitor: Failed to sync domains: %v", err)
+			return
+		}
+		if added > 0 || removed > 0 {
+			log.Printf("🌍 Domain Monitor: %d domains added, %d removed", added, removed)
+		}
+
+		domains, err := domainSvc.GetDueDomains(ctx, domainBatchSize)
+		if err != nil {
+			log.Printf("⚠️ Domain Monitor: Failed to fetch domains: %v", err)
+			return
+		}
+		if len(domains) == 0 {
+			return
+		}
+
+		// B. Look Up with a Worker Pool
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+
+		for _, d := range domains {
+			wg.Add(1)
+			sem <- struct{}{}
+
+			go func(domain string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer func() {
+					if r := recover(); r != nil {
+						log.Printf("🔥 CRITICAL PANIC in Domain Monitor [Domain: %s]: %v", domain, r)
+						fmt.Println(string(debug.Stack()))
+					}
+				}()
+
+				lookup, err := domainSvc.CheckDomain(ctx, domain)
+				if err != nil {
+					log.Printf("⚠️ Domain Monitor: Lookup of %s failed: %v", domain, err)
+					return
+				}
+				if lookup.ExpiresAt == nil {
+					log.Printf("🌍 Domain Monitor: %s publishes no expiry date (%s)", domain, lookup.Source)
+				}
+			}(d)
+		}
+		wg.Wait()
+		log.Printf("✅ Domain Monitor: Checked %d domains.", len(domains))
+	}
+
+	// 2. Launch the loop
+	go func() {
+		log.Printf("🌍 Domain Monitor started. Interval: %v | Concurrency: %d", interval, concurrency)
+
+		runCheckBatch()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			runCheckBatch()
+		}
+	}()
+}
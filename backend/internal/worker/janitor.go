@@ -12,8 +12,11 @@ import (
 func NewJanitorJob(
 	agentSvc service.AgentService,
 	certSvc service.CertificateService,
+	targetSvc service.AgentLessTargetService,
 	agentTTL time.Duration,
 	missingCertTTL time.Duration,
+	scanHistoryRetention time.Duration,
+	replacementRetention time.Duration,
 ) func() {
 	return func() {
 		log.Println("🧹 Janitor: Starting scheduled cleanup...")
@@ -35,7 +38,15 @@ func NewJanitorJob(
 			log.Printf("🧹 Janitor: Removed %d missing certificate links", deletedInstances)
 		}
 
-		// 3. Cleanup Orphaned Definitions
+		// 3. Prune Replacement History (Old definitions are only kept while referenced by it)
+		deletedReplacements, err := certSvc.PruneReplacements(ctx, replacementRetention)
+		if err != nil {
+			log.Printf("⚠️ Janitor Error (Replacements): %v", err)
+		} else if deletedReplacements > 0 {
+			log.Printf("🧹 Janitor: Removed %d certificate replacement entries", deletedReplacements)
+		}
+
+		// 4. Cleanup Orphaned Definitions
 		deletedCerts, err := certSvc.CleanupOrphanedCerts(ctx)
 		if err != nil {
 			log.Printf("⚠️ Janitor Error (Orphans): %v", err)
@@ -43,6 +54,14 @@ func NewJanitorJob(
 			log.Printf("🧹 Janitor: Removed %d orphaned certificate definitions", deletedCerts)
 		}
 
+		// 5. Prune Scan History
+		deletedScans, err := targetSvc.PruneScanHistory(ctx, scanHistoryRetention)
+		if err != nil {
+			log.Printf("⚠️ Janitor Error (Scan History): %v", err)
+		} else if deletedScans > 0 {
+			log.Printf("🧹 Janitor: Removed %d scan history entries", deletedScans)
+		}
+
 		log.Println("🧹 Janitor: Cleanup complete.")
 	}
 }
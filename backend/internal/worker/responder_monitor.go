@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"cert-manager-backend/internal/model"
+	"cert-manager-backend/internal/service"
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// StartResponderMonitor launches the poller that checks CRL / OCSP endpoints (Responder Targets)
+// and alerts owners when one turns stale or stops answering.
+func StartResponderMonitor(
+	responderSvc service.ResponderService,
+	authSvc service.AuthService,
+	notifiers []service.ResponderNotifier,
+	interval time.Duration,
+	concurrency int,
+) {
+	// 1. Define the work logic as a reusable function
+	runCheckBatch := func() {
+		ctx := context.Background()
+
+		targets, err := responderSvc.GetDueTargets(ctx)
+		if err != nil {
+			log.Printf("⚠️ Responder Monitor: Failed to fetch targets: %v", err)
+			return
+		}
+		if len(targets) == 0 {
+			return
+		}
+
+		// A. Check with a Worker Pool
+		var mu sync.Mutex
+		var alerts []model.ResponderTarget
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+
+		for _, t := range targets {
+			wg.Add(1)
+			sem <- struct{}{}
+
+			go func(target model.ResponderTarget) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer func() {
+					if r := recover(); r != nil {
+						log.Printf("🔥 CRITICAL PANIC in Responder Monitor [Target: %s]: %v", target.URL, r)
+						fmt.Println(string(debug.Stack()))
+					}
+				}()
+
+				updated, alertDue, err := responderSvc.CheckTarget(ctx, target)
+				if updated == nil {
+					log.Printf("⚠️ Responder Monitor: Failed to check %s: %v", target.URL, err)
+					return
+				}
+				if updated.Health != target.Health {
+					log.Printf("📜 Responder Monitor: %s %s is now %s %v", target.Kind, target.URL, updated.Health, updated.Problems)
+				}
+				if alertDue {
+					mu.Lock()
+					alerts = append(alerts, *updated)
+					mu.Unlock()
+				}
+			}(t)
+		}
+		wg.Wait()
+
+		if len(alerts) == 0 {
+			return
+		}
+
+		// B. Resolve Owners & Notify
+		userMap, err := authSvc.GetUsersByIDs(ctx, responderOwnerIDs(alerts))
+		if err != nil {
+			log.Printf("⚠️ Responder Monitor: Failed to fetch user context: %v", err)
+			return
+		}
+
+		log.Printf("🔔 Responder Monitor: Alerting on %d endpoints across %d channels.", len(alerts), len(notifiers))
+		for _, n := range notifiers {
+			if err := n.NotifyResponders(ctx, alerts, userMap); err != nil {
+				log.Printf("⚠️ Responder Monitor: A notifier failed: %v", err)
+			}
+		}
+
+		// C. Start the Cooldown
+		ids := make([]string, len(alerts))
+		for i, a := range alerts {
+			ids[i] = a.ID
+		}
+		if err := responderSvc.MarkAlerted(ctx, ids); err != nil {
+			log.Printf("⚠️ Responder Monitor: Failed to record alerts: %v", err)
+		}
+	}
+
+	// 2. Launch the loop
+	go func() {
+		log.Printf("📜 Responder Monitor started. Interval: %v | Concurrency: %d", interval, concurrency)
+
+		runCheckBatch()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			runCheckBatch()
+		}
+	}()
+}
+
+func responderOwnerIDs(targets []model.ResponderTarget) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, t := range targets {
+		if !seen[t.UserID] {
+			seen[t.UserID] = true
+			ids = append(ids, t.UserID)
+		}
+	}
+	return ids
+}
@@ -1,8 +1,11 @@
 import React, { useState } from 'react';
 import { Copy, Check, AlertTriangle, X, Terminal, FileCode } from 'lucide-react';
 
-export default function ApiKeyModal({ isOpen, onClose, apiKey }) {
+// Shows how to connect a server: the install command for a one-time enrollment token,
+// and/or a freshly created API key.
+export default function ApiKeyModal({ isOpen, onClose, enrollment, apiKey }) {
   const [copiedCommand, setCopiedCommand] = useState(false);
+  const [copiedEnroll, setCopiedEnroll] = useState(false);
   const [copiedKey, setCopiedKey] = useState(false);
   const [tool, setTool] = useState('curl'); // 'curl' | 'wget'
 
@@ -12,20 +15,32 @@ export default function ApiKeyModal({ isOpen, onClose, apiKey }) {
   const isLocal = window.location.hostname === 'localhost' || window.location.hostname === '127.0.0.1';
   const backendOrigin = isLocal ? 'http://localhost:8080' : window.location.origin;
   
+  const token = enrollment?.enrollment_token;
+  const expiresAt = enrollment?.expires_at ? new Date(enrollment.expires_at).toLocaleString() : '';
+
   // Define Commands
   const commands = {
-    curl: `curl -sL ${backendOrigin}/api/agent/install | sudo bash -s -- -k ${apiKey}`,
-    wget: `wget -qO- ${backendOrigin}/api/agent/install | sudo bash -s -- -k ${apiKey}`
+    curl: `curl -sL ${backendOrigin}/api/agent/install | sudo bash -s -- -t ${token}`,
+    wget: `wget -qO- ${backendOrigin}/api/agent/install | sudo bash -s -- -t ${token}`
   };
 
   const activeCommand = commands[tool];
 
+  // Manual / Docker enrollment: exchange the token for the agent's ID & secret
+  const enrollCommand = `curl -sX POST ${backendOrigin}/api/agents/enroll -H "Content-Type: application/json" -d '{"enrollment_token":"${token}","hostname":"my-server"}'`;
+
   const handleCopyCommand = () => {
     navigator.clipboard.writeText(activeCommand);
     setCopiedCommand(true);
     setTimeout(() => setCopiedCommand(false), 2000);
   };
 
+  const handleCopyEnroll = () => {
+    navigator.clipboard.writeText(enrollCommand);
+    setCopiedEnroll(true);
+    setTimeout(() => setCopiedEnroll(false), 2000);
+  };
+
   const handleCopyKey = () => {
     navigator.clipboard.writeText(apiKey);
     setCopiedKey(true);
@@ -38,7 +53,7 @@ export default function ApiKeyModal({ isOpen, onClose, apiKey }) {
         
         {/* Header */}
         <div className="bg-slate-50 px-6 py-4 border-b border-slate-100 flex justify-between items-center">
-          <h3 className="font-semibold text-slate-800 text-lg">Connect Your Server</h3>
+          <h3 className="font-semibold text-slate-800 text-lg">{token ? 'Connect Your Server' : 'New API Key'}</h3>
           <button onClick={onClose} className="text-slate-400 hover:text-slate-600 transition-colors">
             <X className="w-5 h-5" />
           </button>
@@ -51,14 +66,17 @@ export default function ApiKeyModal({ isOpen, onClose, apiKey }) {
           <div className="bg-yellow-50 border border-yellow-200 rounded-lg p-4 flex gap-3">
             <AlertTriangle className="w-5 h-5 text-yellow-600 flex-shrink-0" />
             <div className="text-sm text-yellow-800">
-              <p className="font-medium">Save this key immediately!</p>
+              <p className="font-medium">{token ? 'This enrollment token works once' : 'Save this key immediately!'}</p>
               <p className="mt-1 opacity-90">
-                We only show this once. Once you close this window, the key is gone forever.
+                {token
+                  ? `Use it to install one agent${expiresAt ? ` before ${expiresAt}` : ''}. Each agent gets its own credential; create a new token for every server.`
+                  : 'We only show this once. Once you close this window, the key is gone forever.'}
               </p>
             </div>
           </div>
 
           {/* Section 1: Auto-Install Command */}
+          {token && (
           <div>
             <div className="flex items-center justify-between mb-2">
               <label className="flex items-center gap-2 text-sm font-medium text-slate-700">
@@ -91,11 +109,11 @@ export default function ApiKeyModal({ isOpen, onClose, apiKey }) {
               <div className="bg-slate-900 text-slate-300 font-mono text-sm p-4 rounded-lg break-all border border-slate-800 leading-relaxed pr-12">
                 {tool === 'curl' ? (
                   <>
-                    <span className="text-yellow-400">curl</span> -sL {backendOrigin}/api/agent/install | <span className="text-yellow-400">sudo bash</span> -s -- -k <span className="text-green-400">{apiKey}</span>
+                    <span className="text-yellow-400">curl</span> -sL {backendOrigin}/api/agent/install | <span className="text-yellow-400">sudo bash</span> -s -- -t <span className="text-green-400">{token}</span>
                   </>
                 ) : (
                   <>
-                    <span className="text-yellow-400">wget</span> -qO- {backendOrigin}/api/agent/install | <span className="text-yellow-400">sudo bash</span> -s -- -k <span className="text-green-400">{apiKey}</span>
+                    <span className="text-yellow-400">wget</span> -qO- {backendOrigin}/api/agent/install | <span className="text-yellow-400">sudo bash</span> -s -- -t <span className="text-green-400">{token}</span>
                   </>
                 )}
               </div>
@@ -108,14 +126,44 @@ export default function ApiKeyModal({ isOpen, onClose, apiKey }) {
               </button>
             </div>
             <p className="text-xs text-slate-500 mt-2">
-              Run this on your server to download, configure, and start the agent automatically.
+              Run this on your server to download, enroll, configure, and start the agent automatically.
+            </p>
+          </div>
+          )}
+
+          {/* Section 2: Manual / Docker Enrollment */}
+          {token && (
+          <div>
+            <div className="flex items-center gap-2 mb-2">
+              <FileCode className="w-4 h-4 text-slate-500" />
+              <label className="text-sm font-medium text-slate-700">
+                Manual / Docker Enrollment
+              </label>
+            </div>
+            <div className="relative">
+              <div className="bg-slate-900 text-slate-300 font-mono text-sm p-4 rounded-lg break-all border border-slate-800 leading-relaxed pr-12">
+                {enrollCommand}
+              </div>
+              <button
+                onClick={handleCopyEnroll}
+                className="absolute top-2 right-2 p-2 bg-white/10 hover:bg-white/20 text-white rounded-md transition-colors backdrop-blur-sm"
+                title="Copy Command"
+              >
+                {copiedEnroll ? <Check className="w-4 h-4 text-green-400" /> : <Copy className="w-4 h-4" />}
+              </button>
+            </div>
+            <p className="text-xs text-slate-500 mt-2">
+              Instead of the installer: put the returned <code>agent_id</code> and <code>agent_secret</code> into
+              {' '}<code>config.yaml</code> as <code>agent_id</code> and <code>api_key</code>.
             </p>
           </div>
+          )}
 
           {/* Divider */}
-          <div className="border-t border-slate-100"></div>
+          {token && apiKey && <div className="border-t border-slate-100"></div>}
 
-          {/* Section 2: Manual Key */}
+          {/* Section 3: Raw Key */}
+          {apiKey && (
           <div>
             <div className="flex items-center gap-2 mb-2">
               <FileCode className="w-4 h-4 text-slate-500" />
@@ -136,9 +184,10 @@ export default function ApiKeyModal({ isOpen, onClose, apiKey }) {
               </button>
             </div>
             <p className="text-xs text-slate-500 mt-2">
-              Use this if you are configuring the agent manually via <code>config.yaml</code>.
+              Send it as <code>Authorization: Bearer &lt;key&gt;</code> for read-only API access. Agents enroll with a token instead.
             </p>
           </div>
+          )}
 
         </div>
 
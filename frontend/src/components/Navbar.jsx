@@ -79,16 +79,16 @@ export default function Navbar({ user, logout, onRotate, isGenerating }) {
           
           <div className="h-6 w-px bg-slate-200 mx-1"></div> {/* Vertical Divider */}
 
-          {/* Rotate Button */}
-          {user?.has_api_key && onRotate && (
+          {/* New API Key Button */}
+          {onRotate && (
             <button 
               onClick={onRotate}
               disabled={isGenerating}
               className="hidden md:flex items-center gap-2 px-3 py-1.5 text-xs font-medium text-slate-600 border border-slate-300 rounded hover:bg-slate-50 hover:text-red-600 hover:border-red-200 transition-colors"
-              title="Regenerate API Key"
+              title="Create a new API Key"
             >
               <Key className="w-3 h-3" />
-              {isGenerating ? "Rotating..." : "Rotate Key"}
+              {isGenerating ? "Creating..." : "New API Key"}
             </button>
           )}
 
@@ -47,9 +47,10 @@ export const AuthProvider = ({ children }) => {
     delete api.defaults.headers.common['Authorization'];
   };
 
-  // 5. Generate API Key Action
+  // 5. Generate API Key Action (A new read-only key; existing keys stay active until revoked)
   const generateApiKey = async () => {
-    const response = await api.regenerateKey();
+    const name = `Dashboard key ${new Date().toISOString().slice(0, 10)}`;
+    const response = await api.createApiKey(name, 'read');
     const { api_key } = response.data;
 
     if (user) {
@@ -31,6 +31,7 @@ export default function Dashboard() {
   const [error, setError] = useState(null);
   const [isModalOpen, setIsModalOpen] = useState(false);
   const [newKey, setNewKey] = useState('');
+  const [enrollment, setEnrollment] = useState(null);
   const [isGenerating, setIsGenerating] = useState(false);
   const [expandedRowId, setExpandedRowId] = useState(null);
   const [copiedId, setCopiedId] = useState(null);
@@ -177,7 +178,7 @@ export default function Dashboard() {
   };
 
   const handleGenerateKey = async () => {
-    if (user.has_api_key && !window.confirm("WARNING: Generating a new key will invalidate your old one. Continue?")) return;
+    if (!window.confirm("Create a new read-only API key?\n\nYour existing keys stay active until you revoke them (GET/DELETE /api/keys).")) return;
     setIsGenerating(true);
     try {
       const key = await generateApiKey();
@@ -187,6 +188,15 @@ export default function Dashboard() {
     } catch (err) { alert("Failed to generate key"); } finally { setIsGenerating(false); }
   };
 
+  const handleConnectAgent = async () => {
+    setIsGenerating(true);
+    try {
+      const res = await api.createEnrollmentToken();
+      setEnrollment(res.data);
+      setIsModalOpen(true);
+    } catch (err) { alert("Failed to create enrollment token"); } finally { setIsGenerating(false); }
+  };
+
   const handleDeleteInstance = async (e, id, name) => {
     e.stopPropagation(); 
     if (!window.confirm(`Delete certificate record for "${name}"?\n\nThis is a permanent action.`)) return;
@@ -240,7 +250,7 @@ export default function Dashboard() {
   return (
     <div className="min-h-screen bg-slate-50 font-sans">
       <Navbar user={user} logout={logout} onRotate={handleGenerateKey} isGenerating={isGenerating} />
-      <ApiKeyModal isOpen={isModalOpen} onClose={() => { setIsModalOpen(false); setNewKey(''); }} apiKey={newKey} />
+      <ApiKeyModal isOpen={isModalOpen} onClose={() => { setIsModalOpen(false); setNewKey(''); setEnrollment(null); }} enrollment={enrollment} apiKey={newKey} />
       
       <main className="max-w-7xl mx-auto px-6 py-8">
 
@@ -296,18 +306,16 @@ export default function Dashboard() {
                         </button>
                     )}
 
-                    {/* --- NEW: Persistent Connect Agent Button (Only visible if no key exists) --- */}
-                    {!user?.has_api_key && (
-                        <button 
-                            onClick={handleGenerateKey}
-                            disabled={isGenerating}
-                            className="flex items-center gap-2 px-3 py-2 bg-blue-600 hover:bg-blue-700 text-white rounded-lg text-sm font-medium transition-colors shadow-sm animate-in fade-in"
-                            title="Generate API Key to connect physical agents"
-                        >
-                            {isGenerating ? <RefreshCw className="w-4 h-4 animate-spin"/> : <Key className="w-4 h-4" />}
-                            <span className="hidden lg:inline">Connect Agent</span>
-                        </button>
-                    )}
+                    {/* --- Persistent Connect Agent Button (One-time enrollment token per agent) --- */}
+                    <button 
+                        onClick={handleConnectAgent}
+                        disabled={isGenerating}
+                        className="flex items-center gap-2 px-3 py-2 bg-blue-600 hover:bg-blue-700 text-white rounded-lg text-sm font-medium transition-colors shadow-sm animate-in fade-in"
+                        title="Create an enrollment token to connect a physical agent"
+                    >
+                        {isGenerating ? <RefreshCw className="w-4 h-4 animate-spin"/> : <Key className="w-4 h-4" />}
+                        <span className="hidden lg:inline">Connect Agent</span>
+                    </button>
                     {/* -------------------------------------------------------------------------- */}
 
                     <div className="w-px h-8 bg-slate-200 mx-2 hidden md:block"></div>
@@ -17,6 +17,7 @@ api.pruneMissingCertificates = () => api.delete(`/certs/missing`);
 // Agents
 api.getAgents = () => api.get('/agents');
 api.deleteAgent = (id) => api.delete(`/agents/${id}`);
+api.createEnrollmentToken = () => api.post('/agents/enrollment-tokens');
 
 // Cloud Monitor (Agentless)
 api.getCloudTargets = () => api.get('/cloud/targets');
@@ -38,6 +39,11 @@ api.login = (email, password) => api.post('/login', { email, password });
 api.signup = (email, password, orgName) => api.post('/signup', { email, password, orgName });
 api.regenerateKey = () => api.post('/key/regenerate');
 
+// API Keys (Named & scoped; several can be active at once)
+api.getApiKeys = () => api.get('/keys');
+api.createApiKey = (name, scope, expiresInDays = 0) => api.post('/keys', { name, scope, expires_in_days: expiresInDays });
+api.revokeApiKey = (id) => api.delete(`/keys/${id}`);
+
 // Auth - Account Recovery & Verification (NEW)
 api.verifyEmail = (token) => api.post('/auth/verify', { token });
 api.forgotPassword = (email) => api.post('/auth/forgot-password', { email });